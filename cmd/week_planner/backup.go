@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"week-planner/internal/backup"
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+)
+
+func runBackup(cfg config.Config, args []string) error {
+	action := "create"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "create":
+		closeDB, err := openDB(cfg)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+		backups := backup.NewManager(db.Default(), cfg.GetBackupDir(), cfg.BackupKeep)
+		created, err := backups.Create()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created backup %s (%d bytes) in %s\n", created.Name, created.Size, backups.Dir)
+	case "list":
+		backups := backup.NewManager(nil, cfg.GetBackupDir(), cfg.BackupKeep)
+		list, err := backups.List()
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("No backups.")
+			return nil
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSIZE\tCREATED")
+		for _, b := range list {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", b.Name, b.Size, b.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		tw.Flush()
+	default:
+		return fmt.Errorf("backup: unknown action %q (expected create or list)", action)
+	}
+	return nil
+}
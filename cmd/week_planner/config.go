@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"week-planner/internal/config"
+)
+
+// runConfig prints the effective configuration as a TOML config file,
+// noting where each option came from, then whether it is valid.
+func runConfig(cfg config.Config, args []string) error {
+	if len(args) > 0 && args[0] != "show" {
+		return fmt.Errorf("config: unknown action %q (want show)", args[0])
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("config: unexpected argument %q", args[1])
+	}
+
+	if file := cfg.File(); file != "" {
+		fmt.Printf("# Config file: %s\n", file)
+	} else {
+		fmt.Printf("# No config file (looked for config.toml, config.yaml in %s)\n", cfg.GetDataDir())
+	}
+	fmt.Printf("# Database: %s\n# Backups: %s\n\n", cfg.GetDBPath(), cfg.GetBackupDir())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range cfg.Settings() {
+		fmt.Fprintf(w, "%s = %s\t# %s\t%s, %s\n", s.Key, s.Value, s.Source, s.Env, s.Flag)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
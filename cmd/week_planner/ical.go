@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+	"week-planner/internal/ical"
+)
+
+func runImportICS(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("import-ics", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report what would be created")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("import-ics: exactly one file is required (use - for stdin)")
+	}
+
+	var in io.Reader = os.Stdin
+	if positional[0] != "-" {
+		file, err := os.Open(positional[0])
+		if err != nil {
+			return fmt.Errorf("import-ics: %w", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	loc, _ := cfg.GetLocation() // Validated in main.
+	items, err := ical.Decode(in, loc)
+	if err != nil {
+		return fmt.Errorf("import-ics: %w", err)
+	}
+
+	closeDB, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	report, err := ical.ImportTasks(db.Default(), items, *dryRun)
+	if err != nil {
+		return fmt.Errorf("import-ics: %w", err)
+	}
+
+	verb := "Created"
+	if report.DryRun {
+		verb = "Would create"
+	}
+	for _, entry := range report.Created {
+		fmt.Printf("%s: %s\n", verb, describeEntry(entry))
+	}
+	for _, entry := range report.Skipped {
+		fmt.Printf("Skipped: %s\n", describeEntry(entry))
+	}
+	fmt.Printf("%d to create, %d skipped\n", len(report.Created), len(report.Skipped))
+	return nil
+}
+
+func describeEntry(entry ical.ImportEntry) string {
+	due := entry.DueDate
+	if due == "" {
+		due = "inbox"
+	}
+	s := fmt.Sprintf("%s [%s]", entry.Title, due)
+	if entry.TaskID != 0 {
+		s = fmt.Sprintf("%d %s", entry.TaskID, s)
+	}
+	if entry.Note != "" {
+		s += " (" + entry.Note + ")"
+	}
+	return s
+}
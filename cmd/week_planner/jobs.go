@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"week-planner/internal/backup"
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+	"week-planner/internal/reminder"
+	"week-planner/internal/scheduler"
+)
+
+// newScheduler returns the scheduler of the server's background jobs: the
+// recurrence rollover at local midnight, the reminders and the maintenance
+// of store.
+func newScheduler(cfg config.Config, store *db.Store, backups *backup.Manager, reminders *reminder.Dispatcher) *scheduler.Scheduler {
+	s := scheduler.New()
+	s.SetClock(db.ClockFunc(store.Now))
+
+	// Also run at startup, catching up with midnights the server missed.
+	loc, _ := cfg.GetLocation() // Validated in main.
+	s.Add(scheduler.Job{
+		Name:     "recurrence-rollover",
+		Schedule: scheduler.Daily(0, 0, loc),
+		First:    store.Now(),
+		Run: withStore(store, func() error {
+			return store.CreateNextOccurrencesForUndoneRecurringTasks(db.DateIn(store.Now(), loc))
+		}),
+	})
+
+	// Also run at startup, firing reminders due while the server was down.
+	if cfg.ReminderInterval > 0 {
+		s.Add(scheduler.Job{
+			Name:     "reminders",
+			Schedule: scheduler.Every(cfg.ReminderInterval),
+			First:    store.Now(),
+			Run: func(ctx context.Context) error {
+				release := store.Acquire()
+				defer release()
+				_, err := reminders.Dispatch(ctx)
+				return err
+			},
+		})
+	} else {
+		slog.Info("Reminders disabled")
+	}
+
+	if cfg.BackupInterval > 0 {
+		s.Add(scheduler.Job{
+			Name:     "backup",
+			Schedule: scheduler.Every(cfg.BackupInterval),
+			First:    backups.FirstDue(cfg.BackupInterval),
+			Run:      backups.Scheduled,
+		})
+	} else {
+		slog.Info("Scheduled backups disabled")
+	}
+
+	maintenance := []struct {
+		name     string
+		interval time.Duration
+		run      func() error
+	}{
+		{"fts-optimize", cfg.FTSOptimizeInterval, store.OptimizeFTS},
+		{"wal-checkpoint", cfg.CheckpointInterval, store.Checkpoint},
+	}
+	for _, m := range maintenance {
+		if m.interval <= 0 {
+			slog.Info("Maintenance job disabled", "job", m.name)
+			continue
+		}
+		s.Add(scheduler.Job{
+			Name:     m.name,
+			Schedule: scheduler.Every(m.interval),
+			Run:      withStore(store, m.run),
+		})
+	}
+	return s
+}
+
+// withStore wraps fn into a job run holding store, so that the database is
+// not swapped while the job uses it.
+func withStore(store *db.Store, fn func() error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		release := store.Acquire()
+		defer release()
+		return fn()
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+
+	"week-planner/internal/config"
+)
+
+// socketMode lets the owner and group of the socket, e.g. a reverse proxy
+// added to the group, connect.
+const socketMode = 0o660
+
+// loadTLS returns the TLS configuration of the server, nil unless a
+// certificate and key are set.
+func loadTLS(cfg config.Config) (*tls.Config, error) {
+	if cfg.TLSCert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// listen opens the listener the server is configured for: the Unix socket
+// if one is set, HOST:PORT otherwise. It also returns the URL the planner is
+// reachable at, empty for a socket.
+func listen(cfg config.Config) (net.Listener, string, error) {
+	if cfg.Socket == "" {
+		addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, "", err
+		}
+		scheme := "http"
+		if cfg.TLSCert != "" {
+			scheme = "https"
+		}
+		return ln, fmt.Sprintf("%s://%s/", scheme, addr), nil
+	}
+
+	// A socket left behind by a server that did not stop cleanly would make
+	// the listen fail; anything else at the path is not ours to remove.
+	if info, err := os.Lstat(cfg.Socket); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if conn, err := net.Dial("unix", cfg.Socket); err == nil {
+			conn.Close()
+			return nil, "", fmt.Errorf("socket %s is in use by another server", cfg.Socket)
+		}
+		if err := os.Remove(cfg.Socket); err != nil {
+			return nil, "", err
+		}
+	}
+	ln, err := net.Listen("unix", cfg.Socket)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := os.Chmod(cfg.Socket, socketMode); err != nil {
+		ln.Close()
+		return nil, "", err
+	}
+	return ln, "", nil
+}
+
+// isLoopback reports whether only this machine can reach the server.
+func isLoopback(cfg config.Config) bool {
+	if cfg.Socket != "" || cfg.Host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(cfg.Host)
+	return ip != nil && ip.IsLoopback()
+}
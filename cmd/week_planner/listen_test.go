@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"week-planner/internal/config"
+)
+
+// serve answers "ok" on ln until the test ends, over TLS if tlsConfig is
+// set.
+func serve(t *testing.T, ln net.Listener, tlsConfig *tls.Config) {
+	t.Helper()
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		}),
+		TLSConfig: tlsConfig,
+	}
+	go func() {
+		if tlsConfig != nil {
+			srv.ServeTLS(ln, "", "")
+		} else {
+			srv.Serve(ln)
+		}
+	}()
+	t.Cleanup(func() { srv.Close() })
+}
+
+// getThrough requests url through the Unix socket at path and returns the
+// body of the response.
+func getThrough(t *testing.T, path, url string, tlsConfig *tls.Config) string {
+	t.Helper()
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+			TLSClientConfig: tlsConfig,
+		},
+		Timeout: 5 * time.Second,
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// writeCertificate writes a self-signed certificate for localhost and its
+// key to dir, and returns their paths and a pool trusting the certificate.
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestListenOnSocket(t *testing.T) {
+	cfg := config.Config{Socket: filepath.Join(t.TempDir(), "planner.sock")}
+	ln, url, err := listen(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if url != "" {
+		t.Errorf("URL %q, want none for a socket", url)
+	}
+	info, err := os.Lstat(cfg.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Type() != fs.ModeSocket || info.Mode().Perm() != socketMode {
+		t.Errorf("socket mode %s, want a socket with %s", info.Mode(), fs.FileMode(socketMode))
+	}
+	if !isLoopback(cfg) {
+		t.Error("a socket is not reported as reachable from this machine only")
+	}
+
+	serve(t, ln, nil)
+	if body := getThrough(t, cfg.Socket, "http://planner/", nil); body != "ok" {
+		t.Errorf("response %q, want ok", body)
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	cfg := config.Config{Socket: filepath.Join(t.TempDir(), "planner.sock")}
+	// A server that did not stop cleanly leaves its socket behind.
+	stale, err := net.Listen("unix", cfg.Socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Lstat(cfg.Socket); err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+
+	ln, _, err := listen(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	serve(t, ln, nil)
+	if body := getThrough(t, cfg.Socket, "http://planner/", nil); body != "ok" {
+		t.Errorf("response %q, want ok", body)
+	}
+}
+
+func TestListenKeepsSocketInUse(t *testing.T) {
+	cfg := config.Config{Socket: filepath.Join(t.TempDir(), "planner.sock")}
+	other, _, err := listen(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	if ln, _, err := listen(cfg); err == nil || !strings.Contains(err.Error(), "in use") {
+		if ln != nil {
+			ln.Close()
+		}
+		t.Fatalf("listening again: %v, want the socket in use", err)
+	}
+	if _, err := net.Dial("unix", cfg.Socket); err != nil {
+		t.Errorf("the other server's socket was removed: %v", err)
+	}
+}
+
+func TestListenKeepsOtherFiles(t *testing.T) {
+	cfg := config.Config{Socket: filepath.Join(t.TempDir(), "planner.sock")}
+	if err := os.WriteFile(cfg.Socket, []byte("not a socket"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ln, _, err := listen(cfg); err == nil {
+		ln.Close()
+		t.Fatal("listened on a regular file's path")
+	}
+	if content, err := os.ReadFile(cfg.Socket); err != nil || string(content) != "not a socket" {
+		t.Errorf("file at the socket path is now %q, %v", content, err)
+	}
+}
+
+// TLS works over a socket as over TCP, e.g. behind a proxy that checks the
+// certificate.
+func TestListenOnSocketWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, pool := writeCertificate(t, dir)
+	cfg := config.Config{Socket: filepath.Join(dir, "planner.sock"), TLSCert: certFile, TLSKey: keyFile}
+	tlsConfig, err := loadTLS(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, url, err := listen(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if url != "" {
+		t.Errorf("URL %q, want none for a socket", url)
+	}
+
+	serve(t, ln, tlsConfig)
+	if body := getThrough(t, cfg.Socket, "https://localhost/", &tls.Config{RootCAs: pool}); body != "ok" {
+		t.Errorf("response %q, want ok", body)
+	}
+}
+
+func TestListenURL(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCertificate(t, dir)
+	for _, tt := range []struct {
+		cfg  config.Config
+		want string
+	}{
+		{config.Config{Host: "127.0.0.1"}, "http://127.0.0.1:0/"},
+		{config.Config{Host: "127.0.0.1", TLSCert: certFile, TLSKey: keyFile}, "https://127.0.0.1:0/"},
+	} {
+		ln, url, err := listen(tt.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln.Close()
+		if url != tt.want {
+			t.Errorf("URL %q, want %q", url, tt.want)
+		}
+	}
+}
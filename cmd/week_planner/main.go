@@ -1,89 +1,115 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
 	"log"
-	"log/slog"
-	"net/http"
 	"os"
-	"os/exec"
-	"runtime"
-	"time"
+	"strings"
+	_ "time/tzdata" // Time zone names work where the system has no database.
 
 	"week-planner/internal/config"
-	"week-planner/internal/db"
 	"week-planner/internal/jsonlog"
-	"week-planner/internal/server"
 )
 
-func openBrowser(url string) {
-	var err error
-	switch runtime.GOOS {
-	case "linux":
-		err = exec.Command("xdg-open", url).Start()
-	case "windows":
-		err = exec.Command("cmd", "/c", "start", url).Start()
-	case "darwin":
-		err = exec.Command("open", url).Start()
-	default:
-		err = fmt.Errorf("unsupported platform")
-	}
-	if err != nil {
-		slog.Error("Error opening browser", "error", err)
+// command is a single week_planner subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(cfg config.Config, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "Start the web server (default)", runServe},
+		{"add", "Add a task: add \"title #tag\" [--due YYYY-MM-DD] [--list name] [--color name]", runAdd},
+		{"list", "List tasks: list [--week | --inbox | --date YYYY-MM-DD] [--list name] [--tag name]", runList},
+		{"done", "Mark tasks as completed: done <id>...", runDone},
+		{"search", "Search tasks: search <query> (tag:name for tagged tasks)", runSearch},
+		{"backup", "Back up the database now, or list backups: backup [list]", runBackup},
+		{"migrate", "Show or apply schema migrations: migrate [status | up]", runMigrate},
+		{"import-ics", "Merge an .ics file into the tasks: import-ics [--dry-run] <file|->", runImportICS},
+		{"reconcile", "Merge duplicated recurring tasks: reconcile [--dry-run]", runReconcile},
+		{"config", "Show the effective configuration: config [show]", runConfig},
+		{"help", "Show this help", runHelp},
 	}
 }
 
 func main() {
-	cfg, err := config.NewConfig()
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	jsonlog.InitLogger(cfg.GetLogLevel())
 
-	db.InitDB()
-	defer func() {
-		if sqldb, err := db.GetDB().DB(); err == nil {
-			sqldb.Close()
-		}
-	}()
-
-	shutdownChan := make(chan bool)
-
-	router := server.SetupRouter()
-
-	serverAddr := fmt.Sprintf("http://%s:%d/", cfg.Host, cfg.Port)
-	slog.Info(fmt.Sprintf("Server running on %s:%d", cfg.Host, cfg.Port))
-
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: router,
+	// config show reports invalid options itself, after showing them.
+	if len(args) == 0 || args[0] != "config" {
+		err = cfg.Validate()
 	}
-	go func() {
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
-			slog.Error("HTTP server ListenAndServe", "error", err)
-		}
-		slog.Info("HTTP server stopped")
-		shutdownChan <- true
-	}()
+	if err == nil {
+		err = run(cfg, args)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "week_planner:", err)
+		os.Exit(1)
+	}
+}
 
-	skipOpen := false
-	for _, arg := range os.Args {
-		if arg == "skip-open" {
-			skipOpen = true
-			break
+// run dispatches args to a subcommand. Without a subcommand the server is
+// started, and the legacy positional "open"/"skip-open" arguments still work.
+func run(cfg config.Config, args []string) error {
+	if len(args) == 0 || args[0] == "open" || args[0] == "skip-open" || strings.HasPrefix(args[0], "-") {
+		return runServe(cfg, args)
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(cfg, args[1:])
 		}
 	}
+	printUsage()
+	return fmt.Errorf("unknown command %q", args[0])
+}
 
-	if !skipOpen && len(os.Args) >= 2 && os.Args[1] == "open" {
-		go openBrowser(serverAddr)
+func runHelp(cfg config.Config, args []string) error {
+	printUsage()
+	return nil
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: week_planner [options] <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Options override the config file and the environment; each one is also")
+	fmt.Fprintln(os.Stderr, "a flag, e.g. --port 5055 for PORT. `config show` lists them all.")
+	fmt.Fprintln(os.Stderr, "  --config <file>  Config file (CONFIG_FILE), default config.toml or")
+	fmt.Fprintln(os.Stderr, "                   config.yaml in the data directory")
+}
 
-	<-shutdownChan
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		slog.Error("Server Shutdown Failed", "error", err)
+// parseArgs parses flags that may appear before, between or after positional
+// arguments (flag.Parse stops at the first positional one) and returns the
+// positional arguments in order.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		// Everything after a literal "--" is positional.
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
 	}
 }
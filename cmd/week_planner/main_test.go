@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"slices"
+	"testing"
+
+	"week-planner/internal/config"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		due        string
+		repeat     string
+	}{
+		{name: "no arguments"},
+		{
+			name:       "flags first",
+			args:       []string{"--due", "today", "Buy", "milk"},
+			positional: []string{"Buy", "milk"},
+			due:        "today",
+		},
+		{
+			name:       "flags between and after",
+			args:       []string{"Buy", "--due=tomorrow", "milk", "--repeat", "weekly"},
+			positional: []string{"Buy", "milk"},
+			due:        "tomorrow",
+			repeat:     "weekly",
+		},
+		{
+			name:       "after --",
+			args:       []string{"--due", "today", "--", "--repeat", "weekly", "-x"},
+			positional: []string{"--repeat", "weekly", "-x"},
+			due:        "today",
+		},
+		{
+			name:       "-- after positional arguments",
+			args:       []string{"Call", "--", "--due", "today"},
+			positional: []string{"Call", "--due", "today"},
+		},
+		{
+			name:       "a lone dash",
+			args:       []string{"-", "--due", "today"},
+			positional: []string{"-"},
+			due:        "today",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("add", flag.ContinueOnError)
+			due := fs.String("due", "", "")
+			repeat := fs.String("repeat", "", "")
+			positional, err := parseArgs(fs, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(positional, tt.positional) || *due != tt.due || *repeat != tt.repeat {
+				t.Errorf("parseArgs(%q) = %q with --due %q --repeat %q, want %q with %q %q",
+					tt.args, positional, *due, *repeat, tt.positional, tt.due, tt.repeat)
+			}
+		})
+	}
+}
+
+func TestParseArgsRejectsUnknownFlags(t *testing.T) {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Bool("week", false, "")
+	if _, err := parseArgs(fs, []string{"--week", "--month"}); err == nil {
+		t.Error("parseArgs accepted an unknown flag")
+	}
+}
+
+// The config flags are taken out of the command line wherever they are, and
+// the command parses the rest.
+func TestConfigFlagsAmongCommandArguments(t *testing.T) {
+	cfg, args, err := config.Load([]string{
+		"add", "Buy", "--port", "6000", "--due", "today", "milk", "--tz=Europe/Berlin", "--", "--port",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 6000 || cfg.TimeZone != "Europe/Berlin" {
+		t.Errorf("port %d, time zone %q, want the flags", cfg.Port, cfg.TimeZone)
+	}
+	if want := []string{"add", "Buy", "--due", "today", "milk", "--", "--port"}; !slices.Equal(args, want) {
+		t.Fatalf("command line %q, want %q", args, want)
+	}
+
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	due := fs.String("due", "", "")
+	positional, err := parseArgs(fs, args[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Buy", "milk", "--port"}; !slices.Equal(positional, want) || *due != "today" {
+		t.Errorf("arguments %q with --due %q, want %q with today", positional, *due, want)
+	}
+}
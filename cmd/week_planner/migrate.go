@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+)
+
+// runMigrate shows or applies schema migrations. The server applies pending
+// migrations on startup too; this allows doing it (or checking) beforehand.
+func runMigrate(cfg config.Config, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("migrate: unexpected argument %q", args[1])
+	}
+
+	switch action {
+	case "status", "up":
+	default:
+		return fmt.Errorf("migrate: unknown action %q (want status or up)", action)
+	}
+
+	gdb, err := db.Connect(cfg.GetDBPath())
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := gdb.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	if action == "up" {
+		applied, err := db.Migrate(gdb)
+		for _, m := range applied {
+			fmt.Println("Applied", m)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date.")
+		}
+		return nil
+	}
+
+	statuses, err := db.MigrationStatuses(gdb)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.Applied {
+			applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+)
+
+// runReconcile merges the duplicated recurring tasks older versions created.
+func runReconcile(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report what would be merged")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("reconcile: unexpected argument %q", positional[0])
+	}
+
+	closeDB, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	report, err := db.Default().Reconcile(*dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Merged"
+	if report.DryRun {
+		verb = "Would merge"
+	}
+	for _, m := range report.Merged {
+		fmt.Printf("%s %d series into series %d: %s (%s)\n", verb, len(m.MergedIDs), m.SeriesID, m.Title, m.Rule)
+	}
+	verb = "Removed"
+	if report.DryRun {
+		verb = "Would remove"
+	}
+	for _, d := range report.Duplicates {
+		fmt.Printf("%s duplicate %d of task %d: %s [%s]\n", verb, d.TaskID, d.KeptID, d.Title, d.OccurrenceDate)
+	}
+	if len(report.Merged) == 0 {
+		fmt.Println("No duplicated recurring tasks found.")
+	}
+	return nil
+}
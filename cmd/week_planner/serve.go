@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"week-planner/internal/auth"
+	"week-planner/internal/backup"
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+	"week-planner/internal/events"
+	"week-planner/internal/reminder"
+	"week-planner/internal/server"
+	"week-planner/internal/webhook"
+)
+
+// shutdownTimeout bounds how long requests in flight may take to finish
+// when the server is stopped.
+const shutdownTimeout = 5 * time.Second
+
+func openBrowser(url string) {
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		err = exec.Command("xdg-open", url).Start()
+	case "windows":
+		err = exec.Command("cmd", "/c", "start", url).Start()
+	case "darwin":
+		err = exec.Command("open", url).Start()
+	default:
+		err = fmt.Errorf("unsupported platform")
+	}
+	if err != nil {
+		slog.Error("Error opening browser", "error", err)
+	}
+}
+
+// runServe starts the HTTP server and blocks until it stops.
+func runServe(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	open := fs.Bool("open", false, "open the planner in the default browser")
+	skipOpen := fs.Bool("skip-open", false, "never open the browser (overrides --open)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	// Positional forms kept from before subcommands existed.
+	for _, arg := range positional {
+		switch arg {
+		case "open":
+			*open = true
+		case "skip-open":
+			*skipOpen = true
+		default:
+			return fmt.Errorf("serve: unexpected argument %q", arg)
+		}
+	}
+
+	authn, err := auth.New(auth.Config{Token: cfg.AuthToken, User: cfg.AuthUser, Password: cfg.AuthPassword, SessionTTL: cfg.SessionTTL})
+	if err != nil {
+		return err
+	}
+	if !authn.Enabled() && !isLoopback(cfg) {
+		slog.Warn("The API has no authentication; set AUTH_TOKEN or AUTH_USER and AUTH_PASSWORD when others can reach the server", "host", cfg.Host)
+	}
+
+	tlsConfig, err := loadTLS(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := db.InitDB(cfg.GetDBPath()); err != nil {
+		return err
+	}
+	defer db.Default().Close()
+
+	loc, _ := cfg.GetLocation() // Validated in main.
+	backups := backup.NewManager(db.Default(), cfg.GetBackupDir(), cfg.BackupKeep)
+	bus := events.NewBus()
+	reminders := reminder.New(db.Default(), loc)
+	reminders.Subscribe(func(ctx context.Context, r db.ReminderDelivery) {
+		bus.Publish(events.ReminderFired, "", r)
+	})
+	jobs := newScheduler(cfg, db.Default(), backups, reminders)
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobsDone := make(chan struct{})
+	go func() {
+		jobs.Run(jobsCtx)
+		close(jobsDone)
+	}()
+	webhooksDone := make(chan struct{})
+	go func() {
+		webhook.New(db.Default(), db.Default().Acquire).Run(jobsCtx, bus)
+		close(webhooksDone)
+	}()
+	// Stop the jobs and webhooks, waiting for running ones, before the
+	// database closes.
+	defer func() {
+		stopJobs()
+		<-jobsDone
+		<-webhooksDone
+	}()
+
+	router := server.SetupRouter(db.Default(), backups, jobs, loc, bus, authn, cfg.CORSOrigins)
+
+	ln, serverAddr, err := listen(cfg)
+	if err != nil {
+		return err
+	}
+	slog.Info("Server running", "address", ln.Addr().String(), "tls", tlsConfig != nil)
+
+	// Requests get a context that ends when shutting down, so that the event
+	// streams, which would otherwise hold up the shutdown until it times
+	// out, end. Other requests do not watch it and are drained.
+	requestsCtx, endRequests := context.WithCancel(context.Background())
+	defer endRequests()
+	srv := &http.Server{
+		Handler:     router,
+		TLSConfig:   tlsConfig,
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
+	}
+	srv.RegisterOnShutdown(endRequests)
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			serveErr <- srv.ServeTLS(ln, "", "") // The certificate is in TLSConfig.
+		} else {
+			serveErr <- srv.Serve(ln)
+		}
+	}()
+
+	if *open && !*skipOpen && serverAddr != "" {
+		go openBrowser(serverAddr)
+	}
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	select {
+	case err := <-serveErr:
+		if err != http.ErrServerClosed {
+			return fmt.Errorf("HTTP server: %w", err)
+		}
+		return nil
+	case <-signals.Done():
+	}
+	// A second signal kills the process.
+	stopSignals()
+	slog.Info("Shutting down")
+
+	// Drain the requests in flight, then stop the jobs and webhooks, and
+	// checkpoint and close the database (deferred above).
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("Server Shutdown Failed", "error", err)
+	}
+	slog.Info("HTTP server stopped")
+	return nil
+}
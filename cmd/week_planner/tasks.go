@@ -0,0 +1,301 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+)
+
+// openDB opens the configured database for a one-shot command.
+func openDB(cfg config.Config) (closeDB func(), err error) {
+	if err := db.InitDB(cfg.GetDBPath()); err != nil {
+		return nil, err
+	}
+	return func() { db.Default().Close() }, nil
+}
+
+// today returns the current date in the planner's time zone, in the form
+// dates are stored in.
+func today(cfg config.Config) time.Time {
+	loc, _ := cfg.GetLocation() // Validated in main.
+	return db.Today(loc)
+}
+
+// parseDueDate accepts YYYY-MM-DD as well as "today" and "tomorrow".
+func parseDueDate(value string, today time.Time) (time.Time, error) {
+	switch strings.ToLower(value) {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	date, err := time.Parse(config.DateFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD, today or tomorrow)", value)
+	}
+	return date, nil
+}
+
+// findList returns the ID of the list named by value: its ID or its title,
+// ignoring case.
+func findList(value string) (int, error) {
+	lists, err := db.Default().GetLists()
+	if err != nil {
+		return 0, err
+	}
+	id, _ := strconv.Atoi(value)
+	for _, list := range lists {
+		if list.ID == id || strings.EqualFold(list.Title, strings.TrimSpace(value)) {
+			return list.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown list %q", value)
+}
+
+func runAdd(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	due := fs.String("due", "", "due date (YYYY-MM-DD, today, tomorrow); omit for the inbox")
+	color := fs.String("color", "", "task color")
+	description := fs.String("description", "", "task description (Markdown)")
+	repeat := fs.String("repeat", "", "recurrence rule (daily, weekly, monthly, yearly or an RRULE such as FREQ=WEEKLY;BYDAY=MO,WE,FR)")
+	every := fs.Int("every", 1, "recurrence interval of daily/weekly/monthly/yearly rules")
+	at := fs.String("time", "", "time of day (HH:MM) on the due date")
+	duration := fs.Duration("duration", 0, "duration from the time of day, e.g. 45m or 1h30m")
+	remind := fs.String("remind", "", "reminder: a duration relative to the due date and time such as -PT15M, or a time such as 2026-10-20T09:00")
+	list := fs.String("list", "", "list (ID or title) to add the task to; omit for the inbox")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	// "#word"s of the title are tags, as in the web app.
+	title, tags := db.ParseTitleTags(strings.Join(positional, " "))
+	if title == "" {
+		return errors.New("add: task title is required")
+	}
+	if *duration < 0 {
+		return errors.New("add: --duration must not be negative")
+	}
+	if _, err := db.NormalizeRecurrenceRule(*repeat); err != nil {
+		return fmt.Errorf("add: invalid recurrence rule %q: %w", *repeat, err)
+	}
+
+	task := db.Task{
+		Title:              title,
+		Color:              *color,
+		Description:        *description,
+		RecurrenceRule:     *repeat,
+		RecurrenceInterval: *every,
+		DueTime:            *at,
+		DurationMinutes:    int(duration.Minutes()),
+		RemindAt:           *remind,
+		Tags:               tags,
+	}
+	if *due != "" {
+		date, err := parseDueDate(*due, today(cfg))
+		if err != nil {
+			return fmt.Errorf("add: %w", err)
+		}
+		task.DueDate = db.NullTime{Time: date, Valid: true}
+	}
+
+	closeDB, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if *list != "" {
+		id, err := findList(*list)
+		if err != nil {
+			return fmt.Errorf("add: %w", err)
+		}
+		task.ListID = &id
+	}
+	created, err := db.Default().CreateTask(task)
+	if err != nil {
+		return fmt.Errorf("add: %w", err)
+	}
+	printTasks(db.Tasks{created})
+	return nil
+}
+
+func runList(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	week := fs.Bool("week", false, "tasks of the current week (Monday to Sunday)")
+	inbox := fs.Bool("inbox", false, "tasks without a due date")
+	date := fs.String("date", "", "tasks due on a date (YYYY-MM-DD, today, tomorrow)")
+	tag := fs.String("tag", "", "tasks having a tag, or all of several comma-separated ones")
+	list := fs.String("list", "", "tasks of a list (ID or title); with --inbox, its undated tasks")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("list: unexpected argument %q", positional[0])
+	}
+
+	var filter db.TaskFilter
+	switch {
+	case *inbox:
+		filter.Date = "inbox"
+	case *week:
+		day := today(cfg)
+		monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		filter.StartDate = monday.Format(config.DateFormat)
+		filter.EndDate = monday.AddDate(0, 0, 6).Format(config.DateFormat)
+	case *date != "":
+		parsed, err := parseDueDate(*date, today(cfg))
+		if err != nil {
+			return fmt.Errorf("list: %w", err)
+		}
+		filter.Date = parsed.Format(config.DateFormat)
+	}
+
+	closeDB, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if *tag != "" {
+		filter.Tags = strings.Split(*tag, ",")
+	}
+	if *list != "" {
+		if filter.ListID, err = findList(*list); err != nil {
+			return fmt.Errorf("list: %w", err)
+		}
+	}
+	tasks, err := db.Default().GetTasks(filter)
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	printTasks(tasks)
+	return nil
+}
+
+func runDone(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("done: at least one task ID is required")
+	}
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("done: invalid task ID %q", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	closeDB, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	for _, id := range ids {
+		task, err := db.Default().GetTask(id)
+		if err != nil {
+			return fmt.Errorf("done: task %d: %w", id, err)
+		}
+		if task.Completed == 1 {
+			fmt.Printf("Task %d is already completed\n", id)
+			continue
+		}
+		// Completing an occurrence of a recurring task creates the next one.
+		if err := db.Default().UpdateTask(id, map[string]interface{}{"completed": true}, ""); err != nil {
+			return fmt.Errorf("done: task %d: %w", id, err)
+		}
+		fmt.Printf("Completed task %d: %s\n", id, task.Title)
+
+		if task.SeriesID != nil {
+			series, err := db.Default().GetSeries(*task.SeriesID)
+			if err != nil {
+				return fmt.Errorf("done: task %d: %w", id, err)
+			}
+			for _, next := range series.Occurrences {
+				if next.Completed == 0 && next.OccurrenceDate.Time.After(task.OccurrenceDate.Time) {
+					fmt.Printf("Next occurrence %d due %s\n", next.ID, next.DueDate.Time.Format(config.DateFormat))
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func runSearch(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "maximum number of results")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	query := strings.TrimSpace(strings.Join(positional, " "))
+	if query == "" {
+		return errors.New("search: query is required")
+	}
+	if *limit <= 0 {
+		return errors.New("search: --limit must be > 0")
+	}
+
+	closeDB, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	tasks, err := db.Default().SearchTasks(query, today(cfg), *limit, 0)
+	if err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+	printTasks(tasks)
+	return nil
+}
+
+// printTasks writes tasks as an aligned table to stdout.
+func printTasks(tasks db.Tasks) {
+	if len(tasks) == 0 {
+		fmt.Println("No tasks.")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDONE\tDUE\tTITLE\tCOLOR\tREPEAT")
+	for _, task := range tasks {
+		done := "[ ]"
+		if task.Completed == 1 {
+			done = "[x]"
+		}
+		due := "inbox"
+		if task.DueDate.Valid {
+			due = task.DueDate.Time.Format(config.DateFormat)
+			if task.DueTime != "" {
+				due += " " + task.DueTime
+			}
+		}
+		repeat := ""
+		if task.RecurrenceRule != "" {
+			repeat = task.RecurrenceRule
+			if task.RecurrenceInterval > 1 {
+				repeat = fmt.Sprintf("%s/%d", task.RecurrenceRule, task.RecurrenceInterval)
+			}
+		}
+		title := task.Title
+		if task.SubtasksTotal > 0 {
+			title = fmt.Sprintf("%s [%d/%d]", title, task.SubtasksCompleted, task.SubtasksTotal)
+		}
+		for _, tag := range task.Tags {
+			title += " #" + tag
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", task.ID, done, due, title, task.Color, repeat)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,131 @@
+//go:build sqlite_fts5
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"week-planner/internal/config"
+)
+
+// runCommand runs the command line args against the database at dbPath and
+// returns what it printed.
+func runCommand(t *testing.T, dbPath string, args ...string) (string, error) {
+	t.Helper()
+	cfg, args, err := config.Load(append([]string{"--db-path", dbPath, "--tz", "UTC"}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	err = run(cfg, args)
+	os.Stdout = stdout
+	printed, readErr := os.ReadFile(out.Name())
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(printed), err
+}
+
+// collapse returns the lines of out with runs of spaces, e.g. aligning a
+// table, collapsed into one.
+func collapse(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestTaskCommands(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tasks.db")
+	steps := []struct {
+		args []string
+		want []string // Lines printed, with runs of spaces collapsed.
+		err  string
+	}{
+		{
+			args: []string{"add", "Buy", "milk", "#errand", "--due", "2026-10-19"},
+			want: []string{"ID DONE DUE TITLE COLOR REPEAT", "1 [ ] 2026-10-19 Buy milk #errand"},
+		},
+		{
+			args: []string{"add", "--repeat", "weekly", "Water", "plants", "--due=2026-10-19", "--time", "08:00"},
+			want: []string{"ID DONE DUE TITLE COLOR REPEAT", "2 [ ] 2026-10-19 08:00 Water plants weekly"},
+		},
+		{
+			args: []string{"add", "Read", "--list", "📦 Inbox", "--", "--due"},
+			want: []string{"ID DONE DUE TITLE COLOR REPEAT", "3 [ ] inbox Read --due"},
+		},
+		{args: []string{"add", "--due", "today"}, err: "add: task title is required"},
+		{args: []string{"add", "Later", "--due", "someday"}, err: `add: invalid date "someday"`},
+		{args: []string{"add", "Later", "--list", "Work"}, err: `add: unknown list "Work"`},
+
+		{
+			args: []string{"list", "--date", "2026-10-19"},
+			want: []string{
+				"ID DONE DUE TITLE COLOR REPEAT",
+				"1 [ ] 2026-10-19 Buy milk #errand",
+				"2 [ ] 2026-10-19 08:00 Water plants weekly",
+			},
+		},
+		{
+			args: []string{"list", "--inbox"},
+			want: []string{"ID DONE DUE TITLE COLOR REPEAT", "3 [ ] inbox Read --due"},
+		},
+		{
+			args: []string{"list", "--tag", "errand"},
+			want: []string{"ID DONE DUE TITLE COLOR REPEAT", "1 [ ] 2026-10-19 Buy milk #errand"},
+		},
+		{args: []string{"list", "--date", "2026-10-20"}, want: []string{"No tasks."}},
+		{args: []string{"list", "inbox"}, err: `list: unexpected argument "inbox"`},
+
+		{
+			args: []string{"done", "1", "2"},
+			want: []string{
+				"Completed task 1: Buy milk",
+				"Completed task 2: Water plants",
+				"Next occurrence 4 due 2026-10-26",
+			},
+		},
+		{args: []string{"done", "1"}, want: []string{"Task 1 is already completed"}},
+		{args: []string{"done"}, err: "done: at least one task ID is required"},
+		{args: []string{"done", "one"}, err: `done: invalid task ID "one"`},
+		{args: []string{"done", "99"}, err: "done: task 99:"},
+		{
+			args: []string{"list", "--date", "2026-10-26"},
+			want: []string{"ID DONE DUE TITLE COLOR REPEAT", "4 [ ] 2026-10-26 08:00 Water plants weekly"},
+		},
+		{
+			args: []string{"list", "--date", "2026-10-19"},
+			want: []string{
+				"ID DONE DUE TITLE COLOR REPEAT",
+				"1 [x] 2026-10-19 Buy milk #errand",
+				"2 [x] 2026-10-19 08:00 Water plants weekly",
+			},
+		},
+	}
+	for _, step := range steps {
+		out, err := runCommand(t, dbPath, step.args...)
+		if step.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), step.err) {
+				t.Errorf("%q: error %v, want %s", step.args, err, step.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", step.args, err)
+			continue
+		}
+		if collapse(out) != strings.Join(step.want, "\n") {
+			t.Errorf("%q printed\n%s\nwant\n%s", step.args, out, strings.Join(step.want, "\n"))
+		}
+	}
+}
@@ -8,16 +8,94 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+	"week-planner/internal/backup"
 	"week-planner/internal/config"
 	"week-planner/internal/db"
+	"week-planner/internal/events"
+	"week-planner/internal/ical"
 	"week-planner/internal/jsonlog"
+	"week-planner/internal/scheduler"
 
 	"github.com/gorilla/mux"
 )
 
+// Handler serves the planner API on top of the stores it is given.
+type Handler struct {
+	Tasks    db.TaskStore
+	Settings db.SettingsStore
+	// DB is the SQLite database behind Tasks and Settings, needed by the
+	// endpoints working on the whole file (export, import). Those answer 501
+	// when it is nil, e.g. on a db.MemoryStore.
+	DB      *db.Store
+	Backups *backup.Manager      // Nil disables the backup endpoints the same way.
+	Jobs    *scheduler.Scheduler // Background jobs reported by /api/jobs, may be nil.
+	// Reminders are the reminders fired by the server's dispatcher; nil
+	// answers 501 on /api/reminders.
+	Reminders db.ReminderStore
+	Webhooks  db.WebhookStore // Nil answers 501 on /api/webhooks.
+	Tags      db.TagStore     // Nil answers 501 on /api/tags.
+	Lists     db.ListStore    // Nil answers 501 on /api/lists.
+	// Location is the planner's time zone, deciding what "today" is; nil
+	// is the local time zone. Requests may ask for another one.
+	Location *time.Location
+	Clock    db.Clock // Nil is the system clock.
+	// Events receives every change made through the API, streamed to
+	// clients by /api/events; nil publishes nothing and answers 501 there.
+	Events *events.Bus
+}
+
+// location returns the time zone of a request: an IANA name such as
+// "Europe/Berlin" in the "tz" query parameter or the X-Timezone header, or
+// else the planner's.
+func (h *Handler) location(r *http.Request) (*time.Location, error) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		name = r.Header.Get("X-Timezone")
+	}
+	if name == "" {
+		if h.Location == nil {
+			return time.Local, nil
+		}
+		return h.Location, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, db.NewAPIError(http.StatusBadRequest, fmt.Sprintf("Invalid time zone %q", name))
+	}
+	return loc, nil
+}
+
+// today returns the current date in the time zone of a request.
+func (h *Handler) today(r *http.Request) (time.Time, error) {
+	loc, err := h.location(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return db.TodayOn(h.Clock, loc), nil
+}
+
+// requireDB reports whether the SQLite database is available, answering 501 if not.
+func (h *Handler) requireDB(w http.ResponseWriter, r *http.Request) bool {
+	if h.DB == nil {
+		handleError(w, r, db.NewAPIError(http.StatusNotImplemented, "Not supported by this store"))
+		return false
+	}
+	return true
+}
+
+// requireBackups reports whether backups are configured, answering 501 if not.
+func (h *Handler) requireBackups(w http.ResponseWriter, r *http.Request) bool {
+	if h.Backups == nil {
+		handleError(w, r, db.NewAPIError(http.StatusNotImplemented, "Backups are not configured"))
+		return false
+	}
+	return true
+}
+
 // handleError logs errors and sends appropriate HTTP responses.
 func handleError(w http.ResponseWriter, r *http.Request, err error) {
 	jsonlog.LogCtx(r.Context(), slog.LevelError, err.Error(), "error", err)
@@ -38,14 +116,77 @@ func handleError(w http.ResponseWriter, r *http.Request, err error) {
 	})
 }
 
+// publish sends an event about a change made by request r. Clients tag their
+// requests with an X-Client-ID header to recognize their own changes.
+func (h *Handler) publish(r *http.Request, typ string, data map[string]interface{}) {
+	if h.Events == nil {
+		return
+	}
+	h.Events.Publish(typ, r.Header.Get("X-Client-ID"), data)
+}
+
+// snapshot returns a task as JSON before a change, for the event about it.
+// It returns nil without events, or when the task cannot be read.
+func (h *Handler) snapshot(id int) map[string]interface{} {
+	if h.Events == nil {
+		return nil
+	}
+	task, err := h.Tasks.GetTask(id)
+	if err != nil {
+		return nil
+	}
+	return taskToJSON(task)
+}
+
+// publishUpdate publishes a task.updated event, with data, for the task
+// previous is a snapshot of. With completedOnly, it publishes nothing unless
+// the completion of the task changed, e.g. for the parent of a subtask.
+func (h *Handler) publishUpdate(r *http.Request, previous map[string]interface{}, data map[string]interface{}, completedOnly bool) {
+	if previous == nil {
+		return
+	}
+	task, err := h.Tasks.GetTask(previous["id"].(int))
+	if err != nil {
+		return
+	}
+	current := taskToJSON(task)
+	if completedOnly && current["completed"] == previous["completed"] {
+		return
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["task"], data["previous"] = current, previous
+	h.publish(r, events.TaskUpdated, data)
+}
+
+// OccurrenceCreated publishes the occurrences created by recurrence; the
+// server registers it with the store's OnOccurrenceCreated. Rollovers run by
+// the scheduler have no request, so these events have no source.
+func (h *Handler) OccurrenceCreated(occurrence db.Task) {
+	if h.Events == nil {
+		return
+	}
+	h.Events.Publish(events.TaskCreated, "", map[string]interface{}{"task": taskToJSON(occurrence), "generated": true})
+}
+
 // taskToJSON converts a db.Task struct to a JSON-serializable map.
 func taskToJSON(task db.Task) map[string]interface{} {
 	dueDate := ""
 	if task.DueDate.Valid {
 		dueDate = task.DueDate.Time.Format(config.DateFormat)
 	}
+	occurrenceDate := ""
+	if task.OccurrenceDate.Valid {
+		occurrenceDate = task.OccurrenceDate.Time.Format(config.DateFormat)
+	}
+	tags := []string(task.Tags)
+	if tags == nil {
+		tags = []string{}
+	}
 	return map[string]interface{}{
 		"id":                  task.ID,
+		"uid":                 task.UID,
 		"title":               task.Title,
 		"due_date":            dueDate,
 		"completed":           task.Completed,
@@ -54,6 +195,17 @@ func taskToJSON(task db.Task) map[string]interface{} {
 		"description":         task.Description,
 		"recurrence_rule":     task.RecurrenceRule,
 		"recurrence_interval": task.RecurrenceInterval, // Include interval.
+		"parent_id":           task.ParentID,           // Null for top-level tasks.
+		"series_id":           task.SeriesID,           // Null unless the task is an occurrence of a recurring task.
+		"occurrence_date":     occurrenceDate,
+		"spawned_from":        task.SpawnedFrom, // The occurrence this one was created from, if any.
+		"due_time":            task.DueTime,     // "15:04" or empty: tasks are dated, a time is optional.
+		"duration_minutes":    task.DurationMinutes,
+		"remind_at":           task.RemindAt, // Absolute time or duration relative to the start, see db.ParseReminder.
+		"tags":                tags,
+		"list_id":             task.ListID, // Null for subtasks, which are in the list of their task.
+		"subtasks_total":      task.SubtasksTotal,
+		"subtasks_completed":  task.SubtasksCompleted,
 	}
 }
 
@@ -67,12 +219,34 @@ func tasksToJSON(tasks db.Tasks) []map[string]interface{} {
 }
 
 // GetTasksHandler handles requests to retrieve tasks based on query parameters.
-func GetTasksHandler(w http.ResponseWriter, r *http.Request) {
-	tasks, err := db.GetTasks(
-		r.URL.Query().Get("date"),
-		r.URL.Query().Get("start_date"),
-		r.URL.Query().Get("end_date"),
-	)
+// "list" narrows the tasks down to those of a list ("date=inbox" alone means
+// the default list), and each "tag" parameter to those having that tag.
+func (h *Handler) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
+	filter := db.TaskFilter{
+		StartDate: r.URL.Query().Get("start_date"),
+		EndDate:   r.URL.Query().Get("end_date"),
+		Tags:      r.URL.Query()["tag"],
+	}
+	if value := r.URL.Query().Get("list"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			handleError(w, r, db.NewAPIError(400, "Invalid 'list' parameter (must be a list ID)"))
+			return
+		}
+		filter.ListID = id
+	}
+	date := r.URL.Query().Get("date")
+	if date == "today" {
+		// Resolved in the time zone of the request, not the server's.
+		today, err := h.today(r)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		date = today.Format(config.DateFormat)
+	}
+	filter.Date = date
+	tasks, err := h.Tasks.GetTasks(filter)
 	if err != nil {
 		handleError(w, r, err)
 		return
@@ -81,9 +255,9 @@ func GetTasksHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(tasksToJSON(tasks))
 }
 
-// GetInboxTitleHandler retrieves the current inbox title setting.
-func GetInboxTitleHandler(w http.ResponseWriter, r *http.Request) {
-	title, err := db.GetInboxTitle()
+// GetInboxTitleHandler retrieves the inbox title, the title of the default list.
+func (h *Handler) GetInboxTitleHandler(w http.ResponseWriter, r *http.Request) {
+	title, err := h.Settings.GetInboxTitle()
 	if err != nil {
 		handleError(w, r, err)
 		return
@@ -92,8 +266,8 @@ func GetInboxTitleHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"inbox_title": title})
 }
 
-// UpdateInboxTitleHandler updates the inbox title setting.
-func UpdateInboxTitleHandler(w http.ResponseWriter, r *http.Request) {
+// UpdateInboxTitleHandler renames the inbox, the default list.
+func (h *Handler) UpdateInboxTitleHandler(w http.ResponseWriter, r *http.Request) {
 	var data map[string]string
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		handleError(w, r, db.NewAPIError(400, "Invalid request body format"))
@@ -107,24 +281,31 @@ func UpdateInboxTitleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := db.UpdateInboxTitle(newTitle); err != nil {
+	if err := h.Settings.UpdateInboxTitle(newTitle); err != nil {
 		handleError(w, r, err)
 		return
 	}
+	h.publish(r, events.SettingsChanged, map[string]interface{}{"inbox_title": newTitle})
+	h.publishList(r)
 	w.WriteHeader(http.StatusOK)
 }
 
 // CreateTaskHandler handles requests to create a new task.
-func CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	// Define expected input structure.
 	var taskInput struct {
-		Title              string `json:"title"`
-		DueDate            string `json:"due_date"` // Expect date as string YYYY-MM-DD.
-		Order              int    `json:"order"`
-		Color              string `json:"color"`
-		Description        string `json:"description"`
-		RecurrenceRule     string `json:"recurrence_rule"`
-		RecurrenceInterval int    `json:"recurrence_interval"`
+		Title              string   `json:"title"`
+		DueDate            string   `json:"due_date"` // Expect date as string YYYY-MM-DD.
+		Order              int      `json:"order"`
+		Color              string   `json:"color"`
+		Description        string   `json:"description"`
+		RecurrenceRule     string   `json:"recurrence_rule"`
+		RecurrenceInterval int      `json:"recurrence_interval"`
+		DueTime            string   `json:"due_time"` // Optional HH:MM.
+		DurationMinutes    int      `json:"duration_minutes"`
+		RemindAt           string   `json:"remind_at"` // Optional, e.g. "-PT15M" or "2026-10-20T09:00".
+		Tags               []string `json:"tags"`      // Besides the #tags of the title.
+		ListID             *int     `json:"list_id"`   // Omitted or null for the default list.
 	}
 
 	slog.DebugContext(r.Context(), "Received request to create task")
@@ -165,19 +346,34 @@ func CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		recurrenceInterval = 1 // Reset to default if rule is cleared.
 	}
 
+	// "#word"s of the title are tags.
+	title, titleTags := db.ParseTitleTags(taskInput.Title)
+
 	// Prepare Task struct for database insertion.
 	task := db.Task{
-		Title:              taskInput.Title,
+		Title:              title,
 		DueDate:            dueDateNullTime,
 		TaskOrder:          taskInput.Order,
 		Color:              taskInput.Color,
 		Description:        taskInput.Description,
 		RecurrenceRule:     taskInput.RecurrenceRule,
 		RecurrenceInterval: recurrenceInterval,
+		DueTime:            taskInput.DueTime,
+		DurationMinutes:    taskInput.DurationMinutes,
+		RemindAt:           taskInput.RemindAt,
+		Tags:               append(titleTags, taskInput.Tags...),
+		ListID:             taskInput.ListID,
 		// Completed defaults to 0 in the database.
 	}
 
-	createdTask, err := db.CreateTask(task)
+	// The time and reminder are validated (and normalized) here already, so
+	// that a bad value is reported before anything is created.
+	if err := task.Validate(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	createdTask, err := h.Tasks.CreateTask(task)
 	if err != nil {
 		slog.ErrorContext(r.Context(), "Error creating task in database", "error", err)
 		handleError(w, r, err) // Let handleError decide the response code.
@@ -185,6 +381,7 @@ func CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	slog.DebugContext(r.Context(), "Successfully created task", "task", createdTask)
+	h.publish(r, events.TaskCreated, map[string]interface{}{"task": taskToJSON(createdTask)})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -192,7 +389,7 @@ func CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetTaskHandler retrieves a specific task by its ID.
-func GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr, ok := vars["id"]
 	if !ok {
@@ -205,7 +402,7 @@ func GetTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := db.GetTask(id)
+	task, err := h.Tasks.GetTask(id)
 	if err != nil {
 		handleError(w, r, err) // Handles 404 Not Found from db layer.
 		return
@@ -215,7 +412,7 @@ func GetTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // UpdateTaskHandler handles partial updates to an existing task.
-func UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr, ok := vars["id"]
 	if !ok {
@@ -235,102 +432,32 @@ func UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	slog.DebugContext(r.Context(), "Received updates for task", "task_id", id, "updates", updates)
+	// Occurrences of a recurring task may be updated alone or together with
+	// the following ones or the whole series.
+	scope, err := db.ParseScope(r.URL.Query().Get("scope"))
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
 
-	// Validate fields before attempting update. (Validation moved to db.UpdateTask)
+	slog.DebugContext(r.Context(), "Received updates for task", "task_id", id, "updates", updates, "scope", scope)
 
 	// Perform the update via the database layer (which includes validation).
-	if err := db.UpdateTask(id, updates); err != nil {
+	// Completing an occurrence of a recurring task creates the next one.
+	previous := h.snapshot(id)
+	if err := h.Tasks.UpdateTask(id, updates, scope); err != nil {
 		handleError(w, r, err) // Handles validation errors and not found.
 		return
 	}
-
-	// --- Recurrence Handling on Completion ---
-	// Check if the 'completed' field was part of the update and set to true/1.
-	if completedVal, ok := updates["completed"]; ok {
-		isCompleted := false
-		if completedBool, isBool := completedVal.(bool); isBool && completedBool {
-			isCompleted = true
-		} else if completedFloat, isFloat := completedVal.(float64); isFloat && completedFloat == 1 {
-			isCompleted = true
-		}
-
-		if isCompleted {
-			slog.DebugContext(r.Context(), "Task marked as completed, checking for recurrence", "task_id", id)
-			// Fetch the task *after* the update to get its current state (including recurrence rule).
-			task, err := db.GetTask(id)
-			if err != nil {
-				// Log error but don't fail the entire update request just because recurrence failed.
-				slog.ErrorContext(r.Context(), "Failed to fetch task after update for recurrence check", "task_id", id, "error", err)
-			} else if task.RecurrenceRule != "" {
-				slog.DebugContext(r.Context(), "Task is recurring, creating next instance", "task_id", id, "rule", task.RecurrenceRule, "interval", task.RecurrenceInterval)
-				// Create the next recurring task instance.
-				if err := createNextRecurringTask(task); err != nil {
-					// Log error, but don't necessarily fail the original update response.
-					slog.ErrorContext(r.Context(), "Error creating next recurring task instance", "task_id", id, "error", err)
-					// Optionally, could return a specific error or warning to the client here.
-					// handleError(w, r, fmt.Errorf("task updated, but failed to create next recurring instance: %w", err))
-					// return
-				}
-			} else {
-				slog.DebugContext(r.Context(), "Completed task is not recurring", "task_id", id)
-			}
-		}
-	} else {
-		slog.DebugContext(r.Context(), "Update did not include 'completed' field or was not set to true/1", "task_id", id)
-	}
+	// With a wider scope, other occurrences changed too; clients reload them.
+	h.publishUpdate(r, previous, map[string]interface{}{"changes": updates, "scope": scope}, false)
 
 	// If all successful, return OK status.
 	w.WriteHeader(http.StatusOK)
 }
 
-// createNextRecurringTask creates the next instance of a completed recurring task.
-func createNextRecurringTask(task db.Task) error {
-	// Pre-conditions: Task must have a valid due date and a recurrence rule.
-	if !task.DueDate.Valid {
-		return fmt.Errorf("cannot create next instance for recurring task ID %d without a due date", task.ID)
-	}
-	if task.RecurrenceRule == "" {
-		// This case should ideally not be reached if called correctly after checking RecurrenceRule.
-		slog.Warn("createNextRecurringTask called for non-recurring task", "task_id", task.ID)
-		return nil // Not an error, just nothing to do.
-	}
-
-	// Calculate the next due date using the database helper function.
-	nextDueDate, err := db.CalculateNextDueDate(task.DueDate.Time, task.RecurrenceRule, task.RecurrenceInterval)
-	if err != nil {
-		return fmt.Errorf("error calculating next due date for task %d: %w", task.ID, err)
-	}
-
-	// Create the new task struct for the next occurrence.
-	newTask := db.Task{
-		Title:              task.Title,                                  // Copy title.
-		Description:        task.Description,                            // Copy description.
-		Color:              task.Color,                                  // Copy color.
-		RecurrenceRule:     task.RecurrenceRule,                         // Keep the rule.
-		RecurrenceInterval: task.RecurrenceInterval,                     // Keep the interval.
-		DueDate:            db.NullTime{Time: nextDueDate, Valid: true}, // Set calculated next date.
-		Completed:          0,                                           // New instance is not completed.
-		TaskOrder:          0,                                           // Reset order (or implement specific logic).
-	}
-
-	slog.Debug("Preparing to create next recurring task instance",
-		"original_task_id", task.ID,
-		"new_task_details", newTask,
-		"new_due_date_formatted", newTask.DueDate.Time.Format(config.DateFormat),
-	)
-
-	// Create the new task in the database.
-	createdTask, err := db.CreateTask(newTask)
-	if err != nil {
-		return fmt.Errorf("failed to create next recurring task instance in db: %w", err)
-	}
-	slog.Info("Successfully created next recurring task instance", "original_task_id", task.ID, "new_task_id", createdTask.ID, "new_due_date", createdTask.DueDate.Time.Format(config.DateFormat), "rule", createdTask.RecurrenceRule, "interval", createdTask.RecurrenceInterval)
-	return nil
-}
-
 // BulkUpdateTaskOrderHandler updates the order for multiple tasks in one request.
-func BulkUpdateTaskOrderHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) BulkUpdateTaskOrderHandler(w http.ResponseWriter, r *http.Request) {
 	var tasks db.Tasks // Expect a slice of tasks, likely just with ID and Order.
 	if err := json.NewDecoder(r.Body).Decode(&tasks); err != nil {
 		handleError(w, r, db.NewAPIError(400, "Invalid request body format for bulk update"))
@@ -338,15 +465,25 @@ func BulkUpdateTaskOrderHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	if err := db.BulkUpdateTaskOrder(tasks); err != nil {
+	if err := h.Tasks.BulkUpdateTaskOrder(tasks); err != nil {
 		handleError(w, r, err) // Handles potential transaction errors.
 		return
 	}
+	h.publish(r, events.TasksReordered, map[string]interface{}{"tasks": orderJSON(tasks)})
 	w.WriteHeader(http.StatusOK)
 }
 
+// orderJSON returns the IDs and orders of reordered tasks, for events.
+func orderJSON(tasks db.Tasks) []map[string]int {
+	order := make([]map[string]int, 0, len(tasks))
+	for _, task := range tasks {
+		order = append(order, map[string]int{"id": task.ID, "order": task.TaskOrder})
+	}
+	return order
+}
+
 // DeleteTaskHandler handles requests to delete a task by its ID.
-func DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr, ok := vars["id"]
 	if !ok {
@@ -359,232 +496,1162 @@ func DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.DebugContext(r.Context(), "Attempting to delete task", "task_id", id)
+	scope, err := db.ParseScope(r.URL.Query().Get("scope"))
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	slog.DebugContext(r.Context(), "Attempting to delete task", "task_id", id, "scope", scope)
 
-	if err := db.DeleteTask(id); err != nil {
+	previous := h.snapshot(id)
+	if err := h.Tasks.DeleteTask(id, scope); err != nil {
 		handleError(w, r, err) // Handles 404 Not Found from db layer.
 		return
 	}
 	slog.InfoContext(r.Context(), "Successfully deleted task", "task_id", id)
+	if previous != nil {
+		h.publish(r, events.TaskDeleted, map[string]interface{}{"task": previous, "scope": scope})
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
-// SearchTasksHandler performs fuzzy task search with pagination.
-func SearchTasksHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("query")
-	if query == "" {
-		handleError(w, r, db.NewAPIError(400, "Query parameter ('query') is required for search"))
+// RecurrencePreviewHandler returns the next occurrences of a recurrence rule
+// after a start date, so that rules can be checked before they are saved.
+// Query parameters: rule, interval (simple rules only), start (YYYY-MM-DD)
+// and count (default 5).
+func (h *Handler) RecurrencePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("rule") == "" {
+		handleError(w, r, db.NewAPIError(400, "Missing 'rule' parameter"))
 		return
 	}
-
-	// Pagination parameters with defaults.
-	page := 1
-	pageSize := 10 // Default page size.
-
-	if psStr := r.URL.Query().Get("pageSize"); psStr != "" {
-		ps, err := strconv.Atoi(psStr)
-		if err != nil || ps <= 0 || ps > 100 { // Add upper limit for safety.
-			handleError(w, r, db.NewAPIError(400, "Invalid 'pageSize' parameter (must be > 0 and <= 100)"))
+	start, err := time.Parse(config.DateFormat, query.Get("start"))
+	if err != nil {
+		handleError(w, r, db.NewAPIError(400, "Invalid start date format (expected YYYY-MM-DD)"))
+		return
+	}
+	interval := 1
+	if value := query.Get("interval"); value != "" {
+		if interval, err = strconv.Atoi(value); err != nil || interval < 1 {
+			handleError(w, r, db.NewAPIError(400, "Invalid interval (must be a number >= 1)"))
 			return
 		}
-		pageSize = ps
 	}
-
-	if pStr := r.URL.Query().Get("page"); pStr != "" {
-		p, err := strconv.Atoi(pStr)
-		if err != nil || p <= 0 {
-			handleError(w, r, db.NewAPIError(400, "Invalid 'page' parameter (must be > 0)"))
+	count := 5
+	if value := query.Get("count"); value != "" {
+		if count, err = strconv.Atoi(value); err != nil || count < 1 || count > 100 {
+			handleError(w, r, db.NewAPIError(400, "Invalid count (must be a number from 1 to 100)"))
 			return
 		}
-		page = p
 	}
 
-	// Calculate offset for database query.
-	offset := (page - 1) * pageSize
-
-	tasks, err := db.SearchTasks(query, pageSize, offset)
+	rule, err := db.NormalizeRecurrenceRule(query.Get("rule"))
 	if err != nil {
-		handleError(w, r, err) // Handles potential database errors during search.
+		handleError(w, r, db.NewAPIError(400, fmt.Sprintf("Invalid recurrence rule: %v", err)))
 		return
 	}
+	recurrence, _ := db.ParseRecurrence(rule, interval, start) // Validated above.
 
-	// Return search results.
+	// The first occurrence is the start date itself.
+	dates := []string{}
+	for _, date := range recurrence.Occurrences(start, count+1)[1:] {
+		dates = append(dates, date.Format(config.DateFormat))
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasksToJSON(tasks))
+	json.NewEncoder(w).Encode(map[string]interface{}{"rule": rule, "dates": dates})
 }
 
-// ExportDbHandler allows downloading the current SQLite database file.
-func ExportDbHandler(w http.ResponseWriter, r *http.Request) {
-	dbPath := "tasks.db" // Path to the database file.
-
-	dbFile, err := os.Open(dbPath)
+// GetSeriesHandler returns a recurring task series: its rule and template,
+// the exceptions of single occurrences and the occurrences created so far.
+func (h *Handler) GetSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
 	if err != nil {
-		if os.IsNotExist(err) {
-			handleError(w, r, db.NewAPIError(http.StatusNotFound, "Database file not found"))
-		} else {
-			handleError(w, r, fmt.Errorf("exportDbHandler: could not open db file: %w", err))
-		}
+		handleError(w, r, err)
 		return
 	}
-	defer dbFile.Close()
-
-	fileInfo, err := dbFile.Stat()
+	series, err := h.Tasks.GetSeries(id)
 	if err != nil {
-		handleError(w, r, fmt.Errorf("exportDbHandler: could not get file info: %w", err))
+		handleError(w, r, err) // Handles 404 Not Found from db layer.
 		return
 	}
 
-	// Set headers for file download.
-	w.Header().Set("Content-Disposition", "attachment; filename=tasks.db")   // Suggest filename.
-	w.Header().Set("Content-Type", "application/octet-stream")               // Generic binary stream type.
-	w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10)) // Set file size.
+	exceptions := make([]map[string]interface{}, len(series.Exceptions))
+	for i, e := range series.Exceptions {
+		dueDate := ""
+		if e.DueDate.Valid {
+			dueDate = e.DueDate.Time.Format(config.DateFormat)
+		}
+		exceptions[i] = map[string]interface{}{
+			"occurrence_date": e.OccurrenceDate.Format(config.DateFormat),
+			"skip":            e.Skip,
+			"due_date":        dueDate, // Empty unless the occurrence was moved.
+			"title":           e.Title, // Empty unless the occurrence was renamed.
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":                  series.ID,
+		"uid":                 series.UID,
+		"title":               series.Title,
+		"description":         series.Description,
+		"color":               series.Color,
+		"recurrence_rule":     series.RecurrenceRule,
+		"recurrence_interval": series.RecurrenceInterval,
+		"start_date":          series.StartDate.Format(config.DateFormat),
+		"exceptions":          exceptions,
+		"occurrences":         tasksToJSON(series.Occurrences),
+	})
+}
 
-	// Copy file content to response body.
-	_, err = io.Copy(w, dbFile)
+// pathID parses the integer path variable name, e.g. "id" in /tasks/{id}.
+func pathID(r *http.Request, name string) (int, error) {
+	idStr, ok := mux.Vars(r)[name]
+	if !ok {
+		return 0, db.NewAPIError(400, fmt.Sprintf("Missing %s in URL path", name))
+	}
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		// Log error, response might be partially written.
-		slog.ErrorContext(r.Context(), "Error copying database file to response", "error", err)
-		// Avoid calling handleError here as headers might be sent.
+		return 0, db.NewAPIError(400, fmt.Sprintf("Invalid %s format", name))
 	}
+	return id, nil
 }
 
-// ImportDbHandler handles uploading and replacing the SQLite database file.
-func ImportDbHandler(w http.ResponseWriter, r *http.Request) {
-	// Limit upload size (e.g., 10 MB).
-	err := r.ParseMultipartForm(10 << 20)
+// subtaskFromPath returns the parent ID and the subtask of a
+// /tasks/{id}/subtasks/{subtask_id} request, checking that they belong together.
+func (h *Handler) subtaskFromPath(r *http.Request) (int, db.Task, error) {
+	parentID, err := pathID(r, "id")
 	if err != nil {
-		handleError(w, r, db.NewAPIError(http.StatusBadRequest, fmt.Sprintf("Error parsing multipart form: %v. Max size 10MB.", err)))
+		return 0, db.Task{}, err
+	}
+	subtaskID, err := pathID(r, "subtask_id")
+	if err != nil {
+		return 0, db.Task{}, err
+	}
+	subtask, err := h.Tasks.GetTask(subtaskID)
+	if err != nil {
+		return 0, db.Task{}, db.NewAPIError(404, "Subtask not found")
+	}
+	if subtask.ParentID == nil || *subtask.ParentID != parentID {
+		return 0, db.Task{}, db.NewAPIError(404, "Subtask not found")
+	}
+	return parentID, subtask, nil
+}
+
+// GetSubtasksHandler lists the subtasks of a task, by order.
+func (h *Handler) GetSubtasksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	subtasks, err := h.Tasks.GetSubtasks(id)
+	if err != nil {
+		handleError(w, r, err) // Handles 404 Not Found for the parent.
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasksToJSON(subtasks))
+}
 
-	// Get the file from the form data. "database" is the expected field name.
-	file, header, err := r.FormFile("database")
+// CreateSubtaskHandler adds a subtask at the end of a task's subtasks.
+func (h *Handler) CreateSubtaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
 	if err != nil {
-		handleError(w, r, db.NewAPIError(http.StatusBadRequest, "Invalid file upload request. Ensure 'database' field is present."))
+		handleError(w, r, err)
 		return
 	}
-	defer file.Close()
 
-	// Basic validation of the uploaded file (optional but recommended).
-	contentType := header.Header.Get("Content-Type")
-	// Allow common SQLite MIME types, but don't strictly enforce.
-	if contentType != "application/octet-stream" && contentType != "application/x-sqlite3" && contentType != "application/vnd.sqlite3" {
-		slog.WarnContext(r.Context(), "Import: Unexpected Content-Type, proceeding anyway.", "content_type", contentType)
-		// Consider adding more robust validation if needed (e.g., check file magic number).
+	// Subtasks have no due date or recurrence of their own.
+	var input struct {
+		Title       string `json:"title"`
+		Completed   bool   `json:"completed"`
+		Color       string `json:"color"`
+		Description string `json:"description"`
 	}
-	if !strings.HasSuffix(strings.ToLower(header.Filename), ".db") {
-		slog.WarnContext(r.Context(), "Import: Uploaded file does not have .db extension, proceeding anyway.", "filename", header.Filename)
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		handleError(w, r, db.NewAPIError(400, "Invalid JSON format"))
+		return
 	}
+	defer r.Body.Close()
 
-	// Create a temporary file to write the uploaded content safely.
-	tempDBPath := "temp_tasks_import.db"
-	tempFile, err := os.OpenFile(tempDBPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		handleError(w, r, fmt.Errorf("importDbHandler: could not create temp file '%s': %w", tempDBPath, err))
+	if input.Title == "" {
+		handleError(w, r, db.NewAPIError(400, "Task title is required"))
 		return
 	}
-	// Ensure temp file is closed and removed even on errors.
-	defer tempFile.Close()
-	defer os.Remove(tempDBPath)
+	subtask := db.Task{Title: input.Title, Color: input.Color, Description: input.Description}
+	if input.Completed {
+		subtask.Completed = 1
+	}
 
-	// Copy uploaded file content to the temporary file.
-	_, err = io.Copy(tempFile, file)
+	parent := h.snapshot(id)
+	created, err := h.Tasks.CreateSubtask(id, subtask)
 	if err != nil {
-		handleError(w, r, fmt.Errorf("importDbHandler: could not copy uploaded file to temp file: %w", err))
+		handleError(w, r, err)
 		return
 	}
-	// Close immediately after copy to ensure data is flushed before validation.
-	tempFile.Close()
+	slog.DebugContext(r.Context(), "Successfully created subtask", "task_id", id, "subtask", created)
+	h.publish(r, events.TaskCreated, map[string]interface{}{"task": taskToJSON(created)})
+	h.publishUpdate(r, parent, nil, true) // An open subtask reopens the parent.
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(taskToJSON(created))
+}
 
-	// Validate the temporary database file before replacing the current one.
-	// OpenTestDB attempts to open and potentially migrate, which acts as validation.
-	testDB, err := db.OpenTestDB(tempDBPath)
+// UpdateSubtaskHandler handles partial updates to a subtask. Completing the
+// last open subtask completes the parent.
+func (h *Handler) UpdateSubtaskHandler(w http.ResponseWriter, r *http.Request) {
+	parentID, subtask, err := h.subtaskFromPath(r)
 	if err != nil {
-		handleError(w, r, db.NewAPIError(http.StatusBadRequest, fmt.Sprintf("Import failed: Uploaded file is not a valid database or is corrupted: %v", err)))
+		handleError(w, r, err)
 		return
 	}
-	// Close the test connection.
-	if sqlTestDB, sqlErr := testDB.DB(); sqlErr == nil {
-		sqlTestDB.Close()
+
+	var updates map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		handleError(w, r, db.NewAPIError(400, "Invalid request body format"))
+		return
 	}
+	defer r.Body.Close()
 
-	// 1. Close the current active database connection safely.
-	currentDB := db.GetDB() // Get the global *gorm.DB instance
-	if sqlDB, dbErr := currentDB.DB(); dbErr == nil {
-		slog.InfoContext(r.Context(), "Closing current database connection for import...")
-		if closeErr := sqlDB.Close(); closeErr != nil {
-			// Log error but proceed with caution, file might still be locked.
-			slog.ErrorContext(r.Context(), "Error closing current database connection", "error", closeErr)
-		}
-	} else {
-		slog.ErrorContext(r.Context(), "Could not get underlying sql.DB for closing", "error", dbErr)
-		// Proceed, but file operations might fail.
-	}
-
-	// 2. Define file paths.
-	currentDBPath := "tasks.db"
-	backupDBPath := "tasks.db.bak" // Backup path.
-
-	// 3. Rename current DB to backup path (overwrite existing backup if present).
-	slog.InfoContext(r.Context(), "Backing up current database...", "from", currentDBPath, "to", backupDBPath)
-	if err = os.Rename(currentDBPath, backupDBPath); err != nil {
-		// If the current DB doesn't exist (e.g., first run), that's okay.
-		if !os.IsNotExist(err) {
-			// For other errors (e.g., permissions), fail the import.
-			handleError(w, r, fmt.Errorf("importDbHandler: could not backup current database: %w", err))
-			// Attempt to reopen original connection if possible (best effort)
-			db.InitDB()
+	for _, field := range []string{"due_date", "recurrence_rule", "recurrence_interval", "tags", "list_id"} {
+		if _, ok := updates[field]; ok {
+			handleError(w, r, db.NewAPIError(400, fmt.Sprintf("Subtasks cannot have '%s'", field)))
 			return
 		}
-		slog.InfoContext(r.Context(), "Current database file not found, skipping backup.", "path", currentDBPath)
-	}
-
-	// 4. Rename temp DB (validated) to the main DB path.
-	slog.InfoContext(r.Context(), "Replacing database with imported file...", "from", tempDBPath, "to", currentDBPath)
-	err = os.Rename(tempDBPath, currentDBPath)
-	if err != nil {
-		// Attempt to restore backup if the final rename fails.
-		slog.ErrorContext(r.Context(), "Failed to rename temporary DB to main DB path, attempting to restore backup.", "error", err)
-		if restoreErr := os.Rename(backupDBPath, currentDBPath); restoreErr != nil {
-			// Catastrophic failure: Could not restore backup. State is uncertain.
-			slog.ErrorContext(r.Context(), "CRITICAL: Failed to restore database backup after import failure.", "backup_path", backupDBPath, "restore_error", restoreErr)
-			handleError(w, r, fmt.Errorf("import failed: could not replace database file, AND failed to restore backup: %w", err))
-		} else {
-			// Backup restored successfully.
-			slog.InfoContext(r.Context(), "Successfully restored database backup.")
-			handleError(w, r, fmt.Errorf("import failed: could not replace database file, backup restored: %w", err))
-		}
-		// Reinitialize with the restored (or potentially original failed-to-backup) DB.
-		db.InitDB()
+	}
+
+	previous, parent := h.snapshot(subtask.ID), h.snapshot(parentID)
+	if err := h.Tasks.UpdateTask(subtask.ID, updates, ""); err != nil {
+		handleError(w, r, err)
 		return
 	}
+	h.publishUpdate(r, previous, map[string]interface{}{"changes": updates}, false)
+	h.publishUpdate(r, parent, nil, true)
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteSubtaskHandler deletes a subtask.
+func (h *Handler) DeleteSubtaskHandler(w http.ResponseWriter, r *http.Request) {
+	parentID, subtask, err := h.subtaskFromPath(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	parent := h.snapshot(parentID)
+	if err := h.Tasks.DeleteTask(subtask.ID, ""); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Successfully deleted subtask", "task_id", subtask.ID)
+	h.publish(r, events.TaskDeleted, map[string]interface{}{"task": taskToJSON(subtask)})
+	h.publishUpdate(r, parent, nil, true) // Deleting the last open subtask completes the parent.
+	w.WriteHeader(http.StatusOK)
+}
 
-	// 5. Reinitialize the database connection with the newly imported file.
-	slog.InfoContext(r.Context(), "Reinitializing database connection with imported file...")
-	db.InitDB() // This will open the new tasks.db file.
+// BulkUpdateSubtaskOrderHandler reorders the subtasks of a task. All the
+// given IDs must be subtasks of that task.
+func (h *Handler) BulkUpdateSubtaskOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	var tasks db.Tasks // ID and Order of each subtask.
+	if err := json.NewDecoder(r.Body).Decode(&tasks); err != nil {
+		handleError(w, r, db.NewAPIError(400, "Invalid request body format for bulk update"))
+		return
+	}
+	defer r.Body.Close()
 
-	// 6. Remove the backup file after successful import and reinitialization.
-	if err = os.Remove(backupDBPath); err != nil && !os.IsNotExist(err) {
-		// Log error if backup deletion fails, but don't fail the overall request.
-		slog.WarnContext(r.Context(), "Failed to remove database backup file after successful import", "backup_path", backupDBPath, "error", err)
+	subtasks, err := h.Tasks.GetSubtasks(id)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	known := make(map[int]bool, len(subtasks))
+	for _, sub := range subtasks {
+		known[sub.ID] = true
+	}
+	for _, task := range tasks {
+		if !known[task.ID] {
+			handleError(w, r, db.NewAPIError(400, fmt.Sprintf("Task %d is not a subtask of task %d", task.ID, id)))
+			return
+		}
 	}
 
-	slog.InfoContext(r.Context(), "Database imported successfully.")
+	if err := h.Tasks.BulkUpdateTaskOrder(tasks); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	h.publish(r, events.TasksReordered, map[string]interface{}{"parent_id": id, "tasks": orderJSON(tasks)})
 	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Database imported successfully"})
 }
 
-// CheckRecurringTasksHandler triggers the process to create future occurrences
-// for any past-due, uncompleted recurring tasks.
-func CheckRecurringTasksHandler(w http.ResponseWriter, r *http.Request) {
-	slog.InfoContext(r.Context(), "Checking for undone recurring tasks...")
-	if err := db.CreateNextOccurrencesForUndoneRecurringTasks(); err != nil {
-		handleError(w, r, err) // Pass db layer errors up.
+// SearchTasksHandler performs fuzzy task search with pagination.
+func (h *Handler) SearchTasksHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("query")
+	if query == "" {
+		handleError(w, r, db.NewAPIError(400, "Query parameter ('query') is required for search"))
 		return
 	}
-	slog.InfoContext(r.Context(), "Recurring tasks check completed.")
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Recurring tasks checked and updated."})
+
+	// Pagination parameters with defaults.
+	page := 1
+	pageSize := 10 // Default page size.
+
+	if psStr := r.URL.Query().Get("pageSize"); psStr != "" {
+		ps, err := strconv.Atoi(psStr)
+		if err != nil || ps <= 0 || ps > 100 { // Add upper limit for safety.
+			handleError(w, r, db.NewAPIError(400, "Invalid 'pageSize' parameter (must be > 0 and <= 100)"))
+			return
+		}
+		pageSize = ps
+	}
+
+	if pStr := r.URL.Query().Get("page"); pStr != "" {
+		p, err := strconv.Atoi(pStr)
+		if err != nil || p <= 0 {
+			handleError(w, r, db.NewAPIError(400, "Invalid 'page' parameter (must be > 0)"))
+			return
+		}
+		page = p
+	}
+
+	// Calculate offset for database query.
+	offset := (page - 1) * pageSize
+
+	// Tasks due closer to the client's today rank higher.
+	today, err := h.today(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	tasks, err := h.Tasks.SearchTasks(query, today, pageSize, offset)
+	if err != nil {
+		handleError(w, r, err) // Handles potential database errors during search.
+		return
+	}
+
+	// Return search results.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasksToJSON(tasks))
+}
+
+// ExportHandler downloads the planner. "format=json" (the default) returns a
+// versioned JSON document of all tasks and settings; "format=sqlite" returns
+// the database file like ExportDbHandler.
+func (h *Handler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDB(w, r) {
+		return
+	}
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+	case "sqlite", "db":
+		h.ExportDbHandler(w, r)
+		return
+	default:
+		handleError(w, r, db.NewAPIError(http.StatusBadRequest, "Invalid 'format' parameter (must be 'json' or 'sqlite')"))
+		return
+	}
+
+	doc, err := h.DB.ExportDocument()
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	filename := fmt.Sprintf("week-planner-%s.json", doc.ExportedAt.Format(config.DateFormat))
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		slog.ErrorContext(r.Context(), "Error writing export document", "error", err)
+	}
+}
+
+// ImportHandler merges (mode=merge, the default) or replaces (mode=replace)
+// tasks and settings from a JSON document produced by ExportHandler. The
+// document is read from the "file" multipart field or the raw request body.
+// With "dry_run=true" the result is reported but nothing is written.
+func (h *Handler) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDB(w, r) {
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = db.ImportModeMerge
+	}
+	dryRun, err := parseBoolParam(r.URL.Query().Get("dry_run"))
+	if err != nil {
+		handleError(w, r, db.NewAPIError(http.StatusBadRequest, "Invalid 'dry_run' parameter (must be true or false)"))
+		return
+	}
+
+	var body io.Reader
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			handleError(w, r, db.NewAPIError(http.StatusBadRequest, fmt.Sprintf("Error parsing multipart form: %v. Max size 10MB.", err)))
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			handleError(w, r, db.NewAPIError(http.StatusBadRequest, "Invalid file upload request. Ensure 'file' field is present."))
+			return
+		}
+		defer file.Close()
+		body = file
+	} else {
+		body = http.MaxBytesReader(w, r.Body, 10<<20)
+		defer r.Body.Close()
+	}
+
+	var doc db.Document
+	if err := json.NewDecoder(body).Decode(&doc); err != nil {
+		handleError(w, r, db.NewAPIError(http.StatusBadRequest, fmt.Sprintf("Invalid import document: %v", err)))
+		return
+	}
+
+	result, err := h.DB.ImportDocument(doc, mode, dryRun)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Document import finished", "mode", mode, "dry_run", dryRun, "created", result.Created, "updated", result.Updated, "conflicts", len(result.Conflicts))
+	if !dryRun {
+		h.publish(r, events.TasksReloaded, map[string]interface{}{"reason": "import"})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ExportDbHandler allows downloading a consistent snapshot of the SQLite database.
+func (h *Handler) ExportDbHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDB(w, r) {
+		return
+	}
+	dbPath := h.DB.Path() // Path to the database file.
+
+	// Snapshot into a private directory: VACUUM INTO refuses existing files.
+	tempDir, err := os.MkdirTemp(filepath.Dir(dbPath), "export-*")
+	if err != nil {
+		handleError(w, r, fmt.Errorf("exportDbHandler: could not create temp dir: %w", err))
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	snapshotPath := filepath.Join(tempDir, filepath.Base(dbPath))
+	if err := h.DB.Snapshot(snapshotPath); err != nil {
+		handleError(w, r, fmt.Errorf("exportDbHandler: %w", err))
+		return
+	}
+
+	dbFile, err := os.Open(snapshotPath)
+	if err != nil {
+		handleError(w, r, fmt.Errorf("exportDbHandler: could not open snapshot: %w", err))
+		return
+	}
+	defer dbFile.Close()
+
+	fileInfo, err := dbFile.Stat()
+	if err != nil {
+		handleError(w, r, fmt.Errorf("exportDbHandler: could not get file info: %w", err))
+		return
+	}
+
+	// Set headers for file download.
+	w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(dbPath)) // Suggest filename.
+	w.Header().Set("Content-Type", "application/octet-stream")                           // Generic binary stream type.
+	w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))             // Set file size.
+
+	// Copy file content to response body.
+	_, err = io.Copy(w, dbFile)
+	if err != nil {
+		// Log error, response might be partially written.
+		slog.ErrorContext(r.Context(), "Error copying database file to response", "error", err)
+		// Avoid calling handleError here as headers might be sent.
+	}
+}
+
+// CalendarICSHandler serves all dated tasks as an iCalendar feed that calendar
+// clients can subscribe to, with one recurring item per series of recurring
+// tasks (see ical.FromTasks). Tasks are exported as VTODOs unless
+// "component=vevent" is requested, for clients that only show events.
+func (h *Handler) CalendarICSHandler(w http.ResponseWriter, r *http.Request) {
+	component := ical.ComponentTodo
+	switch strings.ToLower(r.URL.Query().Get("component")) {
+	case "", "vtodo":
+	case "vevent":
+		component = ical.ComponentEvent
+	default:
+		handleError(w, r, db.NewAPIError(400, "Invalid 'component' parameter (must be 'vtodo' or 'vevent')"))
+		return
+	}
+
+	items, err := ical.FromTasks(h.Tasks, component)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	cal := ical.Calendar{
+		ProdID: ical.ProdID,
+		Name:   "Week Planner",
+		Items:  items,
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", "inline; filename=calendar.ics")
+	if err := ical.Encode(w, cal); err != nil {
+		// Headers are already sent, only log.
+		slog.ErrorContext(r.Context(), "Error writing calendar feed", "error", err)
+	}
+}
+
+// ImportDbHandler handles uploading and replacing the SQLite database file.
+func (h *Handler) ImportDbHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDB(w, r) {
+		return
+	}
+	// Limit upload size (e.g., 10 MB).
+	err := r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		handleError(w, r, db.NewAPIError(http.StatusBadRequest, fmt.Sprintf("Error parsing multipart form: %v. Max size 10MB.", err)))
+		return
+	}
+
+	// Get the file from the form data. "database" is the expected field name.
+	file, header, err := r.FormFile("database")
+	if err != nil {
+		handleError(w, r, db.NewAPIError(http.StatusBadRequest, "Invalid file upload request. Ensure 'database' field is present."))
+		return
+	}
+	defer file.Close()
+
+	// Basic validation of the uploaded file (optional but recommended).
+	contentType := header.Header.Get("Content-Type")
+	// Allow common SQLite MIME types, but don't strictly enforce.
+	if contentType != "application/octet-stream" && contentType != "application/x-sqlite3" && contentType != "application/vnd.sqlite3" {
+		slog.WarnContext(r.Context(), "Import: Unexpected Content-Type, proceeding anyway.", "content_type", contentType)
+		// Consider adding more robust validation if needed (e.g., check file magic number).
+	}
+	if !strings.HasSuffix(strings.ToLower(header.Filename), ".db") {
+		slog.WarnContext(r.Context(), "Import: Uploaded file does not have .db extension, proceeding anyway.", "filename", header.Filename)
+	}
+
+	// The temp file lives next to the database so the final rename stays on
+	// one filesystem. A unique name keeps concurrent imports apart.
+	tempFile, err := os.CreateTemp(filepath.Dir(h.DB.Path()), "temp_tasks_import-*.db")
+	if err != nil {
+		handleError(w, r, fmt.Errorf("importDbHandler: could not create temp file: %w", err))
+		return
+	}
+	tempDBPath := tempFile.Name()
+	// Ensure temp file is closed and removed even on errors.
+	defer tempFile.Close()
+	defer os.Remove(tempDBPath)
+
+	// Copy uploaded file content to the temporary file.
+	_, err = io.Copy(tempFile, file)
+	if err != nil {
+		handleError(w, r, fmt.Errorf("importDbHandler: could not copy uploaded file to temp file: %w", err))
+		return
+	}
+	// Close immediately after copy to ensure data is flushed before validation.
+	tempFile.Close()
+
+	if err := h.DB.Swap(tempDBPath); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Database imported successfully.")
+	h.publish(r, events.TasksReloaded, map[string]interface{}{"reason": "import_db"})
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Database imported successfully"})
+}
+
+// ListBackupsHandler returns the available database backups, newest first.
+func (h *Handler) ListBackupsHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireBackups(w, r) {
+		return
+	}
+	list, err := h.Backups.List()
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateBackupHandler takes a backup immediately.
+func (h *Handler) CreateBackupHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireBackups(w, r) {
+		return
+	}
+	created, err := h.Backups.Create()
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// RestoreBackupHandler replaces the database with the backup named in the URL.
+func (h *Handler) RestoreBackupHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireBackups(w, r) {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if err := h.Backups.Restore(name); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Database restored from backup", "name", name)
+	h.publish(r, events.TasksReloaded, map[string]interface{}{"reason": "restore"})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Backup restored successfully"})
+}
+
+// ListJobsHandler returns the background jobs of the server with their
+// schedule, last run and next run.
+func (h *Handler) ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Jobs == nil {
+		handleError(w, r, db.NewAPIError(http.StatusNotImplemented, "Background jobs are not running"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Jobs.Status())
+}
+
+// ListRemindersHandler returns the reminders the server fired, oldest first.
+// Clients notifying the user poll it with the ID of the last reminder they
+// saw in "after", so each reminder is shown once.
+func (h *Handler) ListRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Reminders == nil {
+		handleError(w, r, db.NewAPIError(http.StatusNotImplemented, "Reminders are not available"))
+		return
+	}
+	afterID := 0
+	if afterStr := r.URL.Query().Get("after"); afterStr != "" {
+		a, err := strconv.Atoi(afterStr)
+		if err != nil || a < 0 {
+			handleError(w, r, db.NewAPIError(400, "Invalid 'after' parameter (must be a reminder ID >= 0)"))
+			return
+		}
+		afterID = a
+	}
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 || l > 500 {
+			handleError(w, r, db.NewAPIError(400, "Invalid 'limit' parameter (must be > 0 and <= 500)"))
+			return
+		}
+		limit = l
+	}
+	reminders, err := h.Reminders.GetReminders(afterID, limit)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reminders)
+}
+
+// requireWebhooks reports whether webhooks are available, answering 501 if not.
+func (h *Handler) requireWebhooks(w http.ResponseWriter, r *http.Request) bool {
+	if h.Webhooks == nil {
+		handleError(w, r, db.NewAPIError(http.StatusNotImplemented, "Webhooks are not available"))
+		return false
+	}
+	return true
+}
+
+// ListWebhooksHandler returns the registered webhooks, without their secrets.
+func (h *Handler) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWebhooks(w, r) {
+		return
+	}
+	webhooks, err := h.Webhooks.GetWebhooks()
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// CreateWebhookHandler registers a webhook: {"url", "events", "secret"}.
+// Without events it receives all of them; without a secret one is generated.
+// The response is the only one showing the secret.
+func (h *Handler) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWebhooks(w, r) {
+		return
+	}
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		handleError(w, r, db.NewAPIError(400, "Invalid JSON format"))
+		return
+	}
+	defer r.Body.Close()
+
+	webhook, err := h.Webhooks.CreateWebhook(db.Webhook{URL: input.URL, Events: input.Events, Secret: input.Secret})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Webhook registered", "webhook_id", webhook.ID, "url", webhook.URL, "events", webhook.Events)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// DeleteWebhookHandler removes a webhook with its queued and logged deliveries.
+func (h *Handler) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWebhooks(w, r) {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := h.Webhooks.DeleteWebhook(id); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Webhook deleted", "webhook_id", id)
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListWebhookDeliveriesHandler returns the delivery log of a webhook, newest
+// first: queued, delivered and failed deliveries with their attempts.
+// "limit" defaults to 50, at most 500.
+func (h *Handler) ListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWebhooks(w, r) {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 || l > 500 {
+			handleError(w, r, db.NewAPIError(400, "Invalid 'limit' parameter (must be > 0 and <= 500)"))
+			return
+		}
+		limit = l
+	}
+	if _, err := h.Webhooks.GetWebhook(id); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	deliveries, err := h.Webhooks.GetWebhookDeliveries(id, limit)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// requireTags reports whether tags are available, answering 501 if not.
+func (h *Handler) requireTags(w http.ResponseWriter, r *http.Request) bool {
+	if h.Tags == nil {
+		handleError(w, r, db.NewAPIError(http.StatusNotImplemented, "Tags are not available"))
+		return false
+	}
+	return true
+}
+
+// ListTagsHandler returns all tags by name, with the number of tasks having
+// each.
+func (h *Handler) ListTagsHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireTags(w, r) {
+		return
+	}
+	tags, err := h.Tags.GetTags()
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// CreateTagHandler adds a tag: {"name", "color"}. Tags are also created as
+// tasks are tagged, so this is only needed to set a color up front.
+func (h *Handler) CreateTagHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireTags(w, r) {
+		return
+	}
+	var input struct {
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		handleError(w, r, db.NewAPIError(400, "Invalid JSON format"))
+		return
+	}
+	defer r.Body.Close()
+
+	tag, err := h.Tags.CreateTag(db.Tag{Name: input.Name, Color: input.Color})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	h.publish(r, events.TagsChanged, map[string]interface{}{"tag": tag})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tag)
+}
+
+// UpdateTagHandler renames or recolors a tag: {"name", "color"}, either
+// optional. A renamed tag is renamed on its tasks.
+func (h *Handler) UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireTags(w, r) {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	tag, err := h.Tags.GetTag(id)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	var input struct {
+		Name  *string `json:"name"`
+		Color *string `json:"color"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		handleError(w, r, db.NewAPIError(400, "Invalid JSON format"))
+		return
+	}
+	defer r.Body.Close()
+	if input.Name != nil {
+		tag.Name = *input.Name
+	}
+	if input.Color != nil {
+		tag.Color = *input.Color
+	}
+
+	updated, err := h.Tags.UpdateTag(tag)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	h.publish(r, events.TagsChanged, map[string]interface{}{"tag": updated})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteTagHandler deletes a tag, removing it from its tasks.
+func (h *Handler) DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireTags(w, r) {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := h.Tags.DeleteTag(id); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	h.publish(r, events.TagsChanged, map[string]interface{}{"deleted": id})
+	w.WriteHeader(http.StatusOK)
+}
+
+// requireLists reports whether lists are available, answering 501 if not.
+func (h *Handler) requireLists(w http.ResponseWriter, r *http.Request) bool {
+	if h.Lists == nil {
+		handleError(w, r, db.NewAPIError(http.StatusNotImplemented, "Lists are not available"))
+		return false
+	}
+	return true
+}
+
+// publishList publishes the default list after the inbox was renamed, for
+// the clients showing lists.
+func (h *Handler) publishList(r *http.Request) {
+	if h.Lists == nil {
+		return
+	}
+	lists, err := h.Lists.GetLists()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to read lists for event", "error", err)
+		return
+	}
+	for _, list := range lists {
+		if list.IsDefault {
+			h.publish(r, events.ListsChanged, map[string]interface{}{"list": list})
+		}
+	}
+}
+
+// ListListsHandler returns all lists by order, with the number of open tasks
+// of each. The default one is the inbox.
+func (h *Handler) ListListsHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireLists(w, r) {
+		return
+	}
+	lists, err := h.Lists.GetLists()
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lists)
+}
+
+// CreateListHandler adds a list: {"title", "color", "order"}.
+func (h *Handler) CreateListHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireLists(w, r) {
+		return
+	}
+	var input struct {
+		Title string `json:"title"`
+		Color string `json:"color"`
+		Order int    `json:"order"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		handleError(w, r, db.NewAPIError(400, "Invalid JSON format"))
+		return
+	}
+	defer r.Body.Close()
+
+	list, err := h.Lists.CreateList(db.List{Title: input.Title, Color: input.Color, ListOrder: input.Order})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	h.publish(r, events.ListsChanged, map[string]interface{}{"list": list})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateListHandler renames, recolors or reorders a list: {"title", "color",
+// "order"}, each optional.
+func (h *Handler) UpdateListHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireLists(w, r) {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	list, err := h.Lists.GetList(id)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	var input struct {
+		Title *string `json:"title"`
+		Color *string `json:"color"`
+		Order *int    `json:"order"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		handleError(w, r, db.NewAPIError(400, "Invalid JSON format"))
+		return
+	}
+	defer r.Body.Close()
+	if input.Title != nil {
+		list.Title = *input.Title
+	}
+	if input.Color != nil {
+		list.Color = *input.Color
+	}
+	if input.Order != nil {
+		list.ListOrder = *input.Order
+	}
+
+	updated, err := h.Lists.UpdateList(list)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	h.publish(r, events.ListsChanged, map[string]interface{}{"list": updated})
+	if updated.IsDefault && input.Title != nil {
+		h.publish(r, events.SettingsChanged, map[string]interface{}{"inbox_title": updated.Title})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteListHandler deletes a list, moving its tasks to the default list,
+// which cannot be deleted.
+func (h *Handler) DeleteListHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.requireLists(w, r) {
+		return
+	}
+	id, err := pathID(r, "id")
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := h.Lists.DeleteList(id); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	h.publish(r, events.ListsChanged, map[string]interface{}{"deleted": id})
+	w.WriteHeader(http.StatusOK)
+}
+
+// ImportICSHandler merges the VTODO/VEVENT items of an uploaded .ics file into
+// the task list. The file is read from the "calendar" multipart field or, for
+// other content types, from the raw request body. With "dry_run=true" the
+// report of what would be created is returned without writing anything.
+func (h *Handler) ImportICSHandler(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := parseBoolParam(r.URL.Query().Get("dry_run"))
+	if err != nil {
+		handleError(w, r, db.NewAPIError(http.StatusBadRequest, "Invalid 'dry_run' parameter (must be true or false)"))
+		return
+	}
+
+	var body io.Reader
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			handleError(w, r, db.NewAPIError(http.StatusBadRequest, fmt.Sprintf("Error parsing multipart form: %v. Max size 10MB.", err)))
+			return
+		}
+		file, _, err := r.FormFile("calendar")
+		if err != nil {
+			handleError(w, r, db.NewAPIError(http.StatusBadRequest, "Invalid file upload request. Ensure 'calendar' field is present."))
+			return
+		}
+		defer file.Close()
+		body = file
+	} else {
+		body = http.MaxBytesReader(w, r.Body, 10<<20)
+		defer r.Body.Close()
+	}
+
+	loc, err := h.location(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	items, err := ical.Decode(body, loc)
+	if err != nil {
+		handleError(w, r, db.NewAPIError(http.StatusBadRequest, fmt.Sprintf("Invalid iCalendar file: %v", err)))
+		return
+	}
+
+	report, err := ical.ImportTasks(h.Tasks, items, dryRun)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Calendar import finished", "dry_run", dryRun, "created", len(report.Created), "skipped", len(report.Skipped))
+	if !dryRun && len(report.Created) > 0 {
+		h.publish(r, events.TasksReloaded, map[string]interface{}{"reason": "import_ics"})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseBoolParam parses an optional boolean query parameter.
+func parseBoolParam(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// CheckRecurringTasksHandler triggers the process to create future occurrences
+// for any past-due, uncompleted recurring tasks.
+func (h *Handler) CheckRecurringTasksHandler(w http.ResponseWriter, r *http.Request) {
+	slog.InfoContext(r.Context(), "Checking for undone recurring tasks...")
+	// "Overdue" depends on the date in the client's time zone.
+	today, err := h.today(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := h.Tasks.CreateNextOccurrencesForUndoneRecurringTasks(today); err != nil {
+		handleError(w, r, err) // Pass db layer errors up.
+		return
+	}
+	slog.InfoContext(r.Context(), "Recurring tasks check completed.")
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Recurring tasks checked and updated."})
+}
+
+// eventsHeartbeat is how often EventsHandler writes a comment to an idle
+// stream, so that proxies do not close it.
+const eventsHeartbeat = 25 * time.Second
+
+// EventsHandler streams the changes made through the API as Server-Sent
+// Events: each event has the type of an events.Event as its name and the
+// JSON event as its data. Events are not replayed; a client that reconnects,
+// or whose stream ends because it fell behind, reloads what it shows.
+func (h *Handler) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Events == nil {
+		handleError(w, r, db.NewAPIError(http.StatusNotImplemented, "Events are not available"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		handleError(w, r, fmt.Errorf("eventsHandler: streaming is not supported"))
+		return
+	}
+	sub := h.Events.Subscribe(64)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream.
+	w.WriteHeader(http.StatusOK)
+	// Ask browsers to reconnect after 3s, and open the stream right away.
+	fmt.Fprint(w, "retry: 3000\n: connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C():
+			if !ok {
+				slog.WarnContext(r.Context(), "Closing event stream that fell behind")
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error encoding event", "event", e.Type, "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+	}
 }
@@ -0,0 +1,351 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // Asia/Tokyo without relying on the system's zoneinfo.
+
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+	"week-planner/internal/events"
+	"week-planner/internal/jsonlog"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMain(m *testing.M) {
+	// handleError logs through jsonlog, which panics when not set up.
+	jsonlog.InitLogger(slog.LevelError)
+	m.Run()
+}
+
+func TestTodayFollowsClockAndTimeZone(t *testing.T) {
+	store := db.NewMemoryStore()
+	for _, task := range []struct{ title, due string }{
+		{"Saturday", "2026-10-17"},
+		{"Sunday", "2026-10-18"},
+		{"Monday", "2026-10-19"},
+	} {
+		due, err := time.Parse(config.DateFormat, task.due)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.CreateTask(db.Task{Title: task.title, DueDate: db.NullTime{Time: due, Valid: true}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &Handler{
+		Tasks:    store,
+		Settings: store,
+		Location: time.FixedZone("UTC-7", -7*60*60),
+		Clock:    db.FixedClock(time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC)),
+	}
+
+	tests := []struct {
+		target string
+		header string
+		status int
+		want   string
+	}{
+		{"/api/tasks?date=today", "", http.StatusOK, "Sunday"},
+		{"/api/tasks?date=today&tz=UTC", "", http.StatusOK, "Sunday"},
+		{"/api/tasks?date=today&tz=Asia/Tokyo", "", http.StatusOK, "Monday"},
+		{"/api/tasks?date=today", "Asia/Tokyo", http.StatusOK, "Monday"},
+		{"/api/tasks?date=today&tz=Nowhere/Special", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.header != "" {
+			r.Header.Set("X-Timezone", tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.GetTasksHandler(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s (X-Timezone %q): status %d, want %d", tt.target, tt.header, w.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var tasks []struct {
+			Title string `json:"title"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&tasks); err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 1 || tasks[0].Title != tt.want {
+			t.Errorf("%s (X-Timezone %q) = %v, want %s", tt.target, tt.header, tasks, tt.want)
+		}
+	}
+}
+
+func TestChangesArePublished(t *testing.T) {
+	store := db.NewMemoryStore()
+	bus := events.NewBus()
+	sub := bus.Subscribe(16)
+	defer sub.Close()
+	h := &Handler{Tasks: store, Settings: store, Events: bus}
+	store.OnOccurrenceCreated(h.OccurrenceCreated)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"title": "Water plants", "due_date": "2026-10-18", "recurrence_rule": "weekly"}`))
+	r.Header.Set("X-Client-ID", "tab-1")
+	w := httptest.NewRecorder()
+	h.CreateTaskHandler(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	var created struct{ ID int }
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	r = httptest.NewRequest(http.MethodPut, "/api/tasks/1", strings.NewReader(`{"completed": true}`))
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+	w = httptest.NewRecorder()
+	h.UpdateTaskHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", w.Code, w.Body)
+	}
+
+	// The next occurrence is created while the update runs, so its event
+	// comes first.
+	want := []string{events.TaskCreated, events.TaskCreated, events.TaskUpdated}
+	var got []events.Event
+	for range want {
+		select {
+		case e := <-sub.C():
+			got = append(got, e)
+		default:
+			t.Fatalf("got events %v, want types %v", got, want)
+		}
+	}
+	for i, e := range got {
+		if e.Type != want[i] {
+			t.Fatalf("got events %v, want types %v", got, want)
+		}
+	}
+	if got[0].Source != "tab-1" || got[1].Source != "" {
+		t.Errorf("sources %q and %q, want tab-1 and none", got[0].Source, got[1].Source)
+	}
+	if data := got[1].Data.(map[string]interface{}); data["generated"] != true {
+		t.Errorf("next occurrence event %v is not marked generated", data)
+	}
+	data := got[2].Data.(map[string]interface{})
+	previous, task := data["previous"].(map[string]interface{}), data["task"].(map[string]interface{})
+	if previous["id"] != created.ID || previous["completed"] != 0 || task["completed"] != 1 {
+		t.Errorf("update event %v, want task %d completed", data, created.ID)
+	}
+}
+
+func TestEventsHandlerStreamsEvents(t *testing.T) {
+	bus := events.NewBus()
+	h := &Handler{Events: bus}
+	srv := httptest.NewServer(http.HandlerFunc(h.EventsHandler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q, want text/event-stream", ct)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	// The stream is open once the handler has written its first comment.
+	for lines.Scan() && lines.Text() != ": connected" {
+	}
+	e := bus.Publish(events.SettingsChanged, "", map[string]interface{}{"inbox_title": "Later"})
+
+	var frame []string
+	for lines.Scan() {
+		if lines.Text() != "" {
+			frame = append(frame, lines.Text())
+		} else if len(frame) > 0 {
+			break
+		}
+	}
+	if len(frame) != 3 || frame[0] != "id: 1" || frame[1] != "event: settings.changed" || !strings.HasPrefix(frame[2], "data: ") {
+		t.Fatalf("got frame %q for event %+v", frame, e)
+	}
+	var decoded events.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(frame[2], "data: ")), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != e.ID || decoded.Type != e.Type {
+		t.Errorf("decoded %+v, want %+v", decoded, e)
+	}
+}
+
+func TestCreateTaskParsesTitleTags(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := &Handler{Tasks: store, Settings: store, Tags: store}
+
+	w := httptest.NewRecorder()
+	body := `{"title": "Call plumber #Home #urgent", "tags": ["errands"]}`
+	h.CreateTaskHandler(w, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Title != "Call plumber" || strings.Join(created.Tags, ",") != "errands,home,urgent" {
+		t.Errorf("created %+v, want the title without its tags", created)
+	}
+	store.CreateTask(db.Task{Title: "Call bank"})
+
+	w = httptest.NewRecorder()
+	h.GetTasksHandler(w, httptest.NewRequest(http.MethodGet, "/api/tasks?tag=home&tag=urgent", nil))
+	var tasks []struct {
+		Title string `json:"title"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&tasks); err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Title != "Call plumber" {
+		t.Errorf("tasks tagged home and urgent = %+v", tasks)
+	}
+
+	w = httptest.NewRecorder()
+	h.ListTagsHandler(w, httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+	var tags []db.Tag
+	if err := json.NewDecoder(w.Body).Decode(&tags); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 3 || tags[0].Name != "errands" || tags[0].TaskCount != 1 {
+		t.Errorf("tags = %+v", tags)
+	}
+}
+
+func TestTasksOfList(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := &Handler{Tasks: store, Settings: store, Lists: store}
+
+	w := httptest.NewRecorder()
+	h.CreateListHandler(w, httptest.NewRequest(http.MethodPost, "/api/lists", strings.NewReader(`{"title": "Waiting for"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var list db.List
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	store.CreateTask(db.Task{Title: "Learn Go"})
+	w = httptest.NewRecorder()
+	body := fmt.Sprintf(`{"title": "Reply from Bob", "due_date": "2026-10-20", "list_id": %d}`, list.ID)
+	h.CreateTaskHandler(w, httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetTasksHandler(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/tasks?list=%d", list.ID), nil))
+	var tasks []struct {
+		Title  string `json:"title"`
+		ListID int    `json:"list_id"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&tasks); err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Title != "Reply from Bob" || tasks[0].ListID != list.ID {
+		t.Errorf("tasks of the list = %+v", tasks)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetTasksHandler(w, httptest.NewRequest(http.MethodGet, "/api/tasks?list=inbox", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid list: status %d, want 400", w.Code)
+	}
+}
+
+func TestImportICSDryRun(t *testing.T) {
+	store := db.NewMemoryStore()
+	due, _ := time.Parse(config.DateFormat, "2026-10-20")
+	if _, err := store.CreateTask(db.Task{Title: "Dentist", DueDate: db.NullTime{Time: due, Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{Tasks: store, Location: time.UTC}
+	const calendar = "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\nUID:1\r\nSUMMARY:dentist\r\nDUE;VALUE=DATE:20261020\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:2\r\nSUMMARY:Gym\r\nDTSTART;VALUE=DATE:20261019\r\nRRULE:FREQ=WEEKLY\r\nEND:VTODO\r\n" +
+		"BEGIN:VEVENT\r\nUID:3\r\nSUMMARY:Undated\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	importICS := func(target string) (report struct {
+		DryRun  bool `json:"dry_run"`
+		Created []struct {
+			UID    string `json:"uid"`
+			TaskID int    `json:"task_id"`
+		} `json:"created"`
+		Skipped []struct {
+			UID  string `json:"uid"`
+			Note string `json:"note"`
+		} `json:"skipped"`
+	}) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ImportICSHandler(w, httptest.NewRequest(http.MethodPost, target, strings.NewReader(calendar)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, w.Code, w.Body)
+		}
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	taskCount := func() int {
+		t.Helper()
+		tasks, err := store.GetTasks(db.TaskFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(tasks)
+	}
+
+	// A dry run reports what an import creates and skips, without writing.
+	dry := importICS("/api/import_ics?dry_run=true")
+	if !dry.DryRun || len(dry.Created) != 1 || dry.Created[0].UID != "2" || dry.Created[0].TaskID != 0 || len(dry.Skipped) != 2 {
+		t.Errorf("dry run report = %+v, want Gym created, the others skipped", dry)
+	}
+	if n := taskCount(); n != 1 {
+		t.Fatalf("%d tasks after a dry run, want 1", n)
+	}
+
+	imported := importICS("/api/import_ics")
+	if imported.DryRun || len(imported.Created) != 1 || imported.Created[0].UID != "2" || imported.Created[0].TaskID == 0 || len(imported.Skipped) != len(dry.Skipped) {
+		t.Errorf("import report = %+v, want the dry run's with a task ID", imported)
+	}
+	for i := range imported.Skipped {
+		if imported.Skipped[i] != dry.Skipped[i] {
+			t.Errorf("skipped %+v, the dry run %+v", imported.Skipped[i], dry.Skipped[i])
+		}
+	}
+	gym, err := store.GetTask(imported.Created[0].TaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gym.RecurrenceRule != "weekly" {
+		t.Errorf("imported recurrence = %q, want weekly", gym.RecurrenceRule)
+	}
+
+	// Importing the same file again creates nothing.
+	again := importICS("/api/import_ics")
+	if len(again.Created) != 0 || len(again.Skipped) != 3 {
+		t.Errorf("second import report = %+v, want everything skipped", again)
+	}
+	if n := taskCount(); n != 2 {
+		t.Errorf("%d tasks after importing twice, want 2", n)
+	}
+}
@@ -0,0 +1,230 @@
+// Package auth protects the planner's API when it is reachable by others
+// than its user. Clients authenticate with a static bearer token, with a
+// username and password (HTTP Basic, or a login setting a session cookie),
+// or with the token as the Basic password, e.g. calendar apps subscribing to
+// the feed. Without a token or password everything is open, as before.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"week-planner/internal/db"
+)
+
+// CookieName is the name of the session cookie.
+const CookieName = "planner_session"
+
+// loginFailureDelay slows down guessing passwords.
+var loginFailureDelay = time.Second
+
+// publicPaths are the API endpoints usable without authentication.
+var publicPaths = map[string]bool{
+	"/api/login":   true,
+	"/api/logout":  true,
+	"/api/session": true,
+}
+
+// Config is how clients authenticate; the zero value disables it.
+type Config struct {
+	Token    string // Static bearer token.
+	User     string
+	Password string
+	// SessionTTL is how long a login lasts.
+	SessionTTL time.Duration
+}
+
+// Authenticator checks the credentials of requests and keeps the sessions
+// of logged in browsers. Sessions live in memory: restarting the server logs
+// everyone out.
+type Authenticator struct {
+	cfg   Config
+	clock db.Clock
+
+	mu       sync.Mutex
+	sessions map[string]time.Time // Expiry by session ID.
+}
+
+// New returns an authenticator for cfg. A user needs a password, and the
+// other way round.
+func New(cfg Config) (*Authenticator, error) {
+	if (cfg.User == "") != (cfg.Password == "") {
+		return nil, errors.New("auth: AUTH_USER and AUTH_PASSWORD must be set together")
+	}
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = 30 * 24 * time.Hour
+	}
+	return &Authenticator{cfg: cfg, clock: db.SystemClock, sessions: map[string]time.Time{}}, nil
+}
+
+// SetClock makes the authenticator read the time from clock, for sessions.
+func (a *Authenticator) SetClock(clock db.Clock) {
+	a.clock = clock
+}
+
+// Enabled reports whether requests have to authenticate.
+func (a *Authenticator) Enabled() bool {
+	return a != nil && (a.cfg.Token != "" || a.cfg.User != "")
+}
+
+// equal compares secrets in constant time.
+func equal(given, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(given), []byte(want)) == 1
+}
+
+// validCredentials reports whether a username and password, or a token, are
+// those configured.
+func (a *Authenticator) validCredentials(user, password, token string) bool {
+	if token != "" {
+		return equal(token, a.cfg.Token)
+	}
+	// The token also works as a Basic password, with any username.
+	return (equal(user, a.cfg.User) && equal(password, a.cfg.Password)) || equal(password, a.cfg.Token)
+}
+
+// Authenticated reports whether r carries valid credentials or session.
+func (a *Authenticator) Authenticated(r *http.Request) bool {
+	if !a.Enabled() {
+		return true
+	}
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			return a.validCredentials("", "", token)
+		}
+		if user, password, ok := r.BasicAuth(); ok {
+			return a.validCredentials(user, password, "")
+		}
+		return false
+	}
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	expiry, ok := a.sessions[cookie.Value]
+	if ok && !a.clock.Now().Before(expiry) {
+		delete(a.sessions, cookie.Value)
+		return false
+	}
+	return ok
+}
+
+// Middleware answers 401 to unauthenticated requests to the API. The
+// frontend's files, the login endpoints and CORS preflight requests are
+// public.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() || r.Method == http.MethodOptions || !strings.HasPrefix(r.URL.Path, "/api/") || publicPaths[r.URL.Path] || a.Authenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		challenge := `Bearer realm="week-planner"`
+		// Calendar apps only know Basic authentication. Browsers would show
+		// their own login dialog for it, so the API does not offer it.
+		if r.URL.Path == "/api/calendar.ics" {
+			challenge = `Basic realm="week-planner"`
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+	})
+}
+
+// LoginHandler checks {"username", "password"} or {"token"} and starts a
+// session, set as an HttpOnly cookie.
+func (a *Authenticator) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.Enabled() {
+		writeSession(w, a, true)
+		return
+	}
+	var input struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Token    string `json:"token"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	defer r.Body.Close()
+	if (input.Token == "" && input.Password == "") || !a.validCredentials(input.Username, input.Password, input.Token) {
+		slog.WarnContext(r.Context(), "Failed login", "username", input.Username, "remote_addr", r.RemoteAddr)
+		time.Sleep(loginFailureDelay)
+		writeError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		writeError(w, http.StatusInternalServerError, "Could not create a session")
+		return
+	}
+	id := hex.EncodeToString(key)
+	expiry := a.clock.Now().Add(a.cfg.SessionTTL)
+	a.mu.Lock()
+	a.sessions[id] = expiry
+	a.pruneLocked()
+	a.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    id,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.InfoContext(r.Context(), "Logged in", "username", input.Username, "remote_addr", r.RemoteAddr)
+	writeSession(w, a, true)
+}
+
+// pruneLocked forgets expired sessions. Must be called with mu held.
+func (a *Authenticator) pruneLocked() {
+	now := a.clock.Now()
+	for id, expiry := range a.sessions {
+		if !now.Before(expiry) {
+			delete(a.sessions, id)
+		}
+	}
+}
+
+// LogoutHandler ends the session of the request, if any.
+func (a *Authenticator) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(CookieName); err == nil && a.Enabled() {
+		a.mu.Lock()
+		delete(a.sessions, cookie.Value)
+		a.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: CookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	writeSession(w, a, !a.Enabled())
+}
+
+// SessionHandler tells the frontend whether it has to log in, and how.
+func (a *Authenticator) SessionHandler(w http.ResponseWriter, r *http.Request) {
+	writeSession(w, a, a.Authenticated(r))
+}
+
+func writeSession(w http.ResponseWriter, a *Authenticator, authenticated bool) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{
+		"auth_required":  a.Enabled(),
+		"authenticated":  authenticated,
+		"password_login": a.Enabled() && a.cfg.User != "",
+		"token_login":    a.Enabled() && a.cfg.Token != "",
+	})
+}
+
+// writeError answers with an error in the API's format.
+func writeError(w http.ResponseWriter, code int, message string) {
+	(&db.APIError{Code: code, Message: message}).WriteResponse(w)
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"week-planner/internal/db"
+)
+
+func init() {
+	loginFailureDelay = 0
+}
+
+// serve runs r through a's middleware in front of a handler answering 200.
+func serve(a *Authenticator, r *http.Request) int {
+	w := httptest.NewRecorder()
+	a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestMiddleware(t *testing.T) {
+	a, err := New(Config{Token: "s3cret", User: "ann", Password: "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		want   int
+	}{
+		{"no credentials", "GET", "/api/tasks", "", http.StatusUnauthorized},
+		{"bearer token", "GET", "/api/tasks", "Bearer s3cret", http.StatusOK},
+		{"wrong token", "GET", "/api/tasks", "Bearer s3cre", http.StatusUnauthorized},
+		{"basic password", "GET", "/api/export_db", "Basic YW5uOmh1bnRlcjI=", http.StatusOK},             // ann:hunter2
+		{"basic wrong user", "GET", "/api/export_db", "Basic Ym9iOmh1bnRlcjI=", http.StatusUnauthorized}, // bob:hunter2
+		{"basic token", "GET", "/api/calendar.ics", "Basic Y2FsOnMzY3JldA==", http.StatusOK},             // cal:s3cret
+		{"import", "POST", "/api/import_db", "", http.StatusUnauthorized},
+		{"frontend", "GET", "/index.html", "", http.StatusOK},
+		{"login", "POST", "/api/login", "", http.StatusOK},
+		{"preflight", "OPTIONS", "/api/tasks", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		if got := serve(a, r); got != tt.want {
+			t.Errorf("%s: %s %s answered %d, want %d", tt.name, tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	open, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(open, httptest.NewRequest("GET", "/api/tasks", nil)); got != http.StatusOK {
+		t.Errorf("without authentication configured, the API answered %d", got)
+	}
+	if _, err := New(Config{User: "ann"}); err == nil {
+		t.Error("accepted a user without a password")
+	}
+}
+
+func TestLoginSession(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	a, err := New(Config{User: "ann", Password: "hunter2", SessionTTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetClock(db.ClockFunc(func() time.Time { return now }))
+
+	login := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		a.LoginHandler(w, httptest.NewRequest("POST", "/api/login", strings.NewReader(body)))
+		return w
+	}
+	if w := login(`{"username": "ann", "password": "hunter"}`); w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("wrong password: status %d, cookies %v", w.Code, w.Result().Cookies())
+	}
+	w := login(`{"username": "ann", "password": "hunter2"}`)
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusOK || len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("login: status %d, cookies %v", w.Code, cookies)
+	}
+
+	withCookie := func() *http.Request {
+		r := httptest.NewRequest("GET", "/api/tasks", nil)
+		r.AddCookie(cookies[0])
+		return r
+	}
+	if got := serve(a, withCookie()); got != http.StatusOK {
+		t.Errorf("with the session cookie the API answered %d", got)
+	}
+	now = now.Add(time.Hour)
+	if got := serve(a, withCookie()); got != http.StatusUnauthorized {
+		t.Errorf("after the session expired the API answered %d", got)
+	}
+
+	// Logging out ends the session.
+	cookies = login(`{"username": "ann", "password": "hunter2"}`).Result().Cookies()
+	a.LogoutHandler(httptest.NewRecorder(), withCookie())
+	if got := serve(a, withCookie()); got != http.StatusUnauthorized {
+		t.Errorf("after logging out the API answered %d", got)
+	}
+}
@@ -0,0 +1,202 @@
+// Package backup keeps rotated, consistent snapshots of the planner database.
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"week-planner/internal/db"
+)
+
+// timeFormat is used in backup file names; it sorts chronologically.
+const timeFormat = "20060102-150405"
+
+// namePattern matches file names produced by Create.
+var namePattern = regexp.MustCompile(`^tasks-(\d{8}-\d{6})(?:-(\d+))?\.db$`)
+
+// Backup describes one backup file.
+type Backup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manager creates, lists, rotates and restores backups of a database in Dir.
+type Manager struct {
+	Dir   string
+	Keep  int // Number of backups to keep; older ones are deleted. <= 0 keeps all.
+	store *db.Store
+}
+
+// NewManager returns a manager for backups of store in dir keeping the newest
+// keep files. store may be nil if the manager is only used to list backups.
+func NewManager(store *db.Store, dir string, keep int) *Manager {
+	return &Manager{Dir: dir, Keep: keep, store: store}
+}
+
+// Create snapshots the live database into a new backup file and rotates old ones.
+func (m *Manager) Create() (Backup, error) {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return Backup{}, fmt.Errorf("backup: creating %s: %w", m.Dir, err)
+	}
+
+	now := time.Now().UTC()
+	name := "tasks-" + now.Format(timeFormat) + ".db"
+	for i := 1; fileExists(filepath.Join(m.Dir, name)); i++ {
+		name = fmt.Sprintf("tasks-%s-%d.db", now.Format(timeFormat), i)
+	}
+	path := filepath.Join(m.Dir, name)
+
+	if err := m.store.Snapshot(path); err != nil {
+		os.Remove(path)
+		return Backup{}, fmt.Errorf("backup: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Backup{}, fmt.Errorf("backup: %w", err)
+	}
+	slog.Info("Database backup created", "path", path, "size", info.Size())
+
+	if err := m.rotate(); err != nil {
+		// The new backup exists; failing to delete old ones is not fatal.
+		slog.Warn("Failed to rotate backups", "dir", m.Dir, "error", err)
+	}
+	return Backup{Name: name, Size: info.Size(), CreatedAt: info.ModTime().UTC()}, nil
+}
+
+// List returns the backups, newest first.
+func (m *Manager) List() ([]Backup, error) {
+	entries, err := os.ReadDir(m.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Backup{}, nil
+		}
+		return nil, fmt.Errorf("backup: listing %s: %w", m.Dir, err)
+	}
+	backups := []Backup{}
+	for _, entry := range entries {
+		if entry.IsDir() || !namePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed while listing.
+		}
+		backups = append(backups, Backup{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime().UTC()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return newerName(backups[i].Name, backups[j].Name) })
+	return backups, nil
+}
+
+// Restore replaces the live database with the named backup. The current state
+// is backed up first, under the same lock as the swap, so a restore can
+// itself be undone.
+func (m *Manager) Restore(name string) error {
+	if !namePattern.MatchString(name) {
+		return db.NewAPIError(400, fmt.Sprintf("Invalid backup name %q", name))
+	}
+	src := filepath.Join(m.Dir, name)
+	if !fileExists(src) {
+		return db.NewAPIError(404, fmt.Sprintf("Backup %q not found", name))
+	}
+
+	// Copy before taking the safety backup: rotation may delete src. The
+	// copy lives next to the database so the swap can rename it into place;
+	// a unique name keeps concurrent restores apart.
+	temp, err := os.CreateTemp(filepath.Dir(m.store.Path()), "temp_tasks_restore-*.db")
+	if err != nil {
+		return fmt.Errorf("backup: restore %s: %w", name, err)
+	}
+	defer os.Remove(temp.Name())
+	if err := copyFile(src, temp); err != nil {
+		return fmt.Errorf("backup: restore %s: %w", name, err)
+	}
+
+	err = m.store.SwapAfter(temp.Name(), func() error {
+		if _, err := m.Create(); err != nil {
+			return fmt.Errorf("backup: restore %s: backing up current database: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Database restored from backup", "name", name)
+	return nil
+}
+
+// FirstDue returns when the first scheduled backup is due: interval after
+// the newest existing backup, so restarts do not skip or pile up backups.
+// Without backups it is now.
+func (m *Manager) FirstDue(interval time.Duration) time.Time {
+	if backups, err := m.List(); err == nil && len(backups) > 0 {
+		return backups[0].CreatedAt.Add(interval)
+	}
+	return time.Now()
+}
+
+// Scheduled creates a backup on behalf of the scheduler, holding the store
+// so that it is not swapped meanwhile.
+func (m *Manager) Scheduled(ctx context.Context) error {
+	release := m.store.Acquire()
+	defer release()
+	_, err := m.Create()
+	return err
+}
+
+// rotate deletes all but the newest Keep backups.
+func (m *Manager) rotate() error {
+	if m.Keep <= 0 {
+		return nil
+	}
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, old := range backups[min(m.Keep, len(backups)):] {
+		if err := os.Remove(filepath.Join(m.Dir, old.Name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		slog.Info("Old backup removed", "name", old.Name)
+	}
+	return nil
+}
+
+// newerName orders backup names by the creation time and counter they embed.
+func newerName(a, b string) bool {
+	ma, mb := namePattern.FindStringSubmatch(a), namePattern.FindStringSubmatch(b)
+	if ma[1] != mb[1] {
+		return ma[1] > mb[1]
+	}
+	ca, _ := strconv.Atoi(ma[2]) // An empty counter is the first backup of that second.
+	cb, _ := strconv.Atoi(mb[2])
+	return ca > cb
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// copyFile copies the file at src into dst and closes dst.
+func copyFile(src string, dst *os.File) error {
+	in, err := os.Open(src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	defer in.Close()
+	if _, err := io.Copy(dst, in); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,119 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+
+	"week-planner/internal/db"
+)
+
+func TestNewerName(t *testing.T) {
+	// Newest first: later seconds, then higher counters within a second.
+	want := []string{
+		"tasks-20261019-000000.db",
+		"tasks-20261018-090000-10.db",
+		"tasks-20261018-090000-2.db",
+		"tasks-20261018-090000-1.db",
+		"tasks-20261018-090000.db",
+		"tasks-20251231-235959.db",
+	}
+	names := slices.Clone(want)
+	slices.Reverse(names)
+	sort.Slice(names, func(i, j int) bool { return newerName(names[i], names[j]) })
+	if !slices.Equal(names, want) {
+		t.Errorf("sorted names = %v, want %v", names, want)
+	}
+}
+
+func TestListAndRotate(t *testing.T) {
+	dir := t.TempDir()
+	backups := []string{
+		"tasks-20261016-090000.db",
+		"tasks-20261018-090000.db",
+		"tasks-20261018-090000-1.db",
+		"tasks-20261017-090000.db",
+	}
+	others := []string{"notes.txt", "foo.db", "tasks-2026.db", "tasks-20261019-090000.db.tmp"}
+	for _, name := range append(slices.Clone(backups), others...) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewManager(nil, dir, 2)
+
+	list := func() []string {
+		t.Helper()
+		backups, err := m.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, b := range backups {
+			names = append(names, b.Name)
+		}
+		return names
+	}
+	if got, want := list(), []string{
+		"tasks-20261018-090000-1.db",
+		"tasks-20261018-090000.db",
+		"tasks-20261017-090000.db",
+		"tasks-20261016-090000.db",
+	}; !slices.Equal(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+
+	// Rotation keeps the newest backups and leaves other files alone.
+	if err := m.rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(), []string{"tasks-20261018-090000-1.db", "tasks-20261018-090000.db"}; !slices.Equal(got, want) {
+		t.Errorf("List after rotating = %v, want %v", got, want)
+	}
+	for _, name := range others {
+		if !fileExists(filepath.Join(dir, name)) {
+			t.Errorf("rotation removed %s", name)
+		}
+	}
+
+	// Keep <= 0 keeps everything.
+	m.Keep = 0
+	if err := m.rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(); len(got) != 2 {
+		t.Errorf("List after rotating without a limit = %v", got)
+	}
+}
+
+func TestListWithoutDir(t *testing.T) {
+	backups, err := NewManager(nil, filepath.Join(t.TempDir(), "missing"), 3).List()
+	if err != nil || len(backups) != 0 {
+		t.Errorf("List = %v, %v, want no backups", backups, err)
+	}
+}
+
+func TestRestoreRejectsInvalidNames(t *testing.T) {
+	m := NewManager(nil, t.TempDir(), 3)
+	for _, tt := range []struct {
+		name string
+		code int
+	}{
+		{"../x", 400},
+		{"foo.db", 400},
+		{"", 400},
+		{"../tasks-20261018-090000.db", 400},
+		{"tasks-20261018-090000.db/../../x", 400},
+		{"sub/tasks-20261018-090000.db", 400},
+		{"tasks-20261018-090000.db", 404}, // Valid, but there is no such backup.
+	} {
+		err := m.Restore(tt.name)
+		var apiErr *db.APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != tt.code {
+			t.Errorf("Restore(%q) = %v, want a %d APIError", tt.name, err, tt.code)
+		}
+	}
+}
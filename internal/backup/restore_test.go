@@ -0,0 +1,157 @@
+//go:build sqlite_fts5
+
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"week-planner/internal/db"
+)
+
+func TestCreateAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := db.Open(filepath.Join(dir, "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	titles := func() []string {
+		t.Helper()
+		tasks, err := store.GetTasks(db.TaskFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+
+	// With Keep 1 the backup restored from is rotated away by the safety
+	// backup of the restore, which must not lose it.
+	m := NewManager(store, filepath.Join(dir, "backups"), 1)
+	if _, err := store.CreateTask(db.Task{Title: "Before"}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateTask(db.Task{Title: "After"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Restore(before.Name); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(); len(got) != 1 || got[0] != "Before" {
+		t.Errorf("tasks after restoring = %v, want only Before", got)
+	}
+	backups, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || backups[0].Name == before.Name {
+		t.Fatalf("backups after restoring = %v, want only the safety backup", backups)
+	}
+
+	// The safety backup undoes the restore.
+	if err := m.Restore(backups[0].Name); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(); len(got) != 2 {
+		t.Errorf("tasks after undoing the restore = %v, want Before and After", got)
+	}
+}
+
+func TestRestoreKeepsFileMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.db")
+	store, err := db.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(store, filepath.Join(dir, "backups"), 0)
+	b, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Restore(b.Name); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o640 {
+		t.Errorf("mode after restoring = %o, want 640", mode)
+	}
+}
+
+func TestConcurrentRestores(t *testing.T) {
+	dir := t.TempDir()
+	store, err := db.Open(filepath.Join(dir, "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	m := NewManager(store, filepath.Join(dir, "backups"), 0)
+	var names []string
+	for _, title := range []string{"First", "Second", "Third"} {
+		if _, err := store.CreateTask(db.Task{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+		b, err := m.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, b.Name)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(names))
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- m.Restore(name)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Each restore swapped in a whole backup and was backed up first.
+	tasks, err := store.GetTasks(db.TaskFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(tasks); n < 1 || n > 3 {
+		t.Errorf("restored %d tasks, want the ones of a backup", n)
+	}
+	if backups, err := m.List(); err != nil || len(backups) != 2*len(names) {
+		t.Errorf("%d backups, %v, want %d", len(backups), err, 2*len(names))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "temp_") {
+			t.Errorf("%s left behind", entry.Name())
+		}
+	}
+}
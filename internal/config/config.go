@@ -2,37 +2,104 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
-
-	"github.com/ilyakaznacheev/cleanenv"
+	"time"
 )
 
 const DateFormat = "2006-01-02"
 
+// DBFileName is the default name of the SQLite database file.
+const DBFileName = "tasks.db"
+
+// appDirName is the directory created under the XDG data home.
+const appDirName = "week-planner"
+
+// Config is the configuration of the planner. Each option has a key in the
+// config file and an environment variable; see Load for how they combine.
 type Config struct {
-	Host     string `env:"HOST" env-default:"localhost"`
-	Port     int    `env:"PORT" env-default:"5000"`
-	LogLevel string `env:"LOGLEVEL" env-default:"error"`
-}
+	// Host is the address the server binds to: "localhost" by default,
+	// "0.0.0.0" or "::" for every interface.
+	Host string `toml:"host" yaml:"host" env:"HOST" env-default:"localhost"`
+	Port int    `toml:"port" yaml:"port" env:"PORT" env-default:"5000"`
+	// Socket is the path of a Unix domain socket to serve on instead of
+	// HOST:PORT, e.g. behind a reverse proxy.
+	Socket string `toml:"socket" yaml:"socket" env:"SOCKET"`
+	// TLSCert and TLSKey are PEM files; when both are set the server speaks
+	// HTTPS.
+	TLSCert string `toml:"tls_cert" yaml:"tls_cert" env:"TLS_CERT"`
+	TLSKey  string `toml:"tls_key" yaml:"tls_key" env:"TLS_KEY"`
 
-// NewConfig returns app config.
-func NewConfig() (Config, error) {
-	var errFile error
+	LogLevel string `toml:"log_level" yaml:"log_level" env:"LOGLEVEL" env-default:"error"`
+	DataDir  string `toml:"data_dir" yaml:"data_dir" env:"DATA_DIR"`
+	DBPath   string `toml:"db_path" yaml:"db_path" env:"DB_PATH"`
+	// TimeZone is the planner's IANA time zone, e.g. "Europe/Berlin", which
+	// decides when a day ends. Empty for the system's local time zone.
+	TimeZone string `toml:"time_zone" yaml:"time_zone" env:"TZ"`
 
-	cfg := &Config{}
-	errEnv := cleanenv.ReadEnv(cfg)
+	BackupDir      string        `toml:"backup_dir" yaml:"backup_dir" env:"BACKUP_DIR"`
+	BackupKeep     int           `toml:"backup_keep" yaml:"backup_keep" env:"BACKUP_KEEP" env-default:"7"`
+	BackupInterval time.Duration `toml:"backup_interval" yaml:"backup_interval" env:"BACKUP_INTERVAL" env-default:"24h"`
 
-	// fallback to .env file
-	if errEnv != nil {
-		errFile = cleanenv.ReadConfig(".env", cfg)
-	}
+	// Maintenance jobs of the server; a non-positive interval disables one.
+	FTSOptimizeInterval time.Duration `toml:"fts_optimize_interval" yaml:"fts_optimize_interval" env:"FTS_OPTIMIZE_INTERVAL" env-default:"24h"`
+	CheckpointInterval  time.Duration `toml:"checkpoint_interval" yaml:"checkpoint_interval" env:"CHECKPOINT_INTERVAL" env-default:"1h"`
 
-	if errFile != nil {
-		return *cfg, errors.Join(errEnv, errFile)
-	}
+	// ReminderInterval is how often the server looks for due reminders,
+	// which bounds how late they fire; a non-positive interval disables them.
+	ReminderInterval time.Duration `toml:"reminder_interval" yaml:"reminder_interval" env:"REMINDER_INTERVAL" env-default:"30s"`
+
+	// Authentication of the API, off unless a token or a user is set.
+	AuthToken    string        `toml:"auth_token" yaml:"auth_token" env:"AUTH_TOKEN" secret:"true"`
+	AuthUser     string        `toml:"auth_user" yaml:"auth_user" env:"AUTH_USER"`
+	AuthPassword string        `toml:"auth_password" yaml:"auth_password" env:"AUTH_PASSWORD" secret:"true"`
+	SessionTTL   time.Duration `toml:"session_ttl" yaml:"session_ttl" env:"SESSION_TTL" env-default:"720h"`
+	// CORSOrigins are the origins other than the planner's own allowed to
+	// call the API from a browser, e.g. "https://planner.example.com"; "*"
+	// allows any, without credentials.
+	CORSOrigins []string `toml:"cors_origins" yaml:"cors_origins" env:"CORS_ORIGINS" env-separator:","`
 
-	return *cfg, nil
+	// file is the config file the configuration was read from, if any, and
+	// sources where each option came from, by key.
+	file    string
+	sources map[string]string
+}
+
+// Validate checks the options, reporting all the invalid ones.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Socket == "" && (c.Port < 1 || c.Port > 65535) {
+		errs = append(errs, fmt.Errorf("invalid port %d (PORT): expected 1 to 65535", c.Port))
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("invalid log_level %q (LOGLEVEL): expected debug, info, warn or error", c.LogLevel))
+	}
+	if _, err := c.GetLocation(); err != nil {
+		errs = append(errs, err)
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("tls_cert and tls_key (TLS_CERT, TLS_KEY) must be set together"))
+	}
+	if (c.AuthUser == "") != (c.AuthPassword == "") {
+		errs = append(errs, errors.New("auth_user and auth_password (AUTH_USER, AUTH_PASSWORD) must be set together"))
+	}
+	if c.SessionTTL <= 0 {
+		errs = append(errs, fmt.Errorf("invalid session_ttl %s (SESSION_TTL): must be positive", c.SessionTTL))
+	}
+	for _, origin := range c.CORSOrigins {
+		u, err := url.Parse(origin)
+		if origin != "*" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "") {
+			errs = append(errs, fmt.Errorf("invalid cors_origins entry %q (CORS_ORIGINS): expected an origin like https://planner.example.com, or *", origin))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (c *Config) GetLogLevel() slog.Level {
@@ -49,3 +116,70 @@ func (c *Config) GetLogLevel() slog.Level {
 		return slog.LevelError
 	}
 }
+
+// GetLocation returns the planner's time zone.
+func (c *Config) GetLocation() (*time.Location, error) {
+	// A POSIX ":path" TZ from the environment was already applied to
+	// time.Local by the Go runtime; LoadLocation does not accept it.
+	if c.TimeZone == "" || strings.HasPrefix(c.TimeZone, ":") && c.TimeZone == os.Getenv("TZ") {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone TZ=%q: %w", c.TimeZone, err)
+	}
+	return loc, nil
+}
+
+// GetDataDir returns the directory holding the database and its companion files.
+// An explicit DATA_DIR wins; otherwise it is the directory of the database file.
+func (c *Config) GetDataDir() string {
+	if c.DataDir != "" {
+		return c.DataDir
+	}
+	return filepath.Dir(c.GetDBPath())
+}
+
+// GetDBPath resolves the SQLite database location.
+//
+// Precedence: DB_PATH, then DATA_DIR/tasks.db, then an existing tasks.db in the
+// working directory (older installs), then the XDG data home on Linux.
+// Other platforms keep the portable tasks.db next to the working directory.
+func (c *Config) GetDBPath() string {
+	if c.DBPath != "" {
+		return c.DBPath
+	}
+	if c.DataDir != "" {
+		return filepath.Join(c.DataDir, DBFileName)
+	}
+	if _, err := os.Stat(DBFileName); err == nil {
+		return DBFileName
+	}
+	if runtime.GOOS == "linux" {
+		if dir := xdgDataHome(); dir != "" {
+			return filepath.Join(dir, appDirName, DBFileName)
+		}
+	}
+	return DBFileName
+}
+
+// GetBackupDir returns the directory for database backups, "backups" inside
+// the data directory unless BACKUP_DIR is set.
+func (c *Config) GetBackupDir() string {
+	if c.BackupDir != "" {
+		return c.BackupDir
+	}
+	return filepath.Join(c.GetDataDir(), "backups")
+}
+
+// xdgDataHome returns $XDG_DATA_HOME, falling back to ~/.local/share.
+func xdgDataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share")
+}
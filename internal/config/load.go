@@ -0,0 +1,344 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Where the value of an option came from, from lowest to highest
+// precedence.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceDotEnv  = ".env"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// FileEnv is the environment variable naming the config file, like the
+// --config flag. Without either, configFileNames are looked for in the data
+// directory.
+const FileEnv = "CONFIG_FILE"
+
+var configFileNames = []string{"config.toml", "config.yaml", "config.yml"}
+
+// option is a field of Config, described by its tags.
+type option struct {
+	index  int
+	key    string // In the config file.
+	env    string
+	def    string // Default value, parsed like the environment variable.
+	sep    string // Separator of list values.
+	secret bool   // Not shown by Settings.
+}
+
+// flag returns the name of the option's command line flag: the environment
+// variable in lowercase with dashes, e.g. --backup-keep.
+func (o option) flag() string {
+	return strings.ReplaceAll(strings.ToLower(o.env), "_", "-")
+}
+
+var options = func() []option {
+	var opts []option
+	t := reflect.TypeOf(Config{})
+	for i := range t.NumField() {
+		f := t.Field(i)
+		env := f.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		sep := f.Tag.Get("env-separator")
+		if sep == "" {
+			sep = ","
+		}
+		opts = append(opts, option{
+			index:  i,
+			key:    f.Tag.Get("toml"),
+			env:    env,
+			def:    f.Tag.Get("env-default"),
+			sep:    sep,
+			secret: f.Tag.Get("secret") == "true",
+		})
+	}
+	return opts
+}()
+
+// Load returns the configuration from, in increasing precedence: the
+// defaults, the config file, a .env file in the working directory, the
+// environment, and the flags in args, e.g. --port 5055 or --data-dir=/data,
+// for any option. It also returns args without those flags. The result is
+// not validated; see Validate.
+func Load(args []string) (Config, []string, error) {
+	flags, rest, err := parseFlags(args)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	dotEnv, err := godotenv.Read(".env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, nil, fmt.Errorf("reading .env: %w", err)
+	}
+	lookup := func(env, flag string) (string, string, bool) {
+		if value, ok := flags[flag]; ok {
+			return value, SourceFlag, true
+		}
+		if value, ok := os.LookupEnv(env); ok {
+			return value, SourceEnv, true
+		}
+		value, ok := dotEnv[env]
+		return value, SourceDotEnv, ok
+	}
+
+	path, _, explicit := lookup(FileEnv, "config")
+	if !explicit {
+		// The config file is looked for where the other layers put the data.
+		layers, err := build("", lookup)
+		if err != nil {
+			return Config{}, nil, err
+		}
+		path = findFile(layers.GetDataDir())
+	}
+	cfg, err := build(path, lookup)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	cfg.file = path
+	return cfg, rest, nil
+}
+
+// build stacks the layers of the configuration, reading the config file at
+// path unless it is empty.
+func build(path string, lookup func(env, flag string) (string, string, bool)) (Config, error) {
+	cfg := Config{sources: map[string]string{}}
+	v := reflect.ValueOf(&cfg).Elem()
+	for _, o := range options {
+		if err := setOption(v.Field(o.index), o.def, o.sep); err != nil {
+			return Config{}, fmt.Errorf("default of %s: %w", o.key, err)
+		}
+		cfg.sources[o.key] = SourceDefault
+	}
+	if path != "" {
+		keys, err := readFile(path, &cfg)
+		if err != nil {
+			return Config{}, err
+		}
+		for _, key := range keys {
+			cfg.sources[key] = SourceFile
+		}
+	}
+	for _, o := range options {
+		value, source, ok := lookup(o.env, o.flag())
+		if !ok {
+			continue
+		}
+		if err := setOption(v.Field(o.index), value, o.sep); err != nil {
+			name := o.env
+			if source == SourceFlag {
+				name = "--" + o.flag()
+			}
+			return Config{}, fmt.Errorf("invalid %s=%q: %w", name, value, err)
+		}
+		cfg.sources[o.key] = source
+	}
+	return cfg, nil
+}
+
+// setOption parses value into field like an environment variable.
+func setOption(field reflect.Value, value, sep string) error {
+	switch field.Interface().(type) {
+	case time.Duration:
+		if value == "" {
+			field.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case int:
+		if value == "" {
+			field.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("not a number")
+		}
+		field.SetInt(int64(n))
+	case string:
+		field.SetString(value)
+	case []string:
+		list := []string{}
+		for _, item := range strings.Split(value, sep) {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported option type %s", field.Type())
+	}
+	return nil
+}
+
+// readFile decodes the TOML or YAML config file at path into cfg and
+// returns the keys it sets. Unknown keys are errors, typos would be missed
+// otherwise.
+func readFile(path string, cfg *Config) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	var keys []string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("config file %s: unknown option %s", path, undecoded[0])
+		}
+		for _, key := range md.Keys() {
+			keys = append(keys, key.String())
+		}
+	case ".yaml", ".yml":
+		var set map[string]any
+		if err := yaml.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		for key := range set {
+			keys = append(keys, key)
+		}
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q (expected .toml, .yaml or .yml)", path, ext)
+	}
+	return keys, nil
+}
+
+// findFile returns the first of configFileNames in dir, or "".
+func findFile(dir string) string {
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// parseFlags takes the flags of options, and --config, out of args. They
+// may come before or after a command, up to a "--".
+func parseFlags(args []string) (map[string]string, []string, error) {
+	flags := map[string]string{}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || !isFlag(name) {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf("flag --%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		flags[name] = value
+	}
+	return flags, rest, nil
+}
+
+func isFlag(name string) bool {
+	return name == "config" || slices.ContainsFunc(options, func(o option) bool { return o.flag() == name })
+}
+
+// Setting is an option of the effective configuration, as shown by
+// `week_planner config show`.
+type Setting struct {
+	Key    string // In the config file.
+	Env    string
+	Flag   string
+	Value  string // TOML; secrets are masked.
+	Source string
+}
+
+// Settings lists the options of c, in the order of Config.
+func (c Config) Settings() []Setting {
+	v := reflect.ValueOf(c)
+	settings := make([]Setting, 0, len(options))
+	for _, o := range options {
+		value := formatOption(v.Field(o.index))
+		if o.secret && !v.Field(o.index).IsZero() {
+			value = `"********"`
+		}
+		source := c.sources[o.key]
+		if source == "" {
+			source = SourceDefault
+		}
+		settings = append(settings, Setting{Key: o.key, Env: o.env, Flag: "--" + o.flag(), Value: value, Source: source})
+	}
+	return settings
+}
+
+// File returns the config file the configuration was read from, or "".
+func (c Config) File() string {
+	return c.file
+}
+
+// formatOption returns the value of an option field as TOML.
+func formatOption(field reflect.Value) string {
+	switch value := field.Interface().(type) {
+	case time.Duration:
+		return strconv.Quote(formatDuration(value))
+	case int:
+		return strconv.Itoa(value)
+	case string:
+		return strconv.Quote(value)
+	case []string:
+		quoted := make([]string, len(value))
+		for i, item := range value {
+			quoted[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// formatDuration writes d without zero minutes and seconds: 24h rather
+// than 24h0m0s.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.toml", `
+port = 6000
+backup_keep = 3
+backup_interval = "0s"
+cors_origins = ["https://a.example"]
+`)
+	t.Setenv("DATA_DIR", dir)
+	t.Setenv("BACKUP_KEEP", "5")
+
+	cfg, rest, err := Load([]string{"--backup-keep", "9", "serve", "--open", "--tz=Europe/Berlin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(rest, []string{"serve", "--open"}) {
+		t.Errorf("rest = %q, want the command and its own flags", rest)
+	}
+	if cfg.File() != filepath.Join(dir, "config.toml") {
+		t.Errorf("File() = %q, want the config.toml of the data directory", cfg.File())
+	}
+	if cfg.Host != "localhost" || cfg.Port != 6000 || cfg.BackupKeep != 9 || cfg.TimeZone != "Europe/Berlin" {
+		t.Errorf("host %q, port %d, backup_keep %d, time_zone %q", cfg.Host, cfg.Port, cfg.BackupKeep, cfg.TimeZone)
+	}
+	// A zero in the file is kept, not replaced by the default.
+	if cfg.BackupInterval != 0 || cfg.ReminderInterval != 30*time.Second {
+		t.Errorf("backup_interval %s, reminder_interval %s", cfg.BackupInterval, cfg.ReminderInterval)
+	}
+	if !slices.Equal(cfg.CORSOrigins, []string{"https://a.example"}) {
+		t.Errorf("cors_origins %q", cfg.CORSOrigins)
+	}
+
+	sources := map[string]string{}
+	for _, s := range cfg.Settings() {
+		sources[s.Key] = s.Source
+	}
+	want := map[string]string{"host": SourceDefault, "port": SourceFile, "data_dir": SourceEnv, "backup_keep": SourceFlag, "time_zone": SourceFlag}
+	for key, source := range want {
+		if sources[key] != source {
+			t.Errorf("%s comes from %q, want %q", key, sources[key], source)
+		}
+	}
+}
+
+func TestLoadYAMLAndEnvOverFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "planner.yaml", "host: 0.0.0.0\nsession_ttl: 2h\nauth_token: s3cret\n")
+	t.Setenv(FileEnv, path)
+	t.Setenv("HOST", "127.0.0.1")
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "127.0.0.1" || cfg.SessionTTL != 2*time.Hour || cfg.AuthToken != "s3cret" {
+		t.Errorf("host %q, session_ttl %s, auth_token %q", cfg.Host, cfg.SessionTTL, cfg.AuthToken)
+	}
+	for _, s := range cfg.Settings() {
+		if s.Key == "auth_token" && strings.Contains(s.Value, "s3cret") {
+			t.Errorf("Settings shows the token: %s", s.Value)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownOptions(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.toml": "prot = 5000\n", "b.yaml": "prot: 5000\n"} {
+		t.Setenv(FileEnv, writeFile(t, dir, name, content))
+		if _, _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "prot") {
+			t.Errorf("%s: error %v, want one naming the unknown option", name, err)
+		}
+	}
+	t.Setenv(FileEnv, "")
+	if _, _, err := Load([]string{"--port", "many"}); err == nil {
+		t.Error("accepted --port many")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("the defaults are invalid: %v", err)
+	}
+
+	cfg.Port = 0
+	cfg.LogLevel = "loud"
+	cfg.TLSCert = "cert.pem"
+	cfg.CORSOrigins = []string{"https://ok.example", "ok.example/path"}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("accepted an invalid configuration")
+	}
+	for _, want := range []string{"PORT", "LOGLEVEL", "TLS_KEY", "ok.example/path"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package db
+
+import (
+	"fmt"
+)
+
+// Snapshot writes a consistent copy of the live database to dest using
+// VACUUM INTO. Unlike copying tasks.db, the copy includes pages still in the
+// WAL and cannot be torn by concurrent writes. dest must not exist.
+func (s *Store) Snapshot(dest string) error {
+	if err := s.DB().Exec("VACUUM INTO ?", dest).Error; err != nil {
+		return fmt.Errorf("snapshot to %s: %w", dest, err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package db
+
+import "time"
+
+// Clock tells the current time. The stores and the API read the time only
+// through a Clock, so that tests can fix "now" and with it "today".
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface.
+type ClockFunc func() time.Time
+
+// Now implements Clock.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the wall clock.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// FixedClock returns a clock that is always at t.
+func FixedClock(t time.Time) Clock {
+	return ClockFunc(func() time.Time { return t })
+}
+
+// TodayOn returns the current date of clock in loc, as stored. A nil clock is
+// the system clock.
+func TodayOn(clock Clock, loc *time.Location) time.Time {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return DateIn(clock.Now(), loc)
+}
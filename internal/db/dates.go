@@ -0,0 +1,30 @@
+package db
+
+import "time"
+
+// Dates (due dates, occurrence dates) are calendar dates without a time zone.
+// They are stored as midnight UTC of the date, so that SQLite's DATE() and
+// time.Parse(config.DateFormat, ...) agree on them. Only "today" depends on
+// a time zone: the planner's, or the one a request asks for.
+
+// dateOnly returns midnight UTC of the date of t in t's location, the form
+// dates are stored in.
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// DateIn returns the calendar date of the instant t in loc, as stored. A nil
+// loc is the local time zone.
+func DateIn(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.Local
+	}
+	return dateOnly(t.In(loc))
+}
+
+// Today returns the current date in loc, as stored. The day changes at
+// midnight in loc, not at midnight UTC.
+func Today(loc *time.Location) time.Time {
+	return TodayOn(SystemClock, loc)
+}
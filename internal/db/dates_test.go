@@ -0,0 +1,127 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"week-planner/internal/config"
+)
+
+var (
+	tokyo      = time.FixedZone("UTC+9", 9*60*60)
+	losAngeles = time.FixedZone("UTC-7", -7*60*60)
+)
+
+func mustDate(t *testing.T, value string) time.Time {
+	t.Helper()
+	date, err := time.Parse(config.DateFormat, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return date
+}
+
+func TestDateIn(t *testing.T) {
+	tests := []struct {
+		instant string
+		loc     *time.Location
+		want    string
+	}{
+		{"2026-10-18T23:30:00Z", time.UTC, "2026-10-18"},
+		{"2026-10-18T23:30:00Z", tokyo, "2026-10-19"}, // Already tomorrow east of UTC.
+		{"2026-10-19T03:00:00Z", losAngeles, "2026-10-18"},
+		{"2026-10-19T07:00:00Z", losAngeles, "2026-10-19"},
+	}
+	for _, tt := range tests {
+		instant, err := time.Parse(time.RFC3339, tt.instant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := DateIn(instant, tt.loc)
+		if got.Format(config.DateFormat) != tt.want || got.Location() != time.UTC || got.Hour() != 0 {
+			t.Errorf("DateIn(%s, %s) = %s, want %s at midnight UTC", tt.instant, tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestNullTimeValueStoresCalendarDate(t *testing.T) {
+	// Midnight in Tokyo is the previous day in UTC; the date must not shift.
+	nt := NullTime{Time: time.Date(2026, 10, 19, 0, 0, 0, 0, tokyo), Valid: true}
+	value, err := nt.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := value.(time.Time); !got.Equal(mustDate(t, "2026-10-19")) {
+		t.Errorf("Value() = %s, want 2026-10-19 00:00 UTC", got)
+	}
+}
+
+func TestCalculateNextDueDateUsesCalendarDate(t *testing.T) {
+	current := time.Date(2026, 10, 19, 0, 30, 0, 0, tokyo) // 2026-10-18 in UTC.
+	next, err := CalculateNextDueDate(current, "daily", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustDate(t, "2026-10-20"); !next.Equal(want) {
+		t.Errorf("CalculateNextDueDate = %s, want %s", next, want)
+	}
+}
+
+func TestRolloverUsesTodayOfTimeZone(t *testing.T) {
+	store := NewMemoryStore()
+	_, err := store.CreateTask(Task{
+		Title:              "Stretch",
+		DueDate:            NullTime{Time: mustDate(t, "2026-10-18"), Valid: true},
+		RecurrenceRule:     "daily",
+		RecurrenceInterval: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instant := time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC)
+
+	// Still the 18th in Los Angeles: the task is not overdue.
+	if err := store.CreateNextOccurrencesForUndoneRecurringTasks(DateIn(instant, losAngeles)); err != nil {
+		t.Fatal(err)
+	}
+	if tasks, _ := store.GetTasks(TaskFilter{}); len(tasks) != 1 {
+		t.Fatalf("got %d tasks before midnight, want 1", len(tasks))
+	}
+
+	// Already the 19th in Tokyo: the next occurrence is after today.
+	if err := store.CreateNextOccurrencesForUndoneRecurringTasks(DateIn(instant, tokyo)); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := store.GetTasks(TaskFilter{Date: "2026-10-20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Title != "Stretch" {
+		t.Fatalf("got %v on 2026-10-20, want the next occurrence", tasks)
+	}
+}
+
+func TestSearchRanksByProximityToToday(t *testing.T) {
+	store := NewMemoryStore()
+	for _, task := range []struct{ title, due string }{
+		{"Call plumber", "2026-10-10"},
+		{"Call bank", "2026-10-25"},
+	} {
+		_, err := store.CreateTask(Task{Title: task.title, DueDate: NullTime{Time: mustDate(t, task.due), Valid: true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for today, want := range map[string]string{
+		"2026-10-11": "Call plumber",
+		"2026-10-24": "Call bank",
+	} {
+		tasks, err := store.SearchTasks("call", mustDate(t, today), 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 2 || tasks[0].Title != want {
+			t.Errorf("search on %s: first result %v, want %q", today, tasks, want)
+		}
+	}
+}
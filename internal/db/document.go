@@ -0,0 +1,771 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"week-planner/internal/config"
+
+	"gorm.io/gorm"
+)
+
+// DocumentFormat and DocumentVersion identify JSON export documents.
+const (
+	DocumentFormat  = "week-planner"
+	DocumentVersion = 5 // 2 added subtasks (parent_uid), 3 recurring task series, 4 tags, 5 lists.
+)
+
+// Import modes accepted by ImportDocument.
+const (
+	ImportModeMerge   = "merge"
+	ImportModeReplace = "replace"
+)
+
+// Document is the versioned JSON export of a planner: all tasks and settings.
+type Document struct {
+	Format     string           `json:"format"`
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Settings   []Setting        `json:"settings"`
+	Lists      []DocumentList   `json:"lists,omitempty"`
+	Tasks      []DocumentTask   `json:"tasks"`
+	Series     []DocumentSeries `json:"series,omitempty"`
+}
+
+// DocumentList is a list as stored in a Document. Lists are identified by
+// their title across planners, except for the default one.
+type DocumentList struct {
+	Title   string `json:"title"`
+	Color   string `json:"color,omitempty"`
+	Order   int    `json:"order"`
+	Default bool   `json:"default,omitempty"`
+}
+
+// DocumentTask is a task as stored in a Document. Local IDs are not exported;
+// UID identifies the task across planners.
+type DocumentTask struct {
+	UID                string    `json:"uid"`
+	Title              string    `json:"title"`
+	DueDate            string    `json:"due_date,omitempty"` // YYYY-MM-DD, empty for inbox tasks.
+	Completed          bool      `json:"completed"`
+	Order              int       `json:"order"`
+	Color              string    `json:"color,omitempty"`
+	Description        string    `json:"description,omitempty"`
+	RecurrenceRule     string    `json:"recurrence_rule,omitempty"`
+	RecurrenceInterval int       `json:"recurrence_interval,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	ParentUID          string    `json:"parent_uid,omitempty"` // Set on subtasks.
+	SeriesUID          string    `json:"series_uid,omitempty"` // Set on occurrences of a recurring task.
+	OccurrenceDate     string    `json:"occurrence_date,omitempty"`
+	SpawnedFromUID     string    `json:"spawned_from_uid,omitempty"` // The occurrence this one was created from.
+	DueTime            string    `json:"due_time,omitempty"`
+	DurationMinutes    int       `json:"duration_minutes,omitempty"`
+	RemindAt           string    `json:"remind_at,omitempty"`
+	Tags               []string  `json:"tags,omitempty"` // Tag names.
+	List               string    `json:"list,omitempty"` // Title of its list; empty for the default list and subtasks.
+}
+
+// DocumentSeries is a recurring task series as stored in a Document.
+type DocumentSeries struct {
+	UID                string              `json:"uid"`
+	Title              string              `json:"title"`
+	Description        string              `json:"description,omitempty"`
+	Color              string              `json:"color,omitempty"`
+	RecurrenceRule     string              `json:"recurrence_rule"`
+	RecurrenceInterval int                 `json:"recurrence_interval,omitempty"`
+	StartDate          string              `json:"start_date"`
+	DueTime            string              `json:"due_time,omitempty"`
+	DurationMinutes    int                 `json:"duration_minutes,omitempty"`
+	RemindAt           string              `json:"remind_at,omitempty"`
+	UpdatedAt          time.Time           `json:"updated_at"`
+	Exceptions         []DocumentException `json:"exceptions,omitempty"`
+}
+
+// DocumentException is an exception of a DocumentSeries.
+type DocumentException struct {
+	OccurrenceDate string `json:"occurrence_date"`
+	Skip           bool   `json:"skip,omitempty"`
+	DueDate        string `json:"due_date,omitempty"`
+	Title          string `json:"title,omitempty"`
+}
+
+// ImportConflict describes an item present on both sides with different content.
+type ImportConflict struct {
+	Kind       string `json:"kind"` // "task", "setting" or "list".
+	Key        string `json:"key"`  // Task UID, setting key or list title.
+	Title      string `json:"title,omitempty"`
+	Resolution string `json:"resolution"` // "imported" or "kept_local".
+}
+
+// ImportResult summarizes an ImportDocument call.
+type ImportResult struct {
+	Mode      string           `json:"mode"`
+	DryRun    bool             `json:"dry_run"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Unchanged int              `json:"unchanged"`
+	Deleted   int              `json:"deleted"`
+	Conflicts []ImportConflict `json:"conflicts"`
+}
+
+// errDryRun rolls back the transaction of a dry-run import.
+var errDryRun = errors.New("dry run")
+
+// ExportDocument returns all tasks, series and settings as a Document.
+func (s *Store) ExportDocument() (Document, error) {
+	var tasks Tasks
+	if err := s.DB().Order("id").Find(&tasks).Error; err != nil {
+		return Document{}, fmt.Errorf("exportDocument: %w", err)
+	}
+	var series []Series
+	if err := s.DB().Order("id").Find(&series).Error; err != nil {
+		return Document{}, fmt.Errorf("exportDocument: %w", err)
+	}
+	var exceptions []SeriesException
+	if err := s.DB().Order("series_id, occurrence_date").Find(&exceptions).Error; err != nil {
+		return Document{}, fmt.Errorf("exportDocument: %w", err)
+	}
+	var settings []Setting
+	if err := s.DB().Order("key").Find(&settings).Error; err != nil {
+		return Document{}, fmt.Errorf("exportDocument: %w", err)
+	}
+	var lists []List
+	if err := s.DB().Order("list_order, id").Find(&lists).Error; err != nil {
+		return Document{}, fmt.Errorf("exportDocument: %w", err)
+	}
+
+	doc := Document{
+		Format:     DocumentFormat,
+		Version:    DocumentVersion,
+		ExportedAt: s.Now().UTC(),
+		Settings:   settings,
+		Lists:      make([]DocumentList, len(lists)),
+		Tasks:      make([]DocumentTask, len(tasks)),
+		Series:     make([]DocumentSeries, len(series)),
+	}
+	seriesUIDs := make(map[int]string, len(series))
+	for i, ser := range series {
+		seriesUIDs[ser.ID] = ser.UID
+		doc.Series[i] = toDocumentSeries(ser)
+		for _, e := range exceptions {
+			if e.SeriesID == ser.ID {
+				doc.Series[i].Exceptions = append(doc.Series[i].Exceptions, toDocumentException(e))
+			}
+		}
+	}
+	listTitles := make(map[int]string, len(lists))
+	for i, list := range lists {
+		if !list.IsDefault {
+			listTitles[list.ID] = list.Title
+		}
+		doc.Lists[i] = DocumentList{Title: list.Title, Color: list.Color, Order: list.ListOrder, Default: list.IsDefault}
+	}
+	uids := make(map[int]string, len(tasks))
+	for _, task := range tasks {
+		uids[task.ID] = task.UID
+	}
+	for i, task := range tasks {
+		doc.Tasks[i] = toDocumentTask(task)
+		if task.ParentID != nil {
+			doc.Tasks[i].ParentUID = uids[*task.ParentID]
+		}
+		if task.SeriesID != nil {
+			doc.Tasks[i].SeriesUID = seriesUIDs[*task.SeriesID]
+		}
+		if task.SpawnedFrom != nil {
+			doc.Tasks[i].SpawnedFromUID = uids[*task.SpawnedFrom]
+		}
+		if task.ListID != nil {
+			doc.Tasks[i].List = listTitles[*task.ListID]
+		}
+	}
+	return doc, nil
+}
+
+// ImportDocument applies doc to the database in a single transaction.
+//
+// In merge mode tasks are matched by UID: unknown tasks are created, and a
+// task that differs on both sides is a conflict resolved in favour of the more
+// recently updated copy. Series are matched by UID the same way. Settings
+// and lists (by title) missing locally are added; differing ones are reported
+// and kept. In replace mode all tasks, series, settings and lists are
+// replaced by the document's.
+// Recurring tasks of documents without series start series of their own. With
+// dryRun the result is computed and rolled back.
+func (s *Store) ImportDocument(doc Document, mode string, dryRun bool) (ImportResult, error) {
+	if doc.Format != DocumentFormat {
+		return ImportResult{}, NewAPIError(400, fmt.Sprintf("Unsupported document format %q", doc.Format))
+	}
+	if doc.Version < 1 || doc.Version > DocumentVersion {
+		return ImportResult{}, NewAPIError(400, fmt.Sprintf("Unsupported document version %d (supported up to %d)", doc.Version, DocumentVersion))
+	}
+	if mode != ImportModeMerge && mode != ImportModeReplace {
+		return ImportResult{}, NewAPIError(400, fmt.Sprintf("Invalid import mode %q (must be 'merge' or 'replace')", mode))
+	}
+
+	incoming := make([]Task, len(doc.Tasks))
+	for i, dt := range doc.Tasks {
+		task, err := fromDocumentTask(dt)
+		if err != nil {
+			return ImportResult{}, NewAPIError(400, fmt.Sprintf("Invalid task %d in document: %v", i, err))
+		}
+		incoming[i] = task
+	}
+	incomingSeries := make([]Series, len(doc.Series))
+	for i, ds := range doc.Series {
+		series, err := fromDocumentSeries(ds)
+		if err != nil {
+			return ImportResult{}, NewAPIError(400, fmt.Sprintf("Invalid series %d in document: %v", i, err))
+		}
+		incomingSeries[i] = series
+	}
+
+	// The inbox title is the title of the default list since lists.
+	settings := slices.DeleteFunc(slices.Clone(doc.Settings), func(s Setting) bool { return s.Key == "inbox_title" })
+
+	result := ImportResult{Mode: mode, DryRun: dryRun, Conflicts: []ImportConflict{}}
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		listIDs, err := importLists(tx, doc, mode == ImportModeReplace, &result)
+		if err != nil {
+			return err
+		}
+		for i, dt := range doc.Tasks {
+			if dt.ParentUID == "" {
+				id := listIDs[dt.List]
+				incoming[i].ListID = &id
+			}
+		}
+		if mode == ImportModeReplace {
+			err = replaceAll(tx, incoming, settings, &result)
+		} else {
+			err = mergeAll(tx, incoming, settings, &result)
+		}
+		if err != nil {
+			return err
+		}
+		if err := linkSubtasks(tx, incoming, doc.Tasks); err != nil {
+			return err
+		}
+		if err := importSeries(tx, incomingSeries, doc.Series, mode == ImportModeReplace); err != nil {
+			return err
+		}
+		if err := linkSeries(tx, incoming, doc.Tasks); err != nil {
+			return err
+		}
+		if err := attachSeries(tx); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return ImportResult{}, txError("importDocument", err)
+	}
+	return result, nil
+}
+
+// importLists creates the lists of a document, and of its tasks, that are
+// missing locally and returns the local list IDs by title, "" being the
+// default list. The document's default list is the local default list;
+// documents from before lists only have its title, as the inbox_title
+// setting. Unless replacing, differing local lists are reported and kept.
+func importLists(tx *gorm.DB, doc Document, replacing bool, result *ImportResult) (map[string]int, error) {
+	inbox, err := findList(tx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if replacing {
+		if err := tx.Where("is_default = ?", false).Delete(&List{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	docLists := slices.Clone(doc.Lists)
+	hasDefault := slices.ContainsFunc(docLists, func(l DocumentList) bool { return l.Default })
+	for _, setting := range doc.Settings {
+		if setting.Key == "inbox_title" && !hasDefault {
+			docLists = append(docLists, DocumentList{Title: setting.Value, Color: inbox.Color, Order: inbox.ListOrder, Default: true})
+		}
+	}
+	for _, dt := range doc.Tasks {
+		if dt.List != "" && dt.ParentUID == "" && !slices.ContainsFunc(docLists, func(l DocumentList) bool { return l.Title == dt.List }) {
+			docLists = append(docLists, DocumentList{Title: dt.List})
+		}
+	}
+
+	ids := map[string]int{"": inbox.ID}
+	for _, dl := range docLists {
+		list := List{Title: dl.Title, Color: dl.Color, ListOrder: dl.Order}
+		if err := validateList(&list); err != nil {
+			return nil, err
+		}
+		local := inbox
+		if !dl.Default {
+			err = tx.Where("title = ? AND is_default = ?", list.Title, false).First(&local).Error
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err = tx.Create(&list).Error
+			local = list
+		case err != nil:
+		case replacing:
+			err = tx.Model(&List{}).Where("id = ?", local.ID).
+				Updates(map[string]interface{}{"title": list.Title, "color": list.Color, "list_order": list.ListOrder}).Error
+		case local.Title != list.Title || local.Color != list.Color || local.ListOrder != list.ListOrder:
+			result.Conflicts = append(result.Conflicts, ImportConflict{Kind: "list", Key: list.Title, Resolution: "kept_local"})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list %q: %w", list.Title, err)
+		}
+		if !dl.Default {
+			ids[list.Title] = local.ID
+		}
+	}
+	return ids, nil
+}
+
+func replaceAll(tx *gorm.DB, tasks []Task, settings []Setting, result *ImportResult) error {
+	// Count first: subtasks deleted by the cascade trigger are not in RowsAffected.
+	var count int64
+	if err := tx.Model(&Task{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Task{}).Error; err != nil {
+		return err
+	}
+	result.Deleted = int(count)
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&SeriesException{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Series{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Setting{}).Error; err != nil {
+		return err
+	}
+	for i := range tasks {
+		if err := createImported(tx, &tasks[i]); err != nil {
+			return err
+		}
+		result.Created++
+	}
+	for _, setting := range settings {
+		if err := tx.Create(&Setting{Key: setting.Key, Value: setting.Value}).Error; err != nil {
+			return fmt.Errorf("setting %q: %w", setting.Key, err)
+		}
+	}
+	return nil
+}
+
+func mergeAll(tx *gorm.DB, tasks []Task, settings []Setting, result *ImportResult) error {
+	for i := range tasks {
+		task := &tasks[i]
+		var local Task
+		err := tx.Where("uid = ?", task.UID).First(&local).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := createImported(tx, task); err != nil {
+				return err
+			}
+			result.Created++
+			continue
+		} else if err != nil {
+			return fmt.Errorf("task %s: %w", task.UID, err)
+		}
+
+		if sameTaskContent(local, *task) {
+			result.Unchanged++
+			continue
+		}
+		conflict := ImportConflict{Kind: "task", Key: task.UID, Title: task.Title, Resolution: "kept_local"}
+		if task.UpdatedAt.After(local.UpdatedAt) {
+			conflict.Resolution = "imported"
+			task.ID = local.ID
+			// UpdateColumns keeps the imported updated_at instead of "now".
+			err := tx.Model(&Task{}).Where("id = ?", local.ID).UpdateColumns(map[string]interface{}{
+				"title":               task.Title,
+				"due_date":            task.DueDate,
+				"completed":           task.Completed,
+				"task_order":          task.TaskOrder,
+				"color":               task.Color,
+				"description":         task.Description,
+				"recurrence_rule":     task.RecurrenceRule,
+				"recurrence_interval": task.RecurrenceInterval,
+				"due_time":            task.DueTime,
+				"duration_minutes":    task.DurationMinutes,
+				"remind_at":           task.RemindAt,
+				"tags":                task.Tags,
+				"list_id":             task.ListID,
+				"updated_at":          task.UpdatedAt,
+			}).Error
+			if err == nil {
+				err = setTaskTags(tx, local.ID, task.Tags)
+			}
+			if err != nil {
+				return fmt.Errorf("task %s: %w", task.UID, err)
+			}
+			result.Updated++
+		}
+		slog.Info("Import conflict", "uid", task.UID, "resolution", conflict.Resolution)
+		result.Conflicts = append(result.Conflicts, conflict)
+	}
+
+	for _, setting := range settings {
+		var local Setting
+		err := tx.Where("key = ?", setting.Key).First(&local).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := tx.Create(&Setting{Key: setting.Key, Value: setting.Value}).Error; err != nil {
+				return fmt.Errorf("setting %q: %w", setting.Key, err)
+			}
+			continue
+		} else if err != nil {
+			return fmt.Errorf("setting %q: %w", setting.Key, err)
+		}
+		if local.Value != setting.Value {
+			result.Conflicts = append(result.Conflicts, ImportConflict{Kind: "setting", Key: setting.Key, Resolution: "kept_local"})
+		}
+	}
+	return nil
+}
+
+// linkSubtasks sets the parent of imported subtasks from their parent_uid.
+// Subtasks whose parent is unknown or itself a subtask stay top-level tasks.
+// tasks are the imported tasks, in the order of the document's.
+func linkSubtasks(tx *gorm.DB, tasks []Task, docTasks []DocumentTask) error {
+	for i, dt := range docTasks {
+		if dt.ParentUID == "" {
+			continue
+		}
+		uid := tasks[i].UID
+		var parent Task
+		err := tx.Where("uid = ? AND parent_id IS NULL", dt.ParentUID).First(&parent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Warn("Import: parent of subtask not found", "uid", uid, "parent_uid", dt.ParentUID)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("task %s: %w", uid, err)
+		}
+		err = tx.Model(&Task{}).Where("uid = ? AND id <> ?", uid, parent.ID).
+			UpdateColumns(map[string]interface{}{"parent_id": parent.ID, "list_id": nil}).Error
+		if err != nil {
+			return fmt.Errorf("task %s: %w", uid, err)
+		}
+	}
+	return nil
+}
+
+// importSeries creates the series of a document, with their exceptions.
+// Unless replacing, a series that exists locally is only updated when the
+// document's copy is more recent. docSeries are in the order of series.
+func importSeries(tx *gorm.DB, series []Series, docSeries []DocumentSeries, replacing bool) error {
+	for i := range series {
+		incoming := &series[i]
+		var local Series
+		err := tx.Where("uid = ?", incoming.UID).First(&local).Error
+		if err == nil && (replacing || !incoming.UpdatedAt.After(local.UpdatedAt)) {
+			continue
+		} else if err == nil {
+			incoming.ID = local.ID
+			// UpdateColumns keeps the imported updated_at instead of "now".
+			err = tx.Model(&Series{}).Where("id = ?", local.ID).UpdateColumns(map[string]interface{}{
+				"title":               incoming.Title,
+				"description":         incoming.Description,
+				"color":               incoming.Color,
+				"recurrence_rule":     incoming.RecurrenceRule,
+				"recurrence_interval": incoming.RecurrenceInterval,
+				"start_date":          incoming.StartDate,
+				"due_time":            incoming.DueTime,
+				"duration_minutes":    incoming.DurationMinutes,
+				"remind_at":           incoming.RemindAt,
+				"updated_at":          incoming.UpdatedAt,
+			}).Error
+			if err == nil {
+				err = tx.Where("series_id = ?", local.ID).Delete(&SeriesException{}).Error
+			}
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = tx.Create(incoming).Error
+		}
+		if err != nil {
+			return fmt.Errorf("series %s: %w", incoming.UID, err)
+		}
+		for _, de := range docSeries[i].Exceptions {
+			exception, err := fromDocumentException(de)
+			if err != nil {
+				return NewAPIError(400, fmt.Sprintf("Invalid exception of series %s in document: %v", incoming.UID, err))
+			}
+			exception.SeriesID = incoming.ID
+			if err := (sqlTx{db: tx}).saveException(exception); err != nil {
+				return fmt.Errorf("series %s: %w", incoming.UID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// linkSeries sets the series of imported occurrences from their series_uid.
+// An occurrence whose series is unknown, or whose date the series already has
+// a task for, is left alone (and given a series of its own by attachSeries).
+// tasks are the imported tasks, in the order of the document's.
+func linkSeries(tx *gorm.DB, tasks []Task, docTasks []DocumentTask) error {
+	for i, dt := range docTasks {
+		if dt.SeriesUID == "" || dt.ParentUID != "" {
+			continue
+		}
+		uid := tasks[i].UID
+		date, err := time.Parse(config.DateFormat, dt.OccurrenceDate)
+		if err != nil {
+			slog.Warn("Import: occurrence without a valid occurrence_date", "uid", uid, "occurrence_date", dt.OccurrenceDate)
+			continue
+		}
+		var series Series
+		err = tx.Where("uid = ?", dt.SeriesUID).First(&series).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Warn("Import: series of occurrence not found", "uid", uid, "series_uid", dt.SeriesUID)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("task %s: %w", uid, err)
+		}
+		var taken int64
+		err = tx.Model(&Task{}).Where("series_id = ? AND DATE(occurrence_date) = ? AND uid <> ?", series.ID, dt.OccurrenceDate, uid).
+			Count(&taken).Error
+		if err != nil {
+			return fmt.Errorf("task %s: %w", uid, err)
+		}
+		if taken > 0 {
+			slog.Warn("Import: series already has an occurrence on that date", "uid", uid, "series_uid", dt.SeriesUID, "occurrence_date", dt.OccurrenceDate)
+			continue
+		}
+		columns := map[string]interface{}{
+			"series_id":       series.ID,
+			"occurrence_date": NullTime{Time: date, Valid: true},
+		}
+		if dt.SpawnedFromUID != "" {
+			// Keep the occurrence from spawning a second next occurrence.
+			var from Task
+			err := tx.Where("uid = ? AND id NOT IN (SELECT spawned_from FROM tasks WHERE spawned_from IS NOT NULL AND uid <> ?)", dt.SpawnedFromUID, uid).
+				First(&from).Error
+			if err == nil {
+				columns["spawned_from"] = from.ID
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("task %s: %w", uid, err)
+			}
+		}
+		err = tx.Model(&Task{}).Where("uid = ?", uid).UpdateColumns(columns).Error
+		if err != nil {
+			return fmt.Errorf("task %s: %w", uid, err)
+		}
+	}
+	return nil
+}
+
+// attachSeries makes each dated top-level recurring task without a series
+// the first occurrence of a series of its own, as the migration to series
+// did. Documents from before series have only such tasks.
+func attachSeries(tx *gorm.DB) error {
+	var tasks Tasks
+	err := tx.Where("recurrence_rule <> '' AND due_date IS NOT NULL AND parent_id IS NULL AND series_id IS NULL").
+		Order("id").Find(&tasks).Error
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		if err := attachToSeries(sqlTx{db: tx}, &tasks[i]); err != nil {
+			return fmt.Errorf("task %s: %w", tasks[i].UID, err)
+		}
+	}
+	return nil
+}
+
+// createImported inserts an imported task. GORM only fills updated_at when it
+// is zero, so the imported modification time is kept.
+// Tasks without a list go to the default one, until linkSubtasks finds
+// their parent.
+func createImported(tx *gorm.DB, task *Task) error {
+	if task.ListID == nil {
+		inbox, err := findList(tx, 0)
+		if err != nil {
+			return err
+		}
+		task.ListID = &inbox.ID
+	}
+	if err := tx.Create(task).Error; err != nil {
+		return fmt.Errorf("task %s: %w", task.UID, err)
+	}
+	if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+		return fmt.Errorf("task %s: %w", task.UID, err)
+	}
+	return nil
+}
+
+// sameTaskContent compares the user-visible fields of two tasks.
+func sameTaskContent(a, b Task) bool {
+	sameDate := a.DueDate.Valid == b.DueDate.Valid &&
+		(!a.DueDate.Valid || a.DueDate.Time.Format(config.DateFormat) == b.DueDate.Time.Format(config.DateFormat))
+	return sameDate &&
+		a.Title == b.Title &&
+		a.Completed == b.Completed &&
+		a.TaskOrder == b.TaskOrder &&
+		a.Color == b.Color &&
+		a.Description == b.Description &&
+		a.RecurrenceRule == b.RecurrenceRule &&
+		a.RecurrenceInterval == b.RecurrenceInterval &&
+		a.DueTime == b.DueTime &&
+		a.DurationMinutes == b.DurationMinutes &&
+		a.RemindAt == b.RemindAt &&
+		slices.Equal(a.Tags, b.Tags) &&
+		(a.ListID == nil) == (b.ListID == nil) && (a.ListID == nil || *a.ListID == *b.ListID)
+}
+
+func toDocumentTask(task Task) DocumentTask {
+	dt := DocumentTask{
+		UID:                task.UID,
+		Title:              task.Title,
+		Completed:          task.Completed == 1,
+		Order:              task.TaskOrder,
+		Color:              task.Color,
+		Description:        task.Description,
+		RecurrenceRule:     task.RecurrenceRule,
+		RecurrenceInterval: task.RecurrenceInterval,
+		DueTime:            task.DueTime,
+		DurationMinutes:    task.DurationMinutes,
+		RemindAt:           task.RemindAt,
+		Tags:               task.Tags,
+		UpdatedAt:          task.UpdatedAt.UTC(),
+	}
+	if task.DueDate.Valid {
+		dt.DueDate = task.DueDate.Time.Format(config.DateFormat)
+	}
+	if task.OccurrenceDate.Valid {
+		dt.OccurrenceDate = task.OccurrenceDate.Time.Format(config.DateFormat)
+	}
+	return dt
+}
+
+func fromDocumentTask(dt DocumentTask) (Task, error) {
+	task := Task{
+		UID:                dt.UID,
+		Title:              dt.Title,
+		TaskOrder:          dt.Order,
+		Color:              dt.Color,
+		Description:        dt.Description,
+		RecurrenceRule:     dt.RecurrenceRule,
+		RecurrenceInterval: dt.RecurrenceInterval,
+		DueTime:            dt.DueTime,
+		DurationMinutes:    dt.DurationMinutes,
+		RemindAt:           dt.RemindAt,
+		Tags:               dt.Tags,
+		UpdatedAt:          dt.UpdatedAt,
+	}
+	if task.UID == "" {
+		task.UID = NewUID()
+	}
+	if dt.Completed {
+		task.Completed = 1
+	}
+	if dt.DueDate != "" {
+		date, err := time.Parse(config.DateFormat, dt.DueDate)
+		if err != nil {
+			return Task{}, fmt.Errorf("invalid due_date %q", dt.DueDate)
+		}
+		task.DueDate = NullTime{Time: date, Valid: true}
+	}
+	if !IsValidRecurrenceRule(task.RecurrenceRule) {
+		return Task{}, fmt.Errorf("invalid recurrence_rule %q", task.RecurrenceRule)
+	}
+	if task.RecurrenceInterval <= 0 {
+		task.RecurrenceInterval = 1
+	}
+	if err := task.Validate(); err != nil {
+		return Task{}, err
+	}
+	return task, nil
+}
+
+func toDocumentSeries(series Series) DocumentSeries {
+	return DocumentSeries{
+		UID:                series.UID,
+		Title:              series.Title,
+		Description:        series.Description,
+		Color:              series.Color,
+		RecurrenceRule:     series.RecurrenceRule,
+		RecurrenceInterval: series.RecurrenceInterval,
+		StartDate:          series.StartDate.Format(config.DateFormat),
+		DueTime:            series.DueTime,
+		DurationMinutes:    series.DurationMinutes,
+		RemindAt:           series.RemindAt,
+		UpdatedAt:          series.UpdatedAt.UTC(),
+	}
+}
+
+func fromDocumentSeries(ds DocumentSeries) (Series, error) {
+	series := Series{
+		UID:                ds.UID,
+		Title:              ds.Title,
+		Description:        ds.Description,
+		Color:              ds.Color,
+		RecurrenceRule:     ds.RecurrenceRule,
+		RecurrenceInterval: ds.RecurrenceInterval,
+		DueTime:            ds.DueTime,
+		DurationMinutes:    ds.DurationMinutes,
+		RemindAt:           ds.RemindAt,
+		UpdatedAt:          ds.UpdatedAt,
+	}
+	if series.UID == "" {
+		return Series{}, fmt.Errorf("missing uid")
+	}
+	if series.Title == "" {
+		return Series{}, fmt.Errorf("missing title")
+	}
+	start, err := time.Parse(config.DateFormat, ds.StartDate)
+	if err != nil {
+		return Series{}, fmt.Errorf("invalid start_date %q", ds.StartDate)
+	}
+	series.StartDate = start
+	if series.RecurrenceInterval <= 0 {
+		series.RecurrenceInterval = 1
+	}
+	if series.RecurrenceRule == "" {
+		return Series{}, fmt.Errorf("missing recurrence_rule")
+	}
+	if series.RecurrenceRule, err = NormalizeRecurrenceRule(series.RecurrenceRule); err != nil {
+		return Series{}, fmt.Errorf("invalid recurrence_rule %q", ds.RecurrenceRule)
+	}
+	if series.DueTime, err = normalizeDueTime(series.DueTime); err != nil {
+		return Series{}, fmt.Errorf("invalid due_time %q", ds.DueTime)
+	}
+	if series.RemindAt, err = normalizeRemindAt(series.RemindAt); err != nil {
+		return Series{}, fmt.Errorf("invalid remind_at %q", ds.RemindAt)
+	}
+	return series, nil
+}
+
+func toDocumentException(e SeriesException) DocumentException {
+	de := DocumentException{
+		OccurrenceDate: e.OccurrenceDate.Format(config.DateFormat),
+		Skip:           e.Skip,
+		Title:          e.Title,
+	}
+	if e.DueDate.Valid {
+		de.DueDate = e.DueDate.Time.Format(config.DateFormat)
+	}
+	return de
+}
+
+func fromDocumentException(de DocumentException) (SeriesException, error) {
+	date, err := time.Parse(config.DateFormat, de.OccurrenceDate)
+	if err != nil {
+		return SeriesException{}, fmt.Errorf("invalid occurrence_date %q", de.OccurrenceDate)
+	}
+	e := SeriesException{OccurrenceDate: date, Skip: de.Skip, Title: de.Title}
+	if de.DueDate != "" {
+		due, err := time.Parse(config.DateFormat, de.DueDate)
+		if err != nil {
+			return SeriesException{}, fmt.Errorf("invalid due_date %q", de.DueDate)
+		}
+		e.DueDate = NullTime{Time: due, Valid: true}
+	}
+	return e, nil
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// maxListTitleLength is the longest list title accepted, in characters.
+const maxListTitleLength = 100
+
+// List is a named list of tasks, e.g. "Someday", "Waiting for" or one per
+// client. Every top-level task belongs to a list, whether dated or not, and
+// subtasks to the list of their task. The default list is the inbox: new
+// tasks go there unless given another, and it cannot be deleted.
+type List struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title     string    `gorm:"not null" json:"title"`
+	Color     string    `json:"color"`
+	ListOrder int       `json:"order"`
+	IsDefault bool      `json:"default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TaskCount int `gorm:"->;-:migration" json:"task_count"` // Open tasks, filled by GetLists and GetList.
+}
+
+// ListStore keeps the lists tasks are filed in. Tasks are moved between lists
+// through TaskStore, with their "list_id" field. *Store and MemoryStore
+// implement it.
+type ListStore interface {
+	// GetLists returns all lists by order, with their number of open tasks.
+	GetLists() ([]List, error)
+	GetList(id int) (List, error) // 404 APIError if there is no such list.
+	CreateList(list List) (List, error)
+	// UpdateList sets the title, color and order of a list.
+	UpdateList(list List) (List, error)
+	// DeleteList deletes a list, moving its tasks to the default list; 400
+	// APIError for the default list.
+	DeleteList(id int) error
+}
+
+var (
+	_ ListStore = (*Store)(nil)
+	_ ListStore = (*MemoryStore)(nil)
+)
+
+// validateList trims the title and color of list.
+func validateList(list *List) error {
+	list.Title = strings.TrimSpace(list.Title)
+	if list.Title == "" {
+		return NewAPIError(400, "List title is required")
+	}
+	if utf8.RuneCountInString(list.Title) > maxListTitleLength {
+		return NewAPIError(400, fmt.Sprintf("List title too long (at most %d characters)", maxListTitleLength))
+	}
+	list.Color = strings.TrimSpace(list.Color)
+	return nil
+}
+
+// assignList puts a new top-level task in the default list unless it names
+// another one, which must exist. Subtasks are in no list of their own.
+func assignList(tx taskTx, task *Task) error {
+	if task.ParentID != nil {
+		task.ListID = nil
+		return nil
+	}
+	id := 0
+	if task.ListID != nil {
+		id = *task.ListID
+	}
+	list, err := tx.list(id)
+	if err != nil {
+		return err
+	}
+	task.ListID = &list.ID
+	return nil
+}
+
+// withOpenTaskCount selects lists with the number of their open tasks.
+func withOpenTaskCount(db *gorm.DB) *gorm.DB {
+	return db.Select("lists.*, (SELECT count(*) FROM tasks WHERE tasks.list_id = lists.id AND tasks.completed = 0) AS task_count")
+}
+
+// findList returns the list with the given ID, or the default list for 0.
+// An unknown ID is a 400 APIError: it comes from a task being created or
+// moved.
+func findList(tx *gorm.DB, id int) (List, error) {
+	var list List
+	query := tx.Model(&List{})
+	if id == 0 {
+		query = query.Where("is_default = ?", true)
+	} else {
+		query = query.Where("id = ?", id)
+	}
+	err := query.First(&list).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if id == 0 {
+			return List{}, errors.New("no default list")
+		}
+		return List{}, NewAPIError(400, fmt.Sprintf("Unknown list %d", id))
+	}
+	return list, err
+}
+
+// GetLists implements ListStore.
+func (s *Store) GetLists() ([]List, error) {
+	lists := []List{}
+	if err := s.DB().Scopes(withOpenTaskCount).Order("list_order, id").Find(&lists).Error; err != nil {
+		return nil, fmt.Errorf("getLists: %w", err)
+	}
+	return lists, nil
+}
+
+// GetList implements ListStore.
+func (s *Store) GetList(id int) (List, error) {
+	var list List
+	err := s.DB().Scopes(withOpenTaskCount).First(&list, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return List{}, NewAPIError(404, "List not found")
+	}
+	if err != nil {
+		return List{}, fmt.Errorf("getList: %w", err)
+	}
+	return list, nil
+}
+
+// CreateList implements ListStore.
+func (s *Store) CreateList(list List) (List, error) {
+	if err := validateList(&list); err != nil {
+		return List{}, err
+	}
+	list.ID, list.IsDefault, list.TaskCount = 0, false, 0
+	if err := s.DB().Create(&list).Error; err != nil {
+		return List{}, fmt.Errorf("createList: %w", err)
+	}
+	return list, nil
+}
+
+// UpdateList implements ListStore.
+func (s *Store) UpdateList(list List) (List, error) {
+	if err := validateList(&list); err != nil {
+		return List{}, err
+	}
+	result := s.DB().Model(&List{}).Where("id = ?", list.ID).
+		Updates(map[string]interface{}{"title": list.Title, "color": list.Color, "list_order": list.ListOrder})
+	if result.Error != nil {
+		return List{}, fmt.Errorf("updateList: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return List{}, NewAPIError(404, "List not found")
+	}
+	return s.GetList(list.ID)
+}
+
+// DeleteList implements ListStore.
+func (s *Store) DeleteList(id int) error {
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		var list List
+		err := tx.First(&list, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return NewAPIError(404, "List not found")
+		} else if err != nil {
+			return err
+		}
+		if list.IsDefault {
+			return NewAPIError(400, "The default list cannot be deleted")
+		}
+		inbox, err := findList(tx, 0)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&Task{}).Where("list_id = ?", id).Update("list_id", inbox.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&List{}, id).Error
+	})
+	return txError("deleteList", err)
+}
+
+// GetLists implements ListStore.
+func (m *MemoryStore) GetLists() ([]List, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lists := make([]List, len(m.lists))
+	for i, list := range m.lists {
+		lists[i] = m.withOpenTaskCount(list)
+	}
+	sort.SliceStable(lists, func(i, j int) bool { return lists[i].ListOrder < lists[j].ListOrder })
+	return lists, nil
+}
+
+// GetList implements ListStore.
+func (m *MemoryStore) GetList(id int) (List, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, list := range m.lists {
+		if list.ID == id {
+			return m.withOpenTaskCount(list), nil
+		}
+	}
+	return List{}, NewAPIError(404, "List not found")
+}
+
+// CreateList implements ListStore.
+func (m *MemoryStore) CreateList(list List) (List, error) {
+	if err := validateList(&list); err != nil {
+		return List{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastListID++
+	list.ID, list.IsDefault, list.TaskCount = m.lastListID, false, 0
+	list.CreatedAt = m.clock.Now()
+	list.UpdatedAt = list.CreatedAt
+	m.lists = append(m.lists, list)
+	return list, nil
+}
+
+// UpdateList implements ListStore.
+func (m *MemoryStore) UpdateList(list List) (List, error) {
+	if err := validateList(&list); err != nil {
+		return List{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.lists, func(l List) bool { return l.ID == list.ID })
+	if i < 0 {
+		return List{}, NewAPIError(404, "List not found")
+	}
+	m.lists[i].Title, m.lists[i].Color, m.lists[i].ListOrder = list.Title, list.Color, list.ListOrder
+	m.lists[i].UpdatedAt = m.clock.Now()
+	return m.withOpenTaskCount(m.lists[i]), nil
+}
+
+// DeleteList implements ListStore.
+func (m *MemoryStore) DeleteList(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.lists, func(l List) bool { return l.ID == id })
+	if i < 0 {
+		return NewAPIError(404, "List not found")
+	}
+	if m.lists[i].IsDefault {
+		return NewAPIError(400, "The default list cannot be deleted")
+	}
+	inbox := m.defaultList().ID
+	for taskID, task := range m.tasks {
+		if task.ListID != nil && *task.ListID == id {
+			task.ListID = &inbox
+			task.UpdatedAt = m.clock.Now()
+			m.tasks[taskID] = task
+		}
+	}
+	m.lists = slices.Delete(m.lists, i, i+1)
+	return nil
+}
+
+// defaultList returns the default list. Must be called with mu held.
+func (m *MemoryStore) defaultList() *List {
+	for i := range m.lists {
+		if m.lists[i].IsDefault {
+			return &m.lists[i]
+		}
+	}
+	panic("memory store without a default list")
+}
+
+// withOpenTaskCount fills the task count of list. Must be called with mu held.
+func (m *MemoryStore) withOpenTaskCount(list List) List {
+	list.TaskCount = 0
+	for _, task := range m.tasks {
+		if task.ListID != nil && *task.ListID == list.ID && task.Completed == 0 {
+			list.TaskCount++
+		}
+	}
+	return list
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLists(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		lists, err := store.GetLists()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lists) != 1 || !lists[0].IsDefault {
+			t.Fatalf("lists = %+v, want only the default list", lists)
+		}
+		inbox := lists[0]
+		if err := store.UpdateInboxTitle("Later"); err != nil {
+			t.Fatal(err)
+		}
+		if list, _ := store.GetList(inbox.ID); list.Title != "Later" {
+			t.Errorf("default list title = %q, want the inbox title", list.Title)
+		}
+
+		someday, err := store.CreateList(List{Title: " Someday ", Color: "blue"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if someday.Title != "Someday" || someday.IsDefault {
+			t.Errorf("created list = %+v", someday)
+		}
+		if _, err := store.CreateList(List{Title: "  "}); err == nil {
+			t.Error("CreateList accepted an empty title")
+		}
+
+		undated := mustCreate(t, store, Task{Title: "Learn Go"})
+		if undated.ListID == nil || *undated.ListID != inbox.ID {
+			t.Fatalf("new task list = %v, want the default list %d", undated.ListID, inbox.ID)
+		}
+		mustCreate(t, store, Task{Title: "Read a book", ListID: &someday.ID})
+		mustCreate(t, store, Task{Title: "Book trip", ListID: &someday.ID, DueDate: NullTime{Time: mustDate(t, "2026-10-20"), Valid: true}})
+		if _, err := store.CreateTask(Task{Title: "Lost", ListID: &[]int{99}[0]}); err == nil {
+			t.Error("CreateTask accepted an unknown list")
+		}
+
+		filtered := func(filter TaskFilter) []string {
+			t.Helper()
+			tasks, err := store.GetTasks(filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return titles(tasks)
+		}
+		if got := filtered(TaskFilter{Date: "inbox"}); !slices.Equal(got, []string{"Learn Go"}) {
+			t.Errorf("inbox = %v", got)
+		}
+		if got := filtered(TaskFilter{ListID: someday.ID}); !slices.Equal(got, []string{"Read a book", "Book trip"}) {
+			t.Errorf("tasks of the list = %v", got)
+		}
+		if got := filtered(TaskFilter{Date: "inbox", ListID: someday.ID}); !slices.Equal(got, []string{"Read a book"}) {
+			t.Errorf("undated tasks of the list = %v", got)
+		}
+		if _, err := store.GetTasks(TaskFilter{ListID: 99}); err == nil {
+			t.Error("GetTasks accepted an unknown list")
+		}
+
+		// Tasks move between lists; subtasks stay in the list of their task.
+		if err := store.UpdateTask(undated.ID, map[string]interface{}{"list_id": float64(someday.ID)}, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateTask(undated.ID, map[string]interface{}{"list_id": 99.0}, ""); err == nil {
+			t.Error("UpdateTask accepted an unknown list")
+		}
+		sub, err := store.CreateSubtask(undated.ID, Task{Title: "Install it"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub.ListID != nil {
+			t.Errorf("subtask list = %d, want none", *sub.ListID)
+		}
+		if err := store.UpdateTask(sub.ID, map[string]interface{}{"list_id": float64(inbox.ID)}, ""); err == nil {
+			t.Error("UpdateTask moved a subtask to a list")
+		}
+		if list, _ := store.GetList(someday.ID); list.TaskCount != 3 {
+			t.Errorf("open tasks of the list = %d, want 3", list.TaskCount)
+		}
+
+		renamed, err := store.UpdateList(List{ID: someday.ID, Title: "Someday/maybe", ListOrder: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if renamed.Title != "Someday/maybe" || renamed.Color != "" || renamed.ListOrder != 2 {
+			t.Errorf("updated list = %+v", renamed)
+		}
+
+		// Deleting a list moves its tasks to the default one, which stays.
+		if err := store.DeleteList(inbox.ID); err == nil {
+			t.Error("DeleteList deleted the default list")
+		}
+		if err := store.DeleteList(someday.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.GetList(someday.ID); err == nil {
+			t.Error("the list is still there after DeleteList")
+		}
+		if got := filtered(TaskFilter{Date: "inbox"}); !slices.Equal(got, []string{"Learn Go", "Read a book"}) {
+			t.Errorf("inbox after deleting the list = %v", got)
+		}
+	})
+}
+
+func TestRecurringTaskKeepsList(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		client, err := store.CreateList(List{Title: "Client A"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		task := mustCreate(t, store, Task{
+			Title:          "Send invoice",
+			DueDate:        NullTime{Time: mustDate(t, "2026-10-18"), Valid: true},
+			RecurrenceRule: "weekly",
+			ListID:         &client.ID,
+		})
+		if err := store.UpdateTask(task.ID, map[string]interface{}{"completed": true}, ""); err != nil {
+			t.Fatal(err)
+		}
+		tasks, err := store.GetTasks(TaskFilter{Date: "2026-10-25", ListID: client.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 1 {
+			t.Errorf("next occurrence in the list = %+v, want one", tasks)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// OptimizeFTS merges the segments of the full-text search index, which
+// grows a segment with every write, keeping searches fast.
+func (s *Store) OptimizeFTS() error {
+	if err := s.DB().Exec("INSERT INTO tasks_fts(tasks_fts) VALUES ('optimize')").Error; err != nil {
+		return fmt.Errorf("optimize search index: %w", err)
+	}
+	return nil
+}
+
+// Checkpoint copies the pages of the write-ahead log into the database file
+// without waiting for readers or writers, so the WAL does not keep growing
+// while the server runs.
+func (s *Store) Checkpoint() error {
+	return walCheckpoint(s.DB(), "PASSIVE")
+}
+
+// walCheckpoint runs a checkpoint in mode, one of PASSIVE, FULL, RESTART
+// and TRUNCATE.
+func walCheckpoint(db *gorm.DB, mode string) error {
+	var busy, logPages, checkpointed int
+	row := db.Raw("PRAGMA wal_checkpoint(" + mode + ")").Row()
+	if err := row.Scan(&busy, &logPages, &checkpointed); err != nil {
+		return fmt.Errorf("wal checkpoint: %w", err)
+	}
+	slog.Debug("WAL checkpoint", "mode", mode, "busy", busy != 0, "log_pages", logPages, "checkpointed", checkpointed)
+	if busy != 0 && mode == "TRUNCATE" {
+		return errors.New("wal checkpoint: database is busy")
+	}
+	return nil
+}
@@ -0,0 +1,857 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"week-planner/internal/config"
+)
+
+// MemoryStore is a TaskStore and SettingsStore that keeps everything in
+// memory. It behaves like the SQLite store, except that search matches
+// substrings instead of using FTS5.
+type MemoryStore struct {
+	mu              sync.Mutex
+	tasks           map[int]Task
+	lastID          int
+	series          map[int]Series
+	exceptions      map[int][]SeriesException // By series ID.
+	lastSeriesID    int
+	lastExceptionID int
+	reminders       []ReminderDelivery
+	webhooks        []Webhook
+	lastWebhookID   int
+	deliveries      []WebhookDelivery
+	tags            []Tag
+	lastTagID       int
+	lists           []List
+	lastListID      int
+	clock           Clock
+	observers       []func(occurrence Task)
+}
+
+// NewMemoryStore returns an empty store with the default settings and an
+// empty inbox, the default list.
+func NewMemoryStore() *MemoryStore {
+	now := SystemClock.Now()
+	return &MemoryStore{
+		tasks:      map[int]Task{},
+		series:     map[int]Series{},
+		exceptions: map[int][]SeriesException{},
+		lists:      []List{{ID: 1, Title: "📦 Inbox", IsDefault: true, CreatedAt: now, UpdatedAt: now}},
+		lastListID: 1,
+		clock:      SystemClock,
+	}
+}
+
+// SetClock makes the store read the time from clock, for updated_at.
+func (m *MemoryStore) SetClock(clock Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = clock
+}
+
+// GetTasks implements TaskStore.
+func (m *MemoryStore) GetTasks(filter TaskFilter) (Tasks, error) {
+	if err := checkTaskFilter(filter); err != nil {
+		return Tasks{}, err
+	}
+	tagFilter, err := NormalizeTags(filter.Tags)
+	if err != nil {
+		return Tasks{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	date, startDate, endDate := filter.Date, filter.StartDate, filter.EndDate
+	listID := filter.ListID
+	if listID != 0 {
+		if !slices.ContainsFunc(m.lists, func(l List) bool { return l.ID == listID }) {
+			return Tasks{}, NewAPIError(404, "List not found")
+		}
+	} else if date == "inbox" {
+		listID = m.defaultList().ID
+	}
+	tasks := Tasks{}
+	for _, task := range m.sorted() {
+		if task.ParentID != nil || !hasTags(task, tagFilter) {
+			continue
+		}
+		if listID != 0 && (task.ListID == nil || *task.ListID != listID) {
+			continue
+		}
+		due := ""
+		if task.DueDate.Valid {
+			due = task.DueDate.Time.Format(config.DateFormat)
+		}
+		switch {
+		case date == "inbox":
+			if task.DueDate.Valid {
+				continue
+			}
+		case startDate != "" && endDate != "":
+			if due == "" || due < startDate || due > endDate {
+				continue
+			}
+		case date != "":
+			if due != date {
+				continue
+			}
+		}
+		tasks = append(tasks, m.withCounts(task))
+	}
+	return tasks, nil
+}
+
+// GetTask implements TaskStore.
+func (m *MemoryStore) GetTask(id int) (Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	task, ok := m.tasks[id]
+	if !ok {
+		return Task{}, NewAPIError(404, "Task not found")
+	}
+	return m.withCounts(task), nil
+}
+
+// CreateTask implements TaskStore.
+func (m *MemoryStore) CreateTask(task Task) (Task, error) {
+	if err := task.Validate(); err != nil {
+		return Task{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := createTask(memoryTx{m: m}, &task)
+	return task, err
+}
+
+func (m *MemoryStore) create(task Task) Task {
+	m.lastID++
+	task.ID = m.lastID
+	if task.UID == "" {
+		task.UID = NewUID()
+	}
+	if task.UpdatedAt.IsZero() {
+		task.UpdatedAt = m.clock.Now()
+	}
+	m.tasks[task.ID] = task
+	m.addTags(task.Tags)
+	return task
+}
+
+// UpdateTask implements TaskStore.
+func (m *MemoryStore) UpdateTask(id int, updates map[string]interface{}, scope Scope) error {
+	if err := checkTaskUpdates(updates); err != nil {
+		return err
+	}
+	return m.taskTransaction(func(tx memoryTx) error {
+		return updateTask(tx, id, updates, scope)
+	})
+}
+
+// BulkUpdateTaskOrder implements TaskStore. Unknown IDs are ignored, as with SQLite.
+func (m *MemoryStore) BulkUpdateTaskOrder(tasks []Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, update := range tasks {
+		if task, ok := m.tasks[update.ID]; ok {
+			task.TaskOrder = update.TaskOrder
+			task.UpdatedAt = m.clock.Now()
+			m.tasks[update.ID] = task
+		}
+	}
+	return nil
+}
+
+// DeleteTask implements TaskStore.
+func (m *MemoryStore) DeleteTask(id int, scope Scope) error {
+	return m.taskTransaction(func(tx memoryTx) error {
+		return deleteTask(tx, id, scope)
+	})
+}
+
+// GetSubtasks implements TaskStore.
+func (m *MemoryStore) GetSubtasks(parentID int) (Tasks, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tasks[parentID]; !ok {
+		return nil, NewAPIError(404, "Task not found")
+	}
+	return m.subtasks(parentID), nil
+}
+
+// CreateSubtask implements TaskStore.
+func (m *MemoryStore) CreateSubtask(parentID int, task Task) (Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent, ok := m.tasks[parentID]
+	if !ok {
+		return Task{}, NewAPIError(404, "Task not found")
+	}
+	if parent.ParentID != nil {
+		return Task{}, NewAPIError(400, "Subtasks cannot have subtasks")
+	}
+	prepareSubtask(&task, parentID)
+	if err := task.Validate(); err != nil {
+		return Task{}, err
+	}
+	if existing := m.subtasks(parentID); len(existing) > 0 {
+		task.TaskOrder = existing[len(existing)-1].TaskOrder + 1
+	}
+	created := m.create(task)
+	m.rollUpCompletion(parentID)
+	return created, nil
+}
+
+// SearchTasks implements TaskStore. A task matches when every word of query
+// occurs in its title, description or tags, ignoring case, and it has the
+// tags of the "tag:name" terms. Title prefix matches come first, then tasks
+// due closer to today.
+func (m *MemoryStore) SearchTasks(query string, today time.Time, limit int, offset int) (Tasks, error) {
+	query, tags := parseSearchQuery(query)
+	terms := strings.Fields(strings.ToLower(query))
+	lowerQuery := strings.ToLower(query)
+	m.mu.Lock()
+	var matches Tasks
+	for _, task := range m.sorted() {
+		task = m.withCounts(task)
+		text := strings.ToLower(task.Title + " " + task.Description + " " + strings.Join(task.Tags, " "))
+		matched := (len(terms) > 0 || len(tags) > 0) && hasTags(task, tags)
+		for _, term := range terms {
+			if !strings.Contains(text, term) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, task)
+		}
+	}
+	m.mu.Unlock()
+
+	today = dateOnly(today)
+	proximity := func(task Task) float64 {
+		if !task.DueDate.Valid {
+			return math.Inf(1)
+		}
+		return math.Abs(today.Sub(dateOnly(task.DueDate.Time)).Hours())
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		pi := strings.HasPrefix(strings.ToLower(matches[i].Title), lowerQuery)
+		pj := strings.HasPrefix(strings.ToLower(matches[j].Title), lowerQuery)
+		if pi != pj {
+			return pi
+		}
+		return proximity(matches[i]) < proximity(matches[j])
+	})
+
+	if offset >= len(matches) {
+		return Tasks{}, nil
+	}
+	matches = matches[offset:]
+	if limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// DeliverReminders implements ReminderStore.
+func (m *MemoryStore) DeliverReminders(since, now time.Time, loc *time.Location) ([]ReminderDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var delivered []ReminderDelivery
+	for _, d := range dueReminders(m.sorted(), since, now, loc) {
+		if m.delivered(d) {
+			continue
+		}
+		d.ID = len(m.reminders) + 1
+		m.reminders = append(m.reminders, d)
+		delivered = append(delivered, d)
+	}
+	return delivered, nil
+}
+
+// delivered reports whether the reminder d was delivered. Must be called with mu held.
+func (m *MemoryStore) delivered(d ReminderDelivery) bool {
+	for _, r := range m.reminders {
+		if r.TaskID == d.TaskID && r.RemindAt.Equal(d.RemindAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetReminders implements ReminderStore.
+func (m *MemoryStore) GetReminders(afterID int, limit int) ([]ReminderDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reminders := []ReminderDelivery{}
+	for _, r := range m.reminders {
+		if r.ID > afterID && len(reminders) < limit {
+			reminders = append(reminders, r)
+		}
+	}
+	return reminders, nil
+}
+
+// GetWebhooks implements WebhookStore.
+func (m *MemoryStore) GetWebhooks() ([]Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Webhook{}, m.webhooks...), nil
+}
+
+// GetWebhook implements WebhookStore.
+func (m *MemoryStore) GetWebhook(id int) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, webhook := range m.webhooks {
+		if webhook.ID == id {
+			return webhook, nil
+		}
+	}
+	return Webhook{}, NewAPIError(404, "Webhook not found")
+}
+
+// CreateWebhook implements WebhookStore.
+func (m *MemoryStore) CreateWebhook(webhook Webhook) (Webhook, error) {
+	if err := webhook.Validate(); err != nil {
+		return Webhook{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastWebhookID++
+	webhook.ID = m.lastWebhookID
+	webhook.CreatedAt = m.clock.Now()
+	m.webhooks = append(m.webhooks, webhook)
+	return webhook, nil
+}
+
+// DeleteWebhook implements WebhookStore.
+func (m *MemoryStore) DeleteWebhook(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.webhooks)
+	m.webhooks = slices.DeleteFunc(m.webhooks, func(w Webhook) bool { return w.ID == id })
+	if len(m.webhooks) == n {
+		return NewAPIError(404, "Webhook not found")
+	}
+	m.deliveries = slices.DeleteFunc(m.deliveries, func(d WebhookDelivery) bool { return d.WebhookID == id })
+	return nil
+}
+
+// EnqueueWebhookDeliveries implements WebhookStore.
+func (m *MemoryStore) EnqueueWebhookDeliveries(event string, payload []byte, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queued := 0
+	for _, webhook := range m.webhooks {
+		if !webhook.Subscribes(event) {
+			continue
+		}
+		delivery := newDelivery(webhook.ID, event, payload, now)
+		delivery.ID = len(m.deliveries) + 1
+		m.deliveries = append(m.deliveries, delivery)
+		queued++
+	}
+	return queued, nil
+}
+
+// DueWebhookDeliveries implements WebhookStore.
+func (m *MemoryStore) DueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	due := []WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// SaveWebhookDelivery implements WebhookStore.
+func (m *MemoryStore) SaveWebhookDelivery(delivery WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, d := range m.deliveries {
+		if d.ID == delivery.ID {
+			m.deliveries[i] = delivery
+			return nil
+		}
+	}
+	return NewAPIError(404, "Webhook delivery not found")
+}
+
+// GetWebhookDeliveries implements WebhookStore.
+func (m *MemoryStore) GetWebhookDeliveries(webhookID int, limit int) ([]WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deliveries := []WebhookDelivery{}
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if m.deliveries[i].WebhookID == webhookID {
+			deliveries = append(deliveries, m.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+// GetTags implements TagStore.
+func (m *MemoryStore) GetTags() ([]Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tags := make([]Tag, len(m.tags))
+	for i, tag := range m.tags {
+		tags[i] = m.withTaskCount(tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// GetTag implements TagStore.
+func (m *MemoryStore) GetTag(id int) (Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range m.tags {
+		if tag.ID == id {
+			return m.withTaskCount(tag), nil
+		}
+	}
+	return Tag{}, NewAPIError(404, "Tag not found")
+}
+
+// CreateTag implements TagStore.
+func (m *MemoryStore) CreateTag(tag Tag) (Tag, error) {
+	if err := validateTag(&tag); err != nil {
+		return Tag{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if slices.ContainsFunc(m.tags, func(t Tag) bool { return t.Name == tag.Name }) {
+		return Tag{}, NewAPIError(409, fmt.Sprintf("Tag %q already exists", tag.Name))
+	}
+	m.lastTagID++
+	tag.ID, tag.TaskCount = m.lastTagID, 0
+	tag.CreatedAt = m.clock.Now()
+	m.tags = append(m.tags, tag)
+	return tag, nil
+}
+
+// UpdateTag implements TagStore.
+func (m *MemoryStore) UpdateTag(tag Tag) (Tag, error) {
+	if err := validateTag(&tag); err != nil {
+		return Tag{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.tags, func(t Tag) bool { return t.ID == tag.ID })
+	if i < 0 {
+		return Tag{}, NewAPIError(404, "Tag not found")
+	}
+	if slices.ContainsFunc(m.tags, func(t Tag) bool { return t.Name == tag.Name && t.ID != tag.ID }) {
+		return Tag{}, NewAPIError(409, fmt.Sprintf("Tag %q already exists", tag.Name))
+	}
+	if old := m.tags[i].Name; old != tag.Name {
+		m.retag(old, tag.Name)
+	}
+	m.tags[i].Name, m.tags[i].Color = tag.Name, tag.Color
+	return m.withTaskCount(m.tags[i]), nil
+}
+
+// DeleteTag implements TagStore.
+func (m *MemoryStore) DeleteTag(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.tags, func(t Tag) bool { return t.ID == id })
+	if i < 0 {
+		return NewAPIError(404, "Tag not found")
+	}
+	m.retag(m.tags[i].Name, "")
+	m.tags = slices.Delete(m.tags, i, i+1)
+	return nil
+}
+
+// addTags creates the tags named tags that do not exist yet. Must be called
+// with mu held.
+func (m *MemoryStore) addTags(tags TagList) {
+	for _, name := range tags {
+		if slices.ContainsFunc(m.tags, func(t Tag) bool { return t.Name == name }) {
+			continue
+		}
+		m.lastTagID++
+		m.tags = append(m.tags, Tag{ID: m.lastTagID, Name: name, CreatedAt: m.clock.Now()})
+	}
+}
+
+// retag renames the tag old to name on all tasks, or removes it when name
+// is empty. Must be called with mu held.
+func (m *MemoryStore) retag(old, name string) {
+	for id, task := range m.tasks {
+		if !slices.Contains(task.Tags, old) {
+			continue
+		}
+		tags := slices.DeleteFunc(slices.Clone(task.Tags), func(t string) bool { return t == old })
+		if name != "" {
+			tags = append(tags, name)
+			slices.Sort(tags)
+		}
+		task.Tags = tags
+		task.UpdatedAt = m.clock.Now()
+		m.tasks[id] = task
+	}
+}
+
+// withTaskCount fills the task count of tag. Must be called with mu held.
+func (m *MemoryStore) withTaskCount(tag Tag) Tag {
+	tag.TaskCount = 0
+	for _, task := range m.tasks {
+		if slices.Contains(task.Tags, tag.Name) {
+			tag.TaskCount++
+		}
+	}
+	return tag
+}
+
+// GetSeries implements TaskStore.
+func (m *MemoryStore) GetSeries(id int) (Series, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return loadSeries(memoryTx{m: m}, id)
+}
+
+// CreateNextOccurrencesForUndoneRecurringTasks implements TaskStore.
+func (m *MemoryStore) CreateNextOccurrencesForUndoneRecurringTasks(today time.Time) error {
+	return m.taskTransaction(func(tx memoryTx) error {
+		return createOverdueOccurrences(tx, today)
+	})
+}
+
+// OnOccurrenceCreated implements TaskStore.
+func (m *MemoryStore) OnOccurrenceCreated(fn func(occurrence Task)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observers = append(m.observers, fn)
+}
+
+// taskTransaction runs fn with the lock held, then reports the occurrences it
+// created to the observers if it succeeded. Unlike SQLite, the store does not
+// roll back the changes of a failed fn.
+func (m *MemoryStore) taskTransaction(fn func(tx memoryTx) error) error {
+	var occurrences []Task
+	m.mu.Lock()
+	err := fn(memoryTx{m: m, createdTasks: &occurrences})
+	observers := m.observers
+	m.mu.Unlock()
+	if err == nil {
+		for _, occurrence := range occurrences {
+			for _, observe := range observers {
+				observe(occurrence)
+			}
+		}
+	}
+	return err
+}
+
+// GetInboxTitle implements SettingsStore.
+func (m *MemoryStore) GetInboxTitle() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.defaultList().Title, nil
+}
+
+// UpdateInboxTitle implements SettingsStore.
+func (m *MemoryStore) UpdateInboxTitle(title string) error {
+	m.mu.Lock()
+	inbox := *m.defaultList()
+	m.mu.Unlock()
+	inbox.Title = title
+	_, err := m.UpdateList(inbox)
+	return err
+}
+
+// sorted returns all tasks by order, then ID. Must be called with mu held.
+func (m *MemoryStore) sorted() Tasks {
+	tasks := make(Tasks, 0, len(m.tasks))
+	for _, task := range m.tasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].TaskOrder != tasks[j].TaskOrder {
+			return tasks[i].TaskOrder < tasks[j].TaskOrder
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
+}
+
+// subtasks returns the subtasks of parentID by order. Must be called with mu held.
+func (m *MemoryStore) subtasks(parentID int) Tasks {
+	subtasks := Tasks{}
+	for _, task := range m.sorted() {
+		if task.ParentID != nil && *task.ParentID == parentID {
+			subtasks = append(subtasks, task)
+		}
+	}
+	return subtasks
+}
+
+// withCounts fills the subtask counts of task. Must be called with mu held.
+func (m *MemoryStore) withCounts(task Task) Task {
+	task.SubtasksTotal, task.SubtasksCompleted = 0, 0
+	for _, sub := range m.tasks {
+		if sub.ParentID != nil && *sub.ParentID == task.ID {
+			task.SubtasksTotal++
+			task.SubtasksCompleted += sub.Completed
+		}
+	}
+	return task
+}
+
+// rollUpCompletion is the in-memory rollUpCompletion. Must be called with mu held.
+func (m *MemoryStore) rollUpCompletion(parentID int) {
+	parent, ok := m.tasks[parentID]
+	if !ok {
+		return
+	}
+	counted := m.withCounts(parent)
+	if counted.SubtasksTotal == 0 {
+		return
+	}
+	completed := 0
+	if counted.SubtasksCompleted == counted.SubtasksTotal {
+		completed = 1
+	}
+	if parent.Completed != completed {
+		parent.Completed = completed
+		parent.UpdatedAt = m.clock.Now()
+		m.tasks[parentID] = parent
+	}
+}
+
+// memoryTx implements taskTx on a MemoryStore whose lock is held.
+type memoryTx struct {
+	m            *MemoryStore
+	createdTasks *[]Task // Collects the created occurrences, may be nil.
+}
+
+func (t memoryTx) task(id int) (Task, error) {
+	task, ok := t.m.tasks[id]
+	if !ok {
+		return Task{}, NewAPIError(404, "Task not found")
+	}
+	return task, nil
+}
+
+func (t memoryTx) createTask(task *Task) error {
+	*task = t.m.create(*task)
+	return nil
+}
+
+func (t memoryTx) saveTask(task Task) error {
+	t.m.addTags(task.Tags)
+	task.SubtasksTotal, task.SubtasksCompleted = 0, 0
+	task.UpdatedAt = t.m.clock.Now()
+	t.m.tasks[task.ID] = task
+	return nil
+}
+
+func (t memoryTx) updateTask(id int, updates map[string]interface{}) error {
+	task, ok := t.m.tasks[id]
+	if !ok {
+		return NewAPIError(404, "Task not found for update")
+	}
+	for key, value := range updates {
+		switch key {
+		case "title":
+			task.Title = value.(string)
+		case "description":
+			task.Description, _ = value.(string)
+		case "due_date":
+			task.DueDate = NullTime{}
+			if dateStr, _ := value.(string); dateStr != "" {
+				date, _ := time.Parse(config.DateFormat, dateStr) // Validated by checkTaskUpdates.
+				task.DueDate = NullTime{Time: date, Valid: true}
+			}
+		case "completed":
+			task.Completed = 0
+			if value == true || value == 1.0 {
+				task.Completed = 1
+			}
+		case "color":
+			task.Color, _ = value.(string)
+		case "task_order":
+			task.TaskOrder = toInt(value)
+		case "recurrence_rule":
+			task.RecurrenceRule = value.(string)
+		case "recurrence_interval":
+			task.RecurrenceInterval = toInt(value)
+		case "due_time":
+			task.DueTime, _ = value.(string)
+		case "duration_minutes":
+			task.DurationMinutes = toInt(value)
+		case "remind_at":
+			task.RemindAt, _ = value.(string)
+		case "tags":
+			task.Tags = value.(TagList)
+			t.m.addTags(task.Tags)
+		case "list_id":
+			listID := toInt(value)
+			task.ListID = &listID
+		}
+	}
+	task.UpdatedAt = t.m.clock.Now()
+	t.m.tasks[id] = task
+	return nil
+}
+
+func (t memoryTx) deleteTask(id int) error {
+	delete(t.m.tasks, id)
+	for subID, sub := range t.m.tasks {
+		if sub.ParentID != nil && *sub.ParentID == id {
+			delete(t.m.tasks, subID)
+		}
+	}
+	return nil
+}
+
+func (t memoryTx) copySubtasks(fromID, toID int) error {
+	for _, sub := range t.m.subtasks(fromID) {
+		copied := Task{Title: sub.Title, Description: sub.Description, Color: sub.Color}
+		prepareSubtask(&copied, toID)
+		copied.TaskOrder = sub.TaskOrder
+		t.m.create(copied)
+	}
+	return nil
+}
+
+func (t memoryTx) created(occurrence Task) {
+	if t.createdTasks != nil {
+		*t.createdTasks = append(*t.createdTasks, occurrence)
+	}
+}
+
+func (t memoryTx) spawned(fromID int) (bool, error) {
+	for _, task := range t.m.tasks {
+		if task.SpawnedFrom != nil && *task.SpawnedFrom == fromID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (t memoryTx) rollUpCompletion(parentID int) error {
+	t.m.rollUpCompletion(parentID)
+	return nil
+}
+
+func (t memoryTx) series(id int) (Series, error) {
+	series, ok := t.m.series[id]
+	if !ok {
+		return Series{}, NewAPIError(404, "Series not found")
+	}
+	return series, nil
+}
+
+func (t memoryTx) allSeries() ([]Series, error) {
+	all := make([]Series, 0, len(t.m.series))
+	for _, series := range t.m.series {
+		all = append(all, series)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all, nil
+}
+
+func (t memoryTx) saveSeries(series *Series) error {
+	if series.ID == 0 {
+		t.m.lastSeriesID++
+		series.ID = t.m.lastSeriesID
+	}
+	if series.UID == "" {
+		series.UID = NewUID()
+	}
+	series.UpdatedAt = t.m.clock.Now()
+	t.m.series[series.ID] = *series
+	return nil
+}
+
+func (t memoryTx) deleteSeries(id int) error {
+	delete(t.m.series, id)
+	delete(t.m.exceptions, id)
+	return nil
+}
+
+func (t memoryTx) occurrences(seriesID int) (Tasks, error) {
+	tasks := Tasks{}
+	for _, task := range t.m.tasks {
+		if task.SeriesID != nil && *task.SeriesID == seriesID && task.ParentID == nil {
+			tasks = append(tasks, t.m.withCounts(task))
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].OccurrenceDate.Time.Equal(tasks[j].OccurrenceDate.Time) {
+			return tasks[i].OccurrenceDate.Time.Before(tasks[j].OccurrenceDate.Time)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks, nil
+}
+
+func (t memoryTx) exceptions(seriesID int) ([]SeriesException, error) {
+	exceptions := append([]SeriesException(nil), t.m.exceptions[seriesID]...)
+	sort.Slice(exceptions, func(i, j int) bool {
+		return exceptions[i].OccurrenceDate.Before(exceptions[j].OccurrenceDate)
+	})
+	return exceptions, nil
+}
+
+func (t memoryTx) saveException(exception SeriesException) error {
+	exception.OccurrenceDate = dateOnly(exception.OccurrenceDate)
+	t.deleteException(exception.SeriesID, exception.OccurrenceDate)
+	t.m.lastExceptionID++
+	exception.ID = t.m.lastExceptionID
+	t.m.exceptions[exception.SeriesID] = append(t.m.exceptions[exception.SeriesID], exception)
+	return nil
+}
+
+func (t memoryTx) deleteException(seriesID int, date time.Time) error {
+	kept := t.m.exceptions[seriesID][:0]
+	for _, e := range t.m.exceptions[seriesID] {
+		if !dateOnly(e.OccurrenceDate).Equal(dateOnly(date)) {
+			kept = append(kept, e)
+		}
+	}
+	t.m.exceptions[seriesID] = kept
+	return nil
+}
+
+func (t memoryTx) list(id int) (List, error) {
+	if id == 0 {
+		return *t.m.defaultList(), nil
+	}
+	for _, list := range t.m.lists {
+		if list.ID == id {
+			return list, nil
+		}
+	}
+	return List{}, NewAPIError(400, fmt.Sprintf("Unknown list %d", id))
+}
+
+// toInt converts a validated JSON number (float64) or int update value.
+func toInt(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	panic(fmt.Sprintf("toInt: unexpected %T", value))
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationName matches migration files: a four digit version and a name.
+var migrationName = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.sql$`)
+
+// Migration is a numbered schema change: an SQL file embedded from
+// migrations/ or, for data conversions SQL cannot express, a Go function
+// registered in goMigrations.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+	Up      func(tx *gorm.DB) error // Used instead of SQL when set.
+}
+
+// String returns the migration's file name without extension, e.g. "0002_task_uid".
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus is a migration and whether it has been applied to a database.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// schemaMigration is a row of the schema_migrations table.
+type schemaMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// legacyColumns are the task columns of the baseline schema that databases
+// created by old versions may lack (AutoMigrate used to add them on startup).
+var legacyColumns = []struct{ name, definition string }{
+	{"due_date", "date"},
+	{"completed", "integer DEFAULT 0"},
+	{"task_order", "integer"},
+	{"color", "text DEFAULT ''"},
+	{"description", "text"},
+	{"recurrence_rule", "text DEFAULT ''"},
+	{"recurrence_interval", "integer DEFAULT 1"},
+}
+
+// legacyApplied reports, for migrations after the baseline, whether a
+// database from before schema_migrations existed already has the change.
+var legacyApplied = map[int]func(tx *gorm.DB) (bool, error){
+	2: func(tx *gorm.DB) (bool, error) { return hasColumn(tx, "tasks", "uid") },
+}
+
+// goMigrations are the migrations implemented in Go. Like SQL ones they run
+// in a transaction and must not depend on the current models, which may have
+// columns added by later migrations.
+var goMigrations = []Migration{
+	{Version: 4, Name: "checklist_subtasks", Up: convertChecklists},
+}
+
+var migrations = mustLoadMigrations()
+
+// Migrations returns all known migrations in version order.
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+func mustLoadMigrations() []Migration {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	var list []Migration
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			panic(fmt.Sprintf("db: malformed migration file name %q", entry.Name()))
+		}
+		sql, err := fs.ReadFile(migrationFiles, "migrations/"+entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		version, _ := strconv.Atoi(match[1])
+		list = append(list, Migration{Version: version, Name: match[2], SQL: string(sql)})
+	}
+	list = append(list, goMigrations...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i, m := range list {
+		if m.Version != i+1 {
+			panic(fmt.Sprintf("db: migration %s out of sequence, expected version %d", m, i+1))
+		}
+	}
+	return list
+}
+
+// MigrationStatuses lists all known migrations and whether each has been
+// applied to gdb. It does not change the database.
+func MigrationStatuses(gdb *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(gdb)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i].Migration = m
+		if row, ok := applied[m.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = row.AppliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Migrate applies all pending migrations to gdb, each in its own transaction,
+// and returns the ones applied. Databases created before schema_migrations
+// existed are adopted: the baseline is applied on top of their tables and
+// later migrations they already contain are only recorded.
+func Migrate(gdb *gorm.DB) ([]Migration, error) {
+	legacy := false
+	if !hasTable(gdb, "schema_migrations") && hasTable(gdb, "tasks") {
+		legacy = true
+		slog.Info("Database predates schema migrations, adopting it...")
+	}
+
+	if err := gdb.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at datetime NOT NULL
+	)`).Error; err != nil {
+		return nil, fmt.Errorf("migrate: creating schema_migrations: %w", err)
+	}
+
+	applied, err := appliedMigrations(gdb)
+	if err != nil {
+		return nil, err
+	}
+	latest := migrations[len(migrations)-1].Version
+	for version := range applied {
+		if version > latest {
+			return nil, fmt.Errorf("migrate: database schema version %d is newer than this build supports (%d)", version, latest)
+		}
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err := gdb.Transaction(func(tx *gorm.DB) error {
+			run := true
+			if legacy {
+				if m.Version == 1 {
+					if err := addLegacyColumns(tx); err != nil {
+						return err
+					}
+				} else if check, ok := legacyApplied[m.Version]; ok {
+					present, err := check(tx)
+					if err != nil {
+						return err
+					}
+					run = !present
+				}
+			}
+			if run && m.Up != nil {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+			} else if run {
+				if err := tx.Exec(m.SQL).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				m.Version, m.Name, time.Now().UTC()).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migrate: %s: %w", m, err)
+		}
+		slog.Info("Migration applied", "migration", m.String())
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+func appliedMigrations(gdb *gorm.DB) (map[int]schemaMigration, error) {
+	applied := map[int]schemaMigration{}
+	if !hasTable(gdb, "schema_migrations") {
+		return applied, nil
+	}
+	var rows []schemaMigration
+	if err := gdb.Table("schema_migrations").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("migrate: reading schema_migrations: %w", err)
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// addLegacyColumns adds the baseline task columns an old database lacks.
+func addLegacyColumns(tx *gorm.DB) error {
+	for _, col := range legacyColumns {
+		present, err := hasColumn(tx, "tasks", col.name)
+		if err != nil {
+			return err
+		}
+		if present {
+			continue
+		}
+		slog.Info("Adding missing column to legacy database", "column", col.name)
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE tasks ADD COLUMN %s %s", col.name, col.definition)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasTable(gdb *gorm.DB, name string) bool {
+	var count int
+	gdb.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	return count > 0
+}
+
+func hasColumn(gdb *gorm.DB, table, column string) (bool, error) {
+	var count int
+	err := gdb.Raw("SELECT count(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count).Error
+	return count > 0, err
+}
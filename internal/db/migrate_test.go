@@ -0,0 +1,334 @@
+//go:build sqlite_fts5
+
+package db
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// baselineSchema is the schema versions before numbered migrations created,
+// search triggers included.
+const baselineSchema = `
+CREATE TABLE tasks (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    title text NOT NULL,
+    due_date date,
+    completed integer DEFAULT 0,
+    task_order integer,
+    color text DEFAULT '',
+    description text,
+    recurrence_rule text DEFAULT '',
+    recurrence_interval integer DEFAULT 1
+);
+CREATE INDEX idx_tasks_title_duedate ON tasks(title, due_date);
+CREATE TABLE settings (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    "key" text NOT NULL UNIQUE,
+    value text
+);
+INSERT INTO settings ("key", value) VALUES ('inbox_title', 'Someday');
+CREATE VIRTUAL TABLE tasks_fts USING fts5(title, description, content='tasks', content_rowid='id');
+CREATE TRIGGER tasks_ai AFTER INSERT ON tasks
+BEGIN
+    INSERT INTO tasks_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+CREATE TRIGGER tasks_ad AFTER DELETE ON tasks
+BEGIN
+    DELETE FROM tasks_fts WHERE rowid = old.id;
+END;
+CREATE TRIGGER tasks_au AFTER UPDATE OF title, description ON tasks
+BEGIN
+    UPDATE tasks_fts SET title = new.title, description = new.description WHERE rowid = old.id;
+END;
+`
+
+// legacyDatabase creates a database from before schema_migrations existed
+// with the given SQL, usually baselineSchema and some tasks, and returns
+// its path.
+func legacyDatabase(t *testing.T, sql string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	gdb, err := Connect(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(gdb)
+	if err := gdb.Exec(sql).Error; err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// openLegacy opens, and so migrates, a database created by legacyDatabase.
+func openLegacy(t *testing.T, sql string) *Store {
+	t.Helper()
+	store, err := Open(legacyDatabase(t, sql))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	store.SetClock(FixedClock(time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)))
+	return store
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	gdb, err := Connect(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(gdb)
+
+	applied, err := Migrate(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(Migrations()) {
+		t.Errorf("applied %v, want all %d migrations", applied, len(Migrations()))
+	}
+	if applied, err := Migrate(gdb); err != nil || len(applied) != 0 {
+		t.Errorf("migrating again applied %v, %v, want nothing", applied, err)
+	}
+	var title string
+	if err := gdb.Raw("SELECT title FROM lists WHERE is_default").Scan(&title).Error; err != nil || title != "📦 Inbox" {
+		t.Errorf("default list %q, %v, want the inbox", title, err)
+	}
+}
+
+func TestMigrationStatuses(t *testing.T) {
+	path := legacyDatabase(t, baselineSchema)
+	gdb, err := Connect(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(gdb)
+
+	// Looking does not adopt the database.
+	statuses, err := MigrationStatuses(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != len(Migrations()) {
+		t.Fatalf("got %d statuses, want %d", len(statuses), len(Migrations()))
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			t.Errorf("%s applied to a legacy database", s)
+		}
+	}
+	if hasTable(gdb, "schema_migrations") {
+		t.Error("MigrationStatuses created schema_migrations")
+	}
+
+	before := time.Now().UTC().Add(-time.Second)
+	if _, err := Migrate(gdb); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err = MigrationStatuses(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range statuses {
+		if s.Version != i+1 || !s.Applied || s.AppliedAt.Before(before) {
+			t.Errorf("status %+v, want version %d applied now", s, i+1)
+		}
+	}
+	if got := statuses[1].String(); got != "0002_task_uid" {
+		t.Errorf("second migration = %q, want 0002_task_uid", got)
+	}
+}
+
+func TestMigrateAdoptsLegacyDatabase(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		uid  string // Of "Water plants", if the database had UIDs.
+	}{
+		{
+			name: "baseline",
+			sql: baselineSchema + `
+INSERT INTO tasks (title, due_date, description, recurrence_rule, recurrence_interval)
+VALUES ('Water plants', '2026-10-05', 'In the kitchen', 'weekly', 1),
+       ('Learn Go', NULL, '', '', 1);`,
+		},
+		{
+			// Without the columns and tables later versions added on startup.
+			name: "very old",
+			sql: `
+CREATE TABLE tasks (id integer PRIMARY KEY AUTOINCREMENT, title text NOT NULL, due_date date, completed integer DEFAULT 0);
+INSERT INTO tasks (title, due_date) VALUES ('Water plants', '2026-10-05'), ('Learn Go', NULL);`,
+		},
+		{
+			name: "with UIDs",
+			sql: baselineSchema + `
+ALTER TABLE tasks ADD COLUMN uid text;
+ALTER TABLE tasks ADD COLUMN updated_at datetime;
+INSERT INTO tasks (title, due_date, uid, updated_at)
+VALUES ('Water plants', '2026-10-05', 'b1f8a4c2-5d3e-4f6a-9b7c-8d2e1f0a3b4c', '2026-10-01 09:00:00'),
+       ('Learn Go', NULL, '0c9d8e7f-6a5b-4c3d-8e2f-1a0b9c8d7e6f', '2026-10-01 09:00:00');`,
+			uid: "b1f8a4c2-5d3e-4f6a-9b7c-8d2e1f0a3b4c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openLegacy(t, tt.sql)
+			statuses, err := MigrationStatuses(store.DB())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range statuses {
+				if !s.Applied {
+					t.Errorf("%s not applied", s)
+				}
+			}
+
+			if got := dueDates(t, store); !slices.Equal(got, []string{"2026-10-05"}) {
+				t.Errorf("due dates = %v, want 2026-10-05", got)
+			}
+			task := occurrenceOn(t, store, "2026-10-05")
+			if task.UID == "" || tt.uid != "" && task.UID != tt.uid {
+				t.Errorf("UID = %q, want %q or a new one", task.UID, tt.uid)
+			}
+			inbox, err := store.GetTasks(TaskFilter{Date: "inbox"})
+			if err != nil || len(inbox) != 1 || inbox[0].ListID == nil {
+				t.Errorf("inbox = %+v, %v, want Learn Go in the default list", inbox, err)
+			}
+
+			// The search index follows changes with the current triggers.
+			search := func(query string) []string {
+				t.Helper()
+				tasks, err := store.SearchTasks(query, mustDate(t, "2026-10-18"), 10, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return titles(tasks)
+			}
+			if got := search("plants"); !slices.Equal(got, []string{"Water plants"}) {
+				t.Errorf("search before renaming = %v", got)
+			}
+			if err := store.UpdateTask(task.ID, map[string]interface{}{"title": "Water ferns"}, ScopeAll); err != nil {
+				t.Fatal(err)
+			}
+			if got := search("plants"); len(got) != 0 {
+				t.Errorf("search for the old title = %v, want nothing", got)
+			}
+			if got := search("ferns"); !slices.Equal(got, []string{"Water ferns"}) {
+				t.Errorf("search for the new title = %v", got)
+			}
+			if err := store.DeleteTask(task.ID, ScopeAll); err != nil {
+				t.Fatal(err)
+			}
+			if got := search("ferns"); len(got) != 0 {
+				t.Errorf("search after deleting = %v, want nothing", got)
+			}
+			if err := store.DB().Exec("INSERT INTO tasks_fts(tasks_fts) VALUES ('integrity-check')").Error; err != nil {
+				t.Errorf("search index does not match the tasks: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateRecurringLegacyTask(t *testing.T) {
+	store := openLegacy(t, baselineSchema+`
+INSERT INTO tasks (title, due_date, completed, recurrence_rule, recurrence_interval)
+VALUES ('Pay rent', '2026-09-30', 1, 'monthly', 1);`)
+	if title, err := store.GetInboxTitle(); err != nil || title != "Someday" {
+		t.Errorf("inbox title = %q, %v, want the legacy setting", title, err)
+	}
+	task := occurrenceOn(t, store, "2026-09-30")
+	if task.SeriesID == nil {
+		t.Fatal("recurring task is not part of a series")
+	}
+	series, err := store.GetSeries(*task.SeriesID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Title != "Pay rent" || series.RecurrenceRule != "monthly" || !series.StartDate.Equal(mustDate(t, "2026-09-30")) {
+		t.Errorf("series = %+v", series)
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	gdb, err := Connect(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(gdb); err != nil {
+		t.Fatal(err)
+	}
+	future := len(Migrations()) + 1
+	if err := gdb.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from_the_future', ?)", future, time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(gdb); err == nil || !strings.Contains(err.Error(), "newer than this build supports") {
+		t.Errorf("Migrate = %v, want an error about the newer schema", err)
+	}
+	closeDB(gdb)
+
+	if store, err := Open(path); err == nil {
+		store.Close()
+		t.Error("Open succeeded on a database with a newer schema")
+	}
+}
+
+func TestMigrateConvertsChecklists(t *testing.T) {
+	store := openLegacy(t, baselineSchema+`
+INSERT INTO tasks (id, title, due_date, description) VALUES
+    (1, 'Trip', '2026-10-23', 'Pack for the weekend.
+
+- [ ] Passport
+- [x] Tickets
+* [X] Charger
+
+Check the weather.'),
+    (2, 'Groceries', NULL, '- [ ] Milk
+    - [ ] Oat milk
+- [x] Bread'),
+    (3, 'Notes', NULL, 'See [the docs] and [x] marks'),
+    (4, 'Call home', NULL, NULL);`)
+	tests := []struct {
+		id          int
+		subtasks    []string // Title, and whether completed.
+		description string
+	}{
+		{1, []string{"Passport", "Tickets ✓", "Charger ✓"}, "Pack for the weekend.\n\nCheck the weather."},
+		// Nested items become subtasks of the task itself.
+		{2, []string{"Milk", "Oat milk", "Bread ✓"}, ""},
+		{3, nil, "See [the docs] and [x] marks"},
+		{4, nil, ""},
+	}
+	for _, tt := range tests {
+		task, err := store.GetTask(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.Description != tt.description {
+			t.Errorf("description of %s = %q, want %q", task.Title, task.Description, tt.description)
+		}
+		subtasks, err := store.GetSubtasks(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, sub := range subtasks {
+			if sub.Completed != 0 {
+				sub.Title += " ✓"
+			}
+			got = append(got, sub.Title)
+		}
+		if !slices.Equal(got, tt.subtasks) {
+			t.Errorf("subtasks of %s = %v, want %v", task.Title, got, tt.subtasks)
+		}
+		if task.SubtasksTotal != len(tt.subtasks) {
+			t.Errorf("%s counts %d subtasks, want %d", task.Title, task.SubtasksTotal, len(tt.subtasks))
+		}
+	}
+	// The converted items are searched as the tasks they became.
+	if found, err := store.SearchTasks("passport", mustDate(t, "2026-10-18"), 10, 0); err != nil || !slices.Equal(titles(found), []string{"Passport"}) {
+		t.Errorf("search for a converted item = %v, %v", titles(found), err)
+	}
+}
@@ -0,0 +1,61 @@
+-- Baseline schema as created by versions before numbered migrations existed.
+-- Every statement is idempotent so databases from those versions can adopt it;
+-- missing task columns of very old databases are added before it runs.
+
+CREATE TABLE IF NOT EXISTS tasks (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    title text NOT NULL,
+    due_date date,
+    completed integer DEFAULT 0,
+    task_order integer,
+    color text DEFAULT '',
+    description text,
+    recurrence_rule text DEFAULT '',
+    recurrence_interval integer DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS idx_tasks_title_duedate ON tasks(title, due_date);
+
+CREATE TABLE IF NOT EXISTS settings (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    "key" text NOT NULL,
+    value text,
+    CONSTRAINT uni_settings_key UNIQUE ("key")
+);
+
+INSERT OR IGNORE INTO settings ("key", value) VALUES ('inbox_title', '📦 Inbox');
+
+-- Full-text search over title and description, kept in sync by triggers.
+CREATE VIRTUAL TABLE IF NOT EXISTS tasks_fts USING fts5(title, description, content='tasks', content_rowid='id');
+
+CREATE TRIGGER IF NOT EXISTS tasks_ai AFTER INSERT ON tasks
+BEGIN
+    INSERT INTO tasks_fts(rowid, title, description)
+    VALUES (new.id, new.title, new.description);
+END;
+
+-- tasks_fts takes its content from tasks, so removing a row from the index
+-- needs the values that were indexed: the 'delete' command is given the old
+-- values. Versions before numbered migrations deleted and updated index rows
+-- directly, making FTS5 read them back from tasks after they had already
+-- changed or gone, so their triggers are replaced and the rebuild below drops
+-- whatever they left behind.
+DROP TRIGGER IF EXISTS tasks_ad;
+DROP TRIGGER IF EXISTS tasks_au;
+
+CREATE TRIGGER tasks_ad AFTER DELETE ON tasks
+BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, description)
+    VALUES ('delete', old.id, old.title, old.description);
+END;
+
+CREATE TRIGGER tasks_au AFTER UPDATE OF title, description ON tasks
+BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, description)
+    VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO tasks_fts(rowid, title, description)
+    VALUES (new.id, new.title, new.description);
+END;
+
+-- Index rows that existed before the search table or the current triggers did.
+INSERT INTO tasks_fts(tasks_fts) VALUES ('rebuild');
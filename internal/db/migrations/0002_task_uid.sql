@@ -0,0 +1,17 @@
+-- Stable task identifiers and modification times, used to merge planners.
+
+ALTER TABLE tasks ADD COLUMN uid text;
+ALTER TABLE tasks ADD COLUMN updated_at datetime;
+
+-- Random (version 4) UUIDs, in the same format as NewUID.
+UPDATE tasks SET uid = lower(
+    hex(randomblob(4)) || '-' ||
+    hex(randomblob(2)) || '-' ||
+    '4' || substr(hex(randomblob(2)), 2) || '-' ||
+    substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' ||
+    hex(randomblob(6))
+) WHERE uid IS NULL OR uid = '';
+
+UPDATE tasks SET updated_at = CURRENT_TIMESTAMP WHERE updated_at IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_uid ON tasks(uid);
@@ -0,0 +1,11 @@
+-- Subtasks are tasks with a parent. Only one level is allowed, enforced by
+-- the stores; deleting a task deletes its subtasks.
+
+ALTER TABLE tasks ADD COLUMN parent_id integer;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_parent_id ON tasks(parent_id);
+
+CREATE TRIGGER IF NOT EXISTS tasks_delete_subtasks AFTER DELETE ON tasks
+BEGIN
+    DELETE FROM tasks WHERE parent_id = old.id;
+END;
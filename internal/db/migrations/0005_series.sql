@@ -0,0 +1,47 @@
+-- Recurring tasks become series: a series owns the rule and the template of
+-- its occurrences, which are tasks pointing at it. Exceptions skip, move or
+-- retitle single occurrences, keyed by the date the rule gives them.
+
+CREATE TABLE IF NOT EXISTS series (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    uid text NOT NULL,
+    title text NOT NULL,
+    description text DEFAULT '',
+    color text DEFAULT '',
+    recurrence_rule text NOT NULL,
+    recurrence_interval integer DEFAULT 1,
+    start_date date NOT NULL,
+    updated_at datetime
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_series_uid ON series(uid);
+
+CREATE TABLE IF NOT EXISTS series_exceptions (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    series_id integer NOT NULL,
+    occurrence_date date NOT NULL,
+    skip integer DEFAULT 0,
+    due_date date,
+    title text DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_series_exceptions_date ON series_exceptions(series_id, occurrence_date);
+
+ALTER TABLE tasks ADD COLUMN series_id integer;
+ALTER TABLE tasks ADD COLUMN occurrence_date date;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_series_occurrence ON tasks(series_id, occurrence_date);
+
+-- Every dated recurring task used to start its recurrence anew, so each one
+-- becomes a series of its own starting on its due date. The series takes the
+-- task's UID, which is only used to link the two here.
+INSERT INTO series (uid, title, description, color, recurrence_rule, recurrence_interval, start_date, updated_at)
+SELECT uid, title, coalesce(description, ''), coalesce(color, ''), recurrence_rule,
+       coalesce(recurrence_interval, 1), due_date, CURRENT_TIMESTAMP
+FROM tasks
+WHERE recurrence_rule <> '' AND due_date IS NOT NULL AND parent_id IS NULL;
+
+UPDATE tasks
+SET series_id = (SELECT series.id FROM series WHERE series.uid = tasks.uid),
+    occurrence_date = due_date
+WHERE recurrence_rule <> '' AND due_date IS NOT NULL AND parent_id IS NULL;
@@ -0,0 +1,20 @@
+-- Each occurrence records the occurrence it was created from, so that an
+-- occurrence spawns its successor at most once: completing it again, or
+-- completing an old one after a newer occurrence was created for it, does
+-- not create another.
+
+ALTER TABLE tasks ADD COLUMN spawned_from integer;
+
+-- Occurrences created so far followed the one before them in their series.
+UPDATE tasks
+SET spawned_from = (
+    SELECT previous.id FROM tasks AS previous
+    WHERE previous.series_id = tasks.series_id
+      AND previous.parent_id IS NULL
+      AND previous.occurrence_date < tasks.occurrence_date
+    ORDER BY previous.occurrence_date DESC, previous.id DESC
+    LIMIT 1
+)
+WHERE series_id IS NOT NULL AND parent_id IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_spawned_from ON tasks(spawned_from);
@@ -0,0 +1,20 @@
+-- Optional time of day, duration and reminder of tasks, and the template of
+-- those for the occurrences of a series.
+ALTER TABLE tasks ADD COLUMN due_time text DEFAULT '';
+ALTER TABLE tasks ADD COLUMN duration_minutes integer DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN remind_at text DEFAULT '';
+
+ALTER TABLE series ADD COLUMN due_time text DEFAULT '';
+ALTER TABLE series ADD COLUMN duration_minutes integer DEFAULT 0;
+ALTER TABLE series ADD COLUMN remind_at text DEFAULT '';
+
+-- Reminders fired by the server, one per task and reminder time.
+CREATE TABLE IF NOT EXISTS reminder_deliveries (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    task_id integer NOT NULL,
+    title text DEFAULT '',
+    remind_at datetime NOT NULL,
+    delivered_at datetime NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reminder_deliveries_task ON reminder_deliveries(task_id, remind_at);
@@ -0,0 +1,54 @@
+-- Tags, linked to tasks by task_tags. The names of a task's tags are also
+-- kept, space-separated, in tasks.tags so that the full-text index, which
+-- takes its content from tasks, can include them.
+CREATE TABLE IF NOT EXISTS tags (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    name text NOT NULL UNIQUE,
+    color text NOT NULL DEFAULT '',
+    created_at datetime
+);
+
+CREATE TABLE IF NOT EXISTS task_tags (
+    task_id integer NOT NULL,
+    tag_id integer NOT NULL,
+    PRIMARY KEY (task_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_tags_tag ON task_tags(tag_id);
+
+ALTER TABLE tasks ADD COLUMN tags text NOT NULL DEFAULT '';
+
+CREATE TRIGGER IF NOT EXISTS tasks_delete_tags AFTER DELETE ON tasks
+BEGIN
+    DELETE FROM task_tags WHERE task_id = old.id;
+END;
+
+-- Index the tags column too.
+DROP TRIGGER IF EXISTS tasks_ai;
+DROP TRIGGER IF EXISTS tasks_ad;
+DROP TRIGGER IF EXISTS tasks_au;
+DROP TABLE IF EXISTS tasks_fts;
+
+CREATE VIRTUAL TABLE tasks_fts USING fts5(title, description, tags, content='tasks', content_rowid='id');
+
+CREATE TRIGGER tasks_ai AFTER INSERT ON tasks
+BEGIN
+    INSERT INTO tasks_fts(rowid, title, description, tags)
+    VALUES (new.id, new.title, new.description, new.tags);
+END;
+
+CREATE TRIGGER tasks_ad AFTER DELETE ON tasks
+BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, description, tags)
+    VALUES ('delete', old.id, old.title, old.description, old.tags);
+END;
+
+CREATE TRIGGER tasks_au AFTER UPDATE OF title, description, tags ON tasks
+BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, description, tags)
+    VALUES ('delete', old.id, old.title, old.description, old.tags);
+    INSERT INTO tasks_fts(rowid, title, description, tags)
+    VALUES (new.id, new.title, new.description, new.tags);
+END;
+
+INSERT INTO tasks_fts(tasks_fts) VALUES ('rebuild');
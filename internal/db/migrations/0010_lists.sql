@@ -0,0 +1,27 @@
+-- Named lists of tasks. The inbox becomes the default list, titled by the
+-- former inbox_title setting; every top-level task, dated or not, belongs to
+-- a list, subtasks to the list of their task.
+CREATE TABLE IF NOT EXISTS lists (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    title text NOT NULL,
+    color text NOT NULL DEFAULT '',
+    list_order integer NOT NULL DEFAULT 0,
+    is_default integer NOT NULL DEFAULT 0,
+    created_at datetime,
+    updated_at datetime
+);
+
+INSERT INTO lists (title, is_default, created_at, updated_at)
+VALUES (
+    COALESCE((SELECT value FROM settings WHERE "key" = 'inbox_title'), '📦 Inbox'),
+    1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+);
+
+DELETE FROM settings WHERE "key" = 'inbox_title';
+
+ALTER TABLE tasks ADD COLUMN list_id integer;
+
+UPDATE tasks SET list_id = (SELECT id FROM lists WHERE is_default = 1)
+WHERE parent_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_list ON tasks(list_id);
@@ -1,26 +1,99 @@
 package db
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 type Task struct {
-	ID                 int      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title              string   `gorm:"not null;index:idx_tasks_title_duedate" json:"title"`
-	DueDate            NullTime `gorm:"type:date;index:idx_tasks_title_duedate" json:"due_date"`
-	Completed          int      `gorm:"default:0" json:"completed"`
-	TaskOrder          int      `json:"order"`
-	Color              string   `gorm:"default:''" json:"color"`
-	Description        string   `gorm:"description"`
-	RecurrenceRule     string   `gorm:"default:''" json:"recurrence_rule"`    // e.g., "daily", "weekly", "monthly", "yearly"
-	RecurrenceInterval int      `gorm:"default:1" json:"recurrence_interval"` // Interval (1, 2, 3...) defaults to 1
+	ID                 int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UID                string    `gorm:"uniqueIndex" json:"uid"` // Stable identifier used to merge planners.
+	Title              string    `gorm:"not null;index:idx_tasks_title_duedate" json:"title"`
+	DueDate            NullTime  `gorm:"type:date;index:idx_tasks_title_duedate" json:"due_date"`
+	Completed          int       `gorm:"default:0" json:"completed"`
+	TaskOrder          int       `json:"order"`
+	Color              string    `gorm:"default:''" json:"color"`
+	Description        string    `gorm:"description"`
+	RecurrenceRule     string    `gorm:"default:''" json:"recurrence_rule"`    // "daily", "weekly", "monthly", "yearly" or an RRULE, e.g. "FREQ=MONTHLY;BYDAY=2TU"
+	RecurrenceInterval int       `gorm:"default:1" json:"recurrence_interval"` // Interval (1, 2, 3...) of simple rules, defaults to 1
+	UpdatedAt          time.Time `json:"updated_at"`
+	ParentID           *int      `gorm:"index" json:"parent_id"`            // Set on subtasks: the task they belong to.
+	SeriesID           *int      `json:"series_id"`                         // Set on occurrences of a recurring task.
+	OccurrenceDate     NullTime  `gorm:"type:date" json:"occurrence_date"`  // Date the series rule gives the occurrence; due_date differs when it was moved.
+	SpawnedFrom        *int      `json:"spawned_from"`                      // Occurrence this one was created from; each spawns at most one.
+	DueTime            string    `gorm:"default:''" json:"due_time"`        // Optional time of day, "15:04" in the planner's time zone.
+	DurationMinutes    int       `gorm:"default:0" json:"duration_minutes"` // Optional duration from DueTime, 0 for none.
+	RemindAt           string    `gorm:"default:''" json:"remind_at"`       // Optional reminder, see ParseReminder.
+	Tags               TagList   `gorm:"column:tags" json:"tags"`           // Names of its tags, see TagStore.
+	ListID             *int      `gorm:"index" json:"list_id"`              // The list of a top-level task, see ListStore; nil on subtasks.
+
+	// Subtask counts, filled by the stores when reading tasks.
+	SubtasksTotal     int `gorm:"->;-:migration" json:"subtasks_total"`
+	SubtasksCompleted int `gorm:"->;-:migration" json:"subtasks_completed"`
 }
 
 type Tasks []Task
 
+// Series is a recurring task: it owns the recurrence rule and the template
+// (title, description, color, time and reminder) of its occurrences, which are the tasks with
+// its SeriesID. Occurrences are created one at a time, when the previous one
+// is completed or skipped.
+type Series struct {
+	ID                 int    `gorm:"primaryKey;autoIncrement"`
+	UID                string `gorm:"uniqueIndex"`
+	Title              string `gorm:"not null"`
+	Description        string
+	Color              string
+	RecurrenceRule     string    // As on tasks: a simple rule or an RRULE, counted from StartDate.
+	RecurrenceInterval int       `gorm:"default:1"`
+	StartDate          time.Time `gorm:"type:date"` // First occurrence (the DTSTART of the rule).
+	DueTime            string
+	DurationMinutes    int
+	RemindAt           string // Relative reminders follow each occurrence; absolute ones fire once.
+	UpdatedAt          time.Time
+
+	// Filled by GetSeries.
+	Exceptions  []SeriesException `gorm:"-"`
+	Occurrences Tasks             `gorm:"-"`
+}
+
+// TableName keeps GORM from guessing the plural of "series".
+func (Series) TableName() string { return "series" }
+
+// SeriesException changes one occurrence of a series, identified by the date
+// the rule gives it: Skip drops it, DueDate moves it and Title overrides the
+// series title.
+type SeriesException struct {
+	ID             int       `gorm:"primaryKey;autoIncrement"`
+	SeriesID       int       `gorm:"not null"`
+	OccurrenceDate time.Time `gorm:"type:date"`
+	Skip           bool
+	DueDate        NullTime `gorm:"type:date"`
+	Title          string
+}
+
+// Scope selects the occurrences of a series an update or deletion applies to.
+type Scope string
+
+const (
+	ScopeThis      Scope = "this"      // Only the given occurrence.
+	ScopeFollowing Scope = "following" // The given occurrence and the later ones.
+	ScopeAll       Scope = "all"       // Every occurrence of the series.
+)
+
+// Setting is a key/value row of the settings table.
+type Setting struct {
+	ID    int    `gorm:"primaryKey;autoIncrement" json:"-"`
+	Key   string `gorm:"unique;not null" json:"key"`
+	Value string `json:"value"`
+}
+
 type APIError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -35,9 +108,51 @@ func (t *Task) Validate() error {
 	if t.RecurrenceRule != "" && t.RecurrenceInterval <= 0 {
 		t.RecurrenceInterval = 1 // Default to 1 if rule is set but interval is invalid
 	}
+	rule, err := NormalizeRecurrenceRule(t.RecurrenceRule)
+	if err != nil {
+		return NewAPIError(400, fmt.Sprintf("Invalid recurrence_rule value: %s (%v)", t.RecurrenceRule, err))
+	}
+	t.RecurrenceRule = rule
+	dueTime, err := normalizeDueTime(t.DueTime)
+	if err != nil {
+		return NewAPIError(400, fmt.Sprintf("Invalid due_time value: %s (%v)", t.DueTime, err))
+	}
+	t.DueTime = dueTime
+	if t.DurationMinutes < 0 {
+		return NewAPIError(400, "Invalid duration_minutes (must be a number >= 0)")
+	}
+	remindAt, err := normalizeRemindAt(t.RemindAt)
+	if err != nil {
+		return NewAPIError(400, fmt.Sprintf("Invalid remind_at value: %s (%v)", t.RemindAt, err))
+	}
+	t.RemindAt = remindAt
+	tags, err := NormalizeTags(t.Tags)
+	if err != nil {
+		return err
+	}
+	t.Tags = tags
+	return nil
+}
+
+// BeforeCreate assigns a UID to tasks that do not have one yet.
+func (t *Task) BeforeCreate(tx *gorm.DB) error {
+	if t.UID == "" {
+		t.UID = NewUID()
+	}
 	return nil
 }
 
+// NewUID returns a random (version 4) UUID string.
+func NewUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Errorf("newUID: %w", err)) // crypto/rand never fails on supported platforms.
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
 func NewAPIError(code int, message string) error {
 	return &APIError{
 		Code:    code,
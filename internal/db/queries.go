@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -10,35 +11,48 @@ import (
 	"unicode"
 
 	"week-planner/internal/config"
+	"week-planner/internal/rrule"
 
 	"gorm.io/gorm"
 )
 
-// GetTasks retrieves tasks based on filters (date, date range, or inbox).
-func GetTasks(date string, startDate string, endDate string) (Tasks, error) {
+// TaskFilter selects the top-level tasks GetTasks returns; the zero value
+// selects them all.
+type TaskFilter struct {
+	Date      string // A YYYY-MM-DD date, or "inbox" for the undated tasks of the list.
+	StartDate string // With EndDate, the tasks due in [StartDate, EndDate].
+	EndDate   string
+	ListID    int      // The tasks of this list; 0 for any, or the default list for the inbox.
+	Tags      []string // The tasks having all of these tags.
+}
+
+// GetTasks retrieves tasks based on filters (date, date range, or inbox), in
+// a list and having all of tags if any are given.
+func (s *Store) GetTasks(filter TaskFilter) (Tasks, error) {
 	var tasks Tasks
-	query := GetDB().Model(&Task{})
+	if err := checkTaskFilter(filter); err != nil {
+		return tasks, err
+	}
+	tagFilter, err := NormalizeTags(filter.Tags)
+	if err != nil {
+		return tasks, err
+	}
+	query := s.DB().Model(&Task{}).Scopes(withSubtaskCounts, withTags(tagFilter)).Where("parent_id IS NULL")
 
-	if date == "inbox" {
-		query = query.Where("due_date IS NULL")
-	} else if startDate != "" && endDate != "" {
-		// Validate date formats
-		_, err := time.Parse(config.DateFormat, startDate)
-		if err != nil {
-			return tasks, NewAPIError(400, "Invalid start date format")
+	if filter.ListID != 0 {
+		if _, err := s.GetList(filter.ListID); err != nil {
+			return tasks, err
 		}
-		_, err = time.Parse(config.DateFormat, endDate)
-		if err != nil {
-			return tasks, NewAPIError(400, "Invalid end date format")
-		}
-		query = query.Where("DATE(due_date) >= ? AND DATE(due_date) <= ?", startDate, endDate)
-	} else if date != "" {
-		// Validate date format
-		_, err := time.Parse(config.DateFormat, date)
-		if err != nil {
-			return tasks, NewAPIError(400, "Invalid date format")
-		}
-		query = query.Where("DATE(due_date) = ?", date)
+		query = query.Where("list_id = ?", filter.ListID)
+	} else if filter.Date == "inbox" {
+		query = query.Where("list_id = (SELECT id FROM lists WHERE is_default = 1)")
+	}
+	if filter.Date == "inbox" {
+		query = query.Where("due_date IS NULL")
+	} else if filter.StartDate != "" && filter.EndDate != "" {
+		query = query.Where("DATE(due_date) >= ? AND DATE(due_date) <= ?", filter.StartDate, filter.EndDate)
+	} else if filter.Date != "" {
+		query = query.Where("DATE(due_date) = ?", filter.Date)
 	}
 
 	// If no specific filters match, it will fetch all tasks (useful for search).
@@ -49,77 +63,73 @@ func GetTasks(date string, startDate string, endDate string) (Tasks, error) {
 	return tasks, nil
 }
 
-// GetInboxTitle returns the current inbox title from settings.
-func GetInboxTitle() (string, error) {
-	type Setting struct {
-		Key   string `gorm:"primaryKey"`
-		Value string
-	}
-	var setting Setting
-
-	// 1. Try to find the setting first.
-	err := GetDB().Model(&Setting{}).Where("key = ?", "inbox_title").First(&setting).Error
-
-	// 2. Check the error type.
-	if err == nil {
-		// Found the setting, return its value.
-		return setting.Value, nil
-	} else if err == gorm.ErrRecordNotFound {
-		// Setting not found, create it with the default value.
-		slog.Info("Inbox title setting not found, creating default.")
-		setting = Setting{Key: "inbox_title", Value: "📦 Inbox"}
-		if createErr := GetDB().Create(&setting).Error; createErr != nil {
-			// Failed to create the default setting.
-			return "", fmt.Errorf("getInboxTitle: failed to create default setting: %w", createErr)
+// checkTaskFilter validates the date filters accepted by GetTasks.
+func checkTaskFilter(filter TaskFilter) error {
+	if filter.ListID < 0 {
+		return NewAPIError(400, "Invalid list")
+	}
+	if filter.Date == "inbox" {
+		return nil
+	} else if filter.StartDate != "" && filter.EndDate != "" {
+		// Validate date formats
+		_, err := time.Parse(config.DateFormat, filter.StartDate)
+		if err != nil {
+			return NewAPIError(400, "Invalid start date format")
+		}
+		_, err = time.Parse(config.DateFormat, filter.EndDate)
+		if err != nil {
+			return NewAPIError(400, "Invalid end date format")
+		}
+	} else if filter.Date != "" {
+		// Validate date format
+		_, err := time.Parse(config.DateFormat, filter.Date)
+		if err != nil {
+			return NewAPIError(400, "Invalid date format")
 		}
-		// Successfully created, return the default value.
-		return setting.Value, nil
-	} else {
-		// Some other database error occurred during the find operation.
-		return "", fmt.Errorf("getInboxTitle: error fetching setting: %w", err)
 	}
+	return nil
 }
 
-// UpdateInboxTitle updates the inbox title setting.
-func UpdateInboxTitle(title string) error {
-	type Setting struct {
-		Key   string `gorm:"primaryKey"`
-		Value string
-	}
-	// Use Updates which handles existing records.
-	result := GetDB().Model(&Setting{}).
-		Where("key = ?", "inbox_title").
-		Update("value", title)
-	if result.Error != nil {
-		return fmt.Errorf("updateInboxTitle: %w", result.Error)
-	}
-	// Check if any row was affected, if not, it means the key didn't exist.
-	if result.RowsAffected == 0 {
-		// If no rows affected, it means the key didn't exist, so create it.
-		createResult := GetDB().Create(&Setting{Key: "inbox_title", Value: title})
-		if createResult.Error != nil {
-			return fmt.Errorf("updateInboxTitle: failed to create setting: %w", createResult.Error)
-		}
+// GetInboxTitle returns the title of the inbox, the default list.
+func (s *Store) GetInboxTitle() (string, error) {
+	list, err := findList(s.DB(), 0)
+	if err != nil {
+		return "", fmt.Errorf("getInboxTitle: %w", err)
 	}
-	return nil
+	return list.Title, nil
 }
 
-// CreateTask inserts a new task into the database.
-func CreateTask(task Task) (Task, error) {
+// UpdateInboxTitle renames the inbox, the default list.
+func (s *Store) UpdateInboxTitle(title string) error {
+	list, err := findList(s.DB(), 0)
+	if err != nil {
+		return fmt.Errorf("updateInboxTitle: %w", err)
+	}
+	list.Title = title
+	_, err = s.UpdateList(list)
+	return err
+}
+
+// CreateTask inserts a new task into the database. A dated recurring task
+// becomes the first occurrence of a new series.
+func (s *Store) CreateTask(task Task) (Task, error) {
 	if err := task.Validate(); err != nil {
 		return Task{}, err
 	}
 
-	if err := GetDB().Create(&task).Error; err != nil {
-		return Task{}, fmt.Errorf("createTask: %w", err)
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		return createTask(sqlTx{db: tx}, &task)
+	})
+	if err != nil {
+		return Task{}, txError("createTask", err)
 	}
 	return task, nil
 }
 
 // GetTask retrieves a single task by its ID.
-func GetTask(id int) (Task, error) {
+func (s *Store) GetTask(id int) (Task, error) {
 	var task Task
-	if err := GetDB().First(&task, id).Error; err != nil {
+	if err := s.DB().Scopes(withSubtaskCounts).First(&task, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return Task{}, NewAPIError(404, "Task not found")
 		}
@@ -128,8 +138,23 @@ func GetTask(id int) (Task, error) {
 	return task, nil
 }
 
-// UpdateTask modifies fields of an existing task.
-func UpdateTask(id int, updates map[string]interface{}) error {
+// UpdateTask modifies fields of an existing task. For occurrences of a
+// recurring task, scope selects the occurrences the update applies to; see
+// ParseScope for the default. Completing an occurrence, directly or through
+// its last subtask, creates the next one.
+func (s *Store) UpdateTask(id int, updates map[string]interface{}, scope Scope) error {
+	if err := checkTaskUpdates(updates); err != nil {
+		return err
+	}
+
+	err := s.taskTransaction(func(tx sqlTx) error {
+		return updateTask(tx, id, updates, scope)
+	})
+	return txError("updateTask", err)
+}
+
+// checkTaskUpdates validates the fields and values accepted by UpdateTask.
+func checkTaskUpdates(updates map[string]interface{}) error {
 	if len(updates) == 0 {
 		return NewAPIError(400, "No fields to update")
 	}
@@ -184,11 +209,13 @@ func UpdateTask(id int, updates map[string]interface{}) error {
 			if !ok {
 				return NewAPIError(400, "Invalid recurrence_rule format (must be string)")
 			}
-			// Allow empty string or specific valid rules.
-			isValidRule := rule == "" || rule == "daily" || rule == "weekly" || rule == "monthly" || rule == "yearly"
-			if !isValidRule {
-				return NewAPIError(400, fmt.Sprintf("Invalid recurrence_rule value: %s", rule))
+			// Allow empty string, the simple rules or an RRULE, which is stored
+			// in canonical form.
+			normalized, err := NormalizeRecurrenceRule(rule)
+			if err != nil {
+				return NewAPIError(400, fmt.Sprintf("Invalid recurrence_rule value: %s (%v)", rule, err))
 			}
+			updates[key] = normalized
 		case "recurrence_interval":
 			// Interval should be a number (float64 from JSON or int internally) and >= 1.
 			valid := false
@@ -200,35 +227,90 @@ func UpdateTask(id int, updates map[string]interface{}) error {
 			if !valid {
 				return NewAPIError(400, "Invalid recurrence_interval (must be a number >= 1)")
 			}
+		case "due_time":
+			// HH:MM, or empty or null to clear.
+			dueTime, ok := value.(string)
+			if !ok && value != nil {
+				return NewAPIError(400, "Invalid due_time format (must be string or null)")
+			}
+			normalized, err := normalizeDueTime(dueTime)
+			if err != nil {
+				return NewAPIError(400, fmt.Sprintf("Invalid due_time value: %s (%v)", dueTime, err))
+			}
+			updates[key] = normalized
+		case "duration_minutes":
+			// Minutes (float64 from JSON or int internally), 0 to clear.
+			valid := false
+			if minutes, ok := value.(float64); ok && minutes >= 0 {
+				valid = true
+			} else if minutes, okInt := value.(int); okInt && minutes >= 0 {
+				valid = true
+			}
+			if !valid {
+				return NewAPIError(400, "Invalid duration_minutes (must be a number >= 0)")
+			}
+		case "remind_at":
+			// An absolute time or a duration relative to the start of the
+			// task (see ParseReminder), or empty or null to clear.
+			remindAt, ok := value.(string)
+			if !ok && value != nil {
+				return NewAPIError(400, "Invalid remind_at format (must be string or null)")
+			}
+			normalized, err := normalizeRemindAt(remindAt)
+			if err != nil {
+				return NewAPIError(400, fmt.Sprintf("Invalid remind_at value: %s (%v)", remindAt, err))
+			}
+			updates[key] = normalized
+		case "tags":
+			// A list of tag names replacing the task's tags, or null to clear.
+			var names []string
+			switch list := value.(type) {
+			case nil:
+			case []string:
+				names = list
+			case TagList:
+				names = list
+			case []interface{}:
+				for _, item := range list {
+					name, ok := item.(string)
+					if !ok {
+						return NewAPIError(400, "Invalid tags format (must be a list of strings or null)")
+					}
+					names = append(names, name)
+				}
+			default:
+				return NewAPIError(400, "Invalid tags format (must be a list of strings or null)")
+			}
+			tags, err := NormalizeTags(names)
+			if err != nil {
+				return err
+			}
+			updates[key] = tags
+		case "list_id":
+			// The ID of the list to move a top-level task to, checked by updateTask.
+			valid := false
+			if id, ok := value.(float64); ok && id >= 1 && id == float64(int(id)) {
+				valid = true
+			} else if id, okInt := value.(int); okInt && id >= 1 {
+				valid = true
+			}
+			if !valid {
+				return NewAPIError(400, "Invalid list_id (must be the ID of a list)")
+			}
+			updates[key] = toInt(value)
 		default:
 			return NewAPIError(400, fmt.Sprintf("Unknown field for update: %s", key))
 		}
 	}
-
-	// Perform the update.
-	res := GetDB().Model(&Task{}).Where("id = ?", id).Updates(updates)
-	if res.Error != nil {
-		return fmt.Errorf("updateTask: %w", res.Error)
-	}
-	if res.RowsAffected == 0 {
-		// Check if the task actually exists to differentiate not found from no change.
-		var count int64
-		GetDB().Model(&Task{}).Where("id = ?", id).Count(&count)
-		if count == 0 {
-			return NewAPIError(404, "Task not found for update")
-		}
-		// If task exists but no rows affected, it means the update didn't change anything.
-		slog.Debug("Update task called but no changes detected", "task_id", id, "updates", updates)
-	}
 	return nil
 }
 
 // BulkUpdateTaskOrder updates the 'task_order' field for multiple tasks in a transaction.
-func BulkUpdateTaskOrder(tasks []Task) error {
+func (s *Store) BulkUpdateTaskOrder(tasks []Task) error {
 	if len(tasks) == 0 {
 		return nil // Nothing to do.
 	}
-	return GetDB().Transaction(func(tx *gorm.DB) error {
+	return s.DB().Transaction(func(tx *gorm.DB) error {
 		for _, task := range tasks {
 			// Update only the task_order field.
 			if err := tx.Model(&Task{}).
@@ -243,25 +325,29 @@ func BulkUpdateTaskOrder(tasks []Task) error {
 	})
 }
 
-// DeleteTask removes a task by its ID.
-func DeleteTask(id int) error {
-	result := GetDB().Delete(&Task{}, id)
-	if result.Error != nil {
-		return fmt.Errorf("deleteTask: %w", result.Error)
-	}
-	if result.RowsAffected == 0 {
-		// Task might have already been deleted.
-		return NewAPIError(404, "Task not found for deletion")
-	}
-	return nil
+// DeleteTask removes a task by its ID, with its subtasks. For occurrences of
+// a recurring task, scope selects the occurrences to delete.
+func (s *Store) DeleteTask(id int, scope Scope) error {
+	err := s.taskTransaction(func(tx sqlTx) error {
+		return deleteTask(tx, id, scope)
+	})
+	return txError("deleteTask", err)
 }
 
 // SearchTasks performs a fuzzy search using FTS5 with pagination and ranking.
-func SearchTasks(query string, limit int, offset int) (Tasks, error) {
+// Proximity is measured to today, the date in the planner's time zone.
+// "tag:name" terms restrict the results to the tasks having that tag; a
+// query of only such terms lists the tasks having them.
+func (s *Store) SearchTasks(query string, today time.Time, limit int, offset int) (Tasks, error) {
 	var tasks Tasks
 
+	text, tags := parseSearchQuery(query)
+	if text == "" && len(tags) == 0 {
+		return Tasks{}, nil
+	}
+
 	// FTS5 query requires escaping special characters and potentially quoting.
-	escapedQuery, quoted := escapeFTS5Query(query)
+	escapedQuery, quoted := escapeFTS5Query(text)
 	var fts5MatchQuery string
 	if !quoted {
 		// Use prefix search for unquoted terms.
@@ -272,7 +358,23 @@ func SearchTasks(query string, limit int, offset int) (Tasks, error) {
 	}
 
 	// Query for exact title match for boosting.
-	exactQuery := query + "%"
+	exactQuery := text + "%"
+
+	// Without text to match, tasks are only filtered by their tags.
+	source := `FROM tasks_fts
+            JOIN tasks ON tasks_fts.rowid = tasks.id
+            WHERE tasks_fts MATCH ?`
+	rank := "rank"
+	var args []interface{}
+	if text == "" {
+		source, rank = "FROM tasks WHERE 1 = 1", "0"
+	} else {
+		args = append(args, fts5MatchQuery)
+	}
+	for _, tag := range tags {
+		source += " AND tasks.id IN (SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE tags.name = ?)"
+		args = append(args, tag)
+	}
 
 	// Build the raw SQL query with ranking logic.
 	// Rank higher: exact title matches, tasks closer to today's date, FTS rank.
@@ -280,6 +382,7 @@ func SearchTasks(query string, limit int, offset int) (Tasks, error) {
         WITH RankedTasks AS (
             SELECT
                 tasks.id,
+                tasks.uid,
                 tasks.title,
                 tasks.due_date,
                 tasks.completed,
@@ -288,15 +391,25 @@ func SearchTasks(query string, limit int, offset int) (Tasks, error) {
                 tasks.description,
                 tasks.recurrence_rule,
                 tasks.recurrence_interval, -- Include interval
-                rank -- FTS rank
-            FROM tasks_fts
-            JOIN tasks ON tasks_fts.rowid = tasks.id
-            WHERE tasks_fts MATCH ?
+                tasks.updated_at,
+                tasks.parent_id, -- Subtasks are searched too.
+                tasks.series_id,
+                tasks.occurrence_date,
+                tasks.spawned_from,
+                tasks.due_time,
+                tasks.duration_minutes,
+                tasks.remind_at,
+                tasks.tags,
+                tasks.list_id,
+                (SELECT count(*) FROM tasks AS sub WHERE sub.parent_id = tasks.id) AS subtasks_total,
+                (SELECT count(*) FROM tasks AS sub WHERE sub.parent_id = tasks.id AND sub.completed = 1) AS subtasks_completed,
+                ` + rank + ` AS rank -- FTS rank
+            ` + source + `
         )
         SELECT
             rt.*,
             (CASE WHEN rt.title LIKE ? THEN 1 ELSE 0 END) AS exact_match_boost,
-            ABS(JULIANDAY('now') - JULIANDAY(rt.due_date)) AS date_proximity -- Lower is better
+            ABS(JULIANDAY(?) - JULIANDAY(rt.due_date)) AS date_proximity -- Days from today, lower is better
         FROM RankedTasks rt
         ORDER BY
             exact_match_boost DESC, -- Prioritize exact title matches
@@ -308,17 +421,17 @@ func SearchTasks(query string, limit int, offset int) (Tasks, error) {
         LIMIT ? OFFSET ?`
 
 	// Arguments for the prepared statement.
-	args := []interface{}{
-		fts5MatchQuery,
+	args = append(args,
 		exactQuery,
+		dateOnly(today).Format(config.DateFormat),
 		limit,
 		offset,
-	}
+	)
 
-	slog.Debug("Searching tasks with FTS query", "fts_query", fts5MatchQuery, "exact_query", exactQuery, "limit", limit, "offset", offset)
+	slog.Debug("Searching tasks with FTS query", "fts_query", fts5MatchQuery, "tags", tags, "exact_query", exactQuery, "limit", limit, "offset", offset)
 
 	// Execute the raw query.
-	if err := GetDB().Raw(queryString, args...).Scan(&tasks).Error; err != nil {
+	if err := s.DB().Raw(queryString, args...).Scan(&tasks).Error; err != nil {
 		// Check for specific SQLite errors like FTS5 syntax error if needed.
 		return nil, fmt.Errorf("searchTasks raw query failed: %w", err)
 	}
@@ -356,125 +469,100 @@ func escapeFTS5Query(query string) (string, bool) {
 	return escaped, false
 }
 
-// CalculateNextDueDate calculates the next due date based on the current date,
-// recurrence rule, and interval. Returns the calculated date and an error if
-// the rule is invalid or unsupported.
-func CalculateNextDueDate(currentDate time.Time, rule string, interval int) (time.Time, error) {
-	if interval <= 0 {
-		// Use a default interval of 1 if an invalid one is provided.
-		slog.Warn("CalculateNextDueDate received invalid interval, defaulting to 1", "rule", rule, "interval", interval)
-		interval = 1
-	}
-
-	nextDueDate := currentDate
-	switch rule {
-	case "daily":
-		nextDueDate = nextDueDate.AddDate(0, 0, 1*interval)
-	case "weekly":
-		nextDueDate = nextDueDate.AddDate(0, 0, 7*interval)
-	case "monthly":
-		nextDueDate = nextDueDate.AddDate(0, 1*interval, 0) // Add N months. Handles month rollovers.
-	case "yearly":
-		nextDueDate = nextDueDate.AddDate(1*interval, 0, 0) // Add N years. Handles leap years correctly.
-	case "":
-		// Cannot calculate the *next* date for a non-recurring task.
-		return time.Time{}, fmt.Errorf("cannot calculate next due date for an empty recurrence rule")
-	default:
-		return time.Time{}, fmt.Errorf("unsupported recurrence rule: %s", rule)
-	}
-	// Consider if the time part should be zeroed out (e.g., nextDueDate = nextDueDate.Truncate(24 * time.Hour))
-	// This depends on whether recurrence should preserve the time of day. For simplicity, we don't truncate here.
-	return nextDueDate, nil
+// simpleRules are the recurrence rules of the original planner, kept as
+// shorthands for RRULEs with just a frequency and recurrence_interval.
+var simpleRules = map[string]rrule.Frequency{
+	"daily":   rrule.Daily,
+	"weekly":  rrule.Weekly,
+	"monthly": rrule.Monthly,
+	"yearly":  rrule.Yearly,
 }
 
-// CreateNextOccurrencesForUndoneRecurringTasks finds recurring tasks due before today
-// that are not completed, calculates their next due date *on or after* today,
-// and creates new task instances for those future dates.
-func CreateNextOccurrencesForUndoneRecurringTasks() error {
-	today := time.Now().Truncate(24 * time.Hour) // Get start of today (00:00:00 UTC or local based on server).
-
-	return GetDB().Transaction(func(tx *gorm.DB) error {
-		var tasks []Task
-		// Find recurring tasks that were due *before* today and are NOT completed.
-		// DATE() function works well with SQLite for date comparisons.
-		result := tx.Where("recurrence_rule != '' AND DATE(due_date) < DATE(?) AND completed = ?", today, 0).Find(&tasks)
-		if result.Error != nil {
-			return fmt.Errorf("failed to find undone recurring tasks: %w", result.Error)
+// ErrRecurrenceEnded is returned when a recurrence has no further occurrence
+// (its COUNT or UNTIL has been reached).
+var ErrRecurrenceEnded = errors.New("recurrence has ended")
+
+// ParseRecurrence returns the RFC 5545 rule of a task recurrence starting on
+// start: one of the simple rules ("daily", "weekly", "monthly", "yearly")
+// repeated every interval periods, or an RRULE value such as
+// "FREQ=WEEKLY;BYDAY=MO,WE,FR", whose own INTERVAL is used instead.
+//
+// An RRULE skips the months that lack the day of the month of start, as RFC
+// 5545 has it. The simple rules predate RRULEs and never skipped a month: a
+// "monthly" task from January 31st falls on the last day of shorter months
+// (February 28th, then March 31st), and a "yearly" one from February 29th on
+// February 28th outside leap years.
+func ParseRecurrence(rule string, interval int, start time.Time) (rrule.Rule, error) {
+	freq, ok := simpleRules[rule]
+	if !ok {
+		return rrule.Parse(rule)
+	}
+	r := rrule.New(freq, interval)
+	day := start.Day()
+	if day > 28 && (freq == rrule.Monthly || (freq == rrule.Yearly && start.Month() == time.February)) {
+		// The last of the days from the 28th to that of start the month has.
+		for d := 28; d <= day; d++ {
+			r.ByMonthDay = append(r.ByMonthDay, d)
 		}
+		r.BySetPos = []int{-1}
+		if freq == rrule.Yearly {
+			r.ByMonth = []int{int(start.Month())}
+		}
+	}
+	return r, nil
+}
 
-		slog.Debug("Found undone recurring tasks due before today", "count", len(tasks))
-
-		for _, task := range tasks {
-			if !task.DueDate.Valid {
-				slog.Warn("Recurring task without a valid due date found", "task_id", task.ID)
-				continue // Skip tasks without a valid due date.
-			}
-			if task.RecurrenceRule == "" {
-				slog.Warn("Task found with empty recurrence rule despite query filter", "task_id", task.ID)
-				continue // Should not happen based on query, but good practice to check.
-			}
-
-			currentDueDate := task.DueDate.Time
-			var nextDueDate time.Time
-			var err error
-
-			// Calculate the first occurrence date that is *on or after* today.
-			// Start calculation from the original due date of the found (past-due) task.
-			calculatedDate := currentDueDate
-			iterationCount := 0   // Safety counter
-			maxIterations := 1000 // Prevent potential infinite loops
-
-			for (calculatedDate.Before(today) || calculatedDate.Equal(today)) && iterationCount < maxIterations {
-				calculatedDate, err = CalculateNextDueDate(calculatedDate, task.RecurrenceRule, task.RecurrenceInterval)
-				if err != nil {
-					slog.Error("Error calculating next due date for undone task", "task_id", task.ID, "rule", task.RecurrenceRule, "interval", task.RecurrenceInterval, "error", err)
-					break // Stop processing this specific task if rule is bad or calculation fails.
-				}
-				// Safety check: ensure calculation progresses.
-				if calculatedDate.Before(currentDueDate) || calculatedDate.Equal(currentDueDate) {
-					slog.Error("Recurrence calculation did not advance date, potential loop", "task_id", task.ID, "rule", task.RecurrenceRule, "interval", task.RecurrenceInterval)
-					err = fmt.Errorf("recurrence calculation did not advance date")
-					break
-				}
-				iterationCount++
-			}
+// NormalizeRecurrenceRule validates a recurrence rule and returns it as it is
+// stored: empty and simple rules unchanged, RRULEs in canonical form.
+func NormalizeRecurrenceRule(rule string) (string, error) {
+	if _, ok := simpleRules[rule]; ok || rule == "" {
+		return rule, nil
+	}
+	r, err := rrule.Parse(rule)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
 
-			if err != nil { // If the loop broke because of an error.
-				continue // Move to the next task in the outer loop.
-			}
-			if iterationCount >= maxIterations {
-				slog.Error("Exceeded max iterations calculating next due date, potential infinite loop", "task_id", task.ID, "rule", task.RecurrenceRule, "interval", task.RecurrenceInterval)
-				continue // Skip this task
-			}
+// IsValidRecurrenceRule reports whether rule is empty, a simple rule or an RRULE.
+func IsValidRecurrenceRule(rule string) bool {
+	_, err := NormalizeRecurrenceRule(rule)
+	return err == nil
+}
 
-			// 'calculatedDate' now holds the first date that is on or after today.
-			nextDueDate = calculatedDate
-
-			slog.Debug("Calculated next occurrence date", "original_task_id", task.ID, "original_due_date", currentDueDate.Format(config.DateFormat), "next_due_date", nextDueDate.Format(config.DateFormat))
-
-			// Create the new task occurrence for the calculated future date.
-			newTask := Task{
-				Title:              task.Title,
-				Description:        task.Description, // Copy relevant fields.
-				Color:              task.Color,
-				RecurrenceRule:     task.RecurrenceRule,     // Keep the recurrence rule.
-				RecurrenceInterval: task.RecurrenceInterval, // Keep the interval.
-				DueDate:            NullTime{Time: nextDueDate, Valid: true},
-				Completed:          0, // New occurrence is not completed.
-				TaskOrder:          0, // Reset order, or implement specific logic if needed.
-			}
+// CalculateNextDueDate calculates the next due date based on the current date,
+// recurrence rule, and interval, with the recurrence starting on the current
+// date (see ParseRecurrence). Returns the calculated date and an error if
+// the rule is invalid, or ErrRecurrenceEnded if there is no next date.
+func CalculateNextDueDate(currentDate time.Time, rule string, interval int) (time.Time, error) {
+	if rule == "" {
+		// Cannot calculate the *next* date for a non-recurring task.
+		return time.Time{}, fmt.Errorf("cannot calculate next due date for an empty recurrence rule")
+	}
+	// Recur on the calendar date of currentDate in its own time zone.
+	currentDate = dateOnly(currentDate)
+	r, err := ParseRecurrence(rule, interval, currentDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unsupported recurrence rule %q: %w", rule, err)
+	}
+	next, _, ok := r.After(currentDate, currentDate)
+	if !ok {
+		return time.Time{}, ErrRecurrenceEnded
+	}
+	return next, nil
+}
 
-			if err := tx.Create(&newTask).Error; err != nil {
-				// Log error but continue processing other tasks; transaction handles rollback on failure.
-				slog.Error("Failed to create next occurrence", "original_task_id", task.ID, "error", err)
-				// To stop the whole process on first failure, uncomment the next line:
-				// return fmt.Errorf("failed to create next occurrence for task ID %d: %w", task.ID, err)
-			} else {
-				slog.Info("Created next occurrence for undone recurring task", "original_task_id", task.ID, "new_task_id", newTask.ID, "new_due_date", newTask.DueDate.Time.Format(config.DateFormat))
-			}
-		}
-		return nil // Commit transaction if loop completes without returning an error.
+// CreateNextOccurrencesForUndoneRecurringTasks finds the series whose latest
+// occurrence is not completed and was due before today, and creates their
+// next occurrence after today. Occurrences already created are not created
+// again, so running it twice changes nothing. today is the current date in
+// the planner's time zone (see Today).
+func (s *Store) CreateNextOccurrencesForUndoneRecurringTasks(today time.Time) error {
+	err := s.taskTransaction(func(tx sqlTx) error {
+		return createOverdueOccurrences(tx, today)
 	})
+	return txError("createNextOccurrences", err)
 }
 
 // --- NullTime Implementation ---
@@ -520,8 +608,9 @@ func (nt NullTime) Value() (driver.Value, error) {
 	if !nt.Valid {
 		return nil, nil
 	}
-	// Return the time value.
-	return nt.Time, nil
+	// Store the calendar date at midnight UTC whatever zone nt.Time is in,
+	// so that DATE(due_date) in queries yields that date.
+	return dateOnly(nt.Time), nil
 }
 
 // Scan implements the sql.Scanner interface for database deserialization.
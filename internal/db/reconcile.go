@@ -0,0 +1,262 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"week-planner/internal/config"
+
+	"gorm.io/gorm"
+)
+
+// ReconcileReport describes what Reconcile merged, or would merge on a dry run.
+type ReconcileReport struct {
+	DryRun     bool                  `json:"dry_run"`
+	Merged     []ReconciledSeries    `json:"merged"`
+	Duplicates []ReconciledDuplicate `json:"duplicates"`
+}
+
+// ReconciledSeries is a series other series were merged into.
+type ReconciledSeries struct {
+	SeriesID  int    `json:"series_id"`
+	Title     string `json:"title"`
+	Rule      string `json:"recurrence_rule"`
+	MergedIDs []int  `json:"merged_ids"` // The series merged into it, which are deleted.
+}
+
+// ReconciledDuplicate is an occurrence deleted in favour of another one of
+// the same series on the same date.
+type ReconciledDuplicate struct {
+	TaskID         int    `json:"task_id"`
+	KeptID         int    `json:"kept_id"`
+	Title          string `json:"title"`
+	OccurrenceDate string `json:"occurrence_date"`
+}
+
+// Reconcile repairs recurring tasks duplicated by versions that created a new
+// instance for every overdue recurring task each time the planner was
+// loaded. Such a database has, after the migration to series, one series per
+// instance: series with the same title and rule whose dates follow the rule
+// of the oldest one are merged into it, keeping one occurrence per date, and
+// each occurrence is marked as spawned by the one before it so that it is
+// not followed by another. A dry run reports the same without changing
+// anything.
+func (s *Store) Reconcile(dryRun bool) (ReconcileReport, error) {
+	var report ReconcileReport
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if report, err = reconcile(sqlTx{db: tx}); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return ReconcileReport{}, fmt.Errorf("reconcile: %w", err)
+	}
+	report.DryRun = dryRun
+	return report, nil
+}
+
+// reconcile merges duplicated series, see Store.Reconcile.
+func reconcile(tx taskTx) (ReconcileReport, error) {
+	report := ReconcileReport{Merged: []ReconciledSeries{}, Duplicates: []ReconciledDuplicate{}}
+	all, err := tx.allSeries()
+	if err != nil {
+		return report, err
+	}
+
+	// Instances of one recurring task share its title and rule; COUNT is
+	// left out since each instance counted down from its predecessor, and
+	// the start date, which simple rules depend on, since each instance
+	// started on a date of its own.
+	var keys []string
+	groups := map[string][]Series{}
+	for _, series := range all {
+		r, err := ParseRecurrence(series.RecurrenceRule, series.RecurrenceInterval, time.Time{})
+		if err != nil {
+			slog.Warn("Reconcile: skipping series with an unsupported rule", "series_id", series.ID, "rule", series.RecurrenceRule)
+			continue
+		}
+		r.Count = 0
+		key := series.Title + "\x00" + r.String()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], series)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			return dateOnly(group[i].StartDate).Before(dateOnly(group[j].StartDate))
+		})
+		target := group[0]
+		merged := ReconciledSeries{SeriesID: target.ID, Title: target.Title, Rule: target.RecurrenceRule}
+		for _, series := range group[1:] {
+			ok, err := followsRule(tx, target, series.StartDate)
+			if err != nil {
+				return report, err
+			}
+			if !ok {
+				slog.Info("Reconcile: series does not follow the rule of an older one with the same title", "series_id", series.ID, "older_series_id", target.ID)
+				continue
+			}
+			duplicates, err := mergeSeries(tx, target, series)
+			if err != nil {
+				return report, fmt.Errorf("merging series %d into %d: %w", series.ID, target.ID, err)
+			}
+			report.Duplicates = append(report.Duplicates, duplicates...)
+			merged.MergedIDs = append(merged.MergedIDs, series.ID)
+		}
+		if len(merged.MergedIDs) == 0 {
+			continue
+		}
+		if err := linkOccurrences(tx, target); err != nil {
+			return report, fmt.Errorf("series %d: %w", target.ID, err)
+		}
+		slog.Info("Reconcile: merged duplicated series", "series_id", target.ID, "merged", merged.MergedIDs)
+		report.Merged = append(report.Merged, merged)
+	}
+	return report, nil
+}
+
+// followsRule reports whether date is a date of the rule of series, or the
+// date of one of its occurrences.
+func followsRule(tx taskTx, series Series, date time.Time) (bool, error) {
+	date = dateOnly(date)
+	occurrences, err := tx.occurrences(series.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, o := range occurrences {
+		if dateOnly(o.OccurrenceDate.Time).Equal(date) {
+			return true, nil
+		}
+	}
+	r, err := ParseRecurrence(series.RecurrenceRule, series.RecurrenceInterval, series.StartDate)
+	if err != nil {
+		return false, err
+	}
+	it := r.Iter(dateOnly(series.StartDate))
+	for {
+		t, ok := it.Next()
+		if !ok || dateOnly(t).After(date) {
+			return false, nil
+		}
+		if dateOnly(t).Equal(date) {
+			return true, nil
+		}
+	}
+}
+
+// mergeSeries moves the occurrences and exceptions of series to target and
+// deletes series. Of two occurrences on the same date the completed one, or
+// the one with more subtasks, or else the older one is kept.
+func mergeSeries(tx taskTx, target, series Series) ([]ReconciledDuplicate, error) {
+	existing, err := tx.occurrences(target.ID)
+	if err != nil {
+		return nil, err
+	}
+	byDate := map[string]Task{}
+	for _, o := range existing {
+		byDate[o.OccurrenceDate.Time.Format(config.DateFormat)] = o
+	}
+	occurrences, err := tx.occurrences(series.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var duplicates []ReconciledDuplicate
+	for _, o := range occurrences {
+		date := o.OccurrenceDate.Time.Format(config.DateFormat)
+		if other, ok := byDate[date]; ok {
+			kept, dropped := other, o
+			if preferOccurrence(o, other) {
+				kept, dropped = o, other
+			}
+			if err := tx.deleteTask(dropped.ID); err != nil {
+				return nil, err
+			}
+			duplicates = append(duplicates, ReconciledDuplicate{TaskID: dropped.ID, KeptID: kept.ID, Title: dropped.Title, OccurrenceDate: date})
+			if kept.ID == other.ID {
+				continue
+			}
+		}
+		o.SeriesID = &target.ID
+		if err := tx.saveTask(o); err != nil {
+			return nil, err
+		}
+		byDate[date] = o
+	}
+
+	targetExceptions, err := tx.exceptions(target.ID)
+	if err != nil {
+		return nil, err
+	}
+	taken := map[string]bool{}
+	for _, e := range targetExceptions {
+		taken[dateOnly(e.OccurrenceDate).Format(config.DateFormat)] = true
+	}
+	exceptions, err := tx.exceptions(series.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exceptions {
+		if taken[dateOnly(e.OccurrenceDate).Format(config.DateFormat)] {
+			continue
+		}
+		e.SeriesID = target.ID
+		if err := tx.saveException(e); err != nil {
+			return nil, err
+		}
+	}
+	return duplicates, tx.deleteSeries(series.ID)
+}
+
+// preferOccurrence reports whether a should be kept rather than b, its
+// duplicate.
+func preferOccurrence(a, b Task) bool {
+	if a.Completed != b.Completed {
+		return a.Completed != 0
+	}
+	if a.SubtasksTotal != b.SubtasksTotal {
+		return a.SubtasksTotal > b.SubtasksTotal
+	}
+	return a.ID < b.ID
+}
+
+// linkOccurrences marks each occurrence of series that was not created from
+// another as spawned by the one before it, and gives it the rule as seen
+// from its date.
+func linkOccurrences(tx taskTx, series Series) error {
+	occurrences, err := tx.occurrences(series.ID)
+	if err != nil {
+		return err
+	}
+	for i, o := range occurrences {
+		o.RecurrenceRule, o.RecurrenceInterval = ruleFrom(series, o.OccurrenceDate.Time)
+		if i > 0 && o.SpawnedFrom == nil {
+			previous := occurrences[i-1].ID
+			spawned, err := tx.spawned(previous)
+			if err != nil {
+				return err
+			}
+			if !spawned {
+				o.SpawnedFrom = &previous
+			}
+		}
+		if err := tx.saveTask(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
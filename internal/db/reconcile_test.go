@@ -0,0 +1,149 @@
+//go:build sqlite_fts5
+
+package db
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// duplicatedSchema is a baseline database in which loading the planner
+// created a new instance of "Water plants" for every overdue one: after
+// the migration to series each instance is a series of its own.
+const duplicatedSchema = baselineSchema + `
+INSERT INTO tasks (id, title, due_date, completed, description, recurrence_rule, recurrence_interval) VALUES
+    (1, 'Water plants', '2026-10-05', 1, '', 'weekly', 1),
+    (2, 'Water plants', '2026-10-12', 0, '', 'weekly', 1),
+    (3, 'Water plants', '2026-10-12', 0, '- [ ] Fill the can
+- [x] Check the soil', 'weekly', 1),
+    (4, 'Water plants', '2026-10-19', 0, '', 'weekly', 1),
+    (5, 'Water plants', '2026-10-19', 1, '', 'weekly', 1),
+    (6, 'Water plants', '2026-10-26', 0, '', 'weekly', 1),
+    (7, 'Water plants', '2026-10-07', 1, '', 'weekly', 1),
+    (8, 'Pay rent', '2026-10-01', 1, '', 'monthly', 1);
+`
+
+func TestReconcile(t *testing.T) {
+	store := openLegacy(t, duplicatedSchema)
+	seriesOf := func(id int) int {
+		t.Helper()
+		task, err := store.GetTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *task.SeriesID
+	}
+	target := seriesOf(1)
+	want := ReconcileReport{
+		Merged: []ReconciledSeries{{
+			SeriesID: target, Title: "Water plants", Rule: "weekly",
+			MergedIDs: []int{seriesOf(2), seriesOf(3), seriesOf(4), seriesOf(5), seriesOf(6)},
+		}},
+		Duplicates: []ReconciledDuplicate{
+			// The one with subtasks, converted from its checklist, and the
+			// completed one are kept.
+			{TaskID: 2, KeptID: 3, Title: "Water plants", OccurrenceDate: "2026-10-12"},
+			{TaskID: 4, KeptID: 5, Title: "Water plants", OccurrenceDate: "2026-10-19"},
+		},
+	}
+	before, _ := plan(t, store)
+
+	report, err := store.Reconcile(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.DryRun = true
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("dry run = %+v, want %+v", report, want)
+	}
+	if got, _ := plan(t, store); !slices.Equal(got, before) {
+		t.Errorf("tasks after a dry run = %v, want unchanged %v", got, before)
+	}
+
+	report, err = store.Reconcile(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.DryRun = false
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	// 10-07 does not follow the rule of the series from 10-05, so it stays
+	// a series of its own.
+	got, series := plan(t, store)
+	wantPlan := []string{
+		"2026-10-01 Pay rent a",
+		"2026-10-05 Water plants b",
+		"2026-10-07 Water plants c",
+		"2026-10-12 Water plants b",
+		"2026-10-19 Water plants b",
+		"2026-10-26 Water plants b",
+	}
+	if !slices.Equal(got, wantPlan) {
+		t.Errorf("tasks = %v, want %v", got, wantPlan)
+	}
+	var spawned []int
+	for _, o := range series["b"].Occurrences {
+		if o.SpawnedFrom == nil {
+			spawned = append(spawned, 0)
+		} else {
+			spawned = append(spawned, *o.SpawnedFrom)
+		}
+	}
+	if want := []int{0, 1, 3, 5}; !slices.Equal(spawned, want) {
+		t.Errorf("occurrences spawned from %v, want %v", spawned, want)
+	}
+	if kept, err := store.GetTask(3); err != nil || kept.SubtasksTotal != 2 || kept.SubtasksCompleted != 1 {
+		t.Errorf("kept occurrence = %+v, %v, want its two subtasks", kept, err)
+	}
+
+	// The merged series goes on once, however often it is rolled over or
+	// its old occurrences are completed.
+	today := TodayOn(FixedClock(time.Date(2026, 10, 28, 9, 0, 0, 0, time.UTC)), time.UTC)
+	for range 2 {
+		if err := store.CreateNextOccurrencesForUndoneRecurringTasks(today); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []int{3, 6} {
+		if err := store.UpdateTask(id, map[string]interface{}{"completed": true}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, _ := plan(t, store); !slices.Equal(got, append(wantPlan, "2026-11-02 Water plants b")) {
+		t.Errorf("tasks after rollover = %v, want one more occurrence on 2026-11-02", got)
+	}
+
+	// Nothing is left to merge.
+	if report, err := store.Reconcile(false); err != nil || len(report.Merged) != 0 || len(report.Duplicates) != 0 {
+		t.Errorf("reconciling again = %+v, %v, want nothing merged", report, err)
+	}
+}
+
+func TestSpawnedFromMigration(t *testing.T) {
+	store := openLegacy(t, duplicatedSchema)
+	// Each legacy instance is a series of its own, so none was spawned by
+	// another and each may still spawn its next occurrence once.
+	for id := 1; id <= 8; id++ {
+		task, err := store.GetTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.SpawnedFrom != nil {
+			t.Errorf("task %d spawned from %d", id, *task.SpawnedFrom)
+		}
+	}
+	if err := store.UpdateTask(6, map[string]interface{}{"completed": true}, ""); err != nil {
+		t.Fatal(err)
+	}
+	next := occurrenceOn(t, store, "2026-11-02")
+	if next.SpawnedFrom == nil || *next.SpawnedFrom != 6 {
+		t.Errorf("next occurrence spawned from %v, want 6", next.SpawnedFrom)
+	}
+	// The unique index on spawned_from keeps a second one from being created.
+	if err := store.DB().Exec("UPDATE tasks SET spawned_from = 6 WHERE id = 2").Error; err == nil {
+		t.Error("two tasks spawned from the same occurrence")
+	}
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"week-planner/internal/config"
+)
+
+func TestRecurrenceMonthEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		rule     string
+		interval int
+		want     []string
+	}{
+		// The simple rules fall on the last day of shorter months.
+		{"monthly on the 31st", "2026-01-31", "monthly", 1, []string{"2026-02-28", "2026-03-31", "2026-04-30", "2026-05-31"}},
+		{"monthly on the 30th", "2026-01-30", "monthly", 1, []string{"2026-02-28", "2026-03-30", "2026-04-30"}},
+		{"monthly on the 29th in a leap year", "2028-01-29", "monthly", 1, []string{"2028-02-29", "2028-03-29"}},
+		{"monthly on the 29th", "2026-01-29", "monthly", 1, []string{"2026-02-28", "2026-03-29"}},
+		{"every other month on the 31st", "2026-01-31", "monthly", 2, []string{"2026-03-31", "2026-05-31", "2026-07-31", "2026-09-30", "2026-11-30", "2027-01-31"}},
+		{"yearly on February 29th", "2028-02-29", "yearly", 1, []string{"2029-02-28", "2030-02-28", "2031-02-28", "2032-02-29"}},
+		// Like RFC 5545, RRULEs skip months without the day.
+		{"RRULE on the 31st", "2026-01-31", "FREQ=MONTHLY", 1, []string{"2026-03-31", "2026-05-31", "2026-07-31", "2026-08-31"}},
+		{"RRULE on February 29th", "2028-02-29", "FREQ=YEARLY", 1, []string{"2032-02-29", "2036-02-29"}},
+		{"last day of the month", "2026-01-31", "FREQ=MONTHLY;BYMONTHDAY=-1", 1, []string{"2026-02-28", "2026-03-31", "2026-04-30"}},
+		{"last day of February in a leap year", "2028-01-31", "FREQ=MONTHLY;BYMONTHDAY=-1", 1, []string{"2028-02-29", "2028-03-31"}},
+		{"daily across the year end", "2026-12-31", "daily", 1, []string{"2027-01-01"}},
+		{"weekly across the month end", "2026-10-29", "weekly", 1, []string{"2026-11-05"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := mustDate(t, tt.from)
+			r, err := ParseRecurrence(tt.rule, tt.interval, start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, date := range r.Occurrences(start, len(tt.want)+1)[1:] {
+				got = append(got, date.Format(config.DateFormat))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("dates after %s = %v, want %v", tt.from, got, tt.want)
+			}
+			next, err := CalculateNextDueDate(start, tt.rule, tt.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := next.Format(config.DateFormat); got != tt.want[0] {
+				t.Errorf("CalculateNextDueDate(%s) = %s, want %s", tt.from, got, tt.want[0])
+			}
+		})
+	}
+}
+
+func TestRolloverCreatesNextOccurrenceAfterToday(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		mustCreate(t, store, Task{
+			Title:              "Stretch",
+			DueDate:            NullTime{Time: mustDate(t, "2026-10-15"), Valid: true},
+			RecurrenceRule:     "daily",
+			RecurrenceInterval: 1,
+		})
+		today := TodayOn(FixedClock(now), time.UTC)
+
+		// Overdue days are not filled in: the next occurrence is tomorrow.
+		// Running the rollover again, as on every load, changes nothing.
+		for range 3 {
+			if err := store.CreateNextOccurrencesForUndoneRecurringTasks(today); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, want := dueDates(t, store), []string{"2026-10-15", "2026-10-19"}; !slices.Equal(got, want) {
+			t.Errorf("due dates = %v, want %v", got, want)
+		}
+
+		// The next day the new occurrence is not overdue yet.
+		if err := store.CreateNextOccurrencesForUndoneRecurringTasks(today.AddDate(0, 0, 1)); err != nil {
+			t.Fatal(err)
+		}
+		if got := dueDates(t, store); len(got) != 2 {
+			t.Errorf("due dates on the next day = %v, want no new occurrence", got)
+		}
+	})
+}
+
+func TestRolloverSkipsCompletedAndUndatedTasks(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		done := mustCreate(t, store, Task{
+			Title:              "Pay rent",
+			DueDate:            NullTime{Time: mustDate(t, "2026-10-01"), Valid: true},
+			RecurrenceRule:     "monthly",
+			RecurrenceInterval: 1,
+		})
+		if err := store.UpdateTask(done.ID, map[string]interface{}{"completed": true}, ""); err != nil {
+			t.Fatal(err)
+		}
+		mustCreate(t, store, Task{Title: "Someday", RecurrenceRule: "weekly", RecurrenceInterval: 1})
+
+		before := dueDates(t, store)
+		if err := store.CreateNextOccurrencesForUndoneRecurringTasks(TodayOn(FixedClock(now), time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+		if got := dueDates(t, store); !slices.Equal(got, before) {
+			t.Errorf("due dates = %v after rollover, want unchanged %v", got, before)
+		}
+	})
+}
+
+func TestRolloverAtMonthEnd(t *testing.T) {
+	now := time.Date(2026, 2, 10, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		mustCreate(t, store, Task{
+			Title:              "Close the books",
+			DueDate:            NullTime{Time: mustDate(t, "2026-01-31"), Valid: true},
+			RecurrenceRule:     "FREQ=MONTHLY;BYMONTHDAY=-1",
+			RecurrenceInterval: 1,
+		})
+		mustCreate(t, store, Task{
+			Title:              "Invoice",
+			DueDate:            NullTime{Time: mustDate(t, "2026-01-31"), Valid: true},
+			RecurrenceRule:     "monthly",
+			RecurrenceInterval: 1,
+		})
+		mustCreate(t, store, Task{
+			Title:              "Report",
+			DueDate:            NullTime{Time: mustDate(t, "2026-01-31"), Valid: true},
+			RecurrenceRule:     "FREQ=MONTHLY",
+			RecurrenceInterval: 1,
+		})
+		if err := store.CreateNextOccurrencesForUndoneRecurringTasks(TodayOn(FixedClock(now), time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+		for date, want := range map[string][]string{
+			"2026-02-28": {"Close the books", "Invoice"}, // The simple rule falls on the last day of February,
+			"2026-03-31": {"Report"},                     // the RRULE skips it.
+		} {
+			tasks, err := store.GetTasks(TaskFilter{Date: date})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := titles(tasks); !slices.Equal(got, want) {
+				t.Errorf("tasks on %s = %v, want %v", date, got, want)
+			}
+		}
+	})
+}
+
+func TestCompletingOccurrenceCreatesNextOnce(t *testing.T) {
+	now := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		task := mustCreate(t, store, Task{
+			Title:              "Pay rent",
+			DueDate:            NullTime{Time: mustDate(t, "2026-01-31"), Valid: true},
+			RecurrenceRule:     "monthly",
+			RecurrenceInterval: 1,
+		})
+		// Completing, reopening and completing again must not create a
+		// second next occurrence.
+		for _, completed := range []bool{true, false, true} {
+			if err := store.UpdateTask(task.ID, map[string]interface{}{"completed": completed}, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, want := dueDates(t, store), []string{"2026-01-31", "2026-02-28"}; !slices.Equal(got, want) {
+			t.Errorf("due dates = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestRolloverEndsWithCount(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		mustCreate(t, store, Task{
+			Title:              "Physiotherapy",
+			DueDate:            NullTime{Time: mustDate(t, "2026-10-01"), Valid: true},
+			RecurrenceRule:     "FREQ=WEEKLY;COUNT=2",
+			RecurrenceInterval: 1,
+		})
+		today := TodayOn(FixedClock(now), time.UTC)
+		for range 2 {
+			if err := store.CreateNextOccurrencesForUndoneRecurringTasks(today); err != nil {
+				t.Fatal(err)
+			}
+			today = today.AddDate(0, 1, 0)
+		}
+		// The second and last occurrence, 2026-10-08, is before today.
+		if got, want := dueDates(t, store), []string{"2026-10-01"}; !slices.Equal(got, want) {
+			t.Errorf("due dates = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestObserversSeeCreatedOccurrences(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		var created []string
+		store.OnOccurrenceCreated(func(occurrence Task) {
+			created = append(created, occurrence.DueDate.Time.Format(config.DateFormat))
+		})
+		task := mustCreate(t, store, Task{
+			Title:              "Standup",
+			DueDate:            NullTime{Time: mustDate(t, "2026-10-12"), Valid: true},
+			RecurrenceRule:     "weekly",
+			RecurrenceInterval: 1,
+		})
+		if len(created) != 0 {
+			t.Fatalf("creating the first occurrence reported %v", created)
+		}
+
+		// Rolling over creates the occurrence after today. Completing the
+		// overdue one then creates nothing more, nor does a failed update.
+		if err := store.CreateNextOccurrencesForUndoneRecurringTasks(TodayOn(FixedClock(now), time.UTC)); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateTask(task.ID, map[string]interface{}{"completed": true}, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateTask(task.ID+100, map[string]interface{}{"completed": true}, ""); err == nil {
+			t.Fatal("updating a missing task succeeded")
+		}
+		if want := []string{"2026-10-19"}; !slices.Equal(created, want) {
+			t.Errorf("reported occurrences %v, want %v", created, want)
+		}
+	})
+}
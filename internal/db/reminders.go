@@ -0,0 +1,261 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tasks are dated, not timed. A task may still have a time of day (due_time,
+// "15:04"), a duration and a reminder (remind_at), which are read in the
+// planner's time zone like "today".
+
+// TimeFormat is the format of due_time.
+const TimeFormat = "15:04"
+
+// reminderLocalFormat is an absolute remind_at without a time zone, read in
+// the planner's.
+const reminderLocalFormat = "2006-01-02T15:04"
+
+// Reminder is a parsed remind_at: an absolute time, or an offset from the
+// start of the task.
+type Reminder struct {
+	At       time.Time // Absolute reminder time; its location is ignored when Floating.
+	Floating bool      // At was given without a time zone.
+	Relative bool
+	Days     int           // Whole days of a relative reminder, counted in calendar days.
+	Offset   time.Duration // The rest of a relative reminder.
+}
+
+// durationPattern matches an RFC 5545 duration such as "-PT15M" or "-P1DT12H".
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseReminder parses a remind_at value: a time "2006-01-02T15:04" in the
+// planner's time zone, an RFC 3339 time with an offset, or an RFC 5545
+// duration relative to the start of the task, e.g. "-PT15M" for 15 minutes
+// before, "PT0S" for on time or "-P1D" for a day before.
+func ParseReminder(value string) (Reminder, error) {
+	value = strings.TrimSpace(value)
+	if m := durationPattern.FindStringSubmatch(strings.ToUpper(value)); m != nil && hasDurationComponent(m) {
+		n := func(s string) int {
+			v, _ := strconv.Atoi(s) // The pattern only matches digits.
+			return v
+		}
+		r := Reminder{
+			Relative: true,
+			Days:     7*n(m[2]) + n(m[3]),
+			Offset:   time.Duration(n(m[4]))*time.Hour + time.Duration(n(m[5]))*time.Minute + time.Duration(n(m[6]))*time.Second,
+		}
+		if m[1] == "-" {
+			r.Days, r.Offset = -r.Days, -r.Offset
+		}
+		return r, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return Reminder{At: t}, nil
+	}
+	if t, err := time.Parse(reminderLocalFormat, value); err == nil {
+		return Reminder{At: t, Floating: true}, nil
+	}
+	return Reminder{}, fmt.Errorf("expected a time such as 2026-10-20T09:00 or a duration such as -PT15M")
+}
+
+// hasDurationComponent reports whether a match of durationPattern has a
+// number in it: "P", "-P", "PT" and "P1DT" are no durations.
+func hasDurationComponent(m []string) bool {
+	if strings.HasSuffix(m[0], "T") {
+		return false
+	}
+	for _, component := range m[2:] {
+		if component != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the canonical form of r.
+func (r Reminder) String() string {
+	switch {
+	case r.Floating:
+		return r.At.Format(reminderLocalFormat)
+	case !r.Relative:
+		return r.At.Format(time.RFC3339)
+	}
+	days, offset, sign := r.Days, r.Offset, ""
+	if days < 0 || offset < 0 {
+		days, offset, sign = -days, -offset, "-"
+	}
+	var b strings.Builder
+	b.WriteString(sign + "P")
+	if days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+	}
+	if offset > 0 || days == 0 {
+		b.WriteString("T")
+		h, m, s := int(offset/time.Hour), int(offset%time.Hour/time.Minute), int(offset%time.Minute/time.Second)
+		if h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+		}
+		if m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+		}
+		if s > 0 || offset == 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
+
+// Start returns when the task starts in loc: its due date at its due time, or
+// at midnight without one. It returns false for tasks without a due date.
+func (t Task) Start(loc *time.Location) (time.Time, bool) {
+	if !t.DueDate.Valid {
+		return time.Time{}, false
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	y, m, d := t.DueDate.Time.Date()
+	var hour, minute int
+	if clock, err := time.Parse(TimeFormat, t.DueTime); err == nil {
+		hour, minute = clock.Hour(), clock.Minute()
+	}
+	return time.Date(y, m, d, hour, minute, 0, 0, loc), true
+}
+
+// ReminderTime returns when the reminder of the task is due in loc. It
+// returns false when the task has no reminder, or one relative to a due date
+// it does not have.
+func (t Task) ReminderTime(loc *time.Location) (time.Time, bool) {
+	if t.RemindAt == "" {
+		return time.Time{}, false
+	}
+	r, err := ParseReminder(t.RemindAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	switch {
+	case r.Floating:
+		y, m, d := r.At.Date()
+		return time.Date(y, m, d, r.At.Hour(), r.At.Minute(), 0, 0, loc), true
+	case !r.Relative:
+		return r.At, true
+	}
+	start, ok := t.Start(loc)
+	if !ok {
+		return time.Time{}, false
+	}
+	return start.AddDate(0, 0, r.Days).Add(r.Offset), true
+}
+
+// normalizeDueTime checks a due_time and returns it as "15:04".
+func normalizeDueTime(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	t, err := time.Parse(TimeFormat, value)
+	if err != nil {
+		return "", fmt.Errorf("expected HH:MM")
+	}
+	return t.Format(TimeFormat), nil
+}
+
+// normalizeRemindAt checks a remind_at and returns its canonical form.
+func normalizeRemindAt(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	r, err := ParseReminder(value)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// ReminderDelivery records a reminder the dispatcher fired. There is one per
+// task and reminder time, so that a reminder fires exactly once even across
+// restarts, and again only when it is moved.
+type ReminderDelivery struct {
+	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaskID      int       `gorm:"not null" json:"task_id"`
+	Title       string    `json:"title"` // Title of the task when the reminder fired.
+	RemindAt    time.Time `json:"remind_at"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
+
+// ReminderStore records the reminders fired by the reminder dispatcher.
+// *Store and MemoryStore implement it.
+type ReminderStore interface {
+	// DeliverReminders records and returns the reminders of open tasks due
+	// in (since, now], read in loc, that were not delivered yet.
+	DeliverReminders(since, now time.Time, loc *time.Location) ([]ReminderDelivery, error)
+	// GetReminders returns up to limit delivered reminders with an ID above
+	// afterID, oldest first.
+	GetReminders(afterID int, limit int) ([]ReminderDelivery, error)
+}
+
+var (
+	_ ReminderStore = (*Store)(nil)
+	_ ReminderStore = (*MemoryStore)(nil)
+)
+
+// dueReminders returns the reminders of the open tasks among tasks that are
+// due in (since, now], with their time in UTC.
+func dueReminders(tasks Tasks, since, now time.Time, loc *time.Location) []ReminderDelivery {
+	var due []ReminderDelivery
+	for _, task := range tasks {
+		if task.Completed != 0 {
+			continue
+		}
+		at, ok := task.ReminderTime(loc)
+		if !ok || !at.After(since) || at.After(now) {
+			continue
+		}
+		due = append(due, ReminderDelivery{TaskID: task.ID, Title: task.Title, RemindAt: at.UTC(), DeliveredAt: now.UTC()})
+	}
+	return due
+}
+
+// DeliverReminders implements ReminderStore. A unique index on the task and
+// reminder time makes concurrent dispatchers deliver each reminder once.
+func (s *Store) DeliverReminders(since, now time.Time, loc *time.Location) ([]ReminderDelivery, error) {
+	var delivered []ReminderDelivery
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		var tasks Tasks
+		if err := tx.Where("completed = 0 AND remind_at != ''").Find(&tasks).Error; err != nil {
+			return err
+		}
+		for _, d := range dueReminders(tasks, since, now, loc) {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&d)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 1 {
+				delivered = append(delivered, d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("deliverReminders: %w", err)
+	}
+	return delivered, nil
+}
+
+// GetReminders implements ReminderStore.
+func (s *Store) GetReminders(afterID int, limit int) ([]ReminderDelivery, error) {
+	reminders := []ReminderDelivery{}
+	if err := s.DB().Where("id > ?", afterID).Order("id").Limit(limit).Find(&reminders).Error; err != nil {
+		return nil, fmt.Errorf("getReminders: %w", err)
+	}
+	return reminders, nil
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReminder(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"-PT15M", "-PT15M"},
+		{"-pt15m", "-PT15M"},
+		{"PT0S", "PT0S"},
+		{"+PT1H30M", "PT1H30M"},
+		{"-P1D", "-P1D"},
+		{"-P1W", "-P7D"},
+		{"-P1DT12H", "-P1DT12H"},
+		{"2026-10-20T09:00", "2026-10-20T09:00"},
+		{"2026-10-20T09:00:00+02:00", "2026-10-20T09:00:00+02:00"},
+	}
+	for _, tt := range tests {
+		r, err := ParseReminder(tt.value)
+		if err != nil {
+			t.Errorf("ParseReminder(%q): %v", tt.value, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("ParseReminder(%q).String() = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+	for _, value := range []string{"", "P", "p", "-P", "pt", "-PT", "P1DT", "p1dt", "15 minutes", "2026-10-20", "PT-5M", "09:00"} {
+		if _, err := ParseReminder(value); err == nil {
+			t.Errorf("ParseReminder(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestReminderTime(t *testing.T) {
+	due := NullTime{Time: mustDate(t, "2026-10-20"), Valid: true}
+	tests := []struct {
+		name string
+		task Task
+		want string // RFC 3339, empty for no reminder.
+	}{
+		{"before the due time", Task{DueDate: due, DueTime: "09:00", RemindAt: "-PT15M"}, "2026-10-20T08:45:00+09:00"},
+		{"without a due time", Task{DueDate: due, RemindAt: "PT0S"}, "2026-10-20T00:00:00+09:00"},
+		{"a day before", Task{DueDate: due, DueTime: "18:30", RemindAt: "-P1D"}, "2026-10-19T18:30:00+09:00"},
+		{"floating", Task{RemindAt: "2026-10-19T07:00"}, "2026-10-19T07:00:00+09:00"},
+		{"with an offset", Task{RemindAt: "2026-10-19T07:00:00Z"}, "2026-10-19T07:00:00Z"},
+		{"relative without a due date", Task{RemindAt: "-PT15M"}, ""},
+		{"none", Task{DueDate: due, DueTime: "09:00"}, ""},
+	}
+	for _, tt := range tests {
+		got, ok := tt.task.ReminderTime(tokyo)
+		switch {
+		case tt.want == "" && ok:
+			t.Errorf("%s: ReminderTime = %s, want none", tt.name, got)
+		case tt.want != "" && (!ok || got.Format(time.RFC3339) != tt.want):
+			t.Errorf("%s: ReminderTime = %s, %v, want %s", tt.name, got.Format(time.RFC3339), ok, tt.want)
+		}
+	}
+}
+
+func TestValidateRejectsBadTimes(t *testing.T) {
+	for _, task := range []Task{
+		{Title: "a", DueTime: "25:00"},
+		{Title: "a", DueTime: "9am"},
+		{Title: "a", DurationMinutes: -5},
+		{Title: "a", RemindAt: "soon"},
+	} {
+		if err := task.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", task)
+		}
+	}
+	task := Task{Title: "a", DueTime: "9:05", RemindAt: "-pt5m"}
+	if err := task.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if task.DueTime != "09:05" || task.RemindAt != "-PT5M" {
+		t.Errorf("Validate normalized to %q, %q, want 09:05, -PT5M", task.DueTime, task.RemindAt)
+	}
+}
+
+func TestDeliverRemindersOnce(t *testing.T) {
+	now := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		reminders := store.(ReminderStore)
+		due := NullTime{Time: mustDate(t, "2026-10-20"), Valid: true}
+		call := mustCreate(t, store, Task{Title: "Call bank", DueDate: due, DueTime: "09:10", RemindAt: "-PT15M"})
+		mustCreate(t, store, Task{Title: "Later", DueDate: due, DueTime: "11:00", RemindAt: "-PT15M"})
+		done := mustCreate(t, store, Task{Title: "Done", DueDate: due, DueTime: "08:00", RemindAt: "PT0S"})
+		if err := store.UpdateTask(done.ID, map[string]interface{}{"completed": true}, ""); err != nil {
+			t.Fatal(err)
+		}
+		mustCreate(t, store, Task{Title: "Long ago", DueDate: NullTime{Time: mustDate(t, "2026-10-01"), Valid: true}, RemindAt: "PT0S"})
+
+		since := now.Add(-24 * time.Hour)
+		for i := range 2 {
+			delivered, err := reminders.DeliverReminders(since, now, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := 1
+			if i > 0 {
+				want = 0 // Already delivered.
+			}
+			if len(delivered) != want {
+				t.Fatalf("run %d delivered %v, want %d reminder(s)", i+1, delivered, want)
+			}
+		}
+
+		// Moving the task moves the reminder, which fires again.
+		if err := store.UpdateTask(call.ID, map[string]interface{}{"due_time": "09:12"}, ""); err != nil {
+			t.Fatal(err)
+		}
+		delivered, err := reminders.DeliverReminders(since, now, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(delivered) != 1 || !delivered[0].RemindAt.Equal(time.Date(2026, 10, 20, 8, 57, 0, 0, time.UTC)) {
+			t.Errorf("after moving delivered %v, want the reminder at 08:57", delivered)
+		}
+
+		all, err := reminders.GetReminders(0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 2 || all[0].Title != "Call bank" || all[1].ID <= all[0].ID {
+			t.Errorf("GetReminders = %v, want both deliveries in order", all)
+		}
+		if rest, _ := reminders.GetReminders(all[0].ID, 10); len(rest) != 1 {
+			t.Errorf("GetReminders after %d = %v, want the second delivery", all[0].ID, rest)
+		}
+	})
+}
+
+func TestOccurrencesInheritTimeAndRelativeReminder(t *testing.T) {
+	now := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		for _, remindAt := range []string{"-PT10M", "2026-10-20T07:00"} {
+			task := mustCreate(t, store, Task{
+				Title:              "Standup " + remindAt,
+				DueDate:            NullTime{Time: mustDate(t, "2026-10-20"), Valid: true},
+				DueTime:            "09:30",
+				DurationMinutes:    15,
+				RemindAt:           remindAt,
+				RecurrenceRule:     "daily",
+				RecurrenceInterval: 1,
+			})
+			if err := store.UpdateTask(task.ID, map[string]interface{}{"completed": true}, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tasks, err := store.GetTasks(TaskFilter{Date: "2026-10-21"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 2 {
+			t.Fatalf("got %v on the next day, want two occurrences", tasks)
+		}
+		for _, next := range tasks {
+			if next.DueTime != "09:30" || next.DurationMinutes != 15 {
+				t.Errorf("%s: time %q, duration %d, want 09:30 for 15 minutes", next.Title, next.DueTime, next.DurationMinutes)
+			}
+			want := ""
+			if next.Title == "Standup -PT10M" {
+				want = "-PT10M" // An absolute reminder is not repeated.
+			}
+			if next.RemindAt != want {
+				t.Errorf("%s: remind_at %q, want %q", next.Title, next.RemindAt, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package db
+
+import "time"
+
+// TaskStore stores tasks. *Store implements it on top of SQLite and
+// MemoryStore in memory.
+type TaskStore interface {
+	// GetTasks returns the top-level tasks filter selects, by order: due on a
+	// date, in a date range or undated ("inbox"), in a list, having tags.
+	GetTasks(filter TaskFilter) (Tasks, error)
+	GetTask(id int) (Task, error)
+	CreateTask(task Task) (Task, error)
+	// UpdateTask applies updates, keyed by column name, to a task. On an
+	// occurrence of a recurring task they apply to the occurrences scope
+	// selects ("" for the default, see ParseScope). Completing an occurrence
+	// creates the next one.
+	UpdateTask(id int, updates map[string]interface{}, scope Scope) error
+	BulkUpdateTaskOrder(tasks []Task) error
+	// DeleteTask deletes a task and its subtasks, or the occurrences of a
+	// recurring task scope selects ("" for only this one).
+	DeleteTask(id int, scope Scope) error
+	// SearchTasks returns a page of the tasks matching query, ranking those
+	// due closer to today first. Tags are searched too, and "tag:name" terms
+	// only match tasks having that tag.
+	SearchTasks(query string, today time.Time, limit int, offset int) (Tasks, error)
+	// GetSubtasks returns the subtasks of a task by order.
+	GetSubtasks(parentID int) (Tasks, error)
+	// CreateSubtask appends a subtask to a top-level task. Completing,
+	// reopening, adding or deleting subtasks (through the other methods)
+	// rolls their completion up to the parent.
+	CreateSubtask(parentID int, task Task) (Task, error)
+	// GetSeries returns a recurring task series with its exceptions and
+	// occurrences.
+	GetSeries(id int) (Series, error)
+	// CreateNextOccurrencesForUndoneRecurringTasks creates the next occurrence
+	// after today of every series whose latest occurrence is past due.
+	CreateNextOccurrencesForUndoneRecurringTasks(today time.Time) error
+	// OnOccurrenceCreated registers fn to be called with each occurrence
+	// created by recurrence, whether on completing, deleting or rolling
+	// over, once the change is committed.
+	OnOccurrenceCreated(fn func(occurrence Task))
+}
+
+// SettingsStore stores planner settings. The inbox title is the title of
+// the default list (see ListStore).
+type SettingsStore interface {
+	GetInboxTitle() (string, error)
+	UpdateInboxTitle(title string) error
+}
+
+var (
+	_ TaskStore     = (*Store)(nil)
+	_ SettingsStore = (*Store)(nil)
+	_ TaskStore     = (*MemoryStore)(nil)
+	_ SettingsStore = (*MemoryStore)(nil)
+)
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// titles returns the titles of tasks, in order.
+func titles(tasks Tasks) []string {
+	titles := make([]string, len(tasks))
+	for i, task := range tasks {
+		titles[i] = task.Title
+	}
+	return titles
+}
+
+func TestSearchOrder(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		for _, task := range []struct{ title, due string }{
+			{"Renew passport and call embassy", "2026-10-18"},
+			{"Call plumber", "2026-09-01"},
+			{"Call bank", "2026-10-20"},
+			{"Call grandma", ""},
+			{"Call dentist", "2026-10-17"},
+		} {
+			due := NullTime{}
+			if task.due != "" {
+				due = NullTime{Time: mustDate(t, task.due), Valid: true}
+			}
+			mustCreate(t, store, Task{Title: task.title, DueDate: due})
+		}
+		today := TodayOn(FixedClock(now), time.UTC)
+
+		tasks, err := store.SearchTasks("call", today, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Titles starting with the query first, then by distance to today;
+		// undated tasks last.
+		want := []string{"Call dentist", "Call bank", "Call plumber", "Call grandma", "Renew passport and call embassy"}
+		got := titles(tasks)
+		if len(got) != len(want) {
+			t.Fatalf("search = %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("search = %v, want %v", got, want)
+			}
+		}
+
+		page, err := store.SearchTasks("call", today, 2, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titles(page); len(got) != 2 || got[0] != "Call bank" || got[1] != "Call plumber" {
+			t.Errorf("second page = %v, want [Call bank Call plumber]", got)
+		}
+
+		// A week later the order follows the new today.
+		later, err := store.SearchTasks("call", today.AddDate(0, 0, -47), 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titles(later); len(got) == 0 || got[0] != "Call plumber" {
+			t.Errorf("search on %s = %v, want Call plumber first", today.AddDate(0, 0, -47).Format("2006-01-02"), got)
+		}
+	})
+}
+
+func TestSearchFollowsChanges(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		today := TodayOn(FixedClock(now), time.UTC)
+		search := func(query string) []string {
+			t.Helper()
+			tasks, err := store.SearchTasks(query, today, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return titles(tasks)
+		}
+		task := mustCreate(t, store, Task{Title: "Water plants", Description: "balcony"})
+
+		if err := store.UpdateTask(task.ID, map[string]interface{}{"title": "Feed the cat", "description": "kitchen"}, ""); err != nil {
+			t.Fatal(err)
+		}
+		if got := search("plants"); len(got) != 0 {
+			t.Errorf("search for the old title = %v, want nothing", got)
+		}
+		if got := search("balcony"); len(got) != 0 {
+			t.Errorf("search for the old description = %v, want nothing", got)
+		}
+		if got := search("cat"); len(got) != 1 {
+			t.Errorf("search for the new title = %v, want the task", got)
+		}
+
+		if err := store.DeleteTask(task.ID, ""); err != nil {
+			t.Fatal(err)
+		}
+		if got := search("cat"); len(got) != 0 {
+			t.Errorf("search after deleting = %v, want nothing", got)
+		}
+	})
+}
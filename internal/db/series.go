@@ -0,0 +1,928 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"week-planner/internal/config"
+	"week-planner/internal/rrule"
+
+	"gorm.io/gorm"
+)
+
+// ParseScope parses the scope parameter of an update or deletion. The empty
+// scope selects the default: only this occurrence, except for changes of the
+// recurrence, which apply to this and the following occurrences.
+func ParseScope(s string) (Scope, error) {
+	switch scope := Scope(s); scope {
+	case "", ScopeThis, ScopeFollowing, ScopeAll:
+		return scope, nil
+	}
+	return "", NewAPIError(400, fmt.Sprintf("Invalid scope %q (must be 'this', 'following' or 'all')", s))
+}
+
+// taskTx is what the task and series logic below needs from a store. Store
+// implements it on a transaction (sqlTx) and MemoryStore with its lock held
+// (memoryTx), so that both behave the same.
+type taskTx interface {
+	task(id int) (Task, error) // 404 APIError if there is no such task.
+	createTask(task *Task) error
+	saveTask(task Task) error                                // Writes all columns.
+	updateTask(id int, updates map[string]interface{}) error // Updates checked by checkTaskUpdates.
+	deleteTask(id int) error                                 // Deletes its subtasks too.
+	copySubtasks(fromID, toID int) error
+	spawned(fromID int) (bool, error) // Whether an occurrence was created from fromID.
+	rollUpCompletion(parentID int) error
+	series(id int) (Series, error) // 404 APIError if there is no such series.
+	allSeries() ([]Series, error)
+	saveSeries(series *Series) error // Creates the series when its ID is 0.
+	deleteSeries(id int) error       // Deletes its exceptions too.
+	occurrences(seriesID int) (Tasks, error)
+	exceptions(seriesID int) ([]SeriesException, error)
+	saveException(exception SeriesException) error // Replaces the exception for the same date.
+	deleteException(seriesID int, date time.Time) error
+	// list returns a list, or the default list for 0; 400 APIError if there
+	// is no such list.
+	list(id int) (List, error)
+	// created records an occurrence created by recurrence, reported to the
+	// store's observers once the transaction is committed.
+	created(occurrence Task)
+}
+
+// txError adds context to an error returned by a transaction. API errors are
+// returned as they are, so that handlers answer with their status code.
+func txError(op string, err error) error {
+	if _, ok := err.(*APIError); ok || err == nil {
+		return err
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// createTask inserts a task, in the default list unless it names another,
+// starting a series if it recurs.
+func createTask(tx taskTx, task *Task) error {
+	if err := assignList(tx, task); err != nil {
+		return err
+	}
+	if err := tx.createTask(task); err != nil {
+		return err
+	}
+	return attachToSeries(tx, task)
+}
+
+// attachToSeries makes a dated top-level recurring task that has no series
+// yet the first occurrence of a new series.
+func attachToSeries(tx taskTx, task *Task) error {
+	if task.RecurrenceRule == "" || !task.DueDate.Valid || task.ParentID != nil || task.SeriesID != nil {
+		return nil
+	}
+	date := dateOnly(task.DueDate.Time)
+	series := Series{
+		UID:                NewUID(),
+		Title:              task.Title,
+		Description:        task.Description,
+		Color:              task.Color,
+		RecurrenceRule:     task.RecurrenceRule,
+		RecurrenceInterval: task.RecurrenceInterval,
+		StartDate:          date,
+		DueTime:            task.DueTime,
+		DurationMinutes:    task.DurationMinutes,
+		RemindAt:           task.RemindAt,
+	}
+	if err := tx.saveSeries(&series); err != nil {
+		return fmt.Errorf("creating series of task %d: %w", task.ID, err)
+	}
+	task.SeriesID = &series.ID
+	task.OccurrenceDate = NullTime{Time: date, Valid: true}
+	slog.Debug("Recurring task started a series", "task_id", task.ID, "series_id", series.ID)
+	return tx.saveTask(*task)
+}
+
+// updateTask applies updates, checked by checkTaskUpdates, to a task. Updates
+// of occurrences of a recurring task apply to the occurrences scope selects.
+func updateTask(tx taskTx, id int, updates map[string]interface{}, scope Scope) error {
+	task, err := tx.task(id)
+	if err != nil {
+		return NewAPIError(404, "Task not found for update")
+	}
+	if id, ok := updates["list_id"]; ok {
+		if task.ParentID != nil {
+			return NewAPIError(400, "Subtasks are in the list of their task")
+		}
+		if _, err := tx.list(toInt(id)); err != nil {
+			return err
+		}
+	}
+	if task.SeriesID != nil {
+		return updateOccurrence(tx, task, updates, scope)
+	}
+
+	if err := tx.updateTask(id, updates); err != nil {
+		return err
+	}
+	// Completing or reopening a subtask may complete or reopen its parent.
+	if task.ParentID != nil {
+		if _, ok := updates["completed"]; ok {
+			return rollUp(tx, *task.ParentID)
+		}
+		return nil
+	}
+	// A task that got both a rule and a due date starts a series.
+	updated, err := tx.task(id)
+	if err != nil {
+		return err
+	}
+	if err := attachToSeries(tx, &updated); err != nil {
+		return err
+	}
+	if task.Completed == 0 && updated.Completed == 1 {
+		return completeOccurrence(tx, updated)
+	}
+	return nil
+}
+
+// rollUp rolls the completion of the subtasks of parentID up to it. When that
+// completes an occurrence of a recurring task, the next one is created.
+func rollUp(tx taskTx, parentID int) error {
+	before, err := tx.task(parentID)
+	if err != nil {
+		return nil // Deleted along with the subtask.
+	}
+	if err := tx.rollUpCompletion(parentID); err != nil {
+		return err
+	}
+	after, err := tx.task(parentID)
+	if err != nil {
+		return err
+	}
+	if before.Completed == 0 && after.Completed == 1 {
+		return completeOccurrence(tx, after)
+	}
+	return nil
+}
+
+// completeOccurrence creates the occurrence following a just completed task,
+// if it belongs to a series.
+func completeOccurrence(tx taskTx, task Task) error {
+	if task.SeriesID == nil {
+		return nil
+	}
+	series, err := tx.series(*task.SeriesID)
+	if err != nil {
+		return err
+	}
+	_, _, err = createNextOccurrence(tx, series, task.OccurrenceDate.Time, task)
+	return err
+}
+
+// updateOccurrence applies updates to the occurrence task and, depending on
+// scope, to the later occurrences or the whole series.
+func updateOccurrence(tx taskTx, task Task, updates map[string]interface{}, scope Scope) error {
+	_, ruleChanged := updates["recurrence_rule"]
+	_, intervalChanged := updates["recurrence_interval"]
+	if dueDate, ok := updates["due_date"]; ok && (dueDate == nil || dueDate == "") {
+		// Moving an occurrence to the inbox takes it out of the series, so
+		// only the occurrence itself is affected whatever else is sent along.
+		delete(updates, "recurrence_rule")
+		delete(updates, "recurrence_interval")
+		ruleChanged, intervalChanged, scope = false, false, ScopeThis
+	}
+	if scope == "" {
+		scope = ScopeThis
+		if ruleChanged || intervalChanged {
+			scope = ScopeFollowing
+		}
+	}
+	series, err := tx.series(*task.SeriesID)
+	if err != nil {
+		return err
+	}
+
+	if scope == ScopeThis {
+		if ruleChanged || intervalChanged {
+			return NewAPIError(400, "The recurrence can only be changed for this and the following occurrences or for all of them")
+		}
+		return updateThisOccurrence(tx, series, task, updates)
+	}
+	for _, key := range []string{"due_date", "completed", "task_order"} {
+		if _, ok := updates[key]; ok {
+			return NewAPIError(400, fmt.Sprintf("'%s' can only be changed for this occurrence", key))
+		}
+	}
+	if scope == ScopeFollowing && dateOnly(task.OccurrenceDate.Time).After(dateOnly(series.StartDate)) {
+		if series, err = splitSeries(tx, series, task); err != nil {
+			return err
+		}
+	}
+	return updateSeries(tx, series, updates)
+}
+
+// updateThisOccurrence applies updates to a single occurrence. A new title or
+// due date is recorded as an exception of the series; an occurrence moved to
+// the inbox leaves the series.
+func updateThisOccurrence(tx taskTx, series Series, task Task, updates map[string]interface{}) error {
+	if err := tx.updateTask(task.ID, updates); err != nil {
+		return err
+	}
+	updated, err := tx.task(task.ID)
+	if err != nil {
+		return err
+	}
+	date := dateOnly(task.OccurrenceDate.Time)
+
+	_, retitled := updates["title"]
+	_, moved := updates["due_date"]
+	if moved && !updated.DueDate.Valid {
+		updated.SeriesID, updated.OccurrenceDate = nil, NullTime{}
+		updated.RecurrenceRule, updated.RecurrenceInterval = "", 1
+		if err := tx.saveTask(updated); err != nil {
+			return err
+		}
+		if err := tx.saveException(SeriesException{SeriesID: series.ID, OccurrenceDate: date, Skip: true}); err != nil {
+			return err
+		}
+		slog.Debug("Occurrence moved to the inbox left its series", "task_id", task.ID, "series_id", series.ID)
+		if task.Completed == 0 {
+			_, _, err = createNextOccurrence(tx, series, date, updated)
+		}
+		return err
+	}
+
+	if retitled || moved {
+		exception := SeriesException{SeriesID: series.ID, OccurrenceDate: date}
+		exceptions, err := tx.exceptions(series.ID)
+		if err != nil {
+			return err
+		}
+		for _, e := range exceptions {
+			if dateOnly(e.OccurrenceDate).Equal(date) {
+				exception = e
+			}
+		}
+		if retitled {
+			exception.Title = ""
+			if updated.Title != series.Title {
+				exception.Title = updated.Title
+			}
+		}
+		if moved {
+			exception.DueDate = NullTime{}
+			if due := dateOnly(updated.DueDate.Time); !due.Equal(date) {
+				exception.DueDate = NullTime{Time: due, Valid: true}
+			}
+		}
+		if exception.Title == "" && !exception.DueDate.Valid {
+			err = tx.deleteException(series.ID, date)
+		} else {
+			err = tx.saveException(exception)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if task.Completed == 0 && updated.Completed == 1 {
+		_, _, err = createNextOccurrence(tx, series, date, updated)
+		return err
+	}
+	return nil
+}
+
+// splitSeries ends series on the day before the occurrence task and moves
+// that occurrence, the later ones and their exceptions to a new series
+// starting on its date, which it returns.
+func splitSeries(tx taskTx, series Series, task Task) (Series, error) {
+	date := dateOnly(task.OccurrenceDate.Time)
+	following := series
+	following.ID = 0
+	following.UID = NewUID()
+	following.StartDate = date
+	following.RecurrenceRule, following.RecurrenceInterval = ruleFrom(series, date)
+	if err := tx.saveSeries(&following); err != nil {
+		return Series{}, err
+	}
+
+	rule, interval, err := ruleEndingBefore(series, date)
+	if err != nil {
+		return Series{}, err
+	}
+	series.RecurrenceRule, series.RecurrenceInterval = rule, interval
+	if err := tx.saveSeries(&series); err != nil {
+		return Series{}, err
+	}
+
+	occurrences, err := tx.occurrences(series.ID)
+	if err != nil {
+		return Series{}, err
+	}
+	for _, o := range occurrences {
+		if dateOnly(o.OccurrenceDate.Time).Before(date) {
+			o.RecurrenceRule, o.RecurrenceInterval = ruleFrom(series, o.OccurrenceDate.Time)
+		} else {
+			o.SeriesID = &following.ID
+		}
+		if err := tx.saveTask(o); err != nil {
+			return Series{}, err
+		}
+	}
+	exceptions, err := tx.exceptions(series.ID)
+	if err != nil {
+		return Series{}, err
+	}
+	for _, e := range exceptions {
+		if dateOnly(e.OccurrenceDate).Before(date) {
+			continue
+		}
+		if err := tx.deleteException(series.ID, e.OccurrenceDate); err != nil {
+			return Series{}, err
+		}
+		e.ID, e.SeriesID = 0, following.ID
+		if err := tx.saveException(e); err != nil {
+			return Series{}, err
+		}
+	}
+	slog.Info("Split recurring task series", "series_id", series.ID, "new_series_id", following.ID, "date", date.Format(config.DateFormat))
+	return following, nil
+}
+
+// updateSeries applies updates to the template of series and to all its
+// occurrences. Clearing the rule ends the series: its occurrences are kept
+// as tasks that do not recur.
+func updateSeries(tx taskTx, series Series, updates map[string]interface{}) error {
+	occurrences, err := tx.occurrences(series.ID)
+	if err != nil {
+		return err
+	}
+	fields := map[string]interface{}{}
+	recurrenceChanged := false
+	for key, value := range updates {
+		switch key {
+		case "title":
+			series.Title = value.(string)
+		case "description":
+			series.Description, _ = value.(string)
+		case "color":
+			series.Color, _ = value.(string)
+		case "due_time":
+			series.DueTime, _ = value.(string)
+		case "duration_minutes":
+			series.DurationMinutes = toInt(value)
+		case "remind_at":
+			series.RemindAt, _ = value.(string)
+		case "recurrence_rule":
+			series.RecurrenceRule = value.(string)
+			recurrenceChanged = true
+			continue
+		case "recurrence_interval":
+			series.RecurrenceInterval = toInt(value)
+			recurrenceChanged = true
+			continue
+		}
+		fields[key] = value
+	}
+
+	for _, o := range occurrences {
+		if len(fields) > 0 {
+			if err := tx.updateTask(o.ID, fields); err != nil {
+				return err
+			}
+		}
+	}
+	if series.RecurrenceRule == "" {
+		for _, o := range occurrences {
+			o, err := tx.task(o.ID)
+			if err != nil {
+				return err
+			}
+			o.SeriesID, o.OccurrenceDate = nil, NullTime{}
+			o.RecurrenceRule, o.RecurrenceInterval = "", 1
+			if err := tx.saveTask(o); err != nil {
+				return err
+			}
+		}
+		slog.Info("Recurring task series ended", "series_id", series.ID)
+		return tx.deleteSeries(series.ID)
+	}
+
+	// A new title for all occurrences replaces the ones of single occurrences.
+	if _, ok := updates["title"]; ok {
+		exceptions, err := tx.exceptions(series.ID)
+		if err != nil {
+			return err
+		}
+		for _, e := range exceptions {
+			if e.Title == "" {
+				continue
+			}
+			e.Title = ""
+			if e.Skip || e.DueDate.Valid {
+				err = tx.saveException(e)
+			} else {
+				err = tx.deleteException(series.ID, e.OccurrenceDate)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.saveSeries(&series); err != nil {
+		return err
+	}
+	if recurrenceChanged {
+		return resyncSeries(tx, series)
+	}
+	return nil
+}
+
+// resyncSeries fits the occurrences of series to its rule after the rule
+// changed: each occurrence shows the rule counted from its date, and open
+// occurrences the rule no longer produces are replaced by the next one it does.
+func resyncSeries(tx taskTx, series Series) error {
+	r, err := ParseRecurrence(series.RecurrenceRule, series.RecurrenceInterval, series.StartDate)
+	if err != nil {
+		return err
+	}
+	occurrences, err := tx.occurrences(series.ID)
+	if err != nil {
+		return err
+	}
+	valid := map[string]bool{}
+	if len(occurrences) > 0 {
+		last := dateOnly(occurrences[len(occurrences)-1].OccurrenceDate.Time)
+		it := r.Iter(series.StartDate)
+		for {
+			t, ok := it.Next()
+			if !ok || dateOnly(t).After(last) {
+				break
+			}
+			valid[dateOnly(t).Format(config.DateFormat)] = true
+		}
+	}
+
+	var stale Tasks
+	keptOpen := false
+	after := dateOnly(series.StartDate).AddDate(0, 0, -1)
+	for _, o := range occurrences {
+		date := dateOnly(o.OccurrenceDate.Time)
+		if o.Completed == 0 && !valid[date.Format(config.DateFormat)] {
+			stale = append(stale, o)
+			continue
+		}
+		if o.Completed == 0 {
+			keptOpen = true
+		}
+		if date.After(after) {
+			after = date
+		}
+		o.RecurrenceRule, o.RecurrenceInterval = ruleFrom(series, date)
+		if err := tx.saveTask(o); err != nil {
+			return err
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	if !keptOpen {
+		// Only the latest open occurrence cannot have spawned another yet.
+		if _, _, err := createNextOccurrence(tx, series, after, stale[len(stale)-1]); err != nil {
+			return err
+		}
+	}
+	for _, o := range stale {
+		slog.Debug("Removing occurrence no longer produced by the series rule", "task_id", o.ID, "series_id", series.ID)
+		if err := tx.deleteTask(o.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteTask deletes a task. Deleting an occurrence of a recurring task
+// deletes the occurrences scope selects: only this one (the default, which
+// the series then skips), this and the following ones, or the whole series.
+func deleteTask(tx taskTx, id int, scope Scope) error {
+	task, err := tx.task(id)
+	if err != nil {
+		return NewAPIError(404, "Task not found for deletion")
+	}
+	if task.SeriesID == nil {
+		if err := tx.deleteTask(id); err != nil {
+			return err
+		}
+		if task.ParentID != nil {
+			return rollUp(tx, *task.ParentID)
+		}
+		return nil
+	}
+
+	series, err := tx.series(*task.SeriesID)
+	if err != nil {
+		return err
+	}
+	date := dateOnly(task.OccurrenceDate.Time)
+	if scope == ScopeFollowing && !date.After(dateOnly(series.StartDate)) {
+		scope = ScopeAll
+	}
+	occurrences, err := tx.occurrences(series.ID)
+	if err != nil {
+		return err
+	}
+
+	switch scope {
+	case ScopeAll:
+		for _, o := range occurrences {
+			if err := tx.deleteTask(o.ID); err != nil {
+				return err
+			}
+		}
+		slog.Info("Deleted recurring task series", "series_id", series.ID, "occurrences", len(occurrences))
+		return tx.deleteSeries(series.ID)
+
+	case ScopeFollowing:
+		rule, interval, err := ruleEndingBefore(series, date)
+		if err != nil {
+			return err
+		}
+		series.RecurrenceRule, series.RecurrenceInterval = rule, interval
+		if err := tx.saveSeries(&series); err != nil {
+			return err
+		}
+		for _, o := range occurrences {
+			if !dateOnly(o.OccurrenceDate.Time).Before(date) {
+				err = tx.deleteTask(o.ID)
+			} else {
+				o.RecurrenceRule, o.RecurrenceInterval = ruleFrom(series, o.OccurrenceDate.Time)
+				err = tx.saveTask(o)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		exceptions, err := tx.exceptions(series.ID)
+		if err != nil {
+			return err
+		}
+		for _, e := range exceptions {
+			if !dateOnly(e.OccurrenceDate).Before(date) {
+				if err := tx.deleteException(series.ID, e.OccurrenceDate); err != nil {
+					return err
+				}
+			}
+		}
+		slog.Info("Recurring task series ended", "series_id", series.ID, "before", date.Format(config.DateFormat))
+		return nil
+
+	default:
+		// The rule must not produce the deleted occurrence again; an open one
+		// makes way for the next.
+		if err := tx.saveException(SeriesException{SeriesID: series.ID, OccurrenceDate: date, Skip: true}); err != nil {
+			return err
+		}
+		if task.Completed == 0 {
+			if _, _, err := createNextOccurrence(tx, series, date, task); err != nil {
+				return err
+			}
+		}
+		if err := tx.deleteTask(id); err != nil {
+			return err
+		}
+		if remaining, err := tx.occurrences(series.ID); err != nil || len(remaining) > 0 {
+			return err
+		}
+		slog.Info("Deleted last occurrence of recurring task series", "series_id", series.ID)
+		return tx.deleteSeries(series.ID)
+	}
+}
+
+// createNextOccurrence creates the first occurrence of series after the date
+// of after that is neither skipped nor already created, with uncompleted
+// copies of the subtasks of from. It returns false, and creates nothing, when
+// the series has ended, that occurrence exists or from already spawned one:
+// an occurrence is followed by a single next one however often it is
+// completed, skipped or found overdue.
+func createNextOccurrence(tx taskTx, series Series, after time.Time, from Task) (Task, bool, error) {
+	if from.ID != 0 {
+		spawned, err := tx.spawned(from.ID)
+		if err != nil {
+			return Task{}, false, err
+		}
+		if spawned {
+			slog.Debug("Occurrence already has a next occurrence", "series_id", series.ID, "task_id", from.ID)
+			return Task{}, false, nil
+		}
+	}
+	r, err := ParseRecurrence(series.RecurrenceRule, series.RecurrenceInterval, series.StartDate)
+	if err != nil {
+		return Task{}, false, fmt.Errorf("series %d: unsupported recurrence rule %q: %w", series.ID, series.RecurrenceRule, err)
+	}
+	exceptions, err := tx.exceptions(series.ID)
+	if err != nil {
+		return Task{}, false, err
+	}
+	byDate := make(map[string]SeriesException, len(exceptions))
+	for _, e := range exceptions {
+		byDate[dateOnly(e.OccurrenceDate).Format(config.DateFormat)] = e
+	}
+	occurrences, err := tx.occurrences(series.ID)
+	if err != nil {
+		return Task{}, false, err
+	}
+	existing := make(map[string]bool, len(occurrences))
+	for _, o := range occurrences {
+		existing[dateOnly(o.OccurrenceDate.Time).Format(config.DateFormat)] = true
+	}
+
+	limit := dateOnly(after)
+	it := r.Iter(dateOnly(series.StartDate))
+	for {
+		t, ok := it.Next()
+		if !ok {
+			slog.Info("Recurring task series has no further occurrence", "series_id", series.ID, "rule", series.RecurrenceRule)
+			return Task{}, false, nil
+		}
+		date := dateOnly(t)
+		key := date.Format(config.DateFormat)
+		if !date.After(limit) {
+			continue
+		}
+		if existing[key] {
+			return Task{}, false, nil
+		}
+		exception := byDate[key]
+		if exception.Skip {
+			continue
+		}
+
+		task := Task{
+			Title:           series.Title,
+			Description:     series.Description,
+			Color:           series.Color,
+			DueDate:         NullTime{Time: date, Valid: true},
+			SeriesID:        &series.ID,
+			OccurrenceDate:  NullTime{Time: date, Valid: true},
+			DueTime:         series.DueTime,
+			DurationMinutes: series.DurationMinutes,
+			Tags:            from.Tags,
+			ListID:          from.ListID,
+		}
+		// An absolute reminder belongs to the occurrence it was set on.
+		if r, err := ParseReminder(series.RemindAt); err == nil && r.Relative {
+			task.RemindAt = series.RemindAt
+		}
+		// As seen from its date, like the occurrences resyncSeries fits.
+		task.RecurrenceRule, task.RecurrenceInterval = ruleFrom(series, date)
+		if from.ID != 0 {
+			task.SpawnedFrom = &from.ID
+		}
+		if exception.Title != "" {
+			task.Title = exception.Title
+		}
+		if exception.DueDate.Valid {
+			task.DueDate = exception.DueDate
+		}
+		if err := assignList(tx, &task); err != nil {
+			return Task{}, false, err
+		}
+		if err := tx.createTask(&task); err != nil {
+			return Task{}, false, fmt.Errorf("creating occurrence %s of series %d: %w", key, series.ID, err)
+		}
+		// The checklist starts over on the next occurrence.
+		if from.ID != 0 {
+			if err := tx.copySubtasks(from.ID, task.ID); err != nil {
+				return Task{}, false, fmt.Errorf("copying subtasks to occurrence %s of series %d: %w", key, series.ID, err)
+			}
+		}
+		slog.Info("Created next occurrence of recurring task", "series_id", series.ID, "task_id", task.ID, "due_date", task.DueDate.Time.Format(config.DateFormat))
+		tx.created(task)
+		return task, true, nil
+	}
+}
+
+// createOverdueOccurrences creates, for each series whose latest occurrence
+// is still open and due before today, the next occurrence after today.
+func createOverdueOccurrences(tx taskTx, today time.Time) error {
+	all, err := tx.allSeries()
+	if err != nil {
+		return err
+	}
+	for _, series := range all {
+		occurrences, err := tx.occurrences(series.ID)
+		if err != nil {
+			return err
+		}
+		if len(occurrences) == 0 {
+			continue
+		}
+		latest := occurrences[len(occurrences)-1]
+		if latest.Completed != 0 || !latest.DueDate.Valid || !dateOnly(latest.DueDate.Time).Before(dateOnly(today)) {
+			continue
+		}
+		if _, _, err := createNextOccurrence(tx, series, today, latest); err != nil {
+			// Log and go on with the other series.
+			slog.Error("Failed to create next occurrence of overdue recurring task", "series_id", series.ID, "task_id", latest.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// loadSeries returns a series with its exceptions and occurrences.
+func loadSeries(tx taskTx, id int) (Series, error) {
+	series, err := tx.series(id)
+	if err != nil {
+		return Series{}, err
+	}
+	if series.Exceptions, err = tx.exceptions(id); err != nil {
+		return Series{}, err
+	}
+	if series.Occurrences, err = tx.occurrences(id); err != nil {
+		return Series{}, err
+	}
+	return series, nil
+}
+
+// ruleFrom returns the rule of series as seen from its occurrence on date:
+// an RRULE with a COUNT loses the occurrences before that date, and a simple
+// rule that would fall on other days counted from date, such as "monthly"
+// from the 31st seen from February 28th, becomes the RRULE it stands for.
+func ruleFrom(series Series, date time.Time) (string, int) {
+	r, err := ParseRecurrence(series.RecurrenceRule, series.RecurrenceInterval, series.StartDate)
+	if err != nil {
+		return series.RecurrenceRule, series.RecurrenceInterval
+	}
+	if _, simple := simpleRules[series.RecurrenceRule]; simple {
+		from, _ := ParseRecurrence(series.RecurrenceRule, series.RecurrenceInterval, date)
+		if from.String() != r.String() {
+			return r.String(), 1
+		}
+		return series.RecurrenceRule, series.RecurrenceInterval
+	}
+	if r.Count == 0 {
+		return series.RecurrenceRule, series.RecurrenceInterval
+	}
+	r.Count -= occurrencesBefore(r, series.StartDate, date)
+	if r.Count < 1 {
+		r.Count = 1
+	}
+	return r.String(), series.RecurrenceInterval
+}
+
+// ruleEndingBefore returns the rule of series ending on the day before date,
+// as an RRULE: with a COUNT of the occurrences before date, or an UNTIL.
+func ruleEndingBefore(series Series, date time.Time) (string, int, error) {
+	r, err := ParseRecurrence(series.RecurrenceRule, series.RecurrenceInterval, series.StartDate)
+	if err != nil {
+		return "", 0, err
+	}
+	if r.Count > 0 {
+		r.Count = occurrencesBefore(r, series.StartDate, date)
+	} else if until := dateOnly(date).AddDate(0, 0, -1); r.Until.IsZero() || r.Until.After(until) {
+		r.Until = until
+	}
+	return r.String(), 1, nil
+}
+
+// occurrencesBefore counts the occurrences of r from start before date.
+func occurrencesBefore(r rrule.Rule, start, date time.Time) int {
+	limit := dateOnly(date)
+	it := r.Iter(dateOnly(start))
+	for count := 0; ; count++ {
+		t, ok := it.Next()
+		if !ok || !dateOnly(t).Before(limit) {
+			return count
+		}
+	}
+}
+
+// sqlTx implements taskTx on a GORM transaction.
+type sqlTx struct {
+	db           *gorm.DB
+	createdTasks *[]Task // Collects the created occurrences, may be nil.
+}
+
+func (t sqlTx) task(id int) (Task, error) {
+	var task Task
+	err := t.db.First(&task, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Task{}, NewAPIError(404, "Task not found")
+	}
+	return task, err
+}
+
+func (t sqlTx) createTask(task *Task) error {
+	if err := t.db.Create(task).Error; err != nil {
+		return err
+	}
+	if len(task.Tags) == 0 {
+		return nil
+	}
+	return setTaskTags(t.db, task.ID, task.Tags)
+}
+
+func (t sqlTx) saveTask(task Task) error {
+	if err := t.db.Save(&task).Error; err != nil {
+		return err
+	}
+	return setTaskTags(t.db, task.ID, task.Tags)
+}
+
+func (t sqlTx) updateTask(id int, updates map[string]interface{}) error {
+	if err := t.db.Model(&Task{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return err
+	}
+	if tags, ok := updates["tags"].(TagList); ok {
+		return setTaskTags(t.db, id, tags)
+	}
+	return nil
+}
+
+func (t sqlTx) deleteTask(id int) error {
+	// Subtasks are deleted along with their parent by a trigger.
+	return t.db.Delete(&Task{}, id).Error
+}
+
+func (t sqlTx) copySubtasks(fromID, toID int) error {
+	return copySubtasks(t.db, fromID, toID)
+}
+
+func (t sqlTx) created(occurrence Task) {
+	if t.createdTasks != nil {
+		*t.createdTasks = append(*t.createdTasks, occurrence)
+	}
+}
+
+func (t sqlTx) spawned(fromID int) (bool, error) {
+	var count int64
+	err := t.db.Model(&Task{}).Where("spawned_from = ?", fromID).Count(&count).Error
+	return count > 0, err
+}
+
+func (t sqlTx) rollUpCompletion(parentID int) error {
+	return rollUpCompletion(t.db, parentID)
+}
+
+func (t sqlTx) series(id int) (Series, error) {
+	var series Series
+	err := t.db.First(&series, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Series{}, NewAPIError(404, "Series not found")
+	}
+	return series, err
+}
+
+func (t sqlTx) allSeries() ([]Series, error) {
+	var all []Series
+	err := t.db.Order("id").Find(&all).Error
+	return all, err
+}
+
+func (t sqlTx) saveSeries(series *Series) error {
+	if series.ID == 0 {
+		return t.db.Create(series).Error
+	}
+	return t.db.Save(series).Error
+}
+
+func (t sqlTx) deleteSeries(id int) error {
+	if err := t.db.Where("series_id = ?", id).Delete(&SeriesException{}).Error; err != nil {
+		return err
+	}
+	return t.db.Delete(&Series{}, id).Error
+}
+
+func (t sqlTx) occurrences(seriesID int) (Tasks, error) {
+	var tasks Tasks
+	err := t.db.Scopes(withSubtaskCounts).Where("series_id = ? AND parent_id IS NULL", seriesID).
+		Order("occurrence_date, id").Find(&tasks).Error
+	return tasks, err
+}
+
+func (t sqlTx) exceptions(seriesID int) ([]SeriesException, error) {
+	var exceptions []SeriesException
+	err := t.db.Where("series_id = ?", seriesID).Order("occurrence_date").Find(&exceptions).Error
+	return exceptions, err
+}
+
+func (t sqlTx) saveException(exception SeriesException) error {
+	exception.OccurrenceDate = dateOnly(exception.OccurrenceDate)
+	if err := t.deleteException(exception.SeriesID, exception.OccurrenceDate); err != nil {
+		return err
+	}
+	exception.ID = 0
+	return t.db.Create(&exception).Error
+}
+
+func (t sqlTx) deleteException(seriesID int, date time.Time) error {
+	return t.db.Where("series_id = ? AND DATE(occurrence_date) = ?", seriesID, date.Format(config.DateFormat)).
+		Delete(&SeriesException{}).Error
+}
+
+func (t sqlTx) list(id int) (List, error) {
+	return findList(t.db, id)
+}
+
+// GetSeries returns a series with its exceptions and occurrences.
+func (s *Store) GetSeries(id int) (Series, error) {
+	return loadSeries(sqlTx{db: s.DB()}, id)
+}
@@ -0,0 +1,408 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"week-planner/internal/config"
+)
+
+// weeklySeries creates "Stretch", weekly from Monday 2026-10-05, and
+// completes the first two occurrences: 10-05 and 10-12 are done, 10-19 is
+// open.
+func weeklySeries(t *testing.T, store testStore) {
+	t.Helper()
+	mustCreate(t, store, Task{
+		Title:              "Stretch",
+		DueDate:            NullTime{Time: mustDate(t, "2026-10-05"), Valid: true},
+		RecurrenceRule:     "weekly",
+		RecurrenceInterval: 1,
+	})
+	for _, date := range []string{"2026-10-05", "2026-10-12"} {
+		if err := store.UpdateTask(occurrenceOn(t, store, date).ID, map[string]interface{}{"completed": true}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// occurrenceOn returns the only task due on date.
+func occurrenceOn(t *testing.T, store TaskStore, date string) Task {
+	t.Helper()
+	tasks, err := store.GetTasks(TaskFilter{Date: date})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("tasks due on %s = %v, want one", date, titles(tasks))
+	}
+	return tasks[0]
+}
+
+// plan describes the dated tasks of store as "date title label", by due
+// date, where the label tells the series apart: "a" for the series of the
+// first one, "b" for the next series and "-" for tasks that do not recur.
+// It also returns the series by label.
+func plan(t *testing.T, store TaskStore) ([]string, map[string]Series) {
+	t.Helper()
+	tasks, err := store.GetTasks(TaskFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].DueDate.Time.Before(tasks[j].DueDate.Time) })
+	labels := map[int]string{}
+	series := map[string]Series{}
+	var lines []string
+	for _, task := range tasks {
+		if !task.DueDate.Valid {
+			continue
+		}
+		label := "-"
+		if task.SeriesID != nil {
+			if labels[*task.SeriesID] == "" {
+				labels[*task.SeriesID] = string(rune('a' + len(labels)))
+				s, err := store.GetSeries(*task.SeriesID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				series[labels[*task.SeriesID]] = s
+			}
+			label = labels[*task.SeriesID]
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s", task.DueDate.Time.Format(config.DateFormat), task.Title, label))
+	}
+	return lines, series
+}
+
+// exceptionLines describes the exceptions of series.
+func exceptionLines(series Series) []string {
+	var lines []string
+	for _, e := range series.Exceptions {
+		line := e.OccurrenceDate.Format(config.DateFormat)
+		if e.Skip {
+			line += " skip"
+		}
+		if e.DueDate.Valid {
+			line += " due " + e.DueDate.Time.Format(config.DateFormat)
+		}
+		if e.Title != "" {
+			line += " " + e.Title
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// apiErrorCode returns the status code of an APIError, or 0.
+func apiErrorCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return 0
+}
+
+func TestUpdateOccurrenceScopes(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		date       string // Of the occurrence updated.
+		updates    map[string]interface{}
+		scope      Scope
+		wantCode   int // Of the error, nothing changing.
+		want       []string
+		rules      map[string]string // Of the series by label.
+		exceptions []string          // Of series a.
+	}{
+		{
+			name: "this", date: "2026-10-12", scope: ScopeThis,
+			updates:    map[string]interface{}{"title": "Yoga"},
+			want:       []string{"2026-10-05 Stretch a", "2026-10-12 Yoga a", "2026-10-19 Stretch a"},
+			exceptions: []string{"2026-10-12 Yoga"},
+		},
+		{
+			name: "this by default", date: "2026-10-19",
+			updates:    map[string]interface{}{"title": "Yoga", "color": "green"},
+			want:       []string{"2026-10-05 Stretch a", "2026-10-12 Stretch a", "2026-10-19 Yoga a"},
+			exceptions: []string{"2026-10-19 Yoga"},
+		},
+		{
+			name: "this, back to the series title", date: "2026-10-12", scope: ScopeThis,
+			updates: map[string]interface{}{"title": "Stretch"},
+			want:    []string{"2026-10-05 Stretch a", "2026-10-12 Stretch a", "2026-10-19 Stretch a"},
+		},
+		{
+			name: "following", date: "2026-10-12", scope: ScopeFollowing,
+			updates: map[string]interface{}{"title": "Yoga"},
+			want:    []string{"2026-10-05 Stretch a", "2026-10-12 Yoga b", "2026-10-19 Yoga b"},
+			rules:   map[string]string{"a": "FREQ=WEEKLY;UNTIL=20261011", "b": "weekly"},
+		},
+		{
+			name: "following from the first occurrence", date: "2026-10-05", scope: ScopeFollowing,
+			updates: map[string]interface{}{"title": "Yoga"},
+			want:    []string{"2026-10-05 Yoga a", "2026-10-12 Yoga a", "2026-10-19 Yoga a"},
+			rules:   map[string]string{"a": "weekly"},
+		},
+		{
+			name: "following by default for a new rule", date: "2026-10-12",
+			updates: map[string]interface{}{"recurrence_rule": "daily"},
+			want:    []string{"2026-10-05 Stretch a", "2026-10-12 Stretch b", "2026-10-19 Stretch b"},
+			rules:   map[string]string{"a": "FREQ=WEEKLY;UNTIL=20261011", "b": "daily"},
+		},
+		{
+			name: "all", date: "2026-10-19", scope: ScopeAll,
+			updates: map[string]interface{}{"title": "Yoga"},
+			want:    []string{"2026-10-05 Yoga a", "2026-10-12 Yoga a", "2026-10-19 Yoga a"},
+			rules:   map[string]string{"a": "weekly"},
+		},
+		{
+			name: "all, ending the series", date: "2026-10-12", scope: ScopeAll,
+			updates: map[string]interface{}{"recurrence_rule": ""},
+			want:    []string{"2026-10-05 Stretch -", "2026-10-12 Stretch -", "2026-10-19 Stretch -"},
+		},
+		{
+			name: "moving to the inbox leaves the series", date: "2026-10-19", scope: ScopeAll,
+			updates:    map[string]interface{}{"due_date": nil, "title": "Yoga"},
+			want:       []string{"2026-10-05 Stretch a", "2026-10-12 Stretch a", "2026-10-26 Stretch a"},
+			exceptions: []string{"2026-10-19 skip"},
+		},
+		{
+			name: "a new rule for this occurrence only", date: "2026-10-12", scope: ScopeThis,
+			updates: map[string]interface{}{"recurrence_rule": "daily"}, wantCode: 400,
+		},
+		{
+			name: "a new due date for the following occurrences", date: "2026-10-12", scope: ScopeFollowing,
+			updates: map[string]interface{}{"due_date": "2026-10-13"}, wantCode: 400,
+		},
+		{
+			name: "completing all occurrences", date: "2026-10-12", scope: ScopeAll,
+			updates: map[string]interface{}{"completed": true}, wantCode: 400,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, now, func(t *testing.T, store testStore) {
+				weeklySeries(t, store)
+				before, _ := plan(t, store)
+				err := store.UpdateTask(occurrenceOn(t, store, tt.date).ID, tt.updates, tt.scope)
+				got, series := plan(t, store)
+				if tt.wantCode != 0 {
+					if code := apiErrorCode(err); code != tt.wantCode {
+						t.Errorf("UpdateTask = %v, want a %d error", err, tt.wantCode)
+					}
+					if !slices.Equal(got, before) {
+						t.Errorf("tasks after a failed update = %v, want %v", got, before)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("tasks = %v, want %v", got, tt.want)
+				}
+				for label, rule := range tt.rules {
+					if series[label].RecurrenceRule != rule {
+						t.Errorf("rule of series %s = %q, want %q", label, series[label].RecurrenceRule, rule)
+					}
+				}
+				if got := exceptionLines(series["a"]); !slices.Equal(got, tt.exceptions) {
+					t.Errorf("exceptions = %v, want %v", got, tt.exceptions)
+				}
+			})
+		})
+	}
+}
+
+func TestDeleteOccurrenceScopes(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		date       string // Of the occurrence deleted.
+		scope      Scope
+		want       []string
+		rules      map[string]string // Of the series by label.
+		exceptions []string          // Of series a.
+		rollover   []string          // Created by a rollover on 2026-10-21.
+	}{
+		{
+			name: "this", date: "2026-10-12", scope: ScopeThis,
+			want:       []string{"2026-10-05 Stretch a", "2026-10-19 Stretch a"},
+			exceptions: []string{"2026-10-12 skip"},
+			rollover:   []string{"2026-10-26 Stretch a"},
+		},
+		{
+			// The open occurrence makes way for the next.
+			name: "this by default, open", date: "2026-10-19",
+			want:       []string{"2026-10-05 Stretch a", "2026-10-12 Stretch a", "2026-10-26 Stretch a"},
+			exceptions: []string{"2026-10-19 skip"},
+		},
+		{
+			name: "following", date: "2026-10-12", scope: ScopeFollowing,
+			want:  []string{"2026-10-05 Stretch a"},
+			rules: map[string]string{"a": "FREQ=WEEKLY;UNTIL=20261011"},
+		},
+		{
+			name: "following from the first occurrence", date: "2026-10-05", scope: ScopeFollowing,
+		},
+		{
+			name: "all", date: "2026-10-12", scope: ScopeAll,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, now, func(t *testing.T, store testStore) {
+				weeklySeries(t, store)
+				deleted := occurrenceOn(t, store, tt.date)
+				if err := store.DeleteTask(deleted.ID, tt.scope); err != nil {
+					t.Fatal(err)
+				}
+				got, series := plan(t, store)
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("tasks = %v, want %v", got, tt.want)
+				}
+				for label, rule := range tt.rules {
+					if series[label].RecurrenceRule != rule {
+						t.Errorf("rule of series %s = %q, want %q", label, series[label].RecurrenceRule, rule)
+					}
+				}
+				if got := exceptionLines(series["a"]); !slices.Equal(got, tt.exceptions) {
+					t.Errorf("exceptions = %v, want %v", got, tt.exceptions)
+				}
+				if len(tt.want) == 0 {
+					if _, err := store.GetSeries(*deleted.SeriesID); apiErrorCode(err) != 404 {
+						t.Errorf("GetSeries of the deleted series = %v, want a 404 error", err)
+					}
+				}
+
+				// Nothing deleted comes back with the next rollover.
+				if err := store.CreateNextOccurrencesForUndoneRecurringTasks(mustDate(t, "2026-10-21")); err != nil {
+					t.Fatal(err)
+				}
+				want := append(got, tt.rollover...)
+				if got, _ := plan(t, store); !slices.Equal(got, want) {
+					t.Errorf("tasks after rollover = %v, want %v", got, want)
+				}
+			})
+		})
+	}
+}
+
+func TestOccurrenceExceptions(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		weeklySeries(t, store)
+		open := occurrenceOn(t, store, "2026-10-19")
+		for _, step := range []struct {
+			updates    map[string]interface{}
+			want       string // The open occurrence.
+			exceptions []string
+		}{
+			{map[string]interface{}{"due_date": "2026-10-21"}, "2026-10-21 Stretch a", []string{"2026-10-19 due 2026-10-21"}},
+			// Editing the moved occurrence keeps it moved.
+			{map[string]interface{}{"title": "Yoga"}, "2026-10-21 Yoga a", []string{"2026-10-19 due 2026-10-21 Yoga"}},
+			{map[string]interface{}{"due_date": "2026-10-22"}, "2026-10-22 Yoga a", []string{"2026-10-19 due 2026-10-22 Yoga"}},
+			{map[string]interface{}{"due_date": "2026-10-19"}, "2026-10-19 Yoga a", []string{"2026-10-19 Yoga"}},
+			{map[string]interface{}{"title": "Stretch"}, "2026-10-19 Stretch a", nil},
+			{map[string]interface{}{"due_date": "2026-10-21", "title": "Yoga"}, "2026-10-21 Yoga a", []string{"2026-10-19 due 2026-10-21 Yoga"}},
+		} {
+			if err := store.UpdateTask(open.ID, step.updates, ScopeThis); err != nil {
+				t.Fatal(err)
+			}
+			got, series := plan(t, store)
+			if got[len(got)-1] != step.want {
+				t.Errorf("after %v: tasks = %v, want the last %q", step.updates, got, step.want)
+			}
+			if got := exceptionLines(series["a"]); !slices.Equal(got, step.exceptions) {
+				t.Errorf("after %v: exceptions = %v, want %v", step.updates, got, step.exceptions)
+			}
+		}
+
+		// Completing the moved occurrence counts on from the date the rule
+		// gave it, not the one it was moved to.
+		if err := store.UpdateTask(open.ID, map[string]interface{}{"completed": true}, ""); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := plan(t, store); got[len(got)-1] != "2026-10-26 Stretch a" {
+			t.Errorf("tasks after completing the moved occurrence = %v, want 2026-10-26 last", got)
+		}
+	})
+}
+
+func TestFollowingEditOfMovedOccurrence(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		weeklySeries(t, store)
+		open := occurrenceOn(t, store, "2026-10-19")
+		if err := store.UpdateTask(open.ID, map[string]interface{}{"due_date": "2026-10-21", "title": "Run"}, ScopeThis); err != nil {
+			t.Fatal(err)
+		}
+
+		// The series splits on the date the rule gave the occurrence, which
+		// takes its move along and loses its title to the new one.
+		if err := store.UpdateTask(open.ID, map[string]interface{}{"title": "Yoga"}, ScopeFollowing); err != nil {
+			t.Fatal(err)
+		}
+		got, series := plan(t, store)
+		want := []string{"2026-10-05 Stretch a", "2026-10-12 Stretch a", "2026-10-21 Yoga b"}
+		if !slices.Equal(got, want) {
+			t.Errorf("tasks = %v, want %v", got, want)
+		}
+		if got := exceptionLines(series["a"]); len(got) != 0 {
+			t.Errorf("exceptions of the first series = %v, want none", got)
+		}
+		if got, want := exceptionLines(series["b"]), []string{"2026-10-19 due 2026-10-21"}; !slices.Equal(got, want) {
+			t.Errorf("exceptions of the new series = %v, want %v", got, want)
+		}
+		if start := series["b"].StartDate.Format(config.DateFormat); start != "2026-10-19" {
+			t.Errorf("new series starts on %s, want 2026-10-19", start)
+		}
+	})
+}
+
+func TestRolloverAfterSplit(t *testing.T) {
+	now := time.Date(2026, 10, 28, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		weeklySeries(t, store)
+		// Both series end up with an overdue open occurrence.
+		first := occurrenceOn(t, store, "2026-10-05")
+		if err := store.UpdateTask(first.ID, map[string]interface{}{"completed": false}, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateTask(occurrenceOn(t, store, "2026-10-12").ID, map[string]interface{}{"title": "Yoga"}, ScopeFollowing); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the new series goes on, and only once.
+		today := TodayOn(FixedClock(now), time.UTC)
+		for range 2 {
+			if err := store.CreateNextOccurrencesForUndoneRecurringTasks(today); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, series := plan(t, store)
+		want := []string{"2026-10-05 Stretch a", "2026-10-12 Yoga b", "2026-10-19 Yoga b", "2026-11-02 Yoga b"}
+		if !slices.Equal(got, want) {
+			t.Errorf("tasks = %v, want %v", got, want)
+		}
+		if n := len(series["b"].Occurrences); n != 3 {
+			t.Errorf("new series has %d occurrences, want 3", n)
+		}
+
+		// Deleting the following occurrences of the first series from its
+		// only one deletes that series and leaves the new one alone.
+		if err := store.DeleteTask(first.ID, ScopeFollowing); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.GetSeries(*first.SeriesID); apiErrorCode(err) != 404 {
+			t.Errorf("GetSeries of the first series = %v, want a 404 error", err)
+		}
+		want = []string{"2026-10-12 Yoga a", "2026-10-19 Yoga a", "2026-11-02 Yoga a"}
+		if got, _ := plan(t, store); !slices.Equal(got, want) {
+			t.Errorf("tasks = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+//go:build sqlite_fts5
+
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func init() {
+	storeKinds["sqlite"] = func(t *testing.T) testStore {
+		store, err := Open(filepath.Join(t.TempDir(), "planner.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+}
+
+func TestStoreUsesClock(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	store := storeKinds["sqlite"](t)
+	store.SetClock(FixedClock(now))
+	task := mustCreate(t, store, Task{Title: "Water plants"})
+
+	later := now.Add(time.Hour)
+	store.SetClock(FixedClock(later))
+	if err := store.UpdateTask(task.ID, map[string]interface{}{"title": "Water the plants"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := store.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.UpdatedAt.Equal(later) {
+		t.Errorf("UpdatedAt = %s, want %s", updated.UpdatedAt, later)
+	}
+}
+
+func TestCloseTruncatesWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "planner.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, store, Task{Title: "Water plants"})
+	if info, err := os.Stat(path + "-wal"); err != nil || info.Size() == 0 {
+		t.Fatalf("expected a WAL before closing: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path + "-wal"); err == nil && info.Size() != 0 {
+		t.Errorf("the WAL still holds %d bytes after closing", info.Size())
+	}
+
+	// Everything is in the database file itself.
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	tasks, err := reopened.GetTasks(TaskFilter{Date: "inbox"})
+	if err != nil || len(tasks) != 1 {
+		t.Errorf("after reopening: tasks %v, %v", tasks, err)
+	}
+}
+
+func TestDocumentKeepsLists(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	source := storeKinds["sqlite"](t).(*Store)
+	source.SetClock(FixedClock(now))
+	if err := source.UpdateInboxTitle("Later"); err != nil {
+		t.Fatal(err)
+	}
+	client, err := source.CreateList(List{Title: "Client A", Color: "green", ListOrder: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := mustCreate(t, source, Task{Title: "Send invoice", ListID: &client.ID})
+	if _, err := source.CreateSubtask(task.ID, Task{Title: "Attach hours"}); err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, source, Task{Title: "Learn Go"})
+	doc, err := source.ExportDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := storeKinds["sqlite"](t).(*Store)
+	if _, err := target.ImportDocument(doc, ImportModeReplace, false); err != nil {
+		t.Fatal(err)
+	}
+	lists, err := target.GetLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 || lists[0].Title != "Later" || !lists[0].IsDefault ||
+		lists[1].Title != "Client A" || lists[1].Color != "green" || lists[1].TaskCount != 1 {
+		t.Fatalf("imported lists = %+v", lists)
+	}
+	tasks, err := target.GetTasks(TaskFilter{ListID: lists[1].ID})
+	if err != nil || len(tasks) != 1 || tasks[0].Title != "Send invoice" || tasks[0].SubtasksTotal != 1 {
+		t.Errorf("tasks of the imported list = %+v, %v", tasks, err)
+	}
+
+	// Documents from before lists title the inbox with a setting.
+	doc = Document{
+		Format:   DocumentFormat,
+		Version:  4,
+		Settings: []Setting{{Key: "inbox_title", Value: "Someday"}},
+		Tasks:    []DocumentTask{{UID: NewUID(), Title: "Learn Rust"}},
+	}
+	if _, err := target.ImportDocument(doc, ImportModeReplace, false); err != nil {
+		t.Fatal(err)
+	}
+	if title, _ := target.GetInboxTitle(); title != "Someday" {
+		t.Errorf("inbox title = %q, want the document's", title)
+	}
+	if tasks, _ := target.GetTasks(TaskFilter{Date: "inbox"}); len(tasks) != 1 || tasks[0].Title != "Learn Rust" {
+		t.Errorf("inbox = %+v", tasks)
+	}
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Store owns an open database and allows swapping the underlying file while
+// the server is running. Users bracket their work with Acquire/release; Swap
+// waits for those to drain and blocks new ones until the new file is open.
+type Store struct {
+	inFlight sync.RWMutex // Read-held by users of the database, write-held by Swap.
+	conn     atomic.Pointer[gorm.DB]
+	path     string
+	clock    Clock
+	// observers are told about the occurrences recurrence creates.
+	observers []func(occurrence Task)
+}
+
+// renameFile and openFile are the file operations of Swap, replaced by tests
+// to make its steps fail.
+var (
+	renameFile = os.Rename
+	openFile   = openDatabase
+)
+
+// Open opens the database at path (creating and migrating it if needed).
+func Open(path string) (*Store, error) {
+	gdb, err := openDatabase(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, clock: SystemClock}
+	s.setConn(gdb)
+	return s, nil
+}
+
+// setConn makes gdb the current connection, with timestamps from s.clock.
+func (s *Store) setConn(gdb *gorm.DB) {
+	gdb.Config.NowFunc = func() time.Time { return s.clock.Now().Local() }
+	s.conn.Store(gdb)
+}
+
+// SetClock makes the store read the time from clock, e.g. for created_at
+// and updated_at. It must be called before the store is shared.
+func (s *Store) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// OnOccurrenceCreated implements TaskStore. It must be called before the
+// store is shared.
+func (s *Store) OnOccurrenceCreated(fn func(occurrence Task)) {
+	s.observers = append(s.observers, fn)
+}
+
+// taskTransaction runs fn in a transaction, then reports the occurrences it
+// created to the observers if it was committed.
+func (s *Store) taskTransaction(fn func(tx sqlTx) error) error {
+	var occurrences []Task
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		return fn(sqlTx{db: tx, createdTasks: &occurrences})
+	})
+	if err == nil {
+		for _, occurrence := range occurrences {
+			for _, observe := range s.observers {
+				observe(occurrence)
+			}
+		}
+	}
+	return err
+}
+
+// Now returns the current time of the store's clock.
+func (s *Store) Now() time.Time {
+	return s.clock.Now()
+}
+
+// DB returns the current connection. Hold Acquire while using it if a Swap
+// may happen concurrently.
+func (s *Store) DB() *gorm.DB {
+	return s.conn.Load()
+}
+
+// Path returns the database file path.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Acquire marks the start of work on the database and returns the function
+// marking its end. It blocks while a Swap is in progress. Calls must not be
+// nested: a pending Swap would deadlock an inner Acquire.
+func (s *Store) Acquire() (release func()) {
+	s.inFlight.RLock()
+	return s.inFlight.RUnlock
+}
+
+// Close copies the whole write-ahead log into the database file, truncating
+// it, and closes the current connection. The file is then complete on its own
+// and can be copied without its -wal sidecar.
+func (s *Store) Close() error {
+	if err := walCheckpoint(s.DB(), "TRUNCATE"); err != nil {
+		// Closing the last connection checkpoints as well, unless another
+		// process still has the database open.
+		slog.Warn("Could not checkpoint the WAL before closing the database", "error", err)
+	}
+	return closeDB(s.DB())
+}
+
+// Swap replaces the database file with the one at srcPath. The new file is
+// validated and migrated before anything is touched; srcPath is consumed and
+// should be in the same directory as the database so renames are atomic.
+// It takes the permissions of the file it replaces. On failure the previous
+// database is put back and reopened.
+func (s *Store) Swap(srcPath string) error {
+	return s.SwapAfter(srcPath, nil)
+}
+
+// SwapAfter is Swap calling before, if not nil, once the users of the
+// database have finished and before it is replaced, e.g. to back it up with
+// nothing written in between. An error from before cancels the swap and is
+// returned as is.
+func (s *Store) SwapAfter(srcPath string, before func() error) error {
+	// Opening runs the same migrations as startup, which acts as validation.
+	candidate, err := openFile(srcPath)
+	if err != nil {
+		return NewAPIError(400, fmt.Sprintf("Import failed: Uploaded file is not a valid database or is corrupted: %v", err))
+	}
+	// Migrating wrote to the WAL, which stays behind when the file is moved.
+	if err := walCheckpoint(candidate, "TRUNCATE"); err != nil {
+		closeDB(candidate)
+		return fmt.Errorf("swap: checkpointing validated database: %w", err)
+	}
+	if err := closeDB(candidate); err != nil {
+		return fmt.Errorf("swap: closing validated database: %w", err)
+	}
+
+	// Wait for in-flight users to finish; new ones wait until we are done.
+	s.inFlight.Lock()
+	defer s.inFlight.Unlock()
+
+	if before != nil {
+		if err := before(); err != nil {
+			return err
+		}
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		if err := os.Chmod(srcPath, info.Mode().Perm()); err != nil {
+			slog.Warn("Failed to give the new database the permissions of the current one", "error", err)
+		}
+	}
+
+	backupPath := s.path + ".bak"
+	old := s.DB()
+
+	// The backup must be complete without its WAL, which is removed below.
+	if err := walCheckpoint(old, "TRUNCATE"); err != nil {
+		return fmt.Errorf("swap: checkpointing current database: %w", err)
+	}
+	slog.Info("Closing current database connection for swap...")
+	if err := closeDB(old); err != nil {
+		// Without a clean close the file may still be in use; keep the old database.
+		return fmt.Errorf("swap: closing current database: %w", err)
+	}
+
+	if err := renameFile(s.path, backupPath); err != nil && !os.IsNotExist(err) {
+		return errors.Join(fmt.Errorf("swap: backing up current database: %w", err), s.reopen())
+	}
+	// A WAL left by the old file must not be applied to the new one.
+	removeSidecars(s.path)
+
+	if err := renameFile(srcPath, s.path); err != nil {
+		slog.Error("Failed to move new database into place, restoring previous one", "error", err)
+		if restoreErr := renameFile(backupPath, s.path); restoreErr != nil && !os.IsNotExist(restoreErr) {
+			slog.Error("CRITICAL: Failed to restore previous database", "backup_path", backupPath, "error", restoreErr)
+			return fmt.Errorf("swap: replacing database: %w (restoring previous one also failed: %v)", err, restoreErr)
+		}
+		return errors.Join(fmt.Errorf("swap: replacing database: %w", err), s.reopen())
+	}
+
+	gdb, err := openFile(s.path)
+	if err != nil {
+		slog.Error("Failed to open swapped-in database, rolling back", "error", err)
+		os.Remove(s.path)
+		removeSidecars(s.path)
+		if restoreErr := renameFile(backupPath, s.path); restoreErr != nil && !os.IsNotExist(restoreErr) {
+			return fmt.Errorf("swap: opening new database: %w (restoring previous one also failed: %v)", err, restoreErr)
+		}
+		return errors.Join(fmt.Errorf("swap: opening new database: %w", err), s.reopen())
+	}
+	s.setConn(gdb)
+
+	if err := os.Remove(backupPath); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to remove previous database after swap", "backup_path", backupPath, "error", err)
+	}
+	slog.Info("Database swapped successfully", "path", s.path)
+	return nil
+}
+
+// reopen opens s.path again after a failed swap. Must be called with inFlight held.
+func (s *Store) reopen() error {
+	gdb, err := openFile(s.path)
+	if err != nil {
+		slog.Error("CRITICAL: Failed to reopen database after failed swap", "path", s.path, "error", err)
+		return fmt.Errorf("reopening database: %w", err)
+	}
+	s.setConn(gdb)
+	return nil
+}
+
+// removeSidecars deletes the WAL and shared-memory files of a closed database.
+func removeSidecars(path string) {
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove database sidecar file", "path", path+suffix, "error", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"week-planner/internal/config"
+)
+
+// testStore is a store the shared tests run against.
+type testStore interface {
+	TaskStore
+	WebhookStore
+	TagStore
+	ListStore
+	SettingsStore
+	SetClock(Clock)
+}
+
+// storeKinds are the stores the shared tests run against, by name. The
+// SQLite store needs FTS5 and is added by sqlite_test.go when the tests are
+// built with the sqlite_fts5 tag.
+var storeKinds = map[string]func(t *testing.T) testStore{
+	"memory": func(t *testing.T) testStore { return NewMemoryStore() },
+}
+
+// forEachStore runs test as a subtest on a new store of every kind, with its
+// clock fixed at now.
+func forEachStore(t *testing.T, now time.Time, test func(t *testing.T, store testStore)) {
+	for name, open := range storeKinds {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			store.SetClock(FixedClock(now))
+			test(t, store)
+		})
+	}
+}
+
+// mustCreate creates a task, failing the test on error.
+func mustCreate(t *testing.T, store TaskStore, task Task) Task {
+	t.Helper()
+	created, err := store.CreateTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// dueDates returns the sorted due dates of the top-level tasks of store.
+func dueDates(t *testing.T, store TaskStore) []string {
+	t.Helper()
+	tasks, err := store.GetTasks(TaskFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		if task.DueDate.Valid {
+			dates = append(dates, task.DueDate.Time.Format(config.DateFormat))
+		}
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+func TestFixedClock(t *testing.T) {
+	now := time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC)
+	clock := FixedClock(now)
+	if !clock.Now().Equal(now) {
+		t.Errorf("Now() = %s, want %s", clock.Now(), now)
+	}
+	if got := TodayOn(clock, tokyo); !got.Equal(mustDate(t, "2026-10-19")) {
+		t.Errorf("TodayOn(clock, tokyo) = %s, want 2026-10-19", got)
+	}
+}
+
+func TestMemoryStoreUsesClock(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.SetClock(FixedClock(now))
+	task := mustCreate(t, store, Task{Title: "Water plants"})
+	if !task.UpdatedAt.Equal(now) {
+		t.Errorf("UpdatedAt = %s, want %s", task.UpdatedAt, now)
+	}
+}
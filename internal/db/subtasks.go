@@ -0,0 +1,206 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// checklistLine matches a Markdown task list item, e.g. "- [x] Buy milk".
+var checklistLine = regexp.MustCompile(`^(\s*)[-*+] \[([ xX])\]\s+(.*\S)\s*$`)
+
+// withSubtaskCounts selects tasks together with the number of their subtasks
+// and of their completed subtasks.
+func withSubtaskCounts(db *gorm.DB) *gorm.DB {
+	return db.Select("tasks.*, " +
+		"(SELECT count(*) FROM tasks AS sub WHERE sub.parent_id = tasks.id) AS subtasks_total, " +
+		"(SELECT count(*) FROM tasks AS sub WHERE sub.parent_id = tasks.id AND sub.completed = 1) AS subtasks_completed")
+}
+
+// GetSubtasks returns the subtasks of a task, by order.
+func (s *Store) GetSubtasks(parentID int) (Tasks, error) {
+	if _, err := s.GetTask(parentID); err != nil {
+		return nil, err
+	}
+	var tasks Tasks
+	if err := s.DB().Where("parent_id = ?", parentID).Order("task_order, id").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("getSubtasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// CreateSubtask adds task as the last subtask of parentID. Subtasks have no
+// due date or recurrence, and cannot have subtasks themselves.
+func (s *Store) CreateSubtask(parentID int, task Task) (Task, error) {
+	parent, err := s.GetTask(parentID)
+	if err != nil {
+		return Task{}, err
+	}
+	if parent.ParentID != nil {
+		return Task{}, NewAPIError(400, "Subtasks cannot have subtasks")
+	}
+	prepareSubtask(&task, parentID)
+	if err := task.Validate(); err != nil {
+		return Task{}, err
+	}
+
+	err = s.DB().Transaction(func(tx *gorm.DB) error {
+		var last sql.NullInt64
+		if err := tx.Model(&Task{}).Where("parent_id = ?", parentID).Select("MAX(task_order)").Row().Scan(&last); err != nil {
+			return err
+		}
+		if last.Valid {
+			task.TaskOrder = int(last.Int64) + 1
+		}
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		return rollUpCompletion(tx, parentID)
+	})
+	if err != nil {
+		return Task{}, fmt.Errorf("createSubtask: %w", err)
+	}
+	return task, nil
+}
+
+// prepareSubtask turns task into a new subtask of parentID.
+func prepareSubtask(task *Task, parentID int) {
+	task.ID = 0
+	task.ParentID = &parentID
+	task.DueDate = NullTime{}
+	task.RecurrenceRule = ""
+	task.RecurrenceInterval = 1
+	task.TaskOrder = 0
+}
+
+// parentOf returns the parent ID of a task, nil for top-level or missing tasks.
+func parentOf(db *gorm.DB, id int) (*int, error) {
+	var task Task
+	err := db.Select("parent_id").First(&task, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return task.ParentID, err
+}
+
+// rollUpCompletion marks a task completed when all its subtasks are, and
+// uncompleted when one of them is not. Tasks without subtasks are left alone.
+func rollUpCompletion(db *gorm.DB, parentID int) error {
+	var counts struct{ Total, Open int }
+	err := db.Model(&Task{}).Where("parent_id = ?", parentID).
+		Select("count(*) AS total, coalesce(sum(completed = 0), 0) AS open").Scan(&counts).Error
+	if err != nil {
+		return fmt.Errorf("rolling up completion of task %d: %w", parentID, err)
+	}
+	if counts.Total == 0 {
+		return nil
+	}
+	completed := 0
+	if counts.Open == 0 {
+		completed = 1
+	}
+	res := db.Model(&Task{}).Where("id = ? AND completed <> ?", parentID, completed).Update("completed", completed)
+	if res.Error != nil {
+		return fmt.Errorf("rolling up completion of task %d: %w", parentID, res.Error)
+	}
+	if res.RowsAffected > 0 {
+		slog.Debug("Task completion rolled up from subtasks", "task_id", parentID, "completed", completed)
+	}
+	return nil
+}
+
+// copySubtasks adds uncompleted copies of the subtasks of fromID to toID.
+func copySubtasks(tx *gorm.DB, fromID, toID int) error {
+	var subtasks Tasks
+	if err := tx.Where("parent_id = ?", fromID).Order("task_order, id").Find(&subtasks).Error; err != nil {
+		return err
+	}
+	for _, sub := range subtasks {
+		copied := Task{Title: sub.Title, Description: sub.Description, Color: sub.Color}
+		prepareSubtask(&copied, toID)
+		copied.TaskOrder = sub.TaskOrder
+		if err := tx.Create(&copied).Error; err != nil {
+			return fmt.Errorf("copying subtask %d: %w", sub.ID, err)
+		}
+	}
+	return nil
+}
+
+// splitChecklist separates the Markdown task list items of a description
+// from the rest of it. Subtasks have no subtasks of their own, so items
+// nested under others become subtasks too; nested reports whether there
+// were any.
+func splitChecklist(description string) (items []Task, rest string, nested bool) {
+	var kept []string
+	indent := -1   // Of the least indented item.
+	split := false // Whether items were taken out since the last kept line.
+	for _, line := range strings.Split(description, "\n") {
+		match := checklistLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			// One blank line is enough where the items were.
+			blank := strings.TrimSpace(line) == ""
+			if !(blank && split && len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) == "") {
+				kept = append(kept, line)
+			}
+			split = split && blank
+			continue
+		}
+		split = true
+		if indent < 0 || len(match[1]) < indent {
+			nested = nested || indent >= 0
+			indent = len(match[1])
+		} else if len(match[1]) > indent {
+			nested = true
+		}
+		item := Task{Title: match[3], TaskOrder: len(items)}
+		if match[2] != " " {
+			item.Completed = 1
+		}
+		items = append(items, item)
+	}
+	return items, strings.TrimSpace(strings.Join(kept, "\n")), nested
+}
+
+// convertChecklists is the migration turning the "- [ ]"/"- [x]" lines of
+// task descriptions into subtasks. It uses plain SQL rather than the models.
+func convertChecklists(tx *gorm.DB) error {
+	var tasks []struct {
+		ID          int
+		Description string
+	}
+	err := tx.Raw("SELECT id, description FROM tasks WHERE parent_id IS NULL AND description LIKE '%[%]%'").Scan(&tasks).Error
+	if err != nil {
+		return err
+	}
+	converted := 0
+	for _, task := range tasks {
+		items, rest, nested := splitChecklist(task.Description)
+		if len(items) == 0 {
+			continue
+		}
+		if nested {
+			slog.Warn("Nested checklist items became subtasks of the task itself", "task_id", task.ID, "subtasks", len(items))
+		}
+		for _, item := range items {
+			err := tx.Exec(`INSERT INTO tasks (uid, title, completed, task_order, color, description, recurrence_rule, recurrence_interval, parent_id, updated_at)
+				VALUES (?, ?, ?, ?, '', '', '', 1, ?, CURRENT_TIMESTAMP)`,
+				NewUID(), item.Title, item.Completed, item.TaskOrder, task.ID).Error
+			if err != nil {
+				return fmt.Errorf("task %d: %w", task.ID, err)
+			}
+		}
+		if err := tx.Exec("UPDATE tasks SET description = ? WHERE id = ?", rest, task.ID).Error; err != nil {
+			return fmt.Errorf("task %d: %w", task.ID, err)
+		}
+		converted++
+	}
+	if converted > 0 {
+		slog.Info("Converted description checklists to subtasks", "tasks", converted)
+	}
+	return nil
+}
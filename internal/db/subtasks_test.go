@@ -0,0 +1,86 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitChecklist(t *testing.T) {
+	type item struct {
+		title     string
+		completed int
+	}
+	tests := []struct {
+		name        string
+		description string
+		items       []item
+		rest        string
+		nested      bool
+	}{
+		{
+			name:        "no checklist",
+			description: "See [the docs] and [x] marks",
+			rest:        "See [the docs] and [x] marks",
+		},
+		{
+			name:        "checklist only",
+			description: "- [ ] Passport\n- [x] Tickets\n* [X] Charger\n+ [ ]  Snacks  ",
+			items:       []item{{"Passport", 0}, {"Tickets", 1}, {"Charger", 1}, {"Snacks", 0}},
+		},
+		{
+			name:        "text around the checklist",
+			description: "Pack for the weekend.\n\n- [ ] Passport\n- [x] Tickets\n\nCheck the weather.\n\n\nCall home.",
+			items:       []item{{"Passport", 0}, {"Tickets", 1}},
+			rest:        "Pack for the weekend.\n\nCheck the weather.\n\n\nCall home.",
+		},
+		{
+			name:        "CRLF line ends",
+			description: "Before\r\n- [ ] One\r\n- [x] Two\r\nAfter",
+			items:       []item{{"One", 0}, {"Two", 1}},
+			rest:        "Before\r\nAfter",
+		},
+		{
+			name:        "indented, not nested",
+			description: "  - [ ] One\n  - [ ] Two",
+			items:       []item{{"One", 0}, {"Two", 0}},
+		},
+		{
+			name:        "nested",
+			description: "- [ ] Milk\n    - [ ] Oat milk\n- [x] Bread",
+			items:       []item{{"Milk", 0}, {"Oat milk", 0}, {"Bread", 1}},
+			nested:      true,
+		},
+		{
+			name:        "nested, less indented later",
+			description: "  - [ ] Oat milk\n- [ ] Milk",
+			items:       []item{{"Oat milk", 0}, {"Milk", 0}},
+			nested:      true,
+		},
+		{
+			name:        "not items",
+			description: "- [] Empty\n- [ ]\n-[ ] No space\n- [y] Other mark",
+			rest:        "- [] Empty\n- [ ]\n-[ ] No space\n- [y] Other mark",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, rest, nested := splitChecklist(tt.description)
+			var items []item
+			for i, task := range tasks {
+				items = append(items, item{task.Title, task.Completed})
+				if task.TaskOrder != i {
+					t.Errorf("item %d has order %d", i, task.TaskOrder)
+				}
+			}
+			if !reflect.DeepEqual(items, tt.items) {
+				t.Errorf("items = %v, want %v", items, tt.items)
+			}
+			if rest != tt.rest {
+				t.Errorf("rest = %q, want %q", rest, tt.rest)
+			}
+			if nested != tt.nested {
+				t.Errorf("nested = %v, want %v", nested, tt.nested)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+//go:build sqlite_fts5
+
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// swapStore opens a store in a new directory with a task "Old", and one more
+// written right before the test swaps, left in the WAL.
+func swapStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	mustCreate(t, store, Task{Title: "Old"})
+	mustCreate(t, store, Task{Title: "Recent"})
+	return store
+}
+
+// upload creates a database with a task "New" next to the database of
+// store, as the handlers do with uploads, and returns its path.
+func upload(t *testing.T, store *Store) string {
+	t.Helper()
+	path := filepath.Join(filepath.Dir(store.Path()), "upload.db")
+	other, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, other, Task{Title: "New"})
+	if err := other.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// inboxTitles returns the titles of the undated tasks of store.
+func inboxTitles(t *testing.T, store TaskStore) []string {
+	t.Helper()
+	tasks, err := store.GetTasks(TaskFilter{Date: "inbox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return titles(tasks)
+}
+
+// replaceFileOps makes Swap rename and open files with the given functions
+// until the test ends.
+func replaceFileOps(t *testing.T, rename func(from, to string) error, open func(path string) (*gorm.DB, error)) {
+	t.Cleanup(func() { renameFile, openFile = os.Rename, openDatabase })
+	if rename != nil {
+		renameFile = rename
+	}
+	if open != nil {
+		openFile = open
+	}
+}
+
+func TestSwap(t *testing.T) {
+	store := swapStore(t)
+	src := upload(t, store)
+	if err := store.Swap(src); err != nil {
+		t.Fatal(err)
+	}
+	if got := inboxTitles(t, store); !slices.Equal(got, []string{"New"}) {
+		t.Errorf("tasks after swapping = %v, want the uploaded ones", got)
+	}
+	for _, path := range []string{src, src + "-wal", store.Path() + ".bak"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s left behind: %v", filepath.Base(path), err)
+		}
+	}
+	// The swapped-in database is the one written to.
+	mustCreate(t, store, Task{Title: "Newer"})
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := Open(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got := inboxTitles(t, reopened); !slices.Equal(got, []string{"New", "Newer"}) {
+		t.Errorf("tasks after reopening = %v", got)
+	}
+}
+
+func TestSwapRejectsInvalidDatabase(t *testing.T) {
+	for name, write := range map[string]func(t *testing.T, path string){
+		"not a database": func(t *testing.T, path string) {
+			if err := os.WriteFile(path, []byte("SQLite format 2\x00 but not really"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"newer schema": func(t *testing.T, path string) {
+			gdb, err := Connect(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closeDB(gdb)
+			if _, err := Migrate(gdb); err != nil {
+				t.Fatal(err)
+			}
+			if err := gdb.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from_the_future', CURRENT_TIMESTAMP)", len(Migrations())+1).Error; err != nil {
+				t.Fatal(err)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := swapStore(t)
+			src := filepath.Join(filepath.Dir(store.Path()), "upload.db")
+			write(t, src)
+			if err := store.Swap(src); apiErrorCode(err) != 400 {
+				t.Errorf("Swap = %v, want a 400 error", err)
+			}
+			if got := inboxTitles(t, store); !slices.Equal(got, []string{"Old", "Recent"}) {
+				t.Errorf("tasks after a rejected swap = %v, want the old ones", got)
+			}
+		})
+	}
+}
+
+func TestSwapRollsBack(t *testing.T) {
+	errFail := errors.New("failing on purpose")
+	tests := []struct {
+		name   string
+		rename func(store *Store, src string) func(from, to string) error
+		open   func(store *Store) func(path string) (*gorm.DB, error)
+	}{
+		{
+			name: "backing up the current database",
+			rename: func(store *Store, src string) func(from, to string) error {
+				return func(from, to string) error {
+					if from == store.Path() {
+						return errFail
+					}
+					return os.Rename(from, to)
+				}
+			},
+		},
+		{
+			name: "moving the new database into place",
+			rename: func(store *Store, src string) func(from, to string) error {
+				return func(from, to string) error {
+					if from == src {
+						return errFail
+					}
+					return os.Rename(from, to)
+				}
+			},
+		},
+		{
+			name: "opening the new database",
+			open: func(store *Store) func(path string) (*gorm.DB, error) {
+				failed := false
+				return func(path string) (*gorm.DB, error) {
+					if path == store.Path() && !failed {
+						failed = true
+						return nil, errFail
+					}
+					return openDatabase(path)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := swapStore(t)
+			src := upload(t, store)
+			var rename func(from, to string) error
+			var open func(path string) (*gorm.DB, error)
+			if tt.rename != nil {
+				rename = tt.rename(store, src)
+			}
+			if tt.open != nil {
+				open = tt.open(store)
+			}
+			replaceFileOps(t, rename, open)
+
+			if err := store.Swap(src); !errors.Is(err, errFail) {
+				t.Errorf("Swap = %v, want the failure", err)
+			}
+			if got := inboxTitles(t, store); !slices.Equal(got, []string{"Old", "Recent"}) {
+				t.Errorf("tasks after a failed swap = %v, want the old ones", got)
+			}
+			if _, err := os.Stat(store.Path() + ".bak"); !os.IsNotExist(err) {
+				t.Errorf("backup of the old database left behind: %v", err)
+			}
+			mustCreate(t, store, Task{Title: "Later"})
+		})
+	}
+}
+
+func TestSwapFailsToReopen(t *testing.T) {
+	store := swapStore(t)
+	src := upload(t, store)
+	errFail := errors.New("failing on purpose")
+	replaceFileOps(t, nil, func(path string) (*gorm.DB, error) {
+		if path == store.Path() {
+			return nil, errFail
+		}
+		return openDatabase(path)
+	})
+	if err := store.Swap(src); !errors.Is(err, errFail) {
+		t.Errorf("Swap = %v, want the failure", err)
+	}
+
+	// The old database is back in place for the next start.
+	openFile = openDatabase
+	reopened, err := Open(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got := inboxTitles(t, reopened); !slices.Equal(got, []string{"Old", "Recent"}) {
+		t.Errorf("tasks after reopening = %v, want the old ones", got)
+	}
+}
+
+func TestSwapAfter(t *testing.T) {
+	store := swapStore(t)
+	src := upload(t, store)
+	errFail := errors.New("failing on purpose")
+	if err := store.SwapAfter(src, func() error { return errFail }); err != errFail {
+		t.Errorf("SwapAfter = %v, want the error of before", err)
+	}
+	if got := inboxTitles(t, store); !slices.Equal(got, []string{"Old", "Recent"}) {
+		t.Errorf("tasks after a cancelled swap = %v, want the old ones", got)
+	}
+
+	// before sees the current database, with nothing else running.
+	var seen []string
+	err := store.SwapAfter(src, func() error {
+		seen = inboxTitles(t, store)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(seen, []string{"Old", "Recent"}) {
+		t.Errorf("before saw %v, want the old tasks", seen)
+	}
+	if got := inboxTitles(t, store); !slices.Equal(got, []string{"New"}) {
+		t.Errorf("tasks after swapping = %v, want the uploaded ones", got)
+	}
+}
@@ -0,0 +1,353 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// maxTagLength is the longest tag name accepted, in characters.
+const maxTagLength = 50
+
+// Tag groups tasks across days. Names are unique and lowercase; tasks refer
+// to their tags by name (see Task.Tags).
+type Tag struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"not null;uniqueIndex" json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TaskCount int `gorm:"->;-:migration" json:"task_count"` // Filled by GetTags.
+}
+
+// TaskTag links a task to one of its tags.
+type TaskTag struct {
+	TaskID int `gorm:"primaryKey;autoIncrement:false"`
+	TagID  int `gorm:"primaryKey;autoIncrement:false"`
+}
+
+// TagList is the names of the tags of a task, sorted. It is stored
+// space-separated in the tags column of tasks, which the full-text index
+// includes; task_tags links the task to the tags themselves.
+type TagList []string
+
+// Value implements driver.Valuer.
+func (l TagList) Value() (driver.Value, error) {
+	return strings.Join(l, " "), nil
+}
+
+// Scan implements sql.Scanner.
+func (l *TagList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+	case string:
+		*l = strings.Fields(v)
+	case []byte:
+		*l = strings.Fields(string(v))
+	default:
+		return fmt.Errorf("unexpected type %T for TagList Scan", value)
+	}
+	return nil
+}
+
+// MarshalJSON writes tasks without tags with an empty list rather than null.
+func (l TagList) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(l))
+}
+
+// NormalizeTagName returns name as tags are stored: without a leading "#",
+// in lowercase. Names are made of letters, digits, "-", "_" and "/", with at
+// least one letter, so that "#1" in a title is not taken for a tag.
+func NormalizeTagName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if name == "" {
+		return "", NewAPIError(400, "Tag name is required")
+	}
+	if utf8.RuneCountInString(name) > maxTagLength {
+		return "", NewAPIError(400, fmt.Sprintf("Tag name too long (at most %d characters)", maxTagLength))
+	}
+	letter := false
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r) || r == '-' || r == '_' || r == '/':
+		default:
+			return "", NewAPIError(400, fmt.Sprintf("Invalid tag name %q (letters, digits, '-', '_' and '/' only)", name))
+		}
+	}
+	if !letter {
+		return "", NewAPIError(400, fmt.Sprintf("Invalid tag name %q (must contain a letter)", name))
+	}
+	return name, nil
+}
+
+// NormalizeTags normalizes tag names (see NormalizeTagName) and returns them
+// sorted, without duplicates.
+func NormalizeTags(names []string) (TagList, error) {
+	tags := TagList{}
+	for _, name := range names {
+		name, err := NormalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(tags, name) {
+			tags = append(tags, name)
+		}
+	}
+	slices.Sort(tags)
+	return tags, nil
+}
+
+// ParseTitleTags takes the #tags out of a task title, e.g. "Call Bob #work"
+// is "Call Bob" tagged work. Words that are not valid tag names, like "#1",
+// stay in the title, and so does a title that is nothing but tags.
+func ParseTitleTags(title string) (string, TagList) {
+	var words []string
+	tags := TagList{}
+	for _, word := range strings.Fields(title) {
+		if strings.HasPrefix(word, "#") {
+			if name, err := NormalizeTagName(word); err == nil {
+				if !slices.Contains(tags, name) {
+					tags = append(tags, name)
+				}
+				continue
+			}
+		}
+		words = append(words, word)
+	}
+	if len(words) == 0 {
+		words = strings.Fields(title)
+	}
+	slices.Sort(tags)
+	return strings.Join(words, " "), tags
+}
+
+// parseSearchQuery takes the "tag:name" terms out of a search query. It
+// returns the rest of the query, to be matched against the text of tasks,
+// and the tags they must have. Terms naming no valid tag are left as text.
+func parseSearchQuery(query string) (string, TagList) {
+	var words []string
+	tags := TagList{}
+	for _, word := range strings.Fields(query) {
+		if value, ok := strings.CutPrefix(strings.ToLower(word), "tag:"); ok {
+			if name, err := NormalizeTagName(value); err == nil {
+				if !slices.Contains(tags, name) {
+					tags = append(tags, name)
+				}
+				continue
+			}
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), tags
+}
+
+// hasTags reports whether task has all of tags.
+func hasTags(task Task, tags TagList) bool {
+	for _, tag := range tags {
+		if !slices.Contains(task.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// TagStore keeps the tags tasks are grouped by. Tasks are tagged through
+// TaskStore: the tags of a task are set with its "tags" field, and tags are
+// created as they are first used. *Store and MemoryStore implement it.
+type TagStore interface {
+	// GetTags returns all tags by name, with the number of tasks having them.
+	GetTags() ([]Tag, error)
+	GetTag(id int) (Tag, error) // 404 APIError if there is no such tag.
+	// CreateTag adds a tag; 409 APIError if one has the same name.
+	CreateTag(tag Tag) (Tag, error)
+	// UpdateTag renames or recolors a tag. Renaming renames it on its tasks;
+	// 409 APIError if another tag has the new name.
+	UpdateTag(tag Tag) (Tag, error)
+	// DeleteTag deletes a tag and removes it from its tasks.
+	DeleteTag(id int) error
+}
+
+var (
+	_ TagStore = (*Store)(nil)
+	_ TagStore = (*MemoryStore)(nil)
+)
+
+// validateTag normalizes the name of tag.
+func validateTag(tag *Tag) error {
+	name, err := NormalizeTagName(tag.Name)
+	if err != nil {
+		return err
+	}
+	tag.Name = name
+	tag.Color = strings.TrimSpace(tag.Color)
+	return nil
+}
+
+// withTags narrows a task query down to the tasks having all of tags.
+func withTags(tags TagList) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, tag := range tags {
+			db = db.Where("tasks.id IN (SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE tags.name = ?)", tag)
+		}
+		return db
+	}
+}
+
+// setTaskTags links a task to the tags named tags, creating the ones that do
+// not exist yet. The tags column of the task is written by the caller.
+func setTaskTags(tx *gorm.DB, taskID int, tags TagList) error {
+	if err := tx.Where("task_id = ?", taskID).Delete(&TaskTag{}).Error; err != nil {
+		return err
+	}
+	for _, name := range tags {
+		tag := Tag{Name: name}
+		if err := tx.Where("name = ?", name).FirstOrCreate(&tag).Error; err != nil {
+			return fmt.Errorf("tag %q: %w", name, err)
+		}
+		if err := tx.Create(&TaskTag{TaskID: taskID, TagID: tag.ID}).Error; err != nil {
+			return fmt.Errorf("tag %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// refreshTaskTags rewrites the tags column of the tasks linked to tagID from
+// task_tags, after the tag was renamed or before it is deleted (skipping it).
+func refreshTaskTags(tx *gorm.DB, tagID int, skip bool) error {
+	var taskIDs []int
+	if err := tx.Model(&TaskTag{}).Where("tag_id = ?", tagID).Pluck("task_id", &taskIDs).Error; err != nil {
+		return err
+	}
+	for _, taskID := range taskIDs {
+		query := tx.Model(&Tag{}).Joins("JOIN task_tags ON task_tags.tag_id = tags.id").
+			Where("task_tags.task_id = ?", taskID)
+		if skip {
+			query = query.Where("tags.id <> ?", tagID)
+		}
+		var names []string
+		if err := query.Order("tags.name").Pluck("tags.name", &names).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Task{}).Where("id = ?", taskID).Update("tags", TagList(names)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTags implements TagStore.
+func (s *Store) GetTags() ([]Tag, error) {
+	tags := []Tag{}
+	err := s.DB().Select("tags.*, (SELECT count(*) FROM task_tags WHERE task_tags.tag_id = tags.id) AS task_count").
+		Order("name").Find(&tags).Error
+	if err != nil {
+		return nil, fmt.Errorf("getTags: %w", err)
+	}
+	return tags, nil
+}
+
+// GetTag implements TagStore.
+func (s *Store) GetTag(id int) (Tag, error) {
+	var tag Tag
+	err := s.DB().Select("tags.*, (SELECT count(*) FROM task_tags WHERE task_tags.tag_id = tags.id) AS task_count").
+		First(&tag, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Tag{}, NewAPIError(404, "Tag not found")
+	}
+	if err != nil {
+		return Tag{}, fmt.Errorf("getTag: %w", err)
+	}
+	return tag, nil
+}
+
+// CreateTag implements TagStore.
+func (s *Store) CreateTag(tag Tag) (Tag, error) {
+	if err := validateTag(&tag); err != nil {
+		return Tag{}, err
+	}
+	tag.ID, tag.TaskCount = 0, 0
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Tag{}).Where("name = ?", tag.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return NewAPIError(409, fmt.Sprintf("Tag %q already exists", tag.Name))
+		}
+		return tx.Create(&tag).Error
+	})
+	if err != nil {
+		return Tag{}, txError("createTag", err)
+	}
+	return tag, nil
+}
+
+// UpdateTag implements TagStore.
+func (s *Store) UpdateTag(tag Tag) (Tag, error) {
+	if err := validateTag(&tag); err != nil {
+		return Tag{}, err
+	}
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		var current Tag
+		err := tx.First(&current, tag.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return NewAPIError(404, "Tag not found")
+		} else if err != nil {
+			return err
+		}
+		if current.Name != tag.Name {
+			var count int64
+			if err := tx.Model(&Tag{}).Where("name = ? AND id <> ?", tag.Name, tag.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return NewAPIError(409, fmt.Sprintf("Tag %q already exists", tag.Name))
+			}
+		}
+		err = tx.Model(&Tag{}).Where("id = ?", tag.ID).
+			Updates(map[string]interface{}{"name": tag.Name, "color": tag.Color}).Error
+		if err != nil {
+			return err
+		}
+		if current.Name != tag.Name {
+			return refreshTaskTags(tx, tag.ID, false)
+		}
+		return nil
+	})
+	if err != nil {
+		return Tag{}, txError("updateTag", err)
+	}
+	return s.GetTag(tag.ID)
+}
+
+// DeleteTag implements TagStore.
+func (s *Store) DeleteTag(id int) error {
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		if err := refreshTaskTags(tx, id, true); err != nil {
+			return err
+		}
+		result := tx.Delete(&Tag{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return NewAPIError(404, "Tag not found")
+		}
+		return tx.Where("tag_id = ?", id).Delete(&TaskTag{}).Error
+	})
+	return txError("deleteTag", err)
+}
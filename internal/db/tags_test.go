@@ -0,0 +1,146 @@
+package db
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTitleTags(t *testing.T) {
+	tests := []struct {
+		title, want string
+		tags        TagList
+	}{
+		{"Call Bob #work", "Call Bob", TagList{"work"}},
+		{"#Home buy milk #errands #home", "buy milk", TagList{"errands", "home"}},
+		{"Fix bug #1 #dev/api", "Fix bug #1", TagList{"dev/api"}},
+		{"#work", "#work", TagList{"work"}},
+		{"No tags", "No tags", TagList{}},
+	}
+	for _, tt := range tests {
+		title, tags := ParseTitleTags(tt.title)
+		if title != tt.want || !slices.Equal(tags, tt.tags) {
+			t.Errorf("ParseTitleTags(%q) = %q, %v, want %q, %v", tt.title, title, tags, tt.want, tt.tags)
+		}
+	}
+	for _, name := range []string{"", "#", "123", "two words", "a,b"} {
+		if _, err := NormalizeTagName(name); err == nil {
+			t.Errorf("NormalizeTagName(%q) accepted an invalid name", name)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	today := mustDate(t, "2026-10-18")
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		plumber := mustCreate(t, store, Task{Title: "Call plumber", Tags: TagList{"Home", "#urgent"}})
+		report := mustCreate(t, store, Task{Title: "Write report", Tags: TagList{"work"}})
+		mustCreate(t, store, Task{Title: "Call bank"})
+		if !slices.Equal(plumber.Tags, TagList{"home", "urgent"}) {
+			t.Fatalf("tags = %v, want the normalized names", plumber.Tags)
+		}
+
+		filtered := func(tags ...string) []string {
+			t.Helper()
+			tasks, err := store.GetTasks(TaskFilter{Tags: tags})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return titles(tasks)
+		}
+		search := func(query string) []string {
+			t.Helper()
+			tasks, err := store.SearchTasks(query, today, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return titles(tasks)
+		}
+		if got := filtered("home"); !slices.Equal(got, []string{"Call plumber"}) {
+			t.Errorf("tasks tagged home = %v", got)
+		}
+		if got := filtered("home", "work"); len(got) != 0 {
+			t.Errorf("tasks tagged home and work = %v, want none", got)
+		}
+		if _, err := store.GetTasks(TaskFilter{Tags: []string{"no tag"}}); err == nil {
+			t.Error("GetTasks accepted an invalid tag")
+		}
+		if got := search("urgent"); !slices.Equal(got, []string{"Call plumber"}) {
+			t.Errorf("search for a tag name = %v", got)
+		}
+		if got := search("call tag:home"); !slices.Equal(got, []string{"Call plumber"}) {
+			t.Errorf("search with tag:home = %v", got)
+		}
+		if got := search("tag:work"); !slices.Equal(got, []string{"Write report"}) {
+			t.Errorf("search for tag:work only = %v", got)
+		}
+
+		if err := store.UpdateTask(report.ID, map[string]interface{}{"tags": []interface{}{"work", "home"}}, ""); err != nil {
+			t.Fatal(err)
+		}
+		if got := filtered("home"); !slices.Equal(got, []string{"Call plumber", "Write report"}) {
+			t.Errorf("tasks tagged home after tagging the report = %v", got)
+		}
+
+		tags, err := store.GetTags()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[string]int{}
+		for _, tag := range tags {
+			counts[tag.Name] = tag.TaskCount
+		}
+		if len(tags) != 3 || counts["home"] != 2 || counts["urgent"] != 1 || counts["work"] != 1 {
+			t.Fatalf("tags = %+v", tags)
+		}
+		if _, err := store.CreateTag(Tag{Name: "Work"}); err == nil {
+			t.Error("created a second tag named work")
+		}
+
+		// Renaming and deleting tags change their tasks.
+		home := tags[slices.IndexFunc(tags, func(tag Tag) bool { return tag.Name == "home" })]
+		home.Name = "house"
+		if _, err := store.UpdateTag(home); err != nil {
+			t.Fatal(err)
+		}
+		if got := search("house"); !slices.Equal(got, []string{"Call plumber", "Write report"}) {
+			t.Errorf("search for the renamed tag = %v", got)
+		}
+		if err := store.DeleteTag(home.ID); err != nil {
+			t.Fatal(err)
+		}
+		task, err := store.GetTask(report.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(task.Tags, TagList{"work"}) {
+			t.Errorf("tags after deleting house = %v, want [work]", task.Tags)
+		}
+		if got := search("house"); len(got) != 0 {
+			t.Errorf("search for the deleted tag = %v, want none", got)
+		}
+	})
+}
+
+func TestRecurringTaskKeepsTags(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		task := mustCreate(t, store, Task{
+			Title:          "Water plants",
+			DueDate:        NullTime{Time: mustDate(t, "2026-10-18"), Valid: true},
+			RecurrenceRule: "weekly",
+			Tags:           TagList{"home"},
+		})
+		if err := store.UpdateTask(task.ID, map[string]interface{}{"completed": true}, ""); err != nil {
+			t.Fatal(err)
+		}
+		tasks, err := store.GetTasks(TaskFilter{Date: "2026-10-25", Tags: []string{"home"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 1 || !slices.Equal(tasks[0].Tags, TagList{"home"}) {
+			t.Errorf("next occurrence = %+v, want it tagged home", tasks)
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The events a webhook can subscribe to.
+const (
+	WebhookTaskCreated   = "task.created"
+	WebhookTaskCompleted = "task.completed"
+	WebhookTaskMoved     = "task.moved" // To another day, or to or from the inbox.
+	WebhookTaskDeleted   = "task.deleted"
+)
+
+// WebhookEvents are all the events webhooks can subscribe to.
+var WebhookEvents = []string{WebhookTaskCreated, WebhookTaskCompleted, WebhookTaskMoved, WebhookTaskDeleted}
+
+// Statuses of a webhook delivery.
+const (
+	DeliveryPending   = "pending" // Queued, or to be retried at NextAttemptAt.
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed" // Given up after too many attempts.
+)
+
+// Webhook is a URL that receives the events it subscribes to as signed JSON
+// POST requests.
+type Webhook struct {
+	ID  int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	URL string `gorm:"not null" json:"url"`
+	// Secret is the HMAC-SHA256 key of the signatures. It is only shown when
+	// the webhook is created.
+	Secret    string    `gorm:"not null" json:"secret,omitempty"`
+	Events    []string  `gorm:"serializer:json" json:"events"` // Empty for all events.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscribes reports whether the webhook receives event.
+func (w Webhook) Subscribes(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// Validate checks the URL and events of a webhook and normalizes them,
+// generating a secret if it has none.
+func (w *Webhook) Validate() error {
+	w.URL = strings.TrimSpace(w.URL)
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return NewAPIError(400, fmt.Sprintf("Invalid webhook url: %q (expected an http or https URL)", w.URL))
+	}
+	events := []string{}
+	for _, event := range w.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			return NewAPIError(400, fmt.Sprintf("Invalid webhook event: %q (expected one of %s)", event, strings.Join(WebhookEvents, ", ")))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	w.Events = events
+	if w.Secret == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return fmt.Errorf("generating webhook secret: %w", err)
+		}
+		w.Secret = hex.EncodeToString(key)
+	}
+	return nil
+}
+
+// WebhookDelivery is one event queued for, or sent to, a webhook. It is the
+// delivery log as well as the queue.
+type WebhookDelivery struct {
+	ID            int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	WebhookID     int             `gorm:"not null" json:"webhook_id"`
+	Event         string          `gorm:"not null" json:"event"`
+	Payload       json.RawMessage `gorm:"type:text;not null" json:"payload"` // The request body.
+	Status        string          `gorm:"not null;default:pending" json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastAttemptAt *time.Time      `json:"last_attempt_at"`
+	// ResponseStatus is the HTTP status of the last attempt, 0 if it got no
+	// response.
+	ResponseStatus int        `json:"response_status"`
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+// WebhookStore keeps the webhooks and the queue of their deliveries. *Store
+// and MemoryStore implement it.
+type WebhookStore interface {
+	GetWebhooks() ([]Webhook, error)
+	GetWebhook(id int) (Webhook, error) // 404 APIError if there is no such webhook.
+	// CreateWebhook validates and adds a webhook; see Webhook.Validate.
+	CreateWebhook(webhook Webhook) (Webhook, error)
+	// DeleteWebhook deletes a webhook with its deliveries.
+	DeleteWebhook(id int) error
+	// EnqueueWebhookDeliveries queues payload for every webhook subscribed
+	// to event, due at now, and returns how many were queued.
+	EnqueueWebhookDeliveries(event string, payload []byte, now time.Time) (int, error)
+	// DueWebhookDeliveries returns up to limit pending deliveries due at
+	// now, oldest first.
+	DueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error)
+	// SaveWebhookDelivery records the outcome of an attempt at a delivery.
+	SaveWebhookDelivery(delivery WebhookDelivery) error
+	// GetWebhookDeliveries returns up to limit deliveries of a webhook,
+	// newest first.
+	GetWebhookDeliveries(webhookID int, limit int) ([]WebhookDelivery, error)
+}
+
+var (
+	_ WebhookStore = (*Store)(nil)
+	_ WebhookStore = (*MemoryStore)(nil)
+)
+
+// GetWebhooks implements WebhookStore.
+func (s *Store) GetWebhooks() ([]Webhook, error) {
+	webhooks := []Webhook{}
+	if err := s.DB().Order("id").Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("getWebhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// GetWebhook implements WebhookStore.
+func (s *Store) GetWebhook(id int) (Webhook, error) {
+	var webhook Webhook
+	err := s.DB().First(&webhook, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Webhook{}, NewAPIError(404, "Webhook not found")
+	}
+	if err != nil {
+		return Webhook{}, fmt.Errorf("getWebhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// CreateWebhook implements WebhookStore.
+func (s *Store) CreateWebhook(webhook Webhook) (Webhook, error) {
+	if err := webhook.Validate(); err != nil {
+		return Webhook{}, err
+	}
+	webhook.ID = 0
+	if err := s.DB().Create(&webhook).Error; err != nil {
+		return Webhook{}, fmt.Errorf("createWebhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook implements WebhookStore.
+func (s *Store) DeleteWebhook(id int) error {
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&Webhook{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return NewAPIError(404, "Webhook not found")
+		}
+		return tx.Where("webhook_id = ?", id).Delete(&WebhookDelivery{}).Error
+	})
+	return txError("deleteWebhook", err)
+}
+
+// EnqueueWebhookDeliveries implements WebhookStore.
+func (s *Store) EnqueueWebhookDeliveries(event string, payload []byte, now time.Time) (int, error) {
+	queued := 0
+	err := s.DB().Transaction(func(tx *gorm.DB) error {
+		var webhooks []Webhook
+		if err := tx.Find(&webhooks).Error; err != nil {
+			return err
+		}
+		for _, webhook := range webhooks {
+			if !webhook.Subscribes(event) {
+				continue
+			}
+			delivery := newDelivery(webhook.ID, event, payload, now)
+			if err := tx.Create(&delivery).Error; err != nil {
+				return err
+			}
+			queued++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("enqueueWebhookDeliveries: %w", err)
+	}
+	return queued, nil
+}
+
+// newDelivery returns a pending delivery of payload to a webhook, due at now.
+func newDelivery(webhookID int, event string, payload []byte, now time.Time) WebhookDelivery {
+	return WebhookDelivery{
+		WebhookID:     webhookID,
+		Event:         event,
+		Payload:       json.RawMessage(payload),
+		Status:        DeliveryPending,
+		NextAttemptAt: now.UTC(),
+		CreatedAt:     now.UTC(),
+	}
+}
+
+// DueWebhookDeliveries implements WebhookStore.
+func (s *Store) DueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	err := s.DB().Where("status = ? AND next_attempt_at <= ?", DeliveryPending, now.UTC()).
+		Order("next_attempt_at, id").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, fmt.Errorf("dueWebhookDeliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// SaveWebhookDelivery implements WebhookStore.
+func (s *Store) SaveWebhookDelivery(delivery WebhookDelivery) error {
+	if err := s.DB().Save(&delivery).Error; err != nil {
+		return fmt.Errorf("saveWebhookDelivery: %w", err)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries implements WebhookStore.
+func (s *Store) GetWebhookDeliveries(webhookID int, limit int) ([]WebhookDelivery, error) {
+	deliveries := []WebhookDelivery{}
+	err := s.DB().Where("webhook_id = ?", webhookID).Order("id DESC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, fmt.Errorf("getWebhookDeliveries: %w", err)
+	}
+	return deliveries, nil
+}
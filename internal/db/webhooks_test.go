@@ -0,0 +1,76 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebhookQueue(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	forEachStore(t, now, func(t *testing.T, store testStore) {
+		if _, err := store.CreateWebhook(Webhook{URL: "ftp://example.com"}); err == nil {
+			t.Error("created a webhook with an ftp URL")
+		}
+		if _, err := store.CreateWebhook(Webhook{URL: "http://example.com", Events: []string{"task.renamed"}}); err == nil {
+			t.Error("created a webhook for an unknown event")
+		}
+		all, err := store.CreateWebhook(Webhook{URL: "http://localhost:8080/all"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		done, err := store.CreateWebhook(Webhook{URL: "http://localhost:8080/done", Events: []string{WebhookTaskCompleted, WebhookTaskCompleted}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if all.Secret == "" || len(done.Events) != 1 {
+			t.Fatalf("created %+v and %+v, want a secret and the events once", all, done)
+		}
+		if got, err := store.GetWebhook(done.ID); err != nil || len(got.Events) != 1 || got.Events[0] != WebhookTaskCompleted {
+			t.Fatalf("GetWebhook = %+v, %v, want the completion webhook", got, err)
+		}
+
+		for _, event := range []string{WebhookTaskCreated, WebhookTaskCompleted} {
+			if _, err := store.EnqueueWebhookDeliveries(event, []byte(`{"event":"`+event+`"}`), now); err != nil {
+				t.Fatal(err)
+			}
+		}
+		due, err := store.DueWebhookDeliveries(now, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(due) != 3 {
+			t.Fatalf("got %d deliveries due, want 2 for the first webhook and 1 for the second", len(due))
+		}
+
+		// A delivery to retry later is no longer due.
+		retry := due[0]
+		retry.Attempts, retry.NextAttemptAt = 1, now.Add(time.Minute)
+		if err := store.SaveWebhookDelivery(retry); err != nil {
+			t.Fatal(err)
+		}
+		if due, _ := store.DueWebhookDeliveries(now, 10); len(due) != 2 {
+			t.Errorf("got %d deliveries due after postponing one, want 2", len(due))
+		}
+		if due, _ := store.DueWebhookDeliveries(now.Add(time.Minute), 10); len(due) != 3 {
+			t.Errorf("got %d deliveries due after the retry delay, want 3", len(due))
+		}
+
+		log, err := store.GetWebhookDeliveries(all.ID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(log) != 2 || log[0].Event != WebhookTaskCompleted || string(log[1].Payload) != `{"event":"task.created"}` {
+			t.Errorf("log %+v, want the completion then the creation", log)
+		}
+
+		if err := store.DeleteWebhook(all.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.DeleteWebhook(all.ID); err == nil {
+			t.Error("deleted a webhook twice")
+		}
+		if due, _ := store.DueWebhookDeliveries(now.Add(time.Minute), 10); len(due) != 1 {
+			t.Errorf("got %d deliveries due after deleting a webhook, want its own gone", len(due))
+		}
+	})
+}
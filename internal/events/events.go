@@ -0,0 +1,136 @@
+// Package events is the server's in-process event bus. The API publishes
+// every change it makes to tasks and settings; the /api/events stream,
+// reminders and webhooks subscribe to it.
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	TaskCreated     = "task.created"     // Data: {"task"}, with "generated" for occurrences created by recurrence.
+	TaskUpdated     = "task.updated"     // Data: {"task", "previous", "scope"}.
+	TaskDeleted     = "task.deleted"     // Data: {"task", "scope"}, the task as it was.
+	TasksReordered  = "tasks.reordered"  // Data: {"tasks": [{"id", "order"}]}, with "parent_id" for subtasks.
+	TasksReloaded   = "tasks.reloaded"   // Data: {"reason"}: many tasks changed at once, e.g. by an import.
+	SettingsChanged = "settings.changed" // Data: the changed settings by key.
+	ReminderFired   = "reminder.fired"   // Data: the db.ReminderDelivery.
+	TagsChanged     = "tags.changed"     // Data: {"tag"}, or {"deleted": id}; renamed or deleted tags change their tasks.
+	ListsChanged    = "lists.changed"    // Data: {"list"}, or {"deleted": id}; the tasks of a deleted list move to the default one.
+)
+
+// Event is something that happened in the planner.
+type Event struct {
+	ID   uint64    `json:"id"` // Increasing within a run of the server.
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// Source is the client that caused the event, from its X-Client-ID
+	// header, so that it can ignore its own changes. Empty for the server.
+	Source string `json:"source,omitempty"`
+	Data   any    `json:"data"`
+}
+
+// historySize is how many of the latest events a bus keeps for dropped
+// subscribers to catch up with.
+const historySize = 1024
+
+// Bus delivers published events to its subscribers. The zero value is not
+// usable; create one with NewBus.
+type Bus struct {
+	mu     sync.Mutex
+	lastID uint64
+	subs   map[*Subscription]struct{}
+	// history holds the latest events, oldest first, up to historySize.
+	history []Event
+}
+
+// NewBus returns a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscription receives the events published after it was created.
+type Subscription struct {
+	bus  *Bus
+	c    chan Event
+	last uint64 // ID of the last event sent to c, or published before it.
+}
+
+// C returns the channel of the subscription's events. It is closed when the
+// subscription is closed, or dropped for falling behind.
+func (s *Subscription) C() <-chan Event {
+	return s.c
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
+
+// Resume replaces a dropped subscription with a new one buffering as many
+// events. It also returns the events published in between, oldest first;
+// complete is false if the bus no longer keeps all of them.
+func (s *Subscription) Resume() (next *Subscription, missed []Event, complete bool) {
+	b := s.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	complete = s.last+uint64(len(b.history)) >= b.lastID
+	for _, e := range b.history {
+		if e.ID > s.last {
+			missed = append(missed, e)
+		}
+	}
+	return b.subscribe(cap(s.c)), missed, complete
+}
+
+// Subscribe returns a subscription buffering up to buffer events. A
+// subscriber that lets its buffer fill up is dropped rather than holding up
+// the publishers: its channel is closed, and it has to catch up some other
+// way, e.g. by reloading or with Resume.
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribe(buffer)
+}
+
+// subscribe adds a subscription. Must be called with mu held.
+func (b *Bus) subscribe(buffer int) *Subscription {
+	s := &Subscription{bus: b, c: make(chan Event, buffer), last: b.lastID}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Publish sends an event to the subscribers and returns it. It never blocks.
+func (b *Bus) Publish(typ, source string, data any) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	e := Event{ID: b.lastID, Type: typ, Time: time.Now().UTC(), Source: source, Data: data}
+	if len(b.history) == historySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, e)
+	for s := range b.subs {
+		select {
+		case s.c <- e:
+			s.last = e.ID
+		default:
+			slog.Warn("Dropping event subscriber that fell behind", "event", e.ID)
+			b.remove(s)
+		}
+	}
+	return e
+}
+
+// remove ends subscription s. Must be called with mu held.
+func (b *Bus) remove(s *Subscription) {
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
@@ -0,0 +1,74 @@
+package events
+
+import "testing"
+
+func TestPublishReachesSubscribers(t *testing.T) {
+	bus := NewBus()
+	a, b := bus.Subscribe(4), bus.Subscribe(4)
+	defer a.Close()
+
+	bus.Publish(TaskCreated, "tab-1", map[string]int{"id": 1})
+	b.Close()
+	bus.Publish(TaskDeleted, "", map[string]int{"id": 1})
+
+	first, second := <-a.C(), <-a.C()
+	if first.Type != TaskCreated || first.Source != "tab-1" || second.Type != TaskDeleted || second.ID <= first.ID {
+		t.Errorf("got %+v then %+v, want task.created then task.deleted", first, second)
+	}
+	if e, ok := <-b.C(); !ok {
+		if e.ID != 0 {
+			t.Errorf("closed subscription received %+v", e)
+		}
+	} else if e.Type != TaskCreated {
+		t.Errorf("closed subscription received %+v after the event before closing", e)
+	} else if _, ok := <-b.C(); ok {
+		t.Error("closed subscription received an event published after closing")
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	bus := NewBus()
+	slow := bus.Subscribe(1)
+	bus.Publish(TaskCreated, "", nil)
+	bus.Publish(TaskCreated, "", nil) // Does not block.
+
+	if _, ok := <-slow.C(); !ok {
+		t.Fatal("buffered event lost")
+	}
+	if _, ok := <-slow.C(); ok {
+		t.Error("subscriber was not dropped after falling behind")
+	}
+	slow.Close() // Closing a dropped subscription is fine.
+}
+
+func TestResumeCatchesUp(t *testing.T) {
+	bus := NewBus()
+	slow := bus.Subscribe(1)
+	for range 3 {
+		bus.Publish(TaskCreated, "", nil)
+	}
+	for range slow.C() {
+	}
+
+	next, missed, complete := slow.Resume()
+	defer next.Close()
+	if !complete || len(missed) != 2 || missed[0].ID != 2 || missed[1].ID != 3 {
+		t.Errorf("missed %+v (complete %v), want events 2 and 3", missed, complete)
+	}
+	bus.Publish(TaskDeleted, "", nil)
+	if e := <-next.C(); e.ID != 4 {
+		t.Errorf("resumed subscription received %+v, want event 4", e)
+	}
+
+	// Events the bus no longer keeps are reported missing.
+	for range historySize + 2 {
+		bus.Publish(TaskCreated, "", nil)
+	}
+	for range next.C() {
+	}
+	last, missed, complete := next.Resume()
+	defer last.Close()
+	if complete || len(missed) != historySize || missed[len(missed)-1].ID != historySize+6 {
+		t.Errorf("missed %d events up to %d (complete %v), want the %d kept", len(missed), missed[len(missed)-1].ID, complete, historySize)
+	}
+}
@@ -0,0 +1,372 @@
+// Package ical reads and writes the subset of iCalendar (RFC 5545) the
+// planner needs: all-day VTODO and VEVENT components.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	ComponentTodo  = "VTODO"
+	ComponentEvent = "VEVENT"
+)
+
+// dateFormat is the iCalendar DATE value format.
+const dateFormat = "20060102"
+
+// dateTimeFormat is the iCalendar UTC DATE-TIME value format.
+const dateTimeFormat = "20060102T150405Z"
+
+// maxLineOctets is the line length limit before folding (RFC 5545 3.1).
+const maxLineOctets = 75
+
+// Item is a single all-day calendar entry.
+type Item struct {
+	Component    string // ComponentTodo or ComponentEvent.
+	UID          string
+	Summary      string
+	Description  string
+	Date         time.Time // Zero for undated to-dos.
+	Completed    bool
+	RRule        string      // Value of the RRULE property without the name, e.g. "FREQ=WEEKLY;INTERVAL=2".
+	ExDates      []time.Time // Dates the RRULE gives that are left out (EXDATE).
+	RecurrenceID time.Time   // On an override: the date of the occurrence of the recurring item with the same UID it replaces.
+	Color        string      // CSS color name (RFC 7986 COLOR).
+}
+
+// Calendar is a VCALENDAR object.
+type Calendar struct {
+	ProdID string
+	Name   string // X-WR-CALNAME, shown by clients as the subscription name.
+	Items  []Item
+}
+
+// Encode writes cal to w in iCalendar format.
+func Encode(w io.Writer, cal Calendar) error {
+	enc := &encoder{w: bufio.NewWriter(w)}
+	stamp := time.Now().UTC().Format(dateTimeFormat)
+
+	enc.line("BEGIN", "VCALENDAR")
+	enc.line("VERSION", "2.0")
+	enc.line("PRODID", cal.ProdID)
+	enc.line("CALSCALE", "GREGORIAN")
+	if cal.Name != "" {
+		enc.line("X-WR-CALNAME", escapeText(cal.Name))
+	}
+	for _, item := range cal.Items {
+		component := item.Component
+		if component == "" {
+			component = ComponentTodo
+		}
+		enc.line("BEGIN", component)
+		enc.line("UID", item.UID)
+		enc.line("DTSTAMP", stamp)
+		enc.line("SUMMARY", escapeText(item.Summary))
+		if item.Description != "" {
+			enc.line("DESCRIPTION", escapeText(item.Description))
+		}
+		if !item.Date.IsZero() {
+			// All-day entries end (exclusively) on the following day.
+			enc.line("DTSTART;VALUE=DATE", item.Date.Format(dateFormat))
+			end := item.Date.AddDate(0, 0, 1).Format(dateFormat)
+			if component == ComponentEvent {
+				enc.line("DTEND;VALUE=DATE", end)
+				enc.line("TRANSP", "TRANSPARENT")
+			} else {
+				enc.line("DUE;VALUE=DATE", end)
+			}
+		}
+		if item.RRule != "" {
+			enc.line("RRULE", item.RRule)
+		}
+		if len(item.ExDates) > 0 {
+			dates := make([]string, len(item.ExDates))
+			for i, date := range item.ExDates {
+				dates[i] = date.Format(dateFormat)
+			}
+			enc.line("EXDATE;VALUE=DATE", strings.Join(dates, ","))
+		}
+		if !item.RecurrenceID.IsZero() {
+			enc.line("RECURRENCE-ID;VALUE=DATE", item.RecurrenceID.Format(dateFormat))
+		}
+		if component == ComponentTodo {
+			if item.Completed {
+				enc.line("STATUS", "COMPLETED")
+				enc.line("PERCENT-COMPLETE", "100")
+			} else {
+				enc.line("STATUS", "NEEDS-ACTION")
+			}
+		}
+		if item.Color != "" {
+			enc.line("COLOR", item.Color)
+		}
+		enc.line("END", component)
+	}
+	enc.line("END", "VCALENDAR")
+
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+// encoder writes folded content lines and remembers the first error.
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.WriteString(fold(name + ":" + value))
+}
+
+// fold splits a content line into CRLF-terminated chunks of at most 75 octets,
+// continuation lines starting with a single space. UTF-8 sequences are never split.
+func fold(line string) string {
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1 // The leading space counts towards the limit.
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+// escapeText escapes a TEXT value (RFC 5545 3.3.11).
+func escapeText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
+
+// Decode parses VTODO and VEVENT components from an iCalendar stream.
+// Properties of nested components (e.g. VALARM) are ignored. UTC date-times
+// fall on their date in loc, the planner's time zone (nil for local time).
+func Decode(r io.Reader, loc *time.Location) ([]Item, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	var stack []string
+	var current *Item
+	for n, raw := range lines {
+		if raw == "" {
+			continue
+		}
+		name, _, value, err := parseContentLine(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		switch name {
+		case "BEGIN":
+			component := strings.ToUpper(value)
+			stack = append(stack, component)
+			if len(stack) == 2 && (component == ComponentTodo || component == ComponentEvent) {
+				current = &Item{Component: component}
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", n+1, value)
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 1 && current != nil {
+				items = append(items, *current)
+				current = nil
+			}
+			continue
+		}
+
+		// Only direct properties of a VTODO/VEVENT are of interest.
+		if current == nil || len(stack) != 2 {
+			continue
+		}
+		switch name {
+		case "UID":
+			current.UID = value
+		case "SUMMARY":
+			current.Summary = unescapeText(value)
+		case "DESCRIPTION":
+			current.Description = unescapeText(value)
+		case "DTSTART":
+			date, err := parseDate(value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: DTSTART: %w", n+1, err)
+			}
+			current.Date = date
+		case "DUE":
+			// DTSTART wins when both are present.
+			if current.Date.IsZero() {
+				date, err := parseDate(value, loc)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: DUE: %w", n+1, err)
+				}
+				current.Date = date
+			}
+		case "RRULE":
+			current.RRule = value
+		case "RECURRENCE-ID":
+			date, err := parseDate(value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: RECURRENCE-ID: %w", n+1, err)
+			}
+			current.RecurrenceID = date
+		case "STATUS":
+			if strings.EqualFold(value, "COMPLETED") {
+				current.Completed = true
+			}
+		case "COMPLETED":
+			current.Completed = true
+		case "COLOR":
+			current.Color = strings.ToLower(value)
+		}
+	}
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("unterminated component %s", stack[len(stack)-1])
+	}
+	return items, nil
+}
+
+// unfold reads content lines, joining folded continuation lines.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading calendar: %w", err)
+	}
+	return lines, nil
+}
+
+// parseContentLine splits "NAME;PARAM=x:value" into its parts. Parameter
+// values may be quoted and contain ':' or ';'.
+func parseContentLine(line string) (name string, params map[string]string, value string, err error) {
+	inQuotes := false
+	colon := -1
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ':':
+			if !inQuotes {
+				colon = i
+			}
+		}
+		if colon >= 0 {
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", fmt.Errorf("malformed content line %q", line)
+	}
+
+	head := line[:colon]
+	value = line[colon+1:]
+	params = map[string]string{}
+	parts := splitUnquoted(head, ';')
+	name = strings.ToUpper(parts[0])
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return name, params, value, nil
+}
+
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case sep:
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseDate reads a DATE or DATE-TIME value and returns its calendar date at
+// midnight UTC. UTC date-times are converted to the date in loc first.
+func parseDate(value string, loc *time.Location) (time.Time, error) {
+	if len(value) < len(dateFormat) {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeFormat, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date-time %q", value)
+		}
+		if loc == nil {
+			loc = time.Local
+		}
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+	// DATE, floating DATE-TIME or DATE-TIME with TZID: the date part is what we need.
+	t, err := time.Parse(dateFormat, value[:len(dateFormat)])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t, nil
+}
+
+// unescapeText reverses escapeText.
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,193 @@
+package ical
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Buy milk"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"long ASCII", "DESCRIPTION:" + strings.Repeat("abcdefghij", 30)},
+		{"multi-byte", "SUMMARY:" + strings.Repeat("Купить молоко, ", 12)},
+		{"emoji", "SUMMARY:" + strings.Repeat("📦", 60)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := fold(tt.line)
+			if !strings.HasSuffix(folded, "\r\n") {
+				t.Fatalf("fold(%q) = %q, want a CRLF at the end", tt.line, folded)
+			}
+			lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+			for i, line := range lines {
+				if len(line) > maxLineOctets {
+					t.Errorf("line %d is %d octets, want at most %d", i, len(line), maxLineOctets)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d = %q, want a leading space", i, line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d = %q splits a UTF-8 sequence", i, line)
+				}
+			}
+			if len(tt.line) <= maxLineOctets && len(lines) != 1 {
+				t.Errorf("fold(%q) folded a line of %d octets", tt.line, len(tt.line))
+			}
+			// Unfolding gives the line back.
+			unfolded, err := unfold(strings.NewReader(folded))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(unfolded) != 1 || unfolded[0] != tt.line {
+				t.Errorf("unfold(fold(%q)) = %q", tt.line, unfolded)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"Buy milk", "Buy milk"},
+		{"Milk, eggs; bread", `Milk\, eggs\; bread`},
+		{`C:\Users`, `C:\\Users`},
+		{"first\nsecond\r\nthird", `first\nsecond\nthird`},
+	}
+	for _, tt := range tests {
+		if got := escapeText(tt.text); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if got := unescapeText(escapeText(tt.text)); got != strings.ReplaceAll(tt.text, "\r\n", "\n") {
+			t.Errorf("unescapeText(escapeText(%q)) = %q", tt.text, got)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	date := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err := Encode(&buf, Calendar{
+		ProdID: ProdID,
+		Name:   "Week, planner",
+		Items: []Item{
+			{UID: "a@week-planner", Summary: "Gym; legs", Date: date, RRule: "FREQ=WEEKLY;BYDAY=MO,WE,FR", Color: "green"},
+			{UID: "b@week-planner", Summary: "Report", Description: "Q3\nnumbers", Date: date, Completed: true},
+			{Component: ComponentEvent, UID: "c@week-planner", Summary: "Trip", Date: date},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n") || !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Errorf("calendar not wrapped in VCALENDAR:\n%s", out)
+	}
+	components := strings.Split(out, "BEGIN:V")[2:]
+	if len(components) != 3 {
+		t.Fatalf("got %d components, want 3:\n%s", len(components), out)
+	}
+	for i, want := range [][]string{
+		{
+			"TODO\r\n", "UID:a@week-planner\r\n", `SUMMARY:Gym\; legs`,
+			"DTSTART;VALUE=DATE:20261019\r\n", "DUE;VALUE=DATE:20261020\r\n",
+			"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR\r\n", "STATUS:NEEDS-ACTION\r\n", "COLOR:green\r\n", "END:VTODO\r\n",
+		},
+		{
+			"TODO\r\n", `DESCRIPTION:Q3\nnumbers`, "STATUS:COMPLETED\r\n", "PERCENT-COMPLETE:100\r\n",
+		},
+		{
+			"EVENT\r\n", "DTSTART;VALUE=DATE:20261019\r\n", "DTEND;VALUE=DATE:20261020\r\n",
+			"TRANSP:TRANSPARENT\r\n", "END:VEVENT\r\n",
+		},
+	} {
+		for _, line := range want {
+			if !strings.Contains(components[i], line) {
+				t.Errorf("component %d lacks %q:\n%s", i, line, components[i])
+			}
+		}
+	}
+	if strings.Contains(components[1], "RRULE") || strings.Contains(components[2], "STATUS") {
+		t.Errorf("unexpected RRULE or STATUS:\n%s", out)
+	}
+	if !strings.Contains(out, `X-WR-CALNAME:Week\, planner`) {
+		t.Errorf("calendar name not escaped:\n%s", out)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	const input = "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:todo-1@example.com\r\n" +
+		"SUMMARY:Call the plumber\\, again\r\n" +
+		"DESCRIPTION:Kitchen sink\\nand the\r\n" +
+		"  bathroom\r\n" +
+		"DUE;VALUE=DATE:20261020\r\n" +
+		"RRULE:FREQ=WEEKLY;INTERVAL=2\r\n" +
+		"STATUS:COMPLETED\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"ACTION:DISPLAY\r\n" +
+		"SUMMARY:Alarm\r\n" +
+		"END:VALARM\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:event-1@example.com\r\n" +
+		"SUMMARY;LANGUAGE=en:Flight\r\n" +
+		"DTSTART:20261018T230000Z\r\n" +
+		"COLOR:Blue\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Conference\r\n" +
+		"DTSTART;TZID=\"Europe/Berlin\":20261021T090000\r\n" +
+		"DUE;VALUE=DATE:20261023\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	tokyo := time.FixedZone("JST", 9*60*60)
+	items, err := Decode(strings.NewReader(input), tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Item{
+		{
+			Component:   ComponentTodo,
+			UID:         "todo-1@example.com",
+			Summary:     "Call the plumber, again",
+			Description: "Kitchen sink\nand the bathroom",
+			Date:        time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC),
+			Completed:   true,
+			RRule:       "FREQ=WEEKLY;INTERVAL=2",
+		},
+		// 23:00 UTC is the next morning in Tokyo.
+		{Component: ComponentEvent, UID: "event-1@example.com", Summary: "Flight", Date: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), Color: "blue"},
+		{Component: ComponentEvent, Summary: "Conference", Date: time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC)},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("Decode = %+v, want %d items", items, len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(items[i], want[i]) {
+			t.Errorf("item %d = %+v, want %+v", i, items[i], want[i])
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, input := range []string{
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:x\r\nEND:VTODO\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nno colon\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nDTSTART:2026\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20261018T250000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if items, err := Decode(strings.NewReader(input), time.UTC); err == nil {
+			t.Errorf("Decode(%q) = %+v, want an error", input, items)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package ical
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+	"week-planner/internal/rrule"
+)
+
+// ProdID identifies the planner as the producer of exported calendars.
+const ProdID = "-//week-planner//week-planner//EN"
+
+// TaskUID returns the iCalendar UID of a task. It is made from the stable
+// UID of the task, which unlike its ID survives a replace import.
+func TaskUID(task db.Task) string {
+	if task.UID == "" {
+		return fmt.Sprintf("task-%d@week-planner", task.ID)
+	}
+	return task.UID + "@week-planner"
+}
+
+// SeriesUID returns the iCalendar UID of a recurring task series.
+func SeriesUID(series db.Series) string {
+	return series.UID + "@week-planner"
+}
+
+// FromTasks converts the dated tasks of store to calendar items of the given
+// component type. A recurring task is a single item for its whole series,
+// with the rule from the start of the series, EXDATEs for the skipped
+// occurrences and an override for each occurrence that was moved, changed
+// or completed. Tasks without a due date are skipped.
+func FromTasks(store db.TaskStore, component string) ([]Item, error) {
+	tasks, err := store.GetTasks(db.TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(tasks))
+	exported := map[int]bool{}
+	for _, task := range tasks {
+		if task.SeriesID == nil {
+			if task.DueDate.Valid {
+				items = append(items, taskItem(task, component))
+			}
+			continue
+		}
+		if exported[*task.SeriesID] {
+			continue
+		}
+		exported[*task.SeriesID] = true
+		series, err := store.GetSeries(*task.SeriesID)
+		if err != nil {
+			return nil, fmt.Errorf("series %d: %w", *task.SeriesID, err)
+		}
+		items = append(items, seriesItems(series, component)...)
+	}
+	return items, nil
+}
+
+// taskItem converts a single dated task, without recurrence.
+func taskItem(task db.Task, component string) Item {
+	return Item{
+		Component:   component,
+		UID:         TaskUID(task),
+		Summary:     task.Title,
+		Description: task.Description,
+		Date:        task.DueDate.Time,
+		Completed:   task.Completed == 1,
+		Color:       itemColor(task.Color),
+	}
+}
+
+// seriesItems converts a recurring task series: the item with its rule,
+// followed by the overrides of its occurrences, by date.
+func seriesItems(series db.Series, component string) []Item {
+	rule, err := db.ParseRecurrence(series.RecurrenceRule, series.RecurrenceInterval, series.StartDate)
+	if err != nil {
+		// Export the occurrences one by one rather than dropping the series.
+		slog.Warn("Exporting occurrences of a series with an unsupported recurrence rule one by one", "series_id", series.ID, "error", err)
+		items := make([]Item, 0, len(series.Occurrences))
+		for _, o := range series.Occurrences {
+			items = append(items, taskItem(o, component))
+		}
+		return items
+	}
+	recurring := Item{
+		Component:   component,
+		UID:         SeriesUID(series),
+		Summary:     series.Title,
+		Description: series.Description,
+		Date:        series.StartDate,
+		RRule:       rule.String(),
+		Color:       itemColor(series.Color),
+	}
+
+	skipped := map[string]bool{}
+	overrides := map[string]Item{}
+	override := func(date time.Time) Item {
+		key := date.Format(config.DateFormat)
+		if o, ok := overrides[key]; ok {
+			return o
+		}
+		o := recurring
+		o.RRule, o.RecurrenceID, o.Date = "", date, date
+		return o
+	}
+	for _, e := range series.Exceptions {
+		key := e.OccurrenceDate.Format(config.DateFormat)
+		if e.Skip {
+			if !skipped[key] {
+				skipped[key] = true
+				recurring.ExDates = append(recurring.ExDates, e.OccurrenceDate)
+			}
+			continue
+		}
+		o := override(e.OccurrenceDate)
+		if e.Title != "" {
+			o.Summary = e.Title
+		}
+		if e.DueDate.Valid {
+			o.Date = e.DueDate.Time
+		}
+		overrides[key] = o
+	}
+	// Occurrences created so far show their own state, whatever the
+	// exceptions said when they were created.
+	for _, task := range series.Occurrences {
+		date := task.OccurrenceDate.Time
+		if !task.OccurrenceDate.Valid {
+			date = task.DueDate.Time
+		}
+		key := date.Format(config.DateFormat)
+		if skipped[key] {
+			continue
+		}
+		item := taskItem(task, component)
+		if item.Summary == recurring.Summary && item.Description == recurring.Description && item.Color == recurring.Color &&
+			item.Date.Format(config.DateFormat) == key && !item.Completed {
+			delete(overrides, key)
+			continue
+		}
+		item.UID, item.RecurrenceID = recurring.UID, date
+		overrides[key] = item
+	}
+
+	items := []Item{recurring}
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		items = append(items, overrides[key])
+	}
+	return items
+}
+
+// itemColor returns the calendar color of a task color.
+func itemColor(color string) string {
+	if color == "no-color" {
+		return ""
+	}
+	return color
+}
+
+// ImportEntry describes one calendar item in an ImportReport.
+type ImportEntry struct {
+	UID     string `json:"uid,omitempty"`
+	Title   string `json:"title"`
+	DueDate string `json:"due_date"` // Empty for inbox tasks.
+	TaskID  int    `json:"task_id,omitempty"`
+	Note    string `json:"note,omitempty"` // Why an item was skipped or what was dropped from it.
+}
+
+// ImportReport lists what an import created (or would create, on a dry run)
+// and what it skipped.
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Created []ImportEntry `json:"created"`
+	Skipped []ImportEntry `json:"skipped"`
+}
+
+// ImportTasks merges calendar items into the task list. Items matching an
+// existing task by title and due date are skipped, so importing the same
+// file twice does not duplicate tasks, and so are the overrides of single
+// occurrences of recurring items. With dryRun nothing is written.
+func ImportTasks(tasks db.TaskStore, items []Item, dryRun bool) (ImportReport, error) {
+	report := ImportReport{DryRun: dryRun, Created: []ImportEntry{}, Skipped: []ImportEntry{}}
+
+	existing, err := tasks.GetTasks(db.TaskFilter{})
+	if err != nil {
+		return report, fmt.Errorf("importTasks: %w", err)
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, task := range existing {
+		seen[taskKey(task)] = true
+	}
+
+	for _, item := range items {
+		task, note := itemToTask(item)
+		entry := ImportEntry{UID: item.UID, Title: task.Title, Note: note}
+		if task.DueDate.Valid {
+			entry.DueDate = task.DueDate.Time.Format(config.DateFormat)
+		}
+
+		switch {
+		case task.Title == "":
+			entry.Note = "missing SUMMARY"
+			report.Skipped = append(report.Skipped, entry)
+			continue
+		case !item.RecurrenceID.IsZero():
+			entry.Note = "changed occurrence of a recurring item"
+			report.Skipped = append(report.Skipped, entry)
+			continue
+		case item.Component == ComponentEvent && !task.DueDate.Valid:
+			entry.Note = "event without DTSTART"
+			report.Skipped = append(report.Skipped, entry)
+			continue
+		case seen[taskKey(task)]:
+			entry.Note = "task with the same title and date already exists"
+			report.Skipped = append(report.Skipped, entry)
+			continue
+		}
+		seen[taskKey(task)] = true
+
+		if !dryRun {
+			created, err := tasks.CreateTask(task)
+			if err != nil {
+				return report, fmt.Errorf("importTasks: %q: %w", task.Title, err)
+			}
+			entry.TaskID = created.ID
+		}
+		report.Created = append(report.Created, entry)
+	}
+	return report, nil
+}
+
+// itemToTask maps a calendar item onto a task. The returned note mentions
+// anything that could not be represented.
+func itemToTask(item Item) (db.Task, string) {
+	task := db.Task{
+		Title:              strings.TrimSpace(item.Summary),
+		Description:        item.Description,
+		Color:              item.Color,
+		RecurrenceInterval: 1,
+	}
+	if !item.Date.IsZero() {
+		task.DueDate = db.NullTime{Time: item.Date, Valid: true}
+	}
+	if item.Completed {
+		task.Completed = 1
+	}
+
+	var note string
+	if item.RRule != "" {
+		rule, interval, err := recurrenceOf(item.RRule)
+		switch {
+		case err != nil:
+			note = fmt.Sprintf("imported without recurrence: %v", err)
+		case !task.DueDate.Valid:
+			note = "imported without recurrence: no start date"
+		default:
+			task.RecurrenceRule = rule
+			task.RecurrenceInterval = interval
+		}
+	}
+	return task, note
+}
+
+// recurrenceOf maps an RRULE value onto a task recurrence: rules with just a
+// frequency and interval become the planner's simple rules, others are kept
+// as RRULEs.
+func recurrenceOf(value string) (rule string, interval int, err error) {
+	r, err := rrule.Parse(value)
+	if err != nil {
+		return "", 0, err
+	}
+	if r.IsSimple() {
+		return strings.ToLower(r.Freq.String()), r.Interval, nil
+	}
+	return r.String(), 1, nil
+}
+
+// taskKey identifies a task for duplicate detection during import.
+func taskKey(task db.Task) string {
+	date := ""
+	if task.DueDate.Valid {
+		date = task.DueDate.Time.Format(config.DateFormat)
+	}
+	return strings.ToLower(strings.TrimSpace(task.Title)) + "\x00" + date
+}
@@ -0,0 +1,128 @@
+package ical
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"week-planner/internal/config"
+	"week-planner/internal/db"
+)
+
+func TestFromTasksExportsSeriesOnce(t *testing.T) {
+	store := db.NewMemoryStore()
+	store.SetClock(db.FixedClock(time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)))
+	day := func(s string) time.Time {
+		t.Helper()
+		d, err := time.Parse(config.DateFormat, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	create := func(task db.Task) db.Task {
+		t.Helper()
+		created, err := store.CreateTask(task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created
+	}
+	dentist := create(db.Task{Title: "Dentist", DueDate: db.NullTime{Time: day("2026-10-20"), Valid: true}})
+	create(db.Task{Title: "Someday"})
+	gym := create(db.Task{
+		Title:          "Gym",
+		DueDate:        db.NullTime{Time: day("2026-10-19"), Valid: true},
+		RecurrenceRule: "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+	})
+	occurrenceOn := func(date string) db.Task {
+		t.Helper()
+		tasks, err := store.GetTasks(db.TaskFilter{Date: date})
+		if err != nil || len(tasks) != 1 {
+			t.Fatalf("tasks on %s = %v, %v, want one occurrence", date, tasks, err)
+		}
+		return tasks[0]
+	}
+	// Completed on Monday, skipped on Wednesday, moved to Saturday and
+	// retitled on Friday.
+	if err := store.UpdateTask(gym.ID, map[string]interface{}{"completed": true}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteTask(occurrenceOn("2026-10-21").ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	friday := occurrenceOn("2026-10-23")
+	if err := store.UpdateTask(friday.ID, map[string]interface{}{"title": "Gym (legs)", "due_date": "2026-10-24"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	series, err := store.GetSeries(*gym.SeriesID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := FromTasks(store, ComponentTodo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := series.UID + "@week-planner"
+	want := []Item{
+		{Component: ComponentTodo, UID: dentist.UID + "@week-planner", Summary: "Dentist", Date: day("2026-10-20")},
+		{
+			Component: ComponentTodo, UID: uid, Summary: "Gym", Date: day("2026-10-19"),
+			RRule: "FREQ=WEEKLY;BYDAY=MO,WE,FR", ExDates: []time.Time{day("2026-10-21")},
+		},
+		{Component: ComponentTodo, UID: uid, Summary: "Gym", Date: day("2026-10-19"), Completed: true, RecurrenceID: day("2026-10-19")},
+		{Component: ComponentTodo, UID: uid, Summary: "Gym (legs)", Date: day("2026-10-24"), RecurrenceID: day("2026-10-23")},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("FromTasks = %+v, want %d items", items, len(want))
+	}
+	for i := range want {
+		got := items[i]
+		// Compare dates by day, whatever the location the store keeps.
+		got.Date, got.RecurrenceID = utcDate(got.Date), utcDate(got.RecurrenceID)
+		for j, d := range got.ExDates {
+			got.ExDates[j] = utcDate(d)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("item %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+// utcDate returns the date of d at midnight UTC, or zero for zero.
+func utcDate(d time.Time) time.Time {
+	if d.IsZero() {
+		return d
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func TestItemToTaskRecurrence(t *testing.T) {
+	date := time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		item     Item
+		rule     string
+		interval int
+		note     string // Part of the note, if any.
+	}{
+		{"simple rule", Item{Summary: "Gym", Date: date, RRule: "FREQ=WEEKLY;INTERVAL=2"}, "weekly", 2, ""},
+		{"RRULE", Item{Summary: "Team", Date: date, RRule: "FREQ=MONTHLY;BYDAY=2TU"}, "FREQ=MONTHLY;BYDAY=2TU", 1, ""},
+		{"count", Item{Summary: "Course", Date: date, RRule: "FREQ=WEEKLY;COUNT=10"}, "FREQ=WEEKLY;COUNT=10", 1, ""},
+		{"unsupported rule", Item{Summary: "Pills", Date: date, RRule: "FREQ=HOURLY"}, "", 1, "imported without recurrence"},
+		{"undated", Item{Summary: "Someday", RRule: "FREQ=DAILY"}, "", 1, "no start date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task, note := itemToTask(tt.item)
+			if task.RecurrenceRule != tt.rule || task.RecurrenceInterval != tt.interval {
+				t.Errorf("recurrence = %q every %d, want %q every %d", task.RecurrenceRule, task.RecurrenceInterval, tt.rule, tt.interval)
+			}
+			if tt.note == "" && note != "" || !strings.Contains(note, tt.note) {
+				t.Errorf("note = %q, want %q", note, tt.note)
+			}
+		})
+	}
+}
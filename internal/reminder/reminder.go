@@ -0,0 +1,68 @@
+// Package reminder fires the reminders of tasks when they are due. The
+// store records each delivery, so a reminder fires exactly once; notifiers
+// subscribed to the dispatcher pass it on to the user.
+package reminder
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"week-planner/internal/db"
+)
+
+// Grace is how late a reminder may still fire, e.g. after the server was
+// down when it was due. Older ones are dropped rather than fired in bulk.
+const Grace = 24 * time.Hour
+
+// Notifier is told about each reminder as it fires.
+type Notifier func(ctx context.Context, reminder db.ReminderDelivery)
+
+// Dispatcher fires the due reminders of a store each time Dispatch runs.
+type Dispatcher struct {
+	store db.ReminderStore
+	loc   *time.Location
+	clock db.Clock
+
+	mu        sync.Mutex
+	notifiers []Notifier
+}
+
+// New returns a dispatcher for the reminders of store, whose times are read
+// in loc (nil for local time).
+func New(store db.ReminderStore, loc *time.Location) *Dispatcher {
+	return &Dispatcher{store: store, loc: loc, clock: db.SystemClock}
+}
+
+// SetClock makes the dispatcher read the time from clock.
+func (d *Dispatcher) SetClock(clock db.Clock) {
+	d.clock = clock
+}
+
+// Subscribe adds a notifier called for every reminder fired from now on.
+func (d *Dispatcher) Subscribe(n Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notifiers = append(d.notifiers, n)
+}
+
+// Dispatch fires the reminders that became due since Grace ago and were not
+// fired yet, and returns them.
+func (d *Dispatcher) Dispatch(ctx context.Context) ([]db.ReminderDelivery, error) {
+	now := d.clock.Now()
+	fired, err := d.store.DeliverReminders(now.Add(-Grace), now, d.loc)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	notifiers := append([]Notifier(nil), d.notifiers...)
+	d.mu.Unlock()
+	for _, r := range fired {
+		slog.Info("Reminder", "task_id", r.TaskID, "title", r.Title, "remind_at", r.RemindAt)
+		for _, notify := range notifiers {
+			notify(ctx, r)
+		}
+	}
+	return fired, nil
+}
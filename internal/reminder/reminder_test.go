@@ -0,0 +1,67 @@
+package reminder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"week-planner/internal/db"
+)
+
+func TestDispatchFiresOnce(t *testing.T) {
+	store := db.NewMemoryStore()
+	due, _ := time.Parse("2006-01-02", "2026-10-20")
+	_, err := store.CreateTask(db.Task{
+		Title:    "Call bank",
+		DueDate:  db.NullTime{Time: due, Valid: true},
+		DueTime:  "09:00",
+		RemindAt: "-PT15M",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin := time.FixedZone("UTC+2", 2*60*60)
+	d := New(store, berlin)
+	var notified []db.ReminderDelivery
+	d.Subscribe(func(ctx context.Context, r db.ReminderDelivery) {
+		notified = append(notified, r)
+	})
+
+	for _, step := range []struct {
+		now  time.Time
+		want int
+	}{
+		{time.Date(2026, 10, 20, 8, 44, 0, 0, berlin), 0}, // Not yet due.
+		{time.Date(2026, 10, 20, 8, 45, 0, 0, berlin), 1},
+		{time.Date(2026, 10, 20, 8, 46, 0, 0, berlin), 0}, // Already fired.
+	} {
+		d.SetClock(db.FixedClock(step.now))
+		fired, err := d.Dispatch(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fired) != step.want {
+			t.Errorf("at %s fired %v, want %d reminder(s)", step.now, fired, step.want)
+		}
+	}
+	if len(notified) != 1 || notified[0].Title != "Call bank" {
+		t.Errorf("notified %v, want the reminder once", notified)
+	}
+}
+
+func TestDispatchDropsRemindersPastGrace(t *testing.T) {
+	store := db.NewMemoryStore()
+	_, err := store.CreateTask(db.Task{Title: "Old", RemindAt: "2026-10-01T09:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(store, time.UTC)
+	d.SetClock(db.FixedClock(time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)))
+	fired, err := d.Dispatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fired) != 0 {
+		t.Errorf("fired %v, want reminders older than %s dropped", fired, Grace)
+	}
+}
@@ -0,0 +1,298 @@
+package rrule
+
+import (
+	"sort"
+	"time"
+)
+
+// maxYears bounds the search for occurrences, so that rules that never match
+// (e.g. BYMONTH=2;BYMONTHDAY=30) end instead of looping. It is a full cycle
+// of the Gregorian calendar.
+const maxYears = 400
+
+// Iterator returns the occurrences of a rule in order. Create one with Iter.
+type Iterator struct {
+	rule    Rule // With the defaults taken from the start date filled in.
+	start   time.Time
+	period  int         // Index of the next period to expand.
+	pending []time.Time // Occurrences of the last expanded period not returned yet.
+	count   int         // Occurrences returned so far.
+	done    bool
+}
+
+// Iter returns an iterator over the occurrences of r starting at start, the
+// DTSTART of RFC 5545. Like DTSTART, start is always the first occurrence,
+// even if the rule does not match it, and counts towards COUNT. Only the date
+// of start matters; occurrences keep its time of day and location.
+func (r Rule) Iter(start time.Time) *Iterator {
+	if r.Interval < 1 {
+		r.Interval = 1
+	}
+	// Parts a rule leaves out default to those of the start date.
+	switch r.Freq {
+	case Weekly:
+		if len(r.ByDay) == 0 {
+			r.ByDay = []WeekdayNum{{Weekday: start.Weekday()}}
+		}
+	case Monthly:
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			r.ByMonthDay = []int{start.Day()}
+		}
+	case Yearly:
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByYearDay) == 0 {
+			if len(r.ByWeekNo) > 0 {
+				r.ByDay = []WeekdayNum{{Weekday: start.Weekday()}}
+			} else {
+				if len(r.ByMonth) == 0 {
+					r.ByMonth = []int{int(start.Month())}
+				}
+				r.ByMonthDay = []int{start.Day()}
+			}
+		}
+	}
+	return &Iterator{rule: r, start: start}
+}
+
+// Next returns the next occurrence, or false when there are no more.
+func (it *Iterator) Next() (time.Time, bool) {
+	if it.done {
+		return time.Time{}, false
+	}
+	if it.count == 0 {
+		it.count++
+		return it.start, true
+	}
+	if it.rule.Count > 0 && it.count >= it.rule.Count {
+		it.done = true
+		return time.Time{}, false
+	}
+	for len(it.pending) == 0 {
+		if !it.expand() {
+			it.done = true
+			return time.Time{}, false
+		}
+	}
+	next := it.pending[0]
+	it.pending = it.pending[1:]
+	if !it.rule.Until.IsZero() && dateOf(next).After(dateOf(it.rule.Until)) {
+		it.done = true
+		return time.Time{}, false
+	}
+	it.count++
+	return next, true
+}
+
+// expand fills pending with the occurrences of the next period after start.
+// It returns false once the search limit is reached.
+func (it *Iterator) expand() bool {
+	r := it.rule
+	y, m, d := it.start.Date()
+	loc := it.start.Location()
+	step := it.period * r.Interval
+	it.period++
+
+	var first time.Time
+	var days int
+	switch r.Freq {
+	case Daily:
+		first, days = time.Date(y, m, d+step, 0, 0, 0, 0, loc), 1
+	case Weekly:
+		offset := (int(it.start.Weekday()) - int(r.Wkst) + 7) % 7
+		first, days = time.Date(y, m, d-offset+7*step, 0, 0, 0, 0, loc), 7
+	case Monthly:
+		first = time.Date(y, m+time.Month(step), 1, 0, 0, 0, 0, loc)
+		days = daysIn(first.Year(), first.Month())
+	case Yearly:
+		first = time.Date(y+step, 1, 1, 0, 0, 0, 0, loc)
+		days = daysInYear(first.Year())
+	}
+	if first.Year() > y+maxYears {
+		return false
+	}
+
+	var set []time.Time
+	for i := 0; i < days; i++ {
+		day := time.Date(first.Year(), first.Month(), first.Day()+i, 0, 0, 0, 0, loc)
+		if r.matches(day) {
+			set = append(set, day)
+		}
+	}
+	if len(r.BySetPos) > 0 {
+		set = selectPositions(set, r.BySetPos)
+	}
+
+	startDate := dateOf(it.start)
+	hour, min, sec := it.start.Clock()
+	for _, day := range set {
+		if !dateOf(day).After(startDate) {
+			continue
+		}
+		it.pending = append(it.pending, time.Date(day.Year(), day.Month(), day.Day(), hour, min, sec, it.start.Nanosecond(), loc))
+	}
+	return true
+}
+
+// matches reports whether day passes all BYxxx filters of the rule.
+func (r Rule) matches(day time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(day.Month())) {
+		return false
+	}
+	if len(r.ByWeekNo) > 0 {
+		week, weeks := weekNumber(day, r.Wkst)
+		if !matchesIndex(r.ByWeekNo, week, weeks) {
+			return false
+		}
+	}
+	if len(r.ByYearDay) > 0 && !matchesIndex(r.ByYearDay, day.YearDay(), daysInYear(day.Year())) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !matchesIndex(r.ByMonthDay, day.Day(), daysIn(day.Year(), day.Month())) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !r.matchesByDay(day) {
+		return false
+	}
+	return true
+}
+
+// matchesByDay reports whether day is one of the BYDAY weekdays. Ordinals
+// count within the month for monthly rules and yearly rules with BYMONTH,
+// and within the year otherwise.
+func (r Rule) matchesByDay(day time.Time) bool {
+	inMonth := r.Freq == Monthly || (r.Freq == Yearly && len(r.ByMonth) > 0)
+	for _, wd := range r.ByDay {
+		if wd.Weekday != day.Weekday() {
+			continue
+		}
+		if wd.N == 0 {
+			return true
+		}
+		index, length := day.YearDay(), daysInYear(day.Year())
+		if inMonth {
+			index, length = day.Day(), daysIn(day.Year(), day.Month())
+		}
+		nth := (index-1)/7 + 1
+		fromEnd := -((length-index)/7 + 1)
+		if wd.N == nth || wd.N == fromEnd {
+			return true
+		}
+	}
+	return false
+}
+
+// selectPositions returns the BYSETPOS elements of the sorted set.
+func selectPositions(set []time.Time, positions []int) []time.Time {
+	var out []time.Time
+	for _, pos := range positions {
+		i := pos - 1
+		if pos < 0 {
+			i = len(set) + pos
+		}
+		if i >= 0 && i < len(set) {
+			out = append(out, set[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	// Positions may name the same day twice (e.g. 1 and -1 of a one-day set).
+	uniq := out[:0]
+	for i, t := range out {
+		if i == 0 || !t.Equal(out[i-1]) {
+			uniq = append(uniq, t)
+		}
+	}
+	return uniq
+}
+
+// weekNumber returns the RFC 5545 week number of day and the number of weeks
+// in its week-numbering year. Week 1 is the first week, starting on wkst,
+// with at least four days in the calendar year.
+func weekNumber(day time.Time, wkst time.Weekday) (week, weeks int) {
+	year := day.Year()
+	start := firstWeekStart(year, wkst, day.Location())
+	if day.Before(start) {
+		year--
+		start = firstWeekStart(year, wkst, day.Location())
+	} else if next := firstWeekStart(year+1, wkst, day.Location()); !day.Before(next) {
+		year++
+		start = next
+	}
+	end := firstWeekStart(year+1, wkst, day.Location())
+	return daysBetween(start, day)/7 + 1, daysBetween(start, end) / 7
+}
+
+// firstWeekStart returns the first day of week 1 of year.
+func firstWeekStart(year int, wkst time.Weekday, loc *time.Location) time.Time {
+	jan1 := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	offset := (int(jan1.Weekday()) - int(wkst) + 7) % 7
+	if offset <= 3 {
+		return time.Date(year, 1, 1-offset, 0, 0, 0, 0, loc)
+	}
+	return time.Date(year, 1, 1+7-offset, 0, 0, 0, 0, loc)
+}
+
+// matchesIndex reports whether the 1-based index within a range of length n
+// is one of values, where negative values count from the end.
+func matchesIndex(values []int, index, n int) bool {
+	for _, v := range values {
+		if v == index || (v < 0 && n+v+1 == index) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(dateOf(b).Sub(dateOf(a)).Hours() / 24)
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+// After returns the first occurrence of r from start that falls on a later
+// date than after, and its index (0 is start). ok is false if there is none.
+func (r Rule) After(start, after time.Time) (occurrence time.Time, index int, ok bool) {
+	it := r.Iter(start)
+	limit := dateOf(after)
+	for i := 0; ; i++ {
+		t, more := it.Next()
+		if !more {
+			return time.Time{}, 0, false
+		}
+		if dateOf(t).After(limit) {
+			return t, i, true
+		}
+	}
+}
+
+// Occurrences returns up to n occurrences of r from start.
+func (r Rule) Occurrences(start time.Time, n int) []time.Time {
+	var out []time.Time
+	it := r.Iter(start)
+	for len(out) < n {
+		t, ok := it.Next()
+		if !ok {
+			break
+		}
+		out = append(out, t)
+	}
+	return out
+}
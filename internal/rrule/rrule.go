@@ -0,0 +1,297 @@
+// Package rrule implements the recurrence rules of RFC 5545 (iCalendar) for
+// date-only events: the DAILY, WEEKLY, MONTHLY and YEARLY frequencies with
+// INTERVAL, COUNT, UNTIL, WKST and the BYDAY, BYMONTHDAY, BYYEARDAY, BYWEEKNO,
+// BYMONTH and BYSETPOS parts.
+//
+// Planner tasks have dates, not times, so sub-daily frequencies and the
+// BYHOUR, BYMINUTE and BYSECOND parts are rejected.
+package rrule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a rule.
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencyNames = [...]string{"DAILY", "WEEKLY", "MONTHLY", "YEARLY"}
+
+func (f Frequency) String() string {
+	if f < Daily || f > Yearly {
+		return fmt.Sprintf("Frequency(%d)", int(f))
+	}
+	return frequencyNames[f]
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// WeekdayNum is a BYDAY entry: a weekday, with an ordinal in monthly and
+// yearly rules (2TU is the second Tuesday, -1FR the last Friday).
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int // 0 for every such weekday.
+}
+
+func (w WeekdayNum) String() string {
+	if w.N == 0 {
+		return weekdayNames[w.Weekday]
+	}
+	return strconv.Itoa(w.N) + weekdayNames[w.Weekday]
+}
+
+// Rule is a parsed RRULE. Use Parse or New to get one: the zero Rule has a
+// week starting on Sunday rather than the default Monday.
+type Rule struct {
+	Freq       Frequency
+	Interval   int       // At least 1.
+	Count      int       // Number of occurrences including the start, 0 for no limit.
+	Until      time.Time // Date of the last possible occurrence, zero for no limit.
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByYearDay  []int
+	ByWeekNo   []int
+	ByMonth    []int
+	BySetPos   []int
+	Wkst       time.Weekday
+}
+
+// New returns a rule repeating every interval periods of freq.
+func New(freq Frequency, interval int) Rule {
+	if interval < 1 {
+		interval = 1
+	}
+	return Rule{Freq: freq, Interval: interval, Wkst: time.Monday}
+}
+
+// Parse parses an RRULE value such as "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1".
+// A leading "RRULE:" is accepted. Names are case-insensitive.
+func Parse(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+	if s == "" {
+		return Rule{}, fmt.Errorf("empty RRULE")
+	}
+
+	r := Rule{Interval: 1, Wkst: time.Monday}
+	seen := map[string]bool{}
+	hasFreq := false
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || key == "" || value == "" {
+			return Rule{}, fmt.Errorf("malformed RRULE part %q", part)
+		}
+		if seen[key] {
+			return Rule{}, fmt.Errorf("duplicate RRULE part %s", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			hasFreq = true
+			switch value {
+			case "DAILY":
+				r.Freq = Daily
+			case "WEEKLY":
+				r.Freq = Weekly
+			case "MONTHLY":
+				r.Freq = Monthly
+			case "YEARLY":
+				r.Freq = Yearly
+			case "HOURLY", "MINUTELY", "SECONDLY":
+				return Rule{}, fmt.Errorf("unsupported RRULE frequency %s: tasks have no time of day", value)
+			default:
+				return Rule{}, fmt.Errorf("invalid RRULE frequency %q", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = parseInt(value, 1, 1<<20)
+		case "COUNT":
+			r.Count, err = parseInt(value, 1, 1<<20)
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(value, 31, false)
+		case "BYYEARDAY":
+			r.ByYearDay, err = parseIntList(value, 366, false)
+		case "BYWEEKNO":
+			r.ByWeekNo, err = parseIntList(value, 53, false)
+		case "BYMONTH":
+			r.ByMonth, err = parseIntList(value, 12, true)
+		case "BYSETPOS":
+			r.BySetPos, err = parseIntList(value, 366, false)
+		case "WKST":
+			r.Wkst, err = parseWeekday(value)
+		case "BYHOUR", "BYMINUTE", "BYSECOND":
+			return Rule{}, fmt.Errorf("unsupported RRULE part %s: tasks have no time of day", key)
+		default:
+			return Rule{}, fmt.Errorf("unknown RRULE part %q", key)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid RRULE %s: %w", key, err)
+		}
+	}
+	if !hasFreq {
+		return Rule{}, fmt.Errorf("RRULE without FREQ")
+	}
+	if err := r.validate(); err != nil {
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+// validate checks the combinations of parts RFC 5545 forbids.
+func (r Rule) validate() error {
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("RRULE cannot have both COUNT and UNTIL")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == Weekly {
+		return fmt.Errorf("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	if len(r.ByYearDay) > 0 && r.Freq != Yearly {
+		return fmt.Errorf("BYYEARDAY can only be used with FREQ=YEARLY")
+	}
+	if len(r.ByWeekNo) > 0 && r.Freq != Yearly {
+		return fmt.Errorf("BYWEEKNO can only be used with FREQ=YEARLY")
+	}
+	for _, day := range r.ByDay {
+		if day.N == 0 {
+			continue
+		}
+		if r.Freq != Monthly && r.Freq != Yearly {
+			return fmt.Errorf("BYDAY %s: ordinals need FREQ=MONTHLY or YEARLY", day)
+		}
+		if r.Freq == Yearly && len(r.ByWeekNo) > 0 {
+			return fmt.Errorf("BYDAY %s: ordinals cannot be used with BYWEEKNO", day)
+		}
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay)+len(r.ByMonthDay)+len(r.ByYearDay)+len(r.ByWeekNo)+len(r.ByMonth) == 0 {
+		return fmt.Errorf("BYSETPOS needs another BYxxx part")
+	}
+	return nil
+}
+
+// String returns the rule in canonical form, without the "RRULE:" prefix.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	list := func(name string, values []int) {
+		if len(values) == 0 {
+			return
+		}
+		s := make([]string, len(values))
+		for i, v := range values {
+			s[i] = strconv.Itoa(v)
+		}
+		parts = append(parts, name+"="+strings.Join(s, ","))
+	}
+	list("BYMONTH", r.ByMonth)
+	list("BYWEEKNO", r.ByWeekNo)
+	list("BYYEARDAY", r.ByYearDay)
+	list("BYMONTHDAY", r.ByMonthDay)
+	if len(r.ByDay) > 0 {
+		s := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			s[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(s, ","))
+	}
+	list("BYSETPOS", r.BySetPos)
+	if r.Wkst != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.Wkst])
+	}
+	return strings.Join(parts, ";")
+}
+
+// IsSimple reports whether the rule only has a frequency and an interval.
+func (r Rule) IsSimple() bool {
+	return r.Count == 0 && r.Until.IsZero() && len(r.ByDay)+len(r.ByMonthDay)+len(r.ByYearDay)+
+		len(r.ByWeekNo)+len(r.ByMonth)+len(r.BySetPos) == 0
+}
+
+func parseInt(s string, min, max int) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "+"))
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%q is not a number from %d to %d", s, min, max)
+	}
+	return n, nil
+}
+
+// parseIntList parses a comma-separated list of numbers from 1 to max, or
+// also from -max to -1 unless positiveOnly.
+func parseIntList(s string, max int, positiveOnly bool) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(item, "+"))
+		if err != nil || n == 0 || n > max || n < -max || (positiveOnly && n < 0) {
+			return nil, fmt.Errorf("%q is out of range", item)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for i, name := range weekdayNames {
+		if s == name {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a weekday", s)
+}
+
+func parseByDay(s string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(s, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("%q is not a weekday", item)
+		}
+		weekday, err := parseWeekday(item[len(item)-2:])
+		if err != nil {
+			return nil, err
+		}
+		day := WeekdayNum{Weekday: weekday}
+		if ordinal := item[:len(item)-2]; ordinal != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(ordinal, "+"))
+			if err != nil || n == 0 || n > 53 || n < -53 {
+				return nil, fmt.Errorf("%q has an invalid ordinal", item)
+			}
+			day.N = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// parseUntil parses an UNTIL date or date-time. Only the date is kept.
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102", "20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date", s)
+}
@@ -0,0 +1,143 @@
+package rrule
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		want  []string // Including start.
+		ended bool     // want lists every occurrence.
+	}{
+		{"Monday, Wednesday and Friday", "FREQ=WEEKLY;BYDAY=MO,WE,FR", "2026-10-19",
+			[]string{"2026-10-19", "2026-10-21", "2026-10-23", "2026-10-26", "2026-10-28"}, false},
+		{"every weekday", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "2026-10-23",
+			[]string{"2026-10-23", "2026-10-26", "2026-10-27", "2026-10-28", "2026-10-29", "2026-10-30", "2026-11-02"}, false},
+		{"every weekday, daily", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", "2026-10-30",
+			[]string{"2026-10-30", "2026-11-02", "2026-11-03"}, false},
+		{"last weekday of the month", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "2026-10-30",
+			[]string{"2026-10-30", "2026-11-30", "2026-12-31", "2027-01-29"}, false},
+		{"second Tuesday", "FREQ=MONTHLY;BYDAY=2TU", "2026-10-13",
+			[]string{"2026-10-13", "2026-11-10", "2026-12-08", "2027-01-12"}, false},
+		{"last Friday", "FREQ=MONTHLY;BYDAY=-1FR", "2026-10-30",
+			[]string{"2026-10-30", "2026-11-27", "2026-12-25"}, false},
+		{"second to last day of the month", "FREQ=MONTHLY;BYMONTHDAY=-2", "2026-01-30",
+			[]string{"2026-01-30", "2026-02-27", "2026-03-30", "2026-04-29"}, false},
+		{"every other week", "FREQ=WEEKLY;INTERVAL=2", "2026-10-18",
+			[]string{"2026-10-18", "2026-11-01", "2026-11-15"}, false},
+		{"count", "FREQ=DAILY;COUNT=3", "2026-10-18",
+			[]string{"2026-10-18", "2026-10-19", "2026-10-20"}, true},
+		{"until", "FREQ=WEEKLY;UNTIL=20261101", "2026-10-18",
+			[]string{"2026-10-18", "2026-10-25", "2026-11-01"}, true},
+		{"until with a time", "FREQ=DAILY;UNTIL=20261019T235959Z", "2026-10-18",
+			[]string{"2026-10-18", "2026-10-19"}, true},
+		{"start not matching the rule", "FREQ=MONTHLY;BYDAY=1MO;COUNT=2", "2026-10-18",
+			[]string{"2026-10-18", "2026-11-02"}, true},
+		// Rules that never match end after the search limit instead of looping.
+		{"February 30th", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", "2026-01-01",
+			[]string{"2026-01-01"}, true},
+		{"April 31st", "FREQ=MONTHLY;BYMONTH=4;BYMONTHDAY=31", "2026-01-01",
+			[]string{"2026-01-01"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := len(tt.want)
+			if tt.ended {
+				n += 5
+			}
+			var got []string
+			for _, d := range r.Occurrences(date(t, tt.start), n) {
+				got = append(got, d.Format("2006-01-02"))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s from %s = %v, want %v", tt.rule, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAfter(t *testing.T) {
+	r, err := Parse("FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := date(t, "2026-10-19")
+	next, index, ok := r.After(start, date(t, "2026-10-21"))
+	if !ok || !next.Equal(date(t, "2026-10-23")) || index != 2 {
+		t.Errorf("After = %s, %d, %v, want 2026-10-23, 2, true", next, index, ok)
+	}
+	if _, _, ok := r.After(start, date(t, "2026-10-26")); ok {
+		t.Error("After found an occurrence past COUNT")
+	}
+}
+
+func TestParseString(t *testing.T) {
+	tests := []struct{ rule, want string }{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"rrule:freq=monthly;byday=-1fr", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"BYDAY=MO,WE;FREQ=WEEKLY;INTERVAL=1", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"FREQ=MONTHLY;BYSETPOS=-1;BYDAY=MO,TU,WE,TH,FR", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1"},
+		{"FREQ=YEARLY;UNTIL=20271231T000000Z;BYMONTH=+3", "FREQ=YEARLY;UNTIL=20271231;BYMONTH=3"},
+		{"FREQ=WEEKLY;WKST=SU;COUNT=10", "FREQ=WEEKLY;COUNT=10;WKST=SU"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.rule, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"RRULE:",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=FORTNIGHTLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=x",
+		"FREQ=DAILY;COUNT=2;UNTIL=20261231",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYYEARDAY=1",
+		"FREQ=MONTHLY;BYWEEKNO=1",
+		"FREQ=YEARLY;BYMONTH=-1",
+		"FREQ=DAILY;BYSETPOS=1",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;FOO=1",
+		"FREQ=DAILY;COUNT",
+	} {
+		if r, err := Parse(rule); err == nil {
+			t.Errorf("Parse(%q) = %s, want an error", rule, r)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package scheduler runs the server's background jobs: the daily recurrence
+// rollover and maintenance such as backups, keeping the status of each job.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"week-planner/internal/db"
+)
+
+// maxWait bounds how long a job waits before looking at the clock again, so
+// that a suspended machine or a changed clock delays a job by at most that.
+const maxWait = time.Minute
+
+// Schedule decides when a job runs next.
+type Schedule interface {
+	// Next returns the first time after now the job is due.
+	Next(now time.Time) time.Time
+	String() string
+}
+
+// Every returns a schedule running a job interval after its previous run.
+func Every(interval time.Duration) Schedule {
+	return every(interval)
+}
+
+type every time.Duration
+
+func (e every) Next(now time.Time) time.Time {
+	return now.Add(time.Duration(e))
+}
+
+// String writes the interval without zero minutes and seconds: "every 24h"
+// rather than "every 24h0m0s".
+func (e every) String() string {
+	s := time.Duration(e).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return "every " + s
+}
+
+// Daily returns a schedule running a job every day at hour:minute in loc
+// (nil for local time).
+func Daily(hour, minute int, loc *time.Location) Schedule {
+	if loc == nil {
+		loc = time.Local
+	}
+	return daily{hour, minute, loc}
+}
+
+type daily struct {
+	hour, minute int
+	loc          *time.Location
+}
+
+func (d daily) Next(now time.Time) time.Time {
+	now = now.In(d.loc)
+	next := time.Date(now.Year(), now.Month(), now.Day(), d.hour, d.minute, 0, 0, d.loc)
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, d.hour, d.minute, 0, 0, d.loc)
+	}
+	return next
+}
+
+func (d daily) String() string {
+	return fmt.Sprintf("daily at %02d:%02d %s", d.hour, d.minute, d.loc)
+}
+
+// Job is a named task run on a schedule.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// First is when the job runs first; the zero time leaves it to
+	// Schedule. A time in the past runs the job as soon as the scheduler
+	// starts, e.g. to catch up with a run missed while the server was down.
+	First time.Time
+	// Run does the work. ctx is cancelled when the scheduler stops; the
+	// scheduler waits for Run to return.
+	Run func(ctx context.Context) error
+}
+
+// Status is the state of a job as reported by Scheduler.Status.
+type Status struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	Running        bool       `json:"running"`
+	Runs           int        `json:"runs"`
+	Failures       int        `json:"failures"`
+	LastRun        *time.Time `json:"last_run"`         // Start of the last run, null before the first one.
+	LastDurationMS int64      `json:"last_duration_ms"` // Duration of the last finished run.
+	LastError      string     `json:"last_error,omitempty"`
+	NextRun        *time.Time `json:"next_run"` // Null when the scheduler is not running.
+}
+
+// entry is a job with its status, guarded by Scheduler.mu.
+type entry struct {
+	job    Job
+	status Status
+}
+
+// Scheduler runs jobs, each in its own goroutine, until its context is
+// cancelled. A job does not overlap with itself: a run that outlasts the
+// interval delays the next one.
+type Scheduler struct {
+	mu      sync.Mutex
+	entries []*entry
+	clock   db.Clock
+	maxWait time.Duration // Longest wait before looking at the clock again, see maxWait.
+}
+
+// New returns a scheduler without jobs.
+func New() *Scheduler {
+	return &Scheduler{clock: db.SystemClock, maxWait: maxWait}
+}
+
+// SetClock makes the scheduler read the time from clock. It must be called
+// before Run.
+func (s *Scheduler) SetClock(clock db.Clock) {
+	s.clock = clock
+}
+
+// Add registers a job. Jobs must be added before Run is called.
+func (s *Scheduler) Add(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, &entry{job: job, status: Status{Name: job.Name, Schedule: job.Schedule.String()}})
+}
+
+// Run runs the jobs until ctx is cancelled, then waits for the running ones
+// to return.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	entries := append([]*entry(nil), s.entries...)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, e)
+		}()
+	}
+	slog.Info("Scheduler started", "jobs", len(entries))
+	wg.Wait()
+	slog.Info("Scheduler stopped")
+}
+
+// Status returns the status of all jobs in the order they were added.
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, len(s.entries))
+	for i, e := range s.entries {
+		statuses[i] = e.status
+	}
+	return statuses
+}
+
+// loop runs the job of e whenever it is due until ctx is cancelled.
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	next := e.job.First
+	if next.IsZero() {
+		next = e.job.Schedule.Next(s.clock.Now())
+	}
+	timer := time.NewTimer(s.maxWait)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		s.setNext(e, &next)
+		wait := next.Sub(s.clock.Now())
+		if wait <= 0 {
+			s.runJob(ctx, e)
+			if ctx.Err() != nil {
+				s.setNext(e, nil)
+				return
+			}
+			next = e.job.Schedule.Next(s.clock.Now())
+			continue
+		}
+		timer.Reset(min(wait, s.maxWait))
+		select {
+		case <-ctx.Done():
+			s.setNext(e, nil)
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// runJob runs the job of e once and records the outcome.
+func (s *Scheduler) runJob(ctx context.Context, e *entry) {
+	start := s.clock.Now()
+	s.mu.Lock()
+	e.status.Running = true
+	e.status.LastRun = &start
+	s.mu.Unlock()
+
+	slog.Debug("Job started", "job", e.job.Name)
+	err := run(ctx, e.job)
+	duration := s.clock.Now().Sub(start)
+	if err != nil {
+		slog.Error("Job failed", "job", e.job.Name, "duration", duration, "error", err)
+	} else {
+		slog.Info("Job finished", "job", e.job.Name, "duration", duration)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.status.Running = false
+	e.status.Runs++
+	e.status.LastDurationMS = duration.Milliseconds()
+	e.status.LastError = ""
+	if err != nil {
+		e.status.Failures++
+		e.status.LastError = err.Error()
+	}
+}
+
+// run calls job.Run, turning a panic into an error so that one failing job
+// does not take the server down.
+func run(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+func (s *Scheduler) setNext(e *entry, next *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if next == nil {
+		e.status.NextRun = nil
+		return
+	}
+	t := *next
+	e.status.NextRun = &t
+}
@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	_ "time/tzdata" // Europe/Berlin without relying on the system's zoneinfo.
+
+	"week-planner/internal/db"
+)
+
+// testClock is a clock the test moves forward.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// start runs s with clock until the test ends, looking at the clock every
+// millisecond instead of every minute.
+func start(t *testing.T, s *Scheduler, clock db.Clock) (stop func()) {
+	t.Helper()
+	s.SetClock(clock)
+	s.maxWait = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	stop = func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("scheduler did not stop")
+		}
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// waitFor waits until the status of the only job of s satisfies ok.
+func waitFor(t *testing.T, s *Scheduler, what string, ok func(Status) bool) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := s.Status()[0]
+		if ok(status) {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s: %+v", what, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDailyNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	midnight := Daily(0, 0, berlin)
+	tests := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2026, 10, 18, 12, 0, 0, 0, berlin), time.Date(2026, 10, 19, 0, 0, 0, 0, berlin)},
+		{time.Date(2026, 10, 18, 0, 0, 0, 0, berlin), time.Date(2026, 10, 19, 0, 0, 0, 0, berlin)}, // Not now again.
+		// 22:30 UTC is already past midnight in Berlin (UTC+2).
+		{time.Date(2026, 10, 18, 22, 30, 0, 0, time.UTC), time.Date(2026, 10, 20, 0, 0, 0, 0, berlin)},
+		// The day the clocks go back an hour is 25 hours long.
+		{time.Date(2026, 10, 25, 0, 0, 0, 0, berlin), time.Date(2026, 10, 26, 0, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		if got := midnight.Next(tt.now); !got.Equal(tt.want) {
+			t.Errorf("Next(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestEveryString(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     string
+	}{
+		{24 * time.Hour, "every 24h"},
+		{90 * time.Minute, "every 1h30m"},
+		{15 * time.Minute, "every 15m"},
+		{90 * time.Second, "every 1m30s"},
+		{time.Hour + time.Second, "every 1h0m1s"},
+	}
+	for _, tt := range tests {
+		if got := Every(tt.interval).String(); got != tt.want {
+			t.Errorf("Every(%s).String() = %q, want %q", tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestRunsDailyJobAtLocalMidnight(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &testClock{now: time.Date(2026, 10, 18, 23, 59, 0, 0, berlin)}
+	runs := make(chan time.Time, 10)
+	s := New()
+	s.Add(Job{Name: "rollover", Schedule: Daily(0, 0, berlin), Run: func(ctx context.Context) error {
+		runs <- clock.Now()
+		return nil
+	}})
+	start(t, s, clock)
+
+	midnight := time.Date(2026, 10, 19, 0, 0, 0, 0, berlin)
+	status := waitFor(t, s, "the next run", func(st Status) bool { return st.NextRun != nil })
+	if !status.NextRun.Equal(midnight) || status.Runs != 0 {
+		t.Errorf("status before midnight = %+v, want the next run at %s", status, midnight)
+	}
+
+	clock.Set(midnight.Add(time.Second))
+	select {
+	case at := <-runs:
+		if at.Before(midnight) {
+			t.Errorf("job ran at %s, before midnight", at)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run at midnight")
+	}
+	status = waitFor(t, s, "the next run after midnight", func(st Status) bool { return st.Runs == 1 && st.NextRun != nil && st.NextRun.After(midnight) })
+	if want := midnight.AddDate(0, 0, 1); status.NextRun == nil || !status.NextRun.Equal(want) {
+		t.Errorf("next run after midnight = %v, want %s", status.NextRun, want)
+	}
+	if status.LastRun == nil || !status.LastRun.Equal(midnight.Add(time.Second)) {
+		t.Errorf("last run = %v, want %s", status.LastRun, midnight.Add(time.Second))
+	}
+	select {
+	case at := <-runs:
+		t.Errorf("job ran again at %s", at)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestCatchesUpOnStart(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	clock := &testClock{now: now}
+	ran := make(chan struct{}, 1)
+	s := New()
+	s.Add(Job{
+		Name:     "rollover",
+		Schedule: Daily(0, 0, time.UTC),
+		First:    now.Add(-9 * time.Hour), // Missed while the server was down.
+		Run: func(ctx context.Context) error {
+			ran <- struct{}{}
+			return nil
+		},
+	})
+	start(t, s, clock)
+
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("missed run did not catch up")
+	}
+	status := waitFor(t, s, "the next run", func(st Status) bool { return st.Runs == 1 && st.NextRun != nil && st.NextRun.After(now) })
+	if want := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC); status.NextRun == nil || !status.NextRun.Equal(want) {
+		t.Errorf("next run = %v, want %s", status.NextRun, want)
+	}
+}
+
+func TestFailedJobStatus(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)}
+	var panics atomic.Bool
+	s := New()
+	s.Add(Job{Name: "backup", Schedule: Every(time.Hour), First: clock.Now(), Run: func(ctx context.Context) error {
+		if !panics.Load() {
+			return errors.New("disk full")
+		}
+		panic("out of luck")
+	}})
+	start(t, s, clock)
+
+	first := clock.Now()
+	status := waitFor(t, s, "the failed run", func(st Status) bool { return st.Runs == 1 && st.NextRun != nil && st.NextRun.After(first) })
+	if status.Failures != 1 || status.LastError != "disk full" {
+		t.Errorf("status after a failure = %+v, want the error", status)
+	}
+	if want := clock.Now().Add(time.Hour); status.NextRun == nil || !status.NextRun.Equal(want) {
+		t.Errorf("next run after a failure = %v, want %s", status.NextRun, want)
+	}
+
+	// A panicking job fails too, without taking the scheduler down.
+	panics.Store(true)
+	clock.Set(clock.Now().Add(time.Hour))
+	status = waitFor(t, s, "the panicking run", func(st Status) bool { return st.Runs == 2 })
+	if status.Failures != 2 || status.LastError != "panic: out of luck" {
+		t.Errorf("status after a panic = %+v, want the panic", status)
+	}
+}
+
+func TestStopsOnCancel(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)}
+	started := make(chan struct{})
+	s := New()
+	s.Add(Job{Name: "optimize", Schedule: Every(time.Hour), First: clock.Now(), Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	stop := start(t, s, clock)
+
+	<-started
+	if status := s.Status()[0]; !status.Running {
+		t.Errorf("status of a running job = %+v", status)
+	}
+	stop() // Fails the test if Run does not return.
+	status := s.Status()[0]
+	if status.Running || status.NextRun != nil || status.Runs != 1 {
+		t.Errorf("status after stopping = %+v, want stopped with no next run", status)
+	}
+}
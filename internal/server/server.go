@@ -5,8 +5,14 @@ import (
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 	"week-planner/internal/api"
+	"week-planner/internal/auth"
+	"week-planner/internal/backup"
+	"week-planner/internal/db"
+	"week-planner/internal/events"
+	"week-planner/internal/scheduler"
 
 	"github.com/gorilla/mux"
 )
@@ -14,17 +20,22 @@ import (
 //go:embed static/*
 var staticFS embed.FS
 
-func SetupRouter() *mux.Router {
+// SetupRouter builds the HTTP routes serving the API on store and the
+// embedded frontend. loc is the planner's time zone. Changes are published
+// on bus, including the occurrences store creates by itself. authn, if
+// enabled, guards the API; browsers may call it from corsOrigins besides the
+// planner's own origin.
+func SetupRouter(store *db.Store, backups *backup.Manager, jobs *scheduler.Scheduler, loc *time.Location, bus *events.Bus, authn *auth.Authenticator, corsOrigins []string) *mux.Router {
 	router := mux.NewRouter()
+	h := &api.Handler{Tasks: store, Settings: store, DB: store, Backups: backups, Jobs: jobs, Location: loc, Reminders: store, Webhooks: store, Tags: store, Lists: store, Events: bus}
+	store.OnOccurrenceCreated(h.OccurrenceCreated)
 
 	// Logging Middleware
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			startTime := time.Now()
 
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+			setCORSHeaders(w, r, corsOrigins)
 
 			if r.Method == "OPTIONS" {
 				return
@@ -39,23 +50,73 @@ func SetupRouter() *mux.Router {
 			next.ServeHTTP(w, r)
 		})
 	})
+	router.Use(authn.Middleware)
 
-	router.HandleFunc("/api/tasks", api.GetTasksHandler).Methods("GET", "OPTIONS")
-	router.HandleFunc("/api/inbox_title", api.GetInboxTitleHandler).Methods("GET", "OPTIONS")
-	router.HandleFunc("/api/inbox_title", api.UpdateInboxTitleHandler).Methods("PUT", "OPTIONS")
-	router.HandleFunc("/api/tasks", api.CreateTaskHandler).Methods("POST", "OPTIONS")
-	router.HandleFunc("/api/tasks/{id}", api.GetTaskHandler).Methods("GET", "OPTIONS")
-	router.HandleFunc("/api/tasks/{id}", api.UpdateTaskHandler).Methods("PUT", "OPTIONS")
-	router.HandleFunc("/api/tasks/bulk_update_order", api.BulkUpdateTaskOrderHandler).Methods("POST", "OPTIONS")
-	router.HandleFunc("/api/tasks/{id}", api.DeleteTaskHandler).Methods("DELETE", "OPTIONS")
-	router.HandleFunc("/api/search_tasks", api.SearchTasksHandler).Methods("GET", "OPTIONS")
+	router.HandleFunc("/api/login", authn.LoginHandler).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/logout", authn.LogoutHandler).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/session", authn.SessionHandler).Methods("GET", "OPTIONS")
+
+	// Routes that swap the database file must not hold the store themselves:
+	// the swap waits for every in-flight request to release it. They are
+	// registered before the /api subrouter so they match first.
+	router.HandleFunc("/api/import_db", h.ImportDbHandler).Methods("POST", "OPTIONS")
+	router.HandleFunc("/api/backups/{name}/restore", h.RestoreBackupHandler).Methods("POST", "OPTIONS")
+	// The event stream stays open; holding the store would block swaps.
+	router.HandleFunc("/api/events", h.EventsHandler).Methods("GET", "OPTIONS")
+
+	apiRouter := router.PathPrefix("/api").Subrouter()
+	// Hold the database for the whole request so a swap never closes the
+	// connection under a running handler.
+	apiRouter.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			release := store.Acquire()
+			defer release()
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	apiRouter.HandleFunc("/tasks", h.GetTasksHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/inbox_title", h.GetInboxTitleHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/inbox_title", h.UpdateInboxTitleHandler).Methods("PUT", "OPTIONS")
+	apiRouter.HandleFunc("/tasks", h.CreateTaskHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/tasks/{id}", h.GetTaskHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/tasks/{id}", h.UpdateTaskHandler).Methods("PUT", "OPTIONS")
+	apiRouter.HandleFunc("/tasks/bulk_update_order", h.BulkUpdateTaskOrderHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/tasks/{id}", h.DeleteTaskHandler).Methods("DELETE", "OPTIONS")
+	apiRouter.HandleFunc("/tasks/{id}/subtasks", h.GetSubtasksHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/tasks/{id}/subtasks", h.CreateSubtaskHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/tasks/{id}/subtasks/bulk_update_order", h.BulkUpdateSubtaskOrderHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/tasks/{id}/subtasks/{subtask_id}", h.UpdateSubtaskHandler).Methods("PUT", "OPTIONS")
+	apiRouter.HandleFunc("/tasks/{id}/subtasks/{subtask_id}", h.DeleteSubtaskHandler).Methods("DELETE", "OPTIONS")
+	apiRouter.HandleFunc("/recurrence_preview", h.RecurrencePreviewHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/series/{id}", h.GetSeriesHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/search_tasks", h.SearchTasksHandler).Methods("GET", "OPTIONS")
 
 	// New routes for export and import
-	router.HandleFunc("/api/export_db", api.ExportDbHandler).Methods("GET", "OPTIONS")
-	router.HandleFunc("/api/import_db", api.ImportDbHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/export_db", h.ExportDbHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/export", h.ExportHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/import", h.ImportHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/backups", h.ListBackupsHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/backups", h.CreateBackupHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/jobs", h.ListJobsHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/reminders", h.ListRemindersHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/webhooks", h.ListWebhooksHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/webhooks", h.CreateWebhookHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/webhooks/{id}", h.DeleteWebhookHandler).Methods("DELETE", "OPTIONS")
+	apiRouter.HandleFunc("/webhooks/{id}/deliveries", h.ListWebhookDeliveriesHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/tags", h.ListTagsHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/tags", h.CreateTagHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/tags/{id}", h.UpdateTagHandler).Methods("PUT", "OPTIONS")
+	apiRouter.HandleFunc("/tags/{id}", h.DeleteTagHandler).Methods("DELETE", "OPTIONS")
+	apiRouter.HandleFunc("/lists", h.ListListsHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/lists", h.CreateListHandler).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/lists/{id}", h.UpdateListHandler).Methods("PUT", "OPTIONS")
+	apiRouter.HandleFunc("/lists/{id}", h.DeleteListHandler).Methods("DELETE", "OPTIONS")
+	apiRouter.HandleFunc("/calendar.ics", h.CalendarICSHandler).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/import_ics", h.ImportICSHandler).Methods("POST", "OPTIONS")
 
 	// --- ADDED: Endpoint for checking recurring tasks ---
-	router.HandleFunc("/api/check_recurring_tasks", api.CheckRecurringTasksHandler).Methods("POST", "OPTIONS") // Or GET
+	apiRouter.HandleFunc("/check_recurring_tasks", h.CheckRecurringTasksHandler).Methods("POST", "OPTIONS") // Or GET
 
 	fsys, err := fs.Sub(staticFS, "static")
 	if err != nil {
@@ -66,3 +127,24 @@ func SetupRouter() *mux.Router {
 
 	return router
 }
+
+// setCORSHeaders allows cross-origin requests from the allowed origins. The
+// planner's own frontend is same-origin and needs none of this.
+func setCORSHeaders(w http.ResponseWriter, r *http.Request, allowed []string) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+	switch {
+	case slices.Contains(allowed, origin):
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	case slices.Contains(allowed, "*"):
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	default:
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Timezone, X-Client-ID")
+}
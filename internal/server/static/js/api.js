@@ -1,9 +1,35 @@
 const API_BASE = "/api";
 
+// The server decides what "today" is (overdue recurring tasks, search
+// ranking) in the browser's time zone rather than its own.
+const TIME_ZONE_HEADERS = {
+  "X-Timezone": Intl.DateTimeFormat().resolvedOptions().timeZone,
+};
+
+// Identifies this tab to the server, which tags the events caused by its
+// requests with it, so that the tab does not reload its own changes.
+export const CLIENT_ID = `${Date.now().toString(36)}-${Math.random()
+  .toString(36)
+  .slice(2)}`;
+
+// fetch, with the client ID header added. When the session has ended, an
+// "auth-required" event is dispatched on window so that the app asks for the
+// login again.
+async function apiFetch(url, options = {}) {
+  const response = await fetch(url, {
+    ...options,
+    headers: { ...options.headers, "X-Client-ID": CLIENT_ID },
+  });
+  if (response.status === 401) {
+    window.dispatchEvent(new Event("auth-required"));
+  }
+  return response;
+}
+
 // Fetch tasks for a specific date range
 export async function fetchTasksForWeek(startDate, endDate) {
   try {
-    const response = await fetch(
+    const response = await apiFetch(
       `${API_BASE}/tasks?start_date=${startDate}&end_date=${endDate}`,
     );
     if (!response.ok) {
@@ -16,10 +42,11 @@ export async function fetchTasksForWeek(startDate, endDate) {
   }
 }
 
-// Fetch tasks for the inbox
-export async function fetchInboxTasks() {
+// Fetch the undated tasks of a list, the default list (the inbox) if none
+export async function fetchInboxTasks(listId = null) {
   try {
-    const response = await fetch(`${API_BASE}/tasks?date=inbox`);
+    const list = listId ? `&list=${listId}` : "";
+    const response = await apiFetch(`${API_BASE}/tasks?date=inbox${list}`);
     if (!response.ok) {
       throw new Error(`HTTP error! status: ${response.status}`);
     }
@@ -33,7 +60,7 @@ export async function fetchInboxTasks() {
 // Fetch the inbox title
 export async function fetchInboxTitle() {
   try {
-    const response = await fetch(`${API_BASE}/inbox_title`);
+    const response = await apiFetch(`${API_BASE}/inbox_title`);
     if (!response.ok) {
       throw new Error(
         `HTTP error! status: ${response.status} fetching inbox title.`,
@@ -50,7 +77,7 @@ export async function fetchInboxTitle() {
 // Create a new task
 export async function createTask(taskData) {
   try {
-    const response = await fetch(`${API_BASE}/tasks`, {
+    const response = await apiFetch(`${API_BASE}/tasks`, {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
@@ -73,7 +100,7 @@ export async function createTask(taskData) {
 // Fetch a single task by ID
 export async function fetchTaskDetails(taskId) {
   try {
-    const response = await fetch(`${API_BASE}/tasks/${taskId}`);
+    const response = await apiFetch(`${API_BASE}/tasks/${taskId}`);
     if (!response.ok) {
       throw new Error(`HTTP error! status: ${response.status}`);
     }
@@ -84,10 +111,17 @@ export async function fetchTaskDetails(taskId) {
   }
 }
 
-// Update a task
-export async function updateTask(taskId, updates) {
+// Builds the ?scope= query for requests on an occurrence of a series.
+function scopeQuery(scope) {
+  return scope ? `?scope=${encodeURIComponent(scope)}` : "";
+}
+
+// Update a task. For an occurrence of a recurring series the scope picks
+// "this", "following" or "all" occurrences; the server decides if empty.
+export async function updateTask(taskId, updates, scope = "") {
   try {
-    const response = await fetch(`${API_BASE}/tasks/${taskId}`, {
+    const url = `${API_BASE}/tasks/${taskId}${scopeQuery(scope)}`;
+    const response = await apiFetch(url, {
       method: "PUT",
       headers: {
         "Content-Type": "application/json",
@@ -107,10 +141,11 @@ export async function updateTask(taskId, updates) {
   }
 }
 
-// Delete a task
-export async function deleteTask(taskId) {
+// Delete a task, or the occurrences of its series selected by scope
+export async function deleteTask(taskId, scope = "") {
   try {
-    const response = await fetch(`${API_BASE}/tasks/${taskId}`, {
+    const url = `${API_BASE}/tasks/${taskId}${scopeQuery(scope)}`;
+    const response = await apiFetch(url, {
       method: "DELETE",
     });
     if (!response.ok) {
@@ -126,7 +161,7 @@ export async function deleteTask(taskId) {
 // Update the order of multiple tasks
 export async function updateTaskOrder(updates) {
   try {
-    const response = await fetch(`${API_BASE}/tasks/bulk_update_order`, {
+    const response = await apiFetch(`${API_BASE}/tasks/bulk_update_order`, {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
@@ -143,10 +178,113 @@ export async function updateTaskOrder(updates) {
   }
 }
 
+// Fetch the subtasks of a task
+export async function fetchSubtasks(taskId) {
+  try {
+    const response = await apiFetch(`${API_BASE}/tasks/${taskId}/subtasks`);
+    if (!response.ok) {
+      throw new Error(`HTTP error! status: ${response.status}`);
+    }
+    return await response.json();
+  } catch (error) {
+    console.error("Could not fetch subtasks:", error);
+    return [];
+  }
+}
+
+// Add a subtask to a task
+export async function createSubtask(taskId, subtaskData) {
+  try {
+    const response = await apiFetch(`${API_BASE}/tasks/${taskId}/subtasks`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify(subtaskData),
+    });
+    if (!response.ok) {
+      const errorText = await response.text();
+      throw new Error(
+        `HTTP error! status: ${response.status}, error: ${errorText}`,
+      );
+    }
+    return await response.json();
+  } catch (error) {
+    console.error("Error creating subtask:", error);
+    return null;
+  }
+}
+
+// Update a subtask
+export async function updateSubtask(taskId, subtaskId, updates) {
+  try {
+    const response = await apiFetch(
+      `${API_BASE}/tasks/${taskId}/subtasks/${subtaskId}`,
+      {
+        method: "PUT",
+        headers: {
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify(updates),
+      },
+    );
+    if (!response.ok) {
+      throw new Error(`HTTP error! status: ${response.status}`);
+    }
+    return true;
+  } catch (error) {
+    console.error("Error updating subtask:", error);
+    return false;
+  }
+}
+
+// Delete a subtask
+export async function deleteSubtask(taskId, subtaskId) {
+  try {
+    const response = await apiFetch(
+      `${API_BASE}/tasks/${taskId}/subtasks/${subtaskId}`,
+      {
+        method: "DELETE",
+      },
+    );
+    if (!response.ok) {
+      throw new Error(`HTTP error! status: ${response.status}`);
+    }
+    return true;
+  } catch (error) {
+    console.error("Error deleting subtask:", error);
+    return false;
+  }
+}
+
+// Fetch the next occurrences of a recurrence rule after a start date.
+// Returns { rule, dates } or { error } for invalid rules.
+export async function fetchRecurrencePreview(rule, interval, startDate) {
+  try {
+    const params = new URLSearchParams({
+      rule,
+      interval,
+      start: startDate,
+      count: 1,
+    });
+    const response = await apiFetch(`${API_BASE}/recurrence_preview?${params}`);
+    const data = await response.json();
+    if (!response.ok) {
+      return {
+        error: data.message || `HTTP error! status: ${response.status}`,
+      };
+    }
+    return data;
+  } catch (error) {
+    console.error("Error fetching recurrence preview:", error);
+    return { error: error.message };
+  }
+}
+
 // Save the inbox title
 export async function saveInboxTitle(newTitle) {
   try {
-    const response = await fetch(`${API_BASE}/inbox_title`, {
+    const response = await apiFetch(`${API_BASE}/inbox_title`, {
       method: "PUT",
       headers: {
         "Content-Type": "application/json",
@@ -166,11 +304,56 @@ export async function saveInboxTitle(newTitle) {
   }
 }
 
+// Fetch all lists by order; the default one is the inbox
+export async function fetchLists() {
+  try {
+    const response = await apiFetch(`${API_BASE}/lists`);
+    if (!response.ok) {
+      throw new Error(`HTTP error! status: ${response.status}`);
+    }
+    return await response.json();
+  } catch (error) {
+    console.error("Could not fetch lists:", error);
+    return [];
+  }
+}
+
+// Create a list: { title, color, order }
+export async function createList(listData) {
+  const response = await apiFetch(`${API_BASE}/lists`, {
+    method: "POST",
+    headers: {
+      "Content-Type": "application/json",
+    },
+    body: JSON.stringify(listData),
+  });
+  if (!response.ok) {
+    throw new Error(`HTTP error! status: ${response.status}`);
+  }
+  return await response.json();
+}
+
+// Update the title, color or order of a list
+export async function updateList(listId, updates) {
+  const response = await apiFetch(`${API_BASE}/lists/${listId}`, {
+    method: "PUT",
+    headers: {
+      "Content-Type": "application/json",
+    },
+    body: JSON.stringify(updates),
+  });
+  if (!response.ok) {
+    throw new Error(`HTTP error! status: ${response.status}`);
+  }
+  return await response.json();
+}
+
 // searchTasks performs a fuzzy search for tasks with pagination.
 export async function searchTasks(query, pageSize, page) {
   try {
-    const response = await fetch(
+    const response = await apiFetch(
       `${API_BASE}/search_tasks?query=${encodeURIComponent(query)}&pageSize=${pageSize}&page=${page}`,
+      { headers: TIME_ZONE_HEADERS },
     );
     if (!response.ok) {
       throw new Error(`HTTP error! Status: ${response.status}`);
@@ -189,8 +372,9 @@ export async function searchTasks(query, pageSize, page) {
  */
 export async function checkRecurringTasks() {
   try {
-    const response = await fetch(`${API_BASE}/check_recurring_tasks`, {
+    const response = await apiFetch(`${API_BASE}/check_recurring_tasks`, {
       method: "POST",
+      headers: TIME_ZONE_HEADERS,
     });
     if (!response.ok) {
       throw new Error(`HTTP error! status: ${response.status}`);
@@ -214,3 +398,57 @@ export async function fetchTodayTasks() {
     return [];
   }
 }
+
+// Fetch the reminders the server fired after the one with ID afterId
+export async function fetchReminders(afterId = 0) {
+  try {
+    const response = await apiFetch(`${API_BASE}/reminders?after=${afterId}`);
+    if (!response.ok) {
+      throw new Error(`HTTP error! status: ${response.status}`);
+    }
+    return await response.json();
+  } catch (error) {
+    console.error("Could not fetch reminders:", error);
+    return [];
+  }
+}
+
+// Fetch whether the server requires a login, and whether this browser has one
+export async function fetchSession() {
+  try {
+    const response = await fetch(`${API_BASE}/session`);
+    if (!response.ok) {
+      throw new Error(`HTTP error! status: ${response.status}`);
+    }
+    return await response.json();
+  } catch (error) {
+    console.error("Could not fetch session:", error);
+    return { auth_required: false, authenticated: true };
+  }
+}
+
+// Log in with {username, password} or {token}; returns whether it succeeded
+export async function login(credentials) {
+  try {
+    const response = await fetch(`${API_BASE}/login`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(credentials),
+    });
+    return response.ok;
+  } catch (error) {
+    console.error("Could not log in:", error);
+    return false;
+  }
+}
+
+// End the session of this browser
+export async function logout() {
+  try {
+    const response = await fetch(`${API_BASE}/logout`, { method: "POST" });
+    return response.ok;
+  } catch (error) {
+    console.error("Could not log out:", error);
+    return false;
+  }
+}
@@ -2,6 +2,9 @@ import * as api from "./api.js";
 import * as calendar from "./calendar.js";
 import * as tasks from "./tasks.js";
 import * as ui from "./ui.js";
+import { checkReminders, startReminders } from "./reminders.js";
+import { startEvents } from "./events.js";
+import { requireLogin } from "./login.js";
 import * as utils from "./utils.js";
 import { loadLanguage, translations } from "./localization.js";
 import { dayIds, TASK_COLORS, initialWrapTaskTitles } from "./config.js";
@@ -48,11 +51,19 @@ async function checkAndRefreshTasks() {
   }
 }
 
+// Reload everything shown, after another client changed tasks
+async function reloadTasks() {
+  await calendar.renderWeekCalendar(getDisplayedWeekStartDateInternal());
+  await calendar.renderInbox();
+  ui.updateTabTitle();
+}
+
 // Initialization function
 async function initialize() {
   await loadLanguage();
   ui.updateSettingsLanguageSelector(localStorage.getItem("language") || "ru");
   ui.setTheme(currentTheme);
+  await requireLogin(); // Log in first if the server requires it
   requestAnimationFrame(ui.updateSelectArrowsColor); // Update select arrows after theme
   await calendar.renderWeekCalendar(getDisplayedWeekStartDateInternal());
   await calendar.renderInbox();
@@ -77,6 +88,8 @@ async function initialize() {
 
   ui.updateTabTitle(); // Initial title/favicon set
   await checkAndRefreshTasks(); // Initial check on load
+  startReminders(); // Poll for reminders fired by the server
+  startEvents(reloadTasks, checkReminders); // Follow changes made elsewhere
 
   if (!initialTaskLinkHandled) {
     initialTaskLinkHandled = true;
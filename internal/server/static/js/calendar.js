@@ -13,6 +13,7 @@ const dayElements = dayIds.reduce((acc, id) => {
 const inboxDiv = document.getElementById("inbox");
 let inboxHeaderElement = null;
 let isEditingInboxTitle = false;
+let inboxList = null; // The list the inbox shows, the default one at first.
 
 // Define the drop handler OUTSIDE of renderWeekCalendar and renderInbox,
 // and accept todayTasks and update function as parameters.
@@ -74,15 +75,29 @@ export async function renderWeekCalendar(date) {
     const dayDateString = dates[index].toLocaleDateString("en-CA");
 
     dayDiv.dataset.date = dayDateString;
-    dayDiv.addEventListener("dragover", tasks.allowDrop);
-    // Pass todayTasks and the update function to the handler
-    dayDiv.addEventListener("drop", (event) =>
-      handleDayDrop(event, ui.todayTasks, async (newTasks) => {
-        await ui.refreshTodayTasks(); // Use the existing function
-        ui.updateTabTitle();
-      }),
-    );
-    dayDiv.addEventListener("dragleave", tasks.handleDragLeave);
+    // The day elements outlive a render (the week is re-rendered whenever
+    // another client changes tasks): listen on them only once.
+    if (!dayDiv.dataset.listening) {
+      dayDiv.dataset.listening = "true";
+      dayDiv.addEventListener("dragover", tasks.allowDrop);
+      // Pass todayTasks and the update function to the handler
+      dayDiv.addEventListener("drop", (event) =>
+        handleDayDrop(event, ui.todayTasks, async (newTasks) => {
+          await ui.refreshTodayTasks(); // Use the existing function
+          ui.updateTabTitle();
+        }),
+      );
+      dayDiv.addEventListener("dragleave", tasks.handleDragLeave);
+      dayDiv.addEventListener("click", (event) => {
+        if (
+          event.target === dayDiv ||
+          (!event.target.closest(".event") &&
+            !event.target.closest(".day-header"))
+        ) {
+          dayDiv.querySelector(".new-task-form input")?.focus();
+        }
+      });
+    }
 
     const dayHeaderDiv = document.createElement("div");
     dayHeaderDiv.classList.add("day-header");
@@ -169,23 +184,78 @@ export async function renderWeekCalendar(date) {
     newTaskForm.addEventListener("submit", addTaskHandler);
     newTaskInput.addEventListener("keydown", addTaskHandler);
     newTaskInput.addEventListener("blur", addTaskHandler);
-    dayDiv.addEventListener("click", (event) => {
-      if (
-        event.target === dayDiv ||
-        (!event.target.closest(".event") &&
-          !event.target.closest(".day-header"))
-      ) {
-        newTaskForm.querySelector("input").focus();
-      }
-    });
   }
   ui.updateTabTitle();
 }
 
+// Returns the list the inbox shows: the one picked last, if it still exists,
+// or else the default list.
+async function currentInboxList() {
+  const lists = await api.fetchLists();
+  const pickedId = Number(localStorage.getItem("inboxList"));
+  const list =
+    lists.find((l) => l.id === pickedId) || lists.find((l) => l.default);
+  return { lists, list };
+}
+
+// Builds the list picker of the inbox header; picking "new list" asks for a
+// title and creates it.
+function createListSelect(lists, current) {
+  const lang = localStorage.getItem("language") || "ru";
+  const select = document.createElement("select");
+  select.classList.add("themed-select", "inbox-list-select");
+  select.title = translations[lang].lists;
+  lists.forEach((list) => {
+    const option = document.createElement("option");
+    option.value = list.id;
+    option.textContent = list.task_count
+      ? `${list.title} (${list.task_count})`
+      : list.title;
+    option.selected = current && list.id === current.id;
+    select.appendChild(option);
+  });
+  const newOption = document.createElement("option");
+  newOption.value = "new";
+  newOption.textContent = translations[lang].newList;
+  select.appendChild(newOption);
+
+  select.addEventListener("change", async () => {
+    let listId = select.value;
+    if (listId === "new") {
+      const title = prompt(translations[lang].newListPrompt);
+      if (!title || !title.trim()) {
+        select.value = current ? current.id : "";
+        return;
+      }
+      try {
+        const created = await api.createList({
+          title: title.trim(),
+          order: lists.length,
+        });
+        listId = created.id;
+      } catch (error) {
+        console.error("Error creating list:", error);
+        select.value = current ? current.id : "";
+        return;
+      }
+    }
+    localStorage.setItem("inboxList", listId);
+    await renderInbox();
+  });
+  return select;
+}
+
 export async function renderInbox() {
   const lang = localStorage.getItem("language") || "ru";
-  const inboxTitle = await api.fetchInboxTitle();
+  const { lists, list } = await currentInboxList();
+  inboxList = list || null;
+  const inboxTitle = inboxList ? inboxList.title : await api.fetchInboxTitle();
   inboxDiv.innerHTML = "";
+  if (inboxList) {
+    inboxDiv.dataset.listId = inboxList.id;
+  } else {
+    delete inboxDiv.dataset.listId;
+  }
   inboxDiv.style.backgroundColor = document.body.classList.contains(
     "dark-theme",
   )
@@ -195,10 +265,16 @@ export async function renderInbox() {
   const headerDiv = document.createElement("div");
   headerDiv.classList.add("inbox-header");
   headerDiv.style.textAlign = "left";
-  headerDiv.textContent = inboxTitle;
+  const titleSpan = document.createElement("span");
+  titleSpan.classList.add("inbox-title");
+  titleSpan.textContent = inboxTitle;
+  headerDiv.appendChild(titleSpan);
+  if (lists.length > 0) {
+    headerDiv.appendChild(createListSelect(lists, inboxList));
+  }
   inboxDiv.appendChild(headerDiv);
 
-  inboxHeaderElement = headerDiv;
+  inboxHeaderElement = titleSpan;
 
   inboxHeaderElement.addEventListener("click", () => {
     if (!isEditingInboxTitle) {
@@ -206,17 +282,20 @@ export async function renderInbox() {
     }
   });
 
-  inboxDiv.addEventListener("dragover", tasks.allowDrop);
-  // Pass todayTasks and the update function to the handler
-  inboxDiv.addEventListener("drop", (event) =>
-    handleDayDrop(event, ui.todayTasks, async (newTasks) => {
-      await ui.refreshTodayTasks();
-      ui.updateTabTitle();
-    }),
-  );
-  inboxDiv.addEventListener("dragleave", tasks.handleDragLeave);
-
-  const inboxTasks = await api.fetchInboxTasks();
+  if (!inboxDiv.dataset.listening) {
+    inboxDiv.dataset.listening = "true";
+    inboxDiv.addEventListener("dragover", tasks.allowDrop);
+    // Pass todayTasks and the update function to the handler
+    inboxDiv.addEventListener("drop", (event) =>
+      handleDayDrop(event, ui.todayTasks, async (newTasks) => {
+        await ui.refreshTodayTasks();
+        ui.updateTabTitle();
+      }),
+    );
+    inboxDiv.addEventListener("dragleave", tasks.handleDragLeave);
+  }
+
+  const inboxTasks = await api.fetchInboxTasks(inboxList && inboxList.id);
   inboxTasks.sort((a, b) => a.order - b.order); // Ensure inbox tasks are sorted
   const taskContainer = document.createElement("div");
   // Initially hide the container, make visible after rendering
@@ -243,6 +322,7 @@ export async function renderInbox() {
         const taskData = {
           title: inboxInputElement.value.trim(),
           due_date: null, // No due date for inbox tasks
+          list_id: inboxList ? inboxList.id : null,
           order: taskContainer.children.length, // Append at the end
           color: "",
           description: "",
@@ -301,8 +381,13 @@ function makeInboxTitleEditable() {
 
     if (newTitle !== currentTitle) {
       try {
-        await api.saveInboxTitle(newTitle);
+        if (inboxList && !inboxList.default) {
+          await api.updateList(inboxList.id, { title: newTitle });
+        } else {
+          await api.saveInboxTitle(newTitle);
+        }
         inboxHeaderElement.textContent = newTitle;
+        await renderInbox(); // Retitles the list in the picker too.
       } catch (error) {
         console.error("Error saving inbox title", error);
         inboxHeaderElement.textContent = currentTitle;
@@ -0,0 +1,63 @@
+import { CLIENT_ID } from "./api.js";
+
+// The server streams the changes made by every client. When another tab or
+// device changes tasks, this one reloads what it shows. Changes come in
+// bursts (completing a recurring task also creates its next occurrence), so
+// reloads are debounced, and they wait while the user is dragging or has
+// typed something that a reload would throw away.
+const RELOAD_DELAY_MS = 300;
+const CHANGE_EVENTS = [
+  "task.created",
+  "task.updated",
+  "task.deleted",
+  "tasks.reordered",
+  "tasks.reloaded",
+  "settings.changed",
+  "lists.changed",
+];
+
+let reloadTimeoutId = null;
+let dragging = false;
+
+function isBusy() {
+  if (dragging) return true;
+  const active = document.activeElement;
+  if (!active) return false;
+  if (active.isContentEditable) return true;
+  return active.matches("input, textarea") && active.value !== "";
+}
+
+function scheduleReload(reload) {
+  clearTimeout(reloadTimeoutId);
+  reloadTimeoutId = setTimeout(() => {
+    if (isBusy()) {
+      scheduleReload(reload);
+      return;
+    }
+    reload();
+  }, RELOAD_DELAY_MS);
+}
+
+// Listens to the server's events: reload is called after changes made
+// elsewhere, onReminder when a reminder fires. Events are not replayed, so
+// everything is reloaded after reconnecting as well.
+export function startEvents(reload, onReminder) {
+  if (!("EventSource" in window)) return;
+  document.addEventListener("dragstart", () => (dragging = true));
+  document.addEventListener("dragend", () => (dragging = false));
+
+  const source = new EventSource("/api/events");
+  CHANGE_EVENTS.forEach((type) =>
+    source.addEventListener(type, (message) => {
+      const event = JSON.parse(message.data);
+      if (event.source !== CLIENT_ID) scheduleReload(reload);
+    }),
+  );
+  source.addEventListener("reminder.fired", onReminder);
+
+  let connected = false;
+  source.addEventListener("open", () => {
+    if (connected) scheduleReload(reload);
+    connected = true;
+  });
+}
@@ -29,6 +29,9 @@ export const translations = {
     // Placeholders & Basic UI
     newTask: "New task...",
     newTaskSomeday: "New task for inbox...",
+    lists: "Lists",
+    newList: "+ New list...",
+    newListPrompt: "Title of the new list:",
     searchPlaceholder: "Search tasks...",
     noResults: "No matching tasks found.",
     close: "Close",
@@ -36,6 +39,8 @@ export const translations = {
     noTodayTasks: "No tasks for today",
     pickADate: "Pick a date",
     description: "Description",
+    newSubtask: "Add a subtask...",
+    deleteSubtask: "Delete subtask",
 
     // Settings
     settings: "Settings",
@@ -52,6 +57,12 @@ export const translations = {
     exportDatabase: "Export Database",
     importDatabase: "Import Database",
     exportDatabaseTitle: "Export Database",
+    logOutTitle: "Log Out",
+    logIn: "Log In",
+    username: "Username",
+    password: "Password",
+    token: "Token",
+    loginFailed: "Invalid credentials.",
     importDatabaseTitle: "Import Database",
 
     // Calendar Navigation
@@ -88,7 +99,24 @@ export const translations = {
     recurrenceMonths: "Months",
     recurrenceYears: "Years",
     recurrenceNext: "Next:",
+    recurrenceCustom: "Custom rule",
+    recurrenceEnded: "No further occurrences",
+    recurrenceInvalid: "Invalid rule:",
     recurrenceRemove: "Remove recurrence",
+    seriesScope: "Apply changes to",
+    seriesScopeThis: "This occurrence",
+    seriesScopeFollowing: "This and following",
+    seriesScopeAll: "All occurrences",
+    dueTime: "Time",
+    durationMinutes: "Minutes",
+    remind: "Remind",
+    remindNever: "Never",
+    remindAtStart: "At start",
+    remind5Minutes: "5 minutes before",
+    remind15Minutes: "15 minutes before",
+    remind1Hour: "1 hour before",
+    remind1Day: "1 day before",
+    failedToSaveReminder: "Failed to save time or reminder",
 
     // Snackbar Messages & Undo
     taskLinkCopied: "Task link copied",
@@ -102,6 +130,7 @@ export const translations = {
     failedToUpdateTaskStatus: "Failed to update task status.",
     errorLoadingTaskDetails: "Error loading task details.",
     failedToSaveColor: "Failed to save color.",
+    failedToSaveSubtask: "Failed to save subtask.",
     failedToUpdateDate: "Failed to update task date.",
     failedToSaveRecurrence: "Failed to save recurrence settings.",
     recurrenceRemoved: "Recurrence removed.",
@@ -144,6 +173,9 @@ export const translations = {
     // Placeholders & Basic UI
     newTask: "Новая задача...",
     newTaskSomeday: "Новая задача на когда-нибудь...",
+    lists: "Списки",
+    newList: "+ Новый список...",
+    newListPrompt: "Название нового списка:",
     searchPlaceholder: "Поиск задач...",
     noResults: "Задачи не найдены.",
     close: "Закрыть",
@@ -151,6 +183,8 @@ export const translations = {
     noTodayTasks: "Нет задач на сегодня",
     pickADate: "Выбрать дату",
     description: "Описание",
+    newSubtask: "Добавить подзадачу...",
+    deleteSubtask: "Удалить подзадачу",
 
     // Settings
     settings: "Настройки",
@@ -167,6 +201,12 @@ export const translations = {
     exportDatabase: "Экспорт базы данных",
     importDatabase: "Импорт базы данных",
     exportDatabaseTitle: "Экспорт базы данных",
+    logOutTitle: "Выйти",
+    logIn: "Войти",
+    username: "Имя пользователя",
+    password: "Пароль",
+    token: "Токен",
+    loginFailed: "Неверные учётные данные.",
     importDatabaseTitle: "Импорт базы данных",
 
     // Calendar Navigation
@@ -203,7 +243,24 @@ export const translations = {
     recurrenceMonths: "мес.",
     recurrenceYears: "г./лет",
     recurrenceNext: "След.:",
+    recurrenceCustom: "Своё правило",
+    recurrenceEnded: "Больше повторений нет",
+    recurrenceInvalid: "Неверное правило:",
     recurrenceRemove: "Убрать повторение",
+    seriesScope: "Применить к",
+    seriesScopeThis: "Этому повторению",
+    seriesScopeFollowing: "Этому и следующим",
+    seriesScopeAll: "Всем повторениям",
+    dueTime: "Время",
+    durationMinutes: "Минут",
+    remind: "Напомнить",
+    remindNever: "Никогда",
+    remindAtStart: "В начале",
+    remind5Minutes: "За 5 минут",
+    remind15Minutes: "За 15 минут",
+    remind1Hour: "За 1 час",
+    remind1Day: "За 1 день",
+    failedToSaveReminder: "Не удалось сохранить время или напоминание",
 
     // Snackbar Messages & Undo
     taskLinkCopied: "Ссылка на задачу скопирована",
@@ -217,6 +274,7 @@ export const translations = {
     failedToUpdateTaskStatus: "Не удалось обновить статус задачи.",
     errorLoadingTaskDetails: "Ошибка загрузки сведений о задаче.",
     failedToSaveColor: "Не удалось сохранить цвет.",
+    failedToSaveSubtask: "Не удалось сохранить подзадачу.",
     failedToUpdateDate: "Не удалось обновить дату задачи.",
     failedToSaveRecurrence: "Не удалось сохранить настройки повторения.",
     recurrenceRemoved: "Повторение удалено.",
@@ -0,0 +1,70 @@
+import * as api from "./api.js";
+import { translations } from "./localization.js";
+
+// When the server is started with AUTH_TOKEN or AUTH_USER and AUTH_PASSWORD,
+// the API answers 401 until this browser logs in. The login sets a session
+// cookie, so the page itself needs no credentials.
+const loginPopup = document.getElementById("login-popup");
+const loginForm = document.getElementById("login-form");
+const usernameInput = document.getElementById("login-username");
+const passwordInput = document.getElementById("login-password");
+const loginError = document.getElementById("login-error");
+const logoutBtn = document.getElementById("logout-btn");
+
+let loginPromise = null;
+
+function translate(key) {
+  const lang = localStorage.getItem("language") || "ru";
+  return translations[lang]?.[key] || key;
+}
+
+// Shows the login and resolves once it succeeded. With only a token
+// configured, the password field takes the token.
+function showLogin(session) {
+  if (loginPromise) return loginPromise;
+  const tokenOnly = session.token_login && !session.password_login;
+  usernameInput.style.display = tokenOnly ? "none" : "";
+  passwordInput.placeholder = translate(tokenOnly ? "token" : "password");
+  loginError.textContent = "";
+  loginPopup.style.display = "flex";
+  (tokenOnly ? passwordInput : usernameInput).focus();
+
+  loginPromise = new Promise((resolve) => {
+    loginForm.onsubmit = async (event) => {
+      event.preventDefault();
+      const credentials = tokenOnly
+        ? { token: passwordInput.value }
+        : { username: usernameInput.value, password: passwordInput.value };
+      if (!(await api.login(credentials))) {
+        loginError.textContent = translate("loginFailed");
+        passwordInput.select();
+        return;
+      }
+      passwordInput.value = "";
+      loginPopup.style.display = "none";
+      loginPromise = null;
+      resolve();
+    };
+  });
+  return loginPromise;
+}
+
+// Resolves once the API is usable, after logging in if the server requires
+// it. Sessions ending later on bring the login back, then reload the page.
+export async function requireLogin() {
+  const session = await api.fetchSession();
+  if (logoutBtn && session.auth_required) {
+    logoutBtn.style.display = "";
+    logoutBtn.addEventListener("click", async () => {
+      await api.logout();
+      window.location.reload();
+    });
+  }
+  if (session.auth_required && !session.authenticated) {
+    await showLogin(session);
+  }
+  window.addEventListener("auth-required", async () => {
+    await showLogin(await api.fetchSession());
+    window.location.reload();
+  });
+}
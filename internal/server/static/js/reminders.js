@@ -0,0 +1,52 @@
+import * as api from "./api.js";
+import { showSnackbar } from "./ui.js";
+
+// The server fires reminders and records them; every open tab polls for
+// new ones and shows them. Notifications are tagged with the reminder ID so
+// that the browser shows a reminder once even with several tabs open.
+const POLL_INTERVAL_MS = 30 * 1000;
+const LAST_REMINDER_KEY = "lastReminderId";
+
+let pollTimeoutId = null;
+
+// Asks for permission to show notifications. Browsers only allow asking
+// from a user action, such as setting a reminder.
+export function requestNotificationPermission() {
+  if ("Notification" in window && Notification.permission === "default") {
+    Notification.requestPermission();
+  }
+}
+
+function showReminder(reminder) {
+  if ("Notification" in window && Notification.permission === "granted") {
+    new Notification(reminder.title, { tag: `reminder-${reminder.id}` });
+    return;
+  }
+  showSnackbar(`🔔 ${reminder.title}`, false, 10000);
+}
+
+async function pollReminders() {
+  clearTimeout(pollTimeoutId);
+  const stored = localStorage.getItem(LAST_REMINDER_KEY);
+  let lastId = Number(stored) || 0;
+  let reminders;
+  do {
+    reminders = await api.fetchReminders(lastId);
+    // On the first run only catch up: the reminders fired before this
+    // browser ever looked are not shown in bulk.
+    if (stored !== null) reminders.forEach(showReminder);
+    if (reminders.length > 0) lastId = reminders[reminders.length - 1].id;
+  } while (stored === null && reminders.length > 0);
+  localStorage.setItem(LAST_REMINDER_KEY, lastId);
+  pollTimeoutId = setTimeout(pollReminders, POLL_INTERVAL_MS);
+}
+
+// Starts polling for reminders.
+export function startReminders() {
+  pollReminders();
+}
+
+// Checks for new reminders now, e.g. when the server announces one.
+export function checkReminders() {
+  pollReminders();
+}
@@ -30,6 +30,14 @@ export async function createTaskElement(task) {
     const eventContent = document.createElement("div");
     eventContent.classList.add("event-content");
 
+    // Tasks are dated; the optional time of day goes before the title.
+    if (task.due_time) {
+      const timeElement = document.createElement("span");
+      timeElement.classList.add("task-time");
+      timeElement.textContent = task.due_time;
+      eventContent.appendChild(timeElement);
+    }
+
     const taskTextElement = document.createElement("span");
     taskTextElement.classList.add("task-text");
     taskTextElement.style.flexGrow = "1";
@@ -37,29 +45,26 @@ export async function createTaskElement(task) {
     taskTextElement.classList.toggle("completed", task.completed === 1);
     taskTextElement.classList.toggle("wrap", wrapTaskTitles);
     taskTextElement.classList.toggle("no-wrap", !wrapTaskTitles);
+    // Tags follow the title, as they were typed when adding the task.
+    if (task.tags?.length) {
+      const tagsElement = document.createElement("span");
+      tagsElement.classList.add("task-tags");
+      tagsElement.textContent = task.tags.map((tag) => `#${tag}`).join(" ");
+      taskTextElement.appendChild(tagsElement);
+    }
     eventContent.appendChild(taskTextElement);
 
-    // Description/Progress Icon Logic
-    if (task.description) {
-      const checkboxRegex = /^- \[([x ])\]/gm;
-      const matches = task.description.match(checkboxRegex);
-      if (matches && matches.length <= 9) {
-        const total = matches.length;
-        const checked = (task.description.match(/^- \[x\]/gm) || []).length;
-        const progressElement = document.createElement("span");
-        progressElement.classList.add("task-progress");
-        progressElement.textContent = `${checked}/${total}`;
-        eventContent.appendChild(progressElement);
-      } else {
-        const descriptionIcon = document.createElement("i");
-        descriptionIcon.classList.add(
-          "fas",
-          "fa-sticky-note",
-          "description-icon",
-        );
-        descriptionIcon.title = "This task has a description";
-        eventContent.appendChild(descriptionIcon);
-      }
+    // Subtask progress, or an icon for tasks with a description.
+    if (task.subtasks_total > 0) {
+      const progressElement = document.createElement("span");
+      progressElement.classList.add("task-progress");
+      progressElement.textContent = `${task.subtasks_completed}/${task.subtasks_total}`;
+      eventContent.appendChild(progressElement);
+    } else if (task.description) {
+      const descriptionIcon = document.createElement("i");
+      descriptionIcon.classList.add("fas", "fa-sticky-note", "description-icon");
+      descriptionIcon.title = "This task has a description";
+      eventContent.appendChild(descriptionIcon);
     }
 
     const rightActionButtons = document.createElement("div");
@@ -296,6 +301,10 @@ export async function handleDrop(event, todayTasks, updateTodayTasks) {
       updates.recurrence_rule = "";
       updates.recurrence_interval = 1;
       recurrenceCleared = true;
+      // Dropped on the inbox, the task joins the list it shows.
+      if (inboxDiv && inboxDiv.dataset.listId) {
+        updates.list_id = Number(inboxDiv.dataset.listId);
+      }
       console.log(`Task ${taskId} moved to Inbox. Clearing recurrence.`);
     }
 
@@ -9,6 +9,7 @@ import {
 } from "./localization.js";
 import { TASK_COLORS, initialWrapTaskTitles } from "./config.js";
 import { setDisplayedWeekStartDate, getDisplayedWeekStartDate } from "./app.js";
+import { requestNotificationPermission } from "./reminders.js";
 
 // --- DOM Element References ---
 const settingsPopup = document.getElementById("settings-popup");
@@ -40,6 +41,9 @@ const markDoneTaskDetailsBtn = document.getElementById(
   "mark-done-task-details",
 );
 const copyTaskLinkBtn = document.getElementById("copy-task-link-btn");
+const subtasksContainer = document.getElementById("subtasks-container");
+const subtasksList = document.getElementById("subtasks-list");
+const newSubtaskInput = document.getElementById("new-subtask-input");
 const snackbar = document.getElementById("snackbar");
 const snackbarText = document.createElement("span");
 const snackbarUndoButton = document.createElement("button");
@@ -57,6 +61,17 @@ const recurrenceIntervalInput = document.getElementById(
   "recurrence-interval-input",
 );
 const recurrencePreview = document.getElementById("recurrence-preview");
+const recurrenceRRuleInput = document.getElementById("recurrence-rrule-input");
+const seriesScopeContainer = document.getElementById("series-scope-container");
+const reminderTaskDetailsBtn = document.getElementById("reminder-task-details");
+const reminderSettingsContainer = document.getElementById(
+  "reminder-settings-container",
+);
+const taskTimeInput = document.getElementById("task-time-input");
+const taskDurationInput = document.getElementById("task-duration-input");
+const taskRemindSelect = document.getElementById("task-remind-select");
+const seriesScopeSelect = document.getElementById("series-scope-select");
+const SIMPLE_RECURRENCE_RULES = ["daily", "weekly", "monthly", "yearly"];
 const exportDbBtn = document.getElementById("export-db-btn");
 const importDbInput = document.getElementById("import-db-input");
 
@@ -484,7 +499,7 @@ export async function openTaskDetails(taskId) {
   clearTimeout(deleteTimeoutId);
   if (lastDeletedTaskData?.id && lastDeletedTaskData.id !== taskId) {
     // *** Perform the final delete API call for the *other* task ***
-    await api.deleteTask(lastDeletedTaskData.id);
+    await api.deleteTask(lastDeletedTaskData.id, lastDeletedTaskData.scope);
     // Remove the *other* task's element from the DOM if it still exists
     const otherTaskElement = document.querySelector(
       `.event[data-task-id="${lastDeletedTaskData.id}"]`,
@@ -538,8 +553,10 @@ export async function openTaskDetails(taskId) {
         const newTitle = newTitleInput.value.trim();
         if (newTitle !== task.title && newTitle !== "") {
           try {
-            await api.updateTask(taskIdForListener, { title: newTitle });
+            const scope = seriesScope();
+            await api.updateTask(taskIdForListener, { title: newTitle }, scope);
             tasks.reRenderTaskElement(taskIdForListener); // Update list view
+            await refreshSeriesOccurrences(scope);
             updateFavicon(todayTasks.filter((t) => t.completed === 0).length);
           } catch (error) {
             console.error(`Error updating title:`, error);
@@ -584,11 +601,7 @@ export async function openTaskDetails(taskId) {
 
     // Update Recurrence Section
     const currentRule = task.recurrence_rule || "";
-    if (recurrencePeriodSelect)
-      recurrencePeriodSelect.value = currentRule || "daily";
-    if (recurrenceIntervalInput)
-      recurrenceIntervalInput.value =
-        task.recurrence_interval > 0 ? task.recurrence_interval : 1;
+    setRecurrenceControls(currentRule || "daily", task.recurrence_interval);
     if (recurringTaskDetailsBtn)
       recurringTaskDetailsBtn.style.display = task.due_date
         ? "inline-block"
@@ -597,6 +610,18 @@ export async function openTaskDetails(taskId) {
       recurrenceSettingsContainer.style.display = "none"; // Hide initially
     updateRecurrenceUI(currentRule && !!task.due_date ? currentRule : "");
 
+    // Update Time and Reminder Section (dated tasks only)
+    setReminderControls(task);
+    if (reminderTaskDetailsBtn)
+      reminderTaskDetailsBtn.style.display = task.due_date
+        ? "inline-block"
+        : "none";
+
+    // Occurrences of a series ask which occurrences an edit applies to
+    if (seriesScopeContainer)
+      seriesScopeContainer.style.display = task.series_id ? "block" : "none";
+    if (seriesScopeSelect) seriesScopeSelect.value = "this";
+
     // Update Description Section
     const descriptionText = task.description || "";
     if (taskDescriptionTextarea)
@@ -628,6 +653,10 @@ export async function openTaskDetails(taskId) {
         ? "fas fa-pen"
         : "fas fa-book-open";
 
+    // Update Subtasks Section
+    if (newSubtaskInput) newSubtaskInput.value = "";
+    await renderSubtasks(taskId);
+
     // Update Color Swatches (replace to clear old listeners)
     document.querySelectorAll(".color-swatch").forEach((swatch) => {
       swatch.classList.remove("selected-color");
@@ -660,6 +689,129 @@ export async function openTaskDetails(taskId) {
   }
 }
 
+// Fills the time and reminder controls of the task details popup.
+function setReminderControls(task) {
+  if (!reminderSettingsContainer || !taskRemindSelect) return;
+  reminderSettingsContainer.style.display = "none"; // Hide initially
+  if (taskTimeInput) taskTimeInput.value = task.due_time || "";
+  if (taskDurationInput) taskDurationInput.value = task.duration_minutes || "";
+  // A reminder set elsewhere (API, command line) gets an option of its own
+  taskRemindSelect.querySelector("option[data-custom]")?.remove();
+  const remindAt = task.remind_at || "";
+  if (![...taskRemindSelect.options].some((o) => o.value === remindAt)) {
+    const option = new Option(remindAt, remindAt);
+    option.dataset.custom = "true";
+    taskRemindSelect.add(option);
+  }
+  taskRemindSelect.value = remindAt;
+  updateReminderButton();
+}
+
+// Highlights the reminder button of tasks with a time or a reminder.
+function updateReminderButton() {
+  reminderTaskDetailsBtn?.classList.toggle(
+    "active",
+    !!(taskTimeInput?.value || taskRemindSelect?.value),
+  );
+}
+
+// Saves a change of the time, duration or reminder of the viewed task.
+async function saveReminderSettings(updates) {
+  const taskId = currentTaskBeingViewed;
+  if (!taskId) return;
+  try {
+    const scope = seriesScope();
+    await api.updateTask(taskId, updates, scope);
+    tasks.reRenderTaskElement(taskId);
+    await refreshSeriesOccurrences(scope);
+    updateReminderButton();
+  } catch (error) {
+    console.error(`Error saving time or reminder:`, error);
+    showSnackbar("failedToSaveReminder", true);
+  }
+}
+
+// Renders the subtasks of taskId in the task details popup.
+async function renderSubtasks(taskId) {
+  if (!subtasksList) return;
+  const subtasks = await api.fetchSubtasks(taskId);
+  if (currentTaskBeingViewed !== taskId) return; // Popup switched meanwhile
+  const lang = localStorage.getItem("language") || "ru";
+  subtasksList.innerHTML = "";
+  subtasks.forEach((subtask) => {
+    const item = document.createElement("li");
+    item.classList.add("subtask-item");
+    item.classList.toggle("completed", subtask.completed === 1);
+
+    const checkbox = document.createElement("input");
+    checkbox.type = "checkbox";
+    checkbox.checked = subtask.completed === 1;
+    checkbox.addEventListener("change", () =>
+      handleSubtaskToggle(taskId, subtask.id, checkbox.checked),
+    );
+
+    const title = document.createElement("span");
+    title.classList.add("subtask-title");
+    title.textContent = subtask.title;
+
+    const deleteButton = document.createElement("button");
+    deleteButton.classList.add("subtask-delete-button");
+    deleteButton.title = translations[lang]?.deleteSubtask || "Delete subtask";
+    deleteButton.innerHTML = '<i class="fas fa-times"></i>';
+    deleteButton.addEventListener("click", async () => {
+      if (!(await api.deleteSubtask(taskId, subtask.id))) {
+        showSnackbar("failedToSaveSubtask", true);
+        return;
+      }
+      await afterSubtaskChange(taskId);
+    });
+
+    item.append(checkbox, title, deleteButton);
+    subtasksList.appendChild(item);
+  });
+}
+
+async function handleSubtaskToggle(taskId, subtaskId, checked) {
+  const parentBefore = await api.fetchTaskDetails(taskId);
+  const ok = await api.updateSubtask(taskId, subtaskId, {
+    completed: checked ? 1 : 0,
+  });
+  if (!ok) {
+    showSnackbar("failedToSaveSubtask", true);
+    await renderSubtasks(taskId);
+    return;
+  }
+  const parent = await afterSubtaskChange(taskId);
+  // Completing the last subtask completes the parent; a recurring parent
+  // then gets its next instance, so the calendar needs a full refresh.
+  if (
+    parent?.completed === 1 &&
+    parentBefore?.completed === 0 &&
+    parent.recurrence_rule
+  ) {
+    await calendar.renderWeekCalendar(getDisplayedWeekStartDate());
+    await calendar.renderInbox();
+  }
+}
+
+// Refreshes the popup and the parent's task element after a subtask change.
+async function afterSubtaskChange(taskId) {
+  await renderSubtasks(taskId);
+  await tasks.reRenderTaskElement(taskId);
+  const parent = await api.fetchTaskDetails(taskId);
+  if (parent) {
+    if (currentTaskBeingViewed === taskId)
+      updateMarkAsDoneButton(parent.completed === 1);
+    setTodayTasks(
+      todayTasks.map((t) =>
+        t.id === taskId ? { ...t, completed: parent.completed } : t,
+      ),
+    );
+    updateTabTitle();
+  }
+  return parent;
+}
+
 async function handleColorSwatchClick(event) {
   const swatch = event.target.closest(".color-swatch");
   if (!currentTaskBeingViewed || !swatch || !swatch.matches(".color-swatch"))
@@ -692,7 +844,9 @@ async function handleColorSwatchClick(event) {
   setTodayTasks(updatedTodayTasks);
 
   try {
-    await api.updateTask(currentTaskBeingViewed, { color: colorToSave });
+    const scope = seriesScope();
+    await api.updateTask(currentTaskBeingViewed, { color: colorToSave }, scope);
+    await refreshSeriesOccurrences(scope);
   } catch (error) {
     showSnackbar("failedToSaveColor", true);
     // Revert UI on error
@@ -837,9 +991,13 @@ export function closeTaskDetailsPopup() {
   if (titleInput) titleInput.value = "";
   if (taskDescriptionTextarea) taskDescriptionTextarea.value = "";
   if (taskDescriptionRendered) taskDescriptionRendered.innerHTML = "";
+  if (subtasksList) subtasksList.innerHTML = "";
+  if (newSubtaskInput) newSubtaskInput.value = "";
   clearRecurrenceInPopup(false); // Clear UI without adjusting height
   if (recurrenceSettingsContainer)
     recurrenceSettingsContainer.style.display = "none";
+  if (reminderSettingsContainer)
+    reminderSettingsContainer.style.display = "none";
 }
 
 // Adjusts the height of the description textarea/rendered view based on available space.
@@ -861,6 +1019,11 @@ export function adjustTextareaHeight() {
     recurrenceSettingsContainer?.style.display === "none"
       ? 0
       : recurrenceSettingsContainer?.offsetHeight || 0;
+  const reminderHeight =
+    reminderSettingsContainer?.style.display === "none"
+      ? 0
+      : reminderSettingsContainer?.offsetHeight || 0;
+  const subtasksHeight = subtasksContainer?.offsetHeight || 0;
   const descLabelHeight =
     popupContent.querySelector('label[for="task-description-textarea"]')
       ?.offsetHeight || 0;
@@ -872,6 +1035,8 @@ export function adjustTextareaHeight() {
       titleHeight -
       titleMarginBottom -
       recurrenceHeight -
+      reminderHeight -
+      subtasksHeight -
       descLabelHeight -
       40, // Approx padding/margins
   );
@@ -928,6 +1093,24 @@ export function handleTaskCompletionUI(taskElement, completed) {
 
 // --- Event Listeners Setup ---
 function setupActionListeners() {
+  // Adding subtasks with Enter
+  if (newSubtaskInput) {
+    newSubtaskInput.addEventListener("keydown", async (event) => {
+      if (event.key !== "Enter") return;
+      const taskIdForUpdate = currentTaskBeingViewed;
+      const title = newSubtaskInput.value.trim();
+      if (!taskIdForUpdate || !title) return;
+      const created = await api.createSubtask(taskIdForUpdate, { title });
+      if (!created) {
+        showSnackbar("failedToSaveSubtask", true);
+        return;
+      }
+      newSubtaskInput.value = "";
+      await afterSubtaskChange(taskIdForUpdate);
+      adjustTextareaHeight();
+    });
+  }
+
   // Description saving on blur
   if (taskDescriptionTextarea) {
     taskDescriptionTextarea.addEventListener("blur", async (event) => {
@@ -942,9 +1125,11 @@ function setupActionListeners() {
           newDescription !== oldDescription &&
           currentTaskBeingViewed === taskIdForUpdate // Double check context
         ) {
-          await api.updateTask(taskIdForUpdate, {
-            description: newDescription,
-          });
+          await api.updateTask(
+            taskIdForUpdate,
+            { description: newDescription },
+            seriesScope(),
+          );
           tasks.reRenderTaskElement(taskIdForUpdate); // Update list view (e.g., progress)
           // If rendered mode is active, update the rendered view too
           if (isDescriptionRenderedMode && taskDescriptionRendered) {
@@ -980,6 +1165,8 @@ function setupActionListeners() {
         // Store original task data *needed for re-rendering*
         lastDeletedTaskData = await api.fetchTaskDetails(taskIdToDelete);
         if (!lastDeletedTaskData) throw new Error("Failed fetch for undo.");
+        // Remember which occurrences of a series go with it
+        lastDeletedTaskData.scope = seriesScope();
 
         // Optimistic UI: Hide immediately *if the element exists in the current view*
         if (taskElement) {
@@ -1041,12 +1228,18 @@ function setupActionListeners() {
           // --- Timeout Action (Permanent Delete API Call) ---
           async () => {
             const idToDelete = lastDeletedTaskData?.id; // Capture ID before clearing state
+            const scopeToDelete = lastDeletedTaskData?.scope;
+            const wasOccurrence = !!lastDeletedTaskData?.series_id;
             // Clear undo state *first*
             lastDeletedTaskData = null;
             deleteTimeoutId = null; // Timeout already fired, but clear for safety
 
             if (idToDelete) {
-              const deleted = await api.deleteTask(idToDelete);
+              const deleted = await api.deleteTask(idToDelete, scopeToDelete);
+              if (deleted && wasOccurrence) {
+                // Deleting an occurrence may remove others or schedule the next one
+                await calendar.renderWeekCalendar(getDisplayedWeekStartDate());
+              }
               if (!deleted) {
                 // If API delete fails *after timeout*, show error and potentially refresh UI
                 showSnackbar("failedToDeleteTask", true);
@@ -1192,6 +1385,27 @@ function setupActionListeners() {
       await updateTaskDueDate(null); // API call to remove date & clear recurrence
     });
 
+  // --- Time and Reminder Listeners ---
+  if (reminderTaskDetailsBtn && reminderSettingsContainer) {
+    reminderTaskDetailsBtn.addEventListener("click", () => {
+      const isVisible = reminderSettingsContainer.style.display !== "none";
+      reminderSettingsContainer.style.display = isVisible ? "none" : "block";
+      adjustTextareaHeight();
+    });
+  }
+  taskTimeInput?.addEventListener("change", () =>
+    saveReminderSettings({ due_time: taskTimeInput.value }),
+  );
+  taskDurationInput?.addEventListener("change", () => {
+    const minutes = Math.max(0, parseInt(taskDurationInput.value, 10) || 0);
+    saveReminderSettings({ duration_minutes: minutes });
+  });
+  taskRemindSelect?.addEventListener("change", () => {
+    // Setting a reminder is the user action browsers want before asking
+    if (taskRemindSelect.value) requestNotificationPermission();
+    saveReminderSettings({ remind_at: taskRemindSelect.value });
+  });
+
   // --- Recurrence Listeners ---
   if (
     recurringTaskDetailsBtn &&
@@ -1297,8 +1511,7 @@ function setupActionListeners() {
           const task = await api.fetchTaskDetails(currentTaskBeingViewed);
           if (!task?.recurrence_rule) {
             recurrenceOpenedForNonRecurring = true; // Set flag for potential save on close
-            if (recurrencePeriodSelect) recurrencePeriodSelect.value = "daily";
-            if (recurrenceIntervalInput) recurrenceIntervalInput.value = 1;
+            setRecurrenceControls("daily", 1);
           } else {
             recurrenceOpenedForNonRecurring = false; // Already recurring
           }
@@ -1315,7 +1528,19 @@ function setupActionListeners() {
   }
   // Save recurrence immediately on changing period/interval
   if (recurrencePeriodSelect)
-    recurrencePeriodSelect.addEventListener("change", saveRecurrenceSettings);
+    recurrencePeriodSelect.addEventListener("change", () => {
+      updateCustomRecurrenceControls();
+      saveRecurrenceSettings();
+    });
+  if (recurrenceRRuleInput) {
+    recurrenceRRuleInput.addEventListener("change", () => {
+      recurrenceOpenedForNonRecurring = false; // Explicit change means user intends to save
+      saveRecurrenceSettings();
+    });
+    recurrenceRRuleInput.addEventListener("keydown", (e) => {
+      if (e.key === "Enter") recurrenceRRuleInput.blur();
+    });
+  }
   if (recurrenceIntervalInput) {
     recurrenceIntervalInput.addEventListener("input", updateRecurrencePreview); // Preview on input
     recurrenceIntervalInput.addEventListener("change", () => {
@@ -1903,10 +2128,10 @@ function restoreRecurrenceUI() {
     lastClearedRecurrence?.rule &&
     currentTaskBeingViewed === lastClearedRecurrence.taskId
   ) {
-    if (recurrencePeriodSelect)
-      recurrencePeriodSelect.value = lastClearedRecurrence.rule;
-    if (recurrenceIntervalInput)
-      recurrenceIntervalInput.value = lastClearedRecurrence.interval;
+    setRecurrenceControls(
+      lastClearedRecurrence.rule,
+      lastClearedRecurrence.interval,
+    );
     // Show container only if a date is still selected
     if (
       recurrenceSettingsContainer &&
@@ -1921,6 +2146,39 @@ function restoreRecurrenceUI() {
   lastClearedRecurrence = null; // Clear state after attempting restore
 }
 
+// Shows a rule in the recurrence controls: simple rules in the period select
+// and interval input, anything else as a custom RRULE.
+function setRecurrenceControls(rule, interval) {
+  const isSimple = SIMPLE_RECURRENCE_RULES.includes(rule);
+  if (recurrencePeriodSelect)
+    recurrencePeriodSelect.value = isSimple ? rule : "custom";
+  if (recurrenceIntervalInput)
+    recurrenceIntervalInput.value = isSimple && interval > 0 ? interval : 1;
+  if (recurrenceRRuleInput) recurrenceRRuleInput.value = isSimple ? "" : rule;
+  updateCustomRecurrenceControls();
+}
+
+// Shows the RRULE input instead of the interval for custom rules.
+function updateCustomRecurrenceControls() {
+  const isCustom = recurrencePeriodSelect?.value === "custom";
+  if (recurrenceRRuleInput)
+    recurrenceRRuleInput.style.display = isCustom ? "" : "none";
+  if (recurrenceIntervalInput)
+    recurrenceIntervalInput.style.display = isCustom ? "none" : "";
+  const everyLabel = recurrenceControls?.querySelector("label");
+  if (everyLabel) everyLabel.style.display = isCustom ? "none" : "";
+}
+
+// Returns the rule and interval selected in the recurrence controls.
+function selectedRecurrence() {
+  const period = recurrencePeriodSelect?.value || "";
+  if (period === "custom") {
+    return { rule: recurrenceRRuleInput?.value.trim() || "", interval: 1 };
+  }
+  const interval = parseInt(recurrenceIntervalInput?.value, 10);
+  return { rule: period, interval };
+}
+
 // Updates the visibility and state of recurrence controls based on current rule/date.
 function updateRecurrenceUI(currentRule) {
   const hasRule = !!currentRule;
@@ -1945,8 +2203,9 @@ function updateRecurrenceUI(currentRule) {
   requestAnimationFrame(adjustTextareaHeight); // Adjust layout
 }
 
-// Updates the text preview showing the next calculated recurrence date.
-function updateRecurrencePreview() {
+// Updates the text preview showing the next recurrence date, as calculated
+// by the server for the selected rule.
+async function updateRecurrencePreview() {
   if (
     !currentTaskBeingViewed ||
     !recurrencePreview ||
@@ -1957,14 +2216,13 @@ function updateRecurrencePreview() {
     return;
   }
   const lang = localStorage.getItem("language") || "ru";
-  const period = recurrencePeriodSelect.value;
-  const interval = parseInt(recurrenceIntervalInput.value, 10);
+  const { rule, interval } = selectedRecurrence();
   const currentDueDateStr = taskDetailsDateInput?.dataset.selectedDate;
 
   // Hide preview if recurrence section is hidden, or inputs are invalid/missing
   if (
     recurrenceSettingsContainer?.style.display === "none" ||
-    !period ||
+    !rule ||
     isNaN(interval) ||
     interval < 1 ||
     !currentDueDateStr
@@ -1973,27 +2231,23 @@ function updateRecurrencePreview() {
     return;
   }
 
-  try {
-    const currentDueDateUTC = utils.parseDateUTC(currentDueDateStr);
-    if (isNaN(currentDueDateUTC.getTime())) {
-      recurrencePreview.textContent = ""; // Invalid date
-      return;
-    }
-
-    let nextDate = utils.calculateNextRecurrence(
-      currentDueDateUTC,
-      period,
-      interval,
-    );
-    if (!nextDate) {
-      recurrencePreview.textContent = ""; // Invalid period/calculation
-      return;
-    }
-
+  const preview = await api.fetchRecurrencePreview(
+    rule,
+    interval,
+    currentDueDateStr,
+  );
+  // Ignore stale answers if the selection changed meanwhile.
+  const current = selectedRecurrence();
+  if (current.rule !== rule || current.interval !== interval) return;
+
+  if (preview.error) {
+    recurrencePreview.textContent = `${translations[lang]?.recurrenceInvalid || "Invalid rule:"} ${preview.error}`;
+  } else if (preview.dates.length === 0) {
+    recurrencePreview.textContent =
+      translations[lang]?.recurrenceEnded || "No further occurrences";
+  } else {
+    const nextDate = utils.parseDateUTC(preview.dates[0]);
     recurrencePreview.textContent = `${translations[lang]?.recurrenceNext || "Next:"} ${nextDate.toLocaleDateString(lang, utils.datePickerFormatOptions)}`;
-  } catch (e) {
-    console.error("Error calculating next recurrence date:", e);
-    recurrencePreview.textContent = ""; // Clear on error
   }
 }
 
@@ -2006,22 +2260,40 @@ async function saveRecurrenceSettings() {
   )
     return;
 
-  const selectedRule = recurrencePeriodSelect.value;
-  let selectedInterval = parseInt(recurrenceIntervalInput.value, 10);
+  let { rule: selectedRule, interval: selectedInterval } =
+    selectedRecurrence();
   // Validate interval, default to 1 if invalid
   if (isNaN(selectedInterval) || selectedInterval < 1) {
     selectedInterval = 1;
     recurrenceIntervalInput.value = 1;
   }
+  // A custom rule is saved once it has been typed in.
+  if (recurrencePeriodSelect.value === "custom" && !selectedRule) {
+    updateRecurrencePreview();
+    return;
+  }
 
   const ruleToSend = selectedRule || ""; // Send empty string if no rule selected
   const intervalToSend = ruleToSend ? selectedInterval : 1; // Send 1 if rule is cleared
 
+  // The recurrence changes from this occurrence on unless "all" is picked
+  const scope = seriesScope() === "all" ? "all" : "";
+
   try {
-    await api.updateTask(currentTaskBeingViewed, {
-      recurrence_rule: ruleToSend,
-      recurrence_interval: intervalToSend,
-    });
+    const saved = await api.updateTask(
+      currentTaskBeingViewed,
+      {
+        recurrence_rule: ruleToSend,
+        recurrence_interval: intervalToSend,
+      },
+      scope,
+    );
+    if (!saved) {
+      // The server rejects invalid RRULEs; the preview shows why.
+      showSnackbar("failedToSaveRecurrence", true);
+      updateRecurrencePreview();
+      return;
+    }
     // Ensure recurrence button is visible if a date exists
     if (recurringTaskDetailsBtn && taskDetailsDateInput?.dataset.selectedDate)
       recurringTaskDetailsBtn.style.display = "inline-block";
@@ -2033,10 +2305,28 @@ async function saveRecurrenceSettings() {
   }
 }
 
+// Returns the scope picked for the occurrence shown in the details popup, or
+// "" when the task is not part of a series.
+function seriesScope() {
+  if (!seriesScopeContainer || seriesScopeContainer.style.display === "none")
+    return "";
+  return seriesScopeSelect?.value || "";
+}
+
+// Re-renders the displayed week after an edit that touched other occurrences
+// of a series as well.
+async function refreshSeriesOccurrences(scope) {
+  if (scope === "following" || scope === "all") {
+    await calendar.renderWeekCalendar(getDisplayedWeekStartDate());
+  }
+}
+
 // Clears the recurrence UI elements in the popup.
 export function clearRecurrenceInPopup(shouldAdjustHeight = true) {
   if (recurrencePeriodSelect) recurrencePeriodSelect.value = "";
   if (recurrenceIntervalInput) recurrenceIntervalInput.value = 1;
+  if (recurrenceRRuleInput) recurrenceRRuleInput.value = "";
+  updateCustomRecurrenceControls();
   if (recurrenceControls) recurrenceControls.style.display = "none";
   if (recurringTaskDetailsBtn)
     recurringTaskDetailsBtn.classList.remove("active");
@@ -101,79 +101,3 @@ export const datePickerFormatOptions = {
   timeZone: "UTC", // Crucial for consistency
 };
 
-/**
- * Calculates the next occurrence date based on a start date, period, and interval.
- * All calculations are done in UTC.
- * @param {Date} startDateUTC - The starting date (must be a valid Date object, preferably UTC midnight).
- * @param {string} period - 'daily', 'weekly', 'monthly', 'yearly'.
- * @param {number} interval - The repetition interval (e.g., every 2 weeks, must be >= 1).
- * @returns {Date|null} - The next occurrence date in UTC, or null if input is invalid.
- */
-export function calculateNextRecurrence(startDateUTC, period, interval) {
-  if (
-    !startDateUTC ||
-    isNaN(startDateUTC.getTime()) ||
-    !interval ||
-    interval < 1
-  ) {
-    console.warn("Invalid input to calculateNextRecurrence:", {
-      startDateUTC,
-      period,
-      interval,
-    });
-    return null; // Invalid start date or interval
-  }
-
-  let nextDate = new Date(startDateUTC); // Clone to avoid modifying original
-
-  switch (period) {
-    case "": // Handle empty string as no recurrence
-      console.warn(
-        "Empty period passed to calculateNextRecurrence, returning null.",
-      );
-      return null;
-    case null:
-    case undefined:
-      return null;
-    case "daily":
-      nextDate.setUTCDate(nextDate.getUTCDate() + interval);
-      break;
-    case "weekly":
-      nextDate.setUTCDate(nextDate.getUTCDate() + 7 * interval);
-      break;
-    case "monthly":
-      const originalDay = nextDate.getUTCDate();
-      // Set month first
-      nextDate.setUTCMonth(nextDate.getUTCMonth() + interval);
-      // Check if the day rolled over (e.g., Jan 31 + 1 month -> March 3 instead of Feb 28/29)
-      // If the day is now less than the original day, it means we went past the end of the target month.
-      // Set the date to 0 to get the last day of the *previous* month (which is our target month).
-      if (nextDate.getUTCDate() < originalDay) {
-        nextDate.setUTCDate(0);
-      }
-      break;
-    case "yearly":
-      const originalMonth = nextDate.getUTCMonth();
-      const originalYearDay = nextDate.getUTCDate();
-      nextDate.setUTCFullYear(nextDate.getUTCFullYear() + interval);
-      // Handle leap year case for Feb 29 explicitly
-      // If the original was Feb 29, but the new date's month isn't February, set it back to Feb 28.
-      if (
-        originalMonth === 1 &&
-        originalYearDay === 29 &&
-        nextDate.getUTCMonth() !== 1
-      ) {
-        // It was Feb 29th, but the resulting year is not a leap year or calculation landed in March
-        nextDate.setUTCMonth(1); // Ensure month is February
-        nextDate.setUTCDate(28); // Set day to 28th
-      }
-      break;
-    default:
-      console.warn("Invalid recurrence period:", period);
-      return null; // Invalid period
-  }
-
-  // Ensure the time part remains at midnight UTC (important due to potential DST shifts if local time were used)
-  nextDate.setUTCHours(0, 0, 0, 0);
-  return nextDate;
-}
@@ -0,0 +1,319 @@
+// Package webhook sends the task lifecycle events of the planner to the
+// webhooks registered in the store. Events are queued in the store as soon
+// as they are published on the event bus, then delivered, and retried with
+// exponential backoff until the receiver accepts them or MaxAttempts is
+// reached.
+//
+// Each delivery is a POST of a JSON payload:
+//
+//	{"event": "task.completed", "time": "...", "task": {...}, "previous": {...}}
+//
+// with the headers X-Planner-Event, X-Planner-Delivery (the delivery ID,
+// the same on every attempt) and X-Planner-Signature, "sha256=" and the hex
+// HMAC-SHA256 of the body keyed with the webhook's secret.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"week-planner/internal/db"
+	"week-planner/internal/events"
+)
+
+const (
+	// MaxAttempts is how often a delivery is tried before it fails.
+	MaxAttempts = 10
+	// FirstRetry is the delay before the first retry; each following one
+	// doubles it, up to MaxRetry.
+	FirstRetry = 30 * time.Second
+	MaxRetry   = 6 * time.Hour
+	// PollInterval is how often retries due are looked for.
+	PollInterval = 10 * time.Second
+	// Timeout bounds each attempt.
+	Timeout = 10 * time.Second
+	// batchSize is how many deliveries are sent per round.
+	batchSize = 50
+)
+
+// Payload is the body of a delivery.
+type Payload struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Task  any       `json:"task"`
+	// Previous is the task before it was completed or moved.
+	Previous any `json:"previous,omitempty"`
+	// Generated is set on tasks created by recurrence rather than a user.
+	Generated bool `json:"generated,omitempty"`
+}
+
+// Payloads returns the webhook payloads of a bus event: tasks created,
+// completed, moved between days and deleted. Subtasks have no webhook
+// events of their own; completing the last one completes the parent, which
+// does.
+func Payloads(e events.Event) []Payload {
+	data, ok := e.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	task, ok := data["task"].(map[string]interface{})
+	if !ok || !topLevel(task) {
+		return nil
+	}
+	generated, _ := data["generated"].(bool)
+	switch e.Type {
+	case events.TaskCreated:
+		return []Payload{{Event: db.WebhookTaskCreated, Time: e.Time, Task: task, Generated: generated}}
+	case events.TaskDeleted:
+		return []Payload{{Event: db.WebhookTaskDeleted, Time: e.Time, Task: task}}
+	case events.TaskUpdated:
+		previous, ok := data["previous"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		var payloads []Payload
+		if previous["completed"] != task["completed"] && task["completed"] == 1 {
+			payloads = append(payloads, Payload{Event: db.WebhookTaskCompleted, Time: e.Time, Task: task, Previous: previous})
+		}
+		if previous["due_date"] != task["due_date"] {
+			payloads = append(payloads, Payload{Event: db.WebhookTaskMoved, Time: e.Time, Task: task, Previous: previous})
+		}
+		return payloads
+	}
+	return nil
+}
+
+// topLevel reports whether the task JSON of an event is not a subtask.
+func topLevel(task map[string]interface{}) bool {
+	parentID, _ := task["parent_id"].(*int)
+	return parentID == nil
+}
+
+// Sign returns the X-Planner-Signature header of body for secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before retrying a delivery attempted attempts
+// times.
+func Backoff(attempts int) time.Duration {
+	delay := FirstRetry
+	for i := 1; i < attempts && delay < MaxRetry; i++ {
+		delay *= 2
+	}
+	return min(delay, MaxRetry)
+}
+
+// Sender queues and delivers the webhook events of a store.
+type Sender struct {
+	store   db.WebhookStore
+	acquire func() (release func())
+	client  *http.Client
+	clock   db.Clock
+	wake    chan struct{}
+}
+
+// New returns a sender for the webhooks of store. acquire, if not nil, is
+// held while the store is used, e.g. (*db.Store).Acquire.
+func New(store db.WebhookStore, acquire func() (release func())) *Sender {
+	if acquire == nil {
+		acquire = func() func() { return func() {} }
+	}
+	return &Sender{
+		store:   store,
+		acquire: acquire,
+		client:  &http.Client{Timeout: Timeout},
+		clock:   db.SystemClock,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// SetClock makes the sender read the time from clock.
+func (s *Sender) SetClock(clock db.Clock) {
+	s.clock = clock
+}
+
+// Run queues the events published on bus and delivers the queue until ctx
+// is done. Deliveries left pending by a previous run are sent first.
+func (s *Sender) Run(ctx context.Context, bus *events.Bus) {
+	sub := bus.Subscribe(256)
+	go func() {
+		defer func() { sub.Close() }()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-sub.C():
+				if ok {
+					s.queue(e)
+					continue
+				}
+				// Queuing is quick, so this should not happen; catch up
+				// with the events published since.
+				var missed []events.Event
+				var complete bool
+				sub, missed, complete = sub.Resume()
+				if complete {
+					slog.Warn("Webhooks fell behind the event bus, catching up", "events", len(missed))
+				} else {
+					slog.Error("Webhooks fell behind the event bus, some events were not delivered", "events", len(missed))
+				}
+				for _, e := range missed {
+					s.queue(e)
+				}
+			}
+		}
+	}()
+
+	poll := time.NewTicker(PollInterval)
+	defer poll.Stop()
+	for {
+		s.Deliver(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// queue queues the payloads of an event received from the bus, logging
+// failures.
+func (s *Sender) queue(e events.Event) {
+	if err := s.Enqueue(e); err != nil {
+		slog.Error("Failed to queue webhook deliveries", "event", e.Type, "error", err)
+	}
+}
+
+// Enqueue queues the payloads of e for the webhooks subscribed to them.
+func (s *Sender) Enqueue(e events.Event) error {
+	payloads := Payloads(e)
+	if len(payloads) == 0 {
+		return nil
+	}
+	release := s.acquire()
+	defer release()
+	queued := 0
+	for _, p := range payloads {
+		body, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		n, err := s.store.EnqueueWebhookDeliveries(p.Event, body, s.clock.Now())
+		if err != nil {
+			return err
+		}
+		queued += n
+	}
+	if queued > 0 {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Deliver sends the deliveries that are due, and returns how many were
+// delivered.
+func (s *Sender) Deliver(ctx context.Context) int {
+	delivered := 0
+	for ctx.Err() == nil {
+		release := s.acquire()
+		due, err := s.store.DueWebhookDeliveries(s.clock.Now(), batchSize)
+		release()
+		if err != nil {
+			slog.Error("Failed to read webhook deliveries", "error", err)
+			return delivered
+		}
+		for _, d := range due {
+			if s.attempt(ctx, d) {
+				delivered++
+			}
+		}
+		if len(due) < batchSize {
+			break
+		}
+	}
+	return delivered
+}
+
+// attempt sends delivery d once and records the outcome. It reports whether
+// the delivery succeeded.
+func (s *Sender) attempt(ctx context.Context, d db.WebhookDelivery) bool {
+	release := s.acquire()
+	webhook, err := s.store.GetWebhook(d.WebhookID)
+	release()
+	if err != nil {
+		// Deleted since, along with its deliveries.
+		return false
+	}
+
+	status, err := s.post(ctx, webhook, d)
+	if ctx.Err() != nil {
+		return false // Shutting down: try again on the next run.
+	}
+	now := s.clock.Now().UTC()
+	d.Attempts++
+	d.LastAttemptAt = &now
+	d.ResponseStatus = status
+	d.LastError = ""
+	switch {
+	case err == nil:
+		d.Status = db.DeliveryDelivered
+		d.DeliveredAt = &now
+	case d.Attempts >= MaxAttempts:
+		d.Status = db.DeliveryFailed
+		d.LastError = err.Error()
+	default:
+		d.NextAttemptAt = now.Add(Backoff(d.Attempts))
+		d.LastError = err.Error()
+	}
+	if d.Status != db.DeliveryDelivered {
+		slog.Warn("Webhook delivery failed", "webhook_id", webhook.ID, "delivery_id", d.ID, "event", d.Event, "attempts", d.Attempts, "status", d.Status, "error", err)
+	}
+
+	release = s.acquire()
+	defer release()
+	if err := s.store.SaveWebhookDelivery(d); err != nil {
+		slog.Error("Failed to record webhook delivery", "delivery_id", d.ID, "error", err)
+	}
+	return d.Status == db.DeliveryDelivered
+}
+
+// post sends delivery d to webhook and returns the response status. Any
+// status but 2xx is an error.
+func (s *Sender) post(ctx context.Context, webhook db.Webhook, d db.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "week-planner-webhook")
+	req.Header.Set("X-Planner-Event", d.Event)
+	req.Header.Set("X-Planner-Delivery", strconv.Itoa(d.ID))
+	req.Header.Set("X-Planner-Signature", Sign(webhook.Secret, d.Payload))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Lets the connection be reused.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%s answered %s", webhook.URL, resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"week-planner/internal/db"
+	"week-planner/internal/events"
+)
+
+func taskJSON(id int, due string, completed int, parentID *int) map[string]interface{} {
+	return map[string]interface{}{"id": id, "due_date": due, "completed": completed, "parent_id": parentID}
+}
+
+func TestPayloads(t *testing.T) {
+	parent := 1
+	tests := []struct {
+		name  string
+		event events.Event
+		want  []string
+	}{
+		{"created", events.Event{Type: events.TaskCreated, Data: map[string]interface{}{"task": taskJSON(1, "", 0, nil)}}, []string{db.WebhookTaskCreated}},
+		{"subtask created", events.Event{Type: events.TaskCreated, Data: map[string]interface{}{"task": taskJSON(2, "", 0, &parent)}}, nil},
+		{"completed and moved", events.Event{Type: events.TaskUpdated, Data: map[string]interface{}{
+			"task":     taskJSON(1, "2026-10-19", 1, nil),
+			"previous": taskJSON(1, "2026-10-18", 0, nil),
+		}}, []string{db.WebhookTaskCompleted, db.WebhookTaskMoved}},
+		{"reopened", events.Event{Type: events.TaskUpdated, Data: map[string]interface{}{
+			"task":     taskJSON(1, "2026-10-18", 0, nil),
+			"previous": taskJSON(1, "2026-10-18", 1, nil),
+		}}, nil},
+		{"deleted", events.Event{Type: events.TaskDeleted, Data: map[string]interface{}{"task": taskJSON(1, "", 0, nil)}}, []string{db.WebhookTaskDeleted}},
+		{"reordered", events.Event{Type: events.TasksReordered, Data: map[string]interface{}{"tasks": []map[string]int{}}}, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range Payloads(tt.event) {
+			got = append(got, p.Event)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: payloads %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 20: MaxRetry} {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+// receiver is a local stand-in for a webhook endpoint answering with the
+// statuses in answers, then 200.
+type receiver struct {
+	answers  []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	if len(rc.answers) > 0 {
+		w.WriteHeader(rc.answers[0])
+		rc.answers = rc.answers[1:]
+	}
+}
+
+func TestDeliverSignsAndRetries(t *testing.T) {
+	rc := &receiver{answers: []int{http.StatusInternalServerError}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	store := db.NewMemoryStore()
+	hook, err := store.CreateWebhook(db.Webhook{URL: srv.URL, Events: []string{db.WebhookTaskCompleted}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	sender := New(store, nil)
+	sender.SetClock(db.ClockFunc(func() time.Time { return now }))
+	ctx := context.Background()
+
+	// The creation is not subscribed to; the completion is.
+	for _, e := range []events.Event{
+		{Type: events.TaskCreated, Data: map[string]interface{}{"task": taskJSON(1, "2026-10-18", 0, nil)}},
+		{Type: events.TaskUpdated, Time: now, Data: map[string]interface{}{
+			"task":     taskJSON(1, "2026-10-18", 1, nil),
+			"previous": taskJSON(1, "2026-10-18", 0, nil),
+		}},
+	} {
+		if err := sender.Enqueue(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := sender.Deliver(ctx); n != 0 || len(rc.requests) != 1 {
+		t.Fatalf("first round delivered %d in %d requests, want a failed attempt", n, len(rc.requests))
+	}
+	log, _ := store.GetWebhookDeliveries(hook.ID, 10)
+	if len(log) != 1 || log[0].Status != db.DeliveryPending || log[0].Attempts != 1 || log[0].ResponseStatus != 500 || !log[0].NextAttemptAt.Equal(now.Add(FirstRetry)) {
+		t.Fatalf("after a failed attempt the log is %+v, want a retry in %s", log, FirstRetry)
+	}
+
+	// Nothing is due until the backoff has passed.
+	if sender.Deliver(ctx); len(rc.requests) != 1 {
+		t.Fatal("retried before the backoff passed")
+	}
+	now = now.Add(FirstRetry)
+	if n := sender.Deliver(ctx); n != 1 {
+		t.Fatalf("retry delivered %d, want 1", n)
+	}
+
+	r, body := rc.requests[1], rc.bodies[1]
+	if r.Header.Get("X-Planner-Event") != db.WebhookTaskCompleted || r.Header.Get("X-Planner-Delivery") != rc.requests[0].Header.Get("X-Planner-Delivery") {
+		t.Errorf("headers %v, want the same delivery of task.completed", r.Header)
+	}
+	if got, want := r.Header.Get("X-Planner-Signature"), Sign(hook.Secret, body); got != want {
+		t.Errorf("signature %s, want %s", got, want)
+	}
+	var payload struct {
+		Event string `json:"event"`
+		Task  struct {
+			ID int `json:"id"`
+		} `json:"task"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Event != db.WebhookTaskCompleted || payload.Task.ID != 1 {
+		t.Errorf("payload %s (%v), want task 1 completed", body, err)
+	}
+	log, _ = store.GetWebhookDeliveries(hook.ID, 10)
+	if log[0].Status != db.DeliveryDelivered || log[0].Attempts != 2 || log[0].DeliveredAt == nil {
+		t.Errorf("log %+v, want delivered on the second attempt", log[0])
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	store := db.NewMemoryStore()
+	hook, err := store.CreateWebhook(db.Webhook{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	sender := New(store, nil)
+	sender.SetClock(db.ClockFunc(func() time.Time { return now }))
+	if err := sender.Enqueue(events.Event{Type: events.TaskDeleted, Data: map[string]interface{}{"task": taskJSON(1, "", 0, nil)}}); err != nil {
+		t.Fatal(err)
+	}
+	for range MaxAttempts + 2 {
+		sender.Deliver(context.Background())
+		now = now.Add(MaxRetry)
+	}
+	log, _ := store.GetWebhookDeliveries(hook.ID, 10)
+	if len(log) != 1 || log[0].Status != db.DeliveryFailed || log[0].Attempts != MaxAttempts || log[0].LastError == "" {
+		t.Errorf("log %+v, want failed after %d attempts", log, MaxAttempts)
+	}
+}
+
+func TestRunCatchesUpAfterFallingBehind(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	store := db.NewMemoryStore()
+	hook, err := store.CreateWebhook(db.Webhook{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var busy sync.RWMutex // Held by the test like a Swap holds the store.
+	sender := New(store, func() func() {
+		busy.RLock()
+		return busy.RUnlock
+	})
+	bus := events.NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sender.Run(ctx, bus)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	publish := func(id int) {
+		bus.Publish(events.TaskCreated, "", map[string]interface{}{"task": taskJSON(id, "", 0, nil)})
+	}
+	// queued returns the IDs of the tasks queued for, sorted.
+	queued := func() []int {
+		log, _ := store.GetWebhookDeliveries(hook.ID, 1000)
+		var ids []int
+		for _, d := range log {
+			var payload struct {
+				Task struct {
+					ID int `json:"id"`
+				} `json:"task"`
+			}
+			if err := json.Unmarshal(d.Payload, &payload); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, payload.Task.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	// Once the sender is subscribed, the store is busy while more events
+	// are published than its subscription buffers, so that the bus drops it.
+	const published = 300
+	deadline := time.Now().Add(5 * time.Second)
+	id := 0
+	for len(queued()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the sender to subscribe")
+		}
+		id++
+		publish(id)
+		time.Sleep(time.Millisecond)
+	}
+	// Events published before the sender subscribed are not queued.
+	busy.Lock()
+	first := queued()[0]
+	for id < published {
+		id++
+		publish(id)
+	}
+	busy.Unlock()
+
+	var want []int
+	for id := first; id <= published; id++ {
+		want = append(want, id)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		got := queued()
+		if len(got) >= len(want) {
+			if !slices.Equal(got, want) {
+				t.Errorf("queued deliveries for tasks %v, want one for each of %d to %d", got, first, published)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queued %d deliveries, want %d", len(got), len(want))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}